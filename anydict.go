@@ -0,0 +1,51 @@
+package colsketch
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// AnyDict is a dictionary of values of any one type, with the type erased,
+// e.g. for holding the dictionaries of the columns of a table schema, of
+// different types, in a single collection. See Dict.AsAny.
+type AnyDict interface {
+	// Encode returns the code of value, or an error if value isn't of the
+	// dictionary's value type or the dictionary isn't valid.
+	Encode(value any) (Code, error)
+
+	// Len returns the number of exact codes of the dictionary.
+	Len() int
+
+	// Mode returns the mode of the dictionary.
+	Mode() Mode
+}
+
+// Mode returns the mode the dictionary was built with.
+func (d *Dict[T]) Mode() Mode {
+	return d.mode
+}
+
+// AsAny returns the dictionary as an AnyDict, whose Encode takes values of
+// any type and returns an error for those that aren't of type `T`, which it
+// tells apart by a type assertion: values of other types with `T` as their
+// underlying type are rejected too. The AnyDict shares the dictionary, and
+// sees any changes made to it.
+func (d *Dict[T]) AsAny() AnyDict {
+	return anyDict[T]{d}
+}
+
+// anyDict is the AnyDict of a Dict.
+type anyDict[T cmp.Ordered] struct {
+	d *Dict[T]
+}
+
+func (a anyDict[T]) Encode(value any) (Code, error) {
+	v, ok := value.(T)
+	if !ok {
+		return 0, fmt.Errorf("colsketch: cannot encode %T value with a Dict[%T]", value, *new(T))
+	}
+	return a.d.EncodeChecked(v)
+}
+
+func (a anyDict[T]) Len() int   { return a.d.NumExactCodes() }
+func (a anyDict[T]) Mode() Mode { return a.d.mode }
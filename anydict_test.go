@@ -0,0 +1,48 @@
+package colsketch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDictAsAny(t *testing.T) {
+	ints := NewDict(Byte, []int64{10, 20, 30})
+	strs := NewDict(Word, []string{"a", "b"})
+
+	// The dictionaries of a table's columns, of different types.
+	schema := map[string]AnyDict{"id": ints.AsAny(), "name": strs.AsAny()}
+	for _, tc := range []struct {
+		column string
+		value  any
+		want   Code
+	}{
+		{"id", int64(20), 4},
+		{"id", int64(25), 5},
+		{"name", "a", 2},
+		{"name", "c", 5},
+	} {
+		if got, err := schema[tc.column].Encode(tc.value); err != nil || got != tc.want {
+			t.Errorf("%s: Encode(%v) = %d, %v, want %d, nil", tc.column, tc.value, got, err, tc.want)
+		}
+	}
+
+	if got := schema["id"]; got.Len() != 3 || got.Mode() != Byte {
+		t.Errorf("AsAny() Len() = %d, Mode() = %s, want 3, %s", got.Len(), got.Mode(), Byte)
+	}
+	if got := schema["name"]; got.Len() != 2 || got.Mode() != Word {
+		t.Errorf("AsAny() Len() = %d, Mode() = %s, want 2, %s", got.Len(), got.Mode(), Word)
+	}
+
+	// Values of other types, including of types with the same underlying
+	// type, are rejected.
+	for _, v := range []any{20, "20", int32(20), time.Duration(20), nil} {
+		if _, err := schema["id"].Encode(v); err == nil {
+			t.Errorf("Encode(%T) with a Dict[int64] succeeded", v)
+		}
+	}
+
+	invalid := NewDict(Mode(7), []int64{1})
+	if _, err := invalid.AsAny().Encode(int64(1)); err != ErrInvalidDict {
+		t.Errorf("Encode() with an invalid dictionary = %v, want %v", err, ErrInvalidDict)
+	}
+}
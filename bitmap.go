@@ -0,0 +1,111 @@
+package colsketch
+
+// ScanBitmap evaluates a predicate like Scan, but returns its candidates as
+// a dense bitmap with one bit per row, set iff Scan would visit the row:
+// bit i%64 of word i/64 stands for row i. The bitmap is stored in dst,
+// which is zeroed first, if it has the capacity for the sketch's
+// (Len()+63)/64 words, and in a newly allocated slice otherwise. Codes are
+// compared a word of them at a time and blocks their headers rule out are
+// skipped.
+func (s *Sketch[T]) ScanBitmap(p Predicate[T], dst []uint64) []uint64 {
+	dst = s.resizeBitmap(dst[:0])
+	s.scanBitmap(p.candidates(s.dict), dst, false)
+	return dst
+}
+
+// ScanBitmapOr is like ScanBitmap, but adds the candidates of the predicate
+// to those already in dst, e.g. to evaluate a disjunction of predicates
+// without intermediate bitmaps. If dst is shorter than the sketch's
+// bitmap, it is extended with zeros first.
+func (s *Sketch[T]) ScanBitmapOr(p Predicate[T], dst []uint64) []uint64 {
+	dst = s.resizeBitmap(dst)
+	s.scanBitmap(p.candidates(s.dict), dst, false)
+	return dst
+}
+
+// ScanBitmapAnd is like ScanBitmap, but keeps only the candidates already
+// in dst that are also candidates of the predicate, e.g. to evaluate a
+// conjunction of predicates without intermediate bitmaps. If dst is
+// shorter than the sketch's bitmap, it is extended with zeros first.
+func (s *Sketch[T]) ScanBitmapAnd(p Predicate[T], dst []uint64) []uint64 {
+	dst = s.resizeBitmap(dst)
+	s.scanBitmap(p.candidates(s.dict), dst, true)
+	return dst
+}
+
+// ScanBitmapDefinite is like ScanBitmap, but also returns a bitmap of the
+// candidates that certainly match, stored in definite like the candidates
+// are in dst: those whose code is exact, such as the rows equal to the
+// operand of Eq, and those whose code only stands for values the predicate
+// holds for, such as the codes strictly between the operands of Between.
+// Only the rows of candidates &^ definite need to be checked against the
+// values they stand for.
+func (s *Sketch[T]) ScanBitmapDefinite(p Predicate[T], dst, definite []uint64) ([]uint64, []uint64) {
+	dst = s.ScanBitmap(p, dst)
+	definite = s.resizeBitmap(definite[:0])
+	s.scanBitmap(p.definite(s.dict), definite, false)
+	return dst, definite
+}
+
+// resizeBitmap returns dst resliced or reallocated to the length of the
+// sketch's bitmap, keeping its contents up to that length and zeroing the
+// rest.
+func (s *Sketch[T]) resizeBitmap(dst []uint64) []uint64 {
+	words := (s.store.len() + 63) / 64
+	if words > cap(dst) {
+		grown := make([]uint64, words)
+		copy(grown, dst)
+		return grown
+	}
+
+	n := len(dst)
+	if n > words {
+		n = words
+	}
+	dst = dst[:words]
+	for i := n; i < words; i++ {
+		dst[i] = 0
+	}
+	return dst
+}
+
+// scanBitmap merges the live rows whose codes pass a filter into a bitmap of
+// the sketch's length: by intersection if and is set, and by union
+// otherwise.
+func (s *Sketch[T]) scanBitmap(f codeFilter, dst []uint64, and bool) {
+	n := s.store.len()
+
+	for i := range s.bounds {
+		start := i * s.blockSize
+		end := start + s.blockSize
+		if end > n {
+			end = n
+		}
+
+		// Blocks are whole words of the bitmap.
+		words := dst[start/64 : (end+63)/64]
+		if f.empty() || s.rulesOut(i, f) || s.isDead(i) {
+			if and {
+				for j := range words {
+					words[j] = 0
+				}
+			}
+			continue
+		}
+
+		for j := range words {
+			pos := start + 64*j
+			k := end - pos
+			if k > 64 {
+				k = 64
+			}
+
+			m := f.mask(s.store, pos, k) &^ s.deletedWord(pos/64)
+			if and {
+				words[j] &= m
+			} else {
+				words[j] |= m
+			}
+		}
+	}
+}
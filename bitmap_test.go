@@ -0,0 +1,158 @@
+package colsketch
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSketchScanBitmap(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]SketchOption{
+		nil,
+		{WithBlockSize(64)},
+		{WithBitPacking(), WithBlockSize(128)},
+	} {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+			s := NewSketch(&dict, opts...)
+			s.AppendCodes(dict.EncodeAll(randomInt64s(rng, 10000+rng.Intn(64), 12000), nil))
+
+			// Garbage in dst must not leak into the bitmap.
+			dst := make([]uint64, 0, 1000)
+			for i := 0; i < 100; i++ {
+				p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+				for j := range dst[:cap(dst)] {
+					dst[:cap(dst)][j] = rng.Uint64()
+				}
+
+				got := s.ScanBitmap(p, dst)
+				testBitmap(t, s, p, got)
+				if &got[0] != &dst[:1][0] {
+					t.Fatalf("%+v: ScanBitmap() didn't reuse dst", p)
+				}
+			}
+		}
+	}
+}
+
+// testBitmap checks that a bitmap of candidates agrees with Scan.
+func testBitmap(t *testing.T, s *Sketch[int64], p Predicate[int64], bitmap []uint64) {
+	t.Helper()
+
+	if got, want := len(bitmap), (s.Len()+63)/64; got != want {
+		t.Fatalf("%+v: bitmap of %d words, want %d", p, got, want)
+	}
+
+	want := make([]uint64, len(bitmap))
+	s.Scan(p, func(pos int) bool {
+		want[pos/64] |= 1 << (pos % 64)
+		return true
+	})
+	for i := range want {
+		if bitmap[i] != want[i] {
+			t.Fatalf("%+v: word %d of bitmap = %#x, want %#x", p, i, bitmap[i], want[i])
+		}
+	}
+}
+
+func TestSketchScanBitmapCombine(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	dict := NewDict(Byte, randomInt64s(rng, 5000, 10000))
+	s := NewSketch(&dict, WithBlockSize(64))
+	s.AppendCodes(dict.EncodeAll(randomInt64s(rng, 1000, 12000), nil))
+	words := (s.Len() + 63) / 64
+
+	for i := 0; i < 100; i++ {
+		p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+		q := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+		bp, bq := s.ScanBitmap(p, nil), s.ScanBitmap(q, nil)
+
+		or := s.ScanBitmapOr(q, s.ScanBitmap(p, nil))
+		and := s.ScanBitmapAnd(q, s.ScanBitmap(p, nil))
+		for j := 0; j < words; j++ {
+			if or[j] != bp[j]|bq[j] {
+				t.Fatalf("%+v or %+v: word %d = %#x, want %#x", p, q, j, or[j], bp[j]|bq[j])
+			}
+			if and[j] != bp[j]&bq[j] {
+				t.Fatalf("%+v and %+v: word %d = %#x, want %#x", p, q, j, and[j], bp[j]&bq[j])
+			}
+		}
+
+		// Missing words are zeros.
+		if got := s.ScanBitmapOr(p, nil); len(got) != words {
+			t.Fatalf("ScanBitmapOr() into nil returned %d words, want %d", len(got), words)
+		} else {
+			testBitmap(t, s, p, got)
+		}
+		short := append(make([]uint64, 0, words), bp[:words/2]...)
+		for _, w := range s.ScanBitmapAnd(p, short)[words/2:] {
+			if w != 0 {
+				t.Fatalf("ScanBitmapAnd() set bits past the end of dst")
+			}
+		}
+	}
+}
+
+func TestSketchScanBitmapDefinite(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	dict := NewDict(Word, randomInt64s(rng, 5000, 10000))
+	s := NewSketch(&dict, WithBlockSize(64))
+	s.AppendCodes(dict.EncodeAll(randomInt64s(rng, 1000, 12000), nil))
+
+	var candidates, definite []uint64
+	for i := 0; i < 200; i++ {
+		p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+		candidates, definite = s.ScanBitmapDefinite(p, candidates, definite)
+		testBitmap(t, s, p, candidates)
+
+		want := make([]uint64, len(definite))
+		s.ScanCertain(p, func(pos int, c Certainty) bool {
+			if c == Definite {
+				want[pos/64] |= 1 << (pos % 64)
+			}
+			return true
+		})
+		for j := range want {
+			if definite[j] != want[j] {
+				t.Fatalf("%+v: word %d of definite bitmap = %#x, want %#x", p, j, definite[j], want[j])
+			}
+		}
+	}
+}
+
+func TestSketchScanBitmapZeroAlloc(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Word, randomInt64s(rng, 5000, 10000))
+	s := NewSketch(&dict)
+	s.AppendCodes(dict.EncodeAll(randomInt64s(rng, 100000, 10000), nil))
+
+	p, q := Ge(int64(1000)), Lt(int64(5000))
+	dst := s.ScanBitmap(p, nil)
+	if n := testing.AllocsPerRun(10, func() {
+		dst = s.ScanBitmapAnd(q, s.ScanBitmap(p, dst))
+	}); n != 0 {
+		t.Errorf("ScanBitmap() into a reused bitmap allocates %v times", n)
+	}
+}
+
+func BenchmarkSketchScanBitmap(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 100000, 1<<30))
+		s := NewSketch(&dict)
+		s.AppendCodes(dict.EncodeAll(randomInt64s(rng, 1<<20, 1<<30), nil))
+		p := Between(int64(1<<28), int64(1<<28+1<<24))
+
+		b.Run(mode.String(), func(b *testing.B) {
+			dst := s.ScanBitmap(p, nil)
+			b.SetBytes(int64(s.Len()))
+			for i := 0; i < b.N; i++ {
+				dst = s.ScanBitmap(p, dst)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package colsketch
+
+import "unsafe"
+
+// DefaultBlockSize is the number of codes per block of a Sketch built
+// without WithBlockSize: a 4 KiB page of Byte mode codes.
+const DefaultBlockSize = 4096
+
+// WithBlockSize sets the number of codes per block of a sketch, which must
+// be a positive multiple of 64. Smaller blocks, down to a cache line of 64
+// Byte mode codes, have tighter bounds, and so let scans skip more of a
+// sketch, at the cost of more headers.
+func WithBlockSize(n int) SketchOption {
+	return func(c *sketchConfig) { c.blockSize = n }
+}
+
+// Block is the header of a block of a sketch's codes. Blocks hold a fixed
+// number of consecutive codes, except for the last, which may be partially
+// filled. In Byte and Word mode storage, every block starts on a 64-byte
+// cache line boundary, which vectorized scans may rely on.
+type Block struct {
+	// The rows [Start, End) of the sketch the block holds the codes of.
+	Start, End int
+
+	// The smallest and largest codes in the block, counting those of
+	// deleted rows.
+	MinCode, MaxCode Code
+
+	// The number of rows of the block that were deleted. Scans skip blocks
+	// whose rows all were.
+	Deleted int
+}
+
+// Len returns the number of codes in the block.
+func (b Block) Len() int {
+	return b.End - b.Start
+}
+
+// BlockSize returns the number of codes per block of the sketch.
+func (s *Sketch[T]) BlockSize() int {
+	return s.blockSize
+}
+
+// NumBlocks returns the number of blocks in the sketch.
+func (s *Sketch[T]) NumBlocks() int {
+	return len(s.bounds)
+}
+
+// Block returns the header of the i-th block. It panics if i is out of
+// range.
+func (s *Sketch[T]) Block(i int) Block {
+	b := s.bounds[i]
+	return Block{Start: i * s.blockSize, End: s.blockEnd(i), MinCode: b.min, MaxCode: b.max, Deleted: s.blockDeletions(i)}
+}
+
+// blockEnd returns the end of the rows of the i-th block.
+func (s *Sketch[T]) blockEnd(i int) int {
+	if end := (i + 1) * s.blockSize; end < s.store.len() {
+		return end
+	}
+	return s.store.len()
+}
+
+// blockRows returns the rows of the i-th block within `[from, to)`.
+func (s *Sketch[T]) blockRows(i, from, to int) (start, end int) {
+	start, end = i*s.blockSize, s.blockEnd(i)
+	if start < from {
+		start = from
+	}
+	if end > to {
+		end = to
+	}
+	return start, end
+}
+
+// isDead returns true iff all the rows of the i-th block were deleted.
+func (s *Sketch[T]) isDead(i int) bool {
+	return s.blockDeletions(i) == s.blockEnd(i)-i*s.blockSize
+}
+
+// blockBounds are the smallest and largest codes in a block.
+type blockBounds struct {
+	min, max Code
+}
+
+// add widens the bounds to include a code.
+func (b *blockBounds) add(c Code) {
+	if c < b.min {
+		b.min = c
+	}
+	if c > b.max {
+		b.max = c
+	}
+}
+
+// cacheLineSize is the alignment of the backing arrays of sketches.
+const cacheLineSize = 64
+
+// growAligned returns a copy of s with capacity for at least n more
+// elements, grown as append would grow it, whose first element is cache
+// line aligned. The alignment holds as long as the copy's length only
+// changes by reslicing from its start.
+func growAligned[E uint8 | uint16 | uint64](s []E, n int) []E {
+	c := 2 * cap(s)
+	if cap(s) >= 256 {
+		// Like append, transition from doubling to growing by 1.25x.
+		c = cap(s) + (cap(s)+3*256)/4
+	}
+	if c < len(s)+n {
+		c = len(s) + n
+	}
+	if minCap := cacheLineSize / int(unsafe.Sizeof(E(0))); c < minCap {
+		c = minCap
+	}
+
+	// Over-allocate by a cache line to be able to start at an aligned
+	// element.
+	size := int(unsafe.Sizeof(E(0)))
+	buf := make([]E, c+cacheLineSize/size)
+	off := 0
+	if r := int(uintptr(unsafe.Pointer(unsafe.SliceData(buf))) % cacheLineSize); r != 0 {
+		off = (cacheLineSize - r) / size
+	}
+
+	t := buf[off : off+len(s) : off+c]
+	copy(t, s)
+	return t
+}
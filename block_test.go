@@ -0,0 +1,91 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"unsafe"
+)
+
+func TestSketchBlocks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 100000, 1<<20))
+
+		for _, blockSize := range []int{64, 4096} {
+			for _, packed := range []bool{false, true} {
+				opts := []SketchOption{WithBlockSize(blockSize)}
+				if packed {
+					opts = append(opts, WithBitPacking())
+				}
+
+				for _, n := range []int{0, 1, blockSize - 1, blockSize, blockSize + 1, 3*blockSize + 17} {
+					name := fmt.Sprintf("mode %s, block size %d, packed %v, %d codes", mode, blockSize, packed, n)
+					s := NewSketch(&dict, opts...)
+					codes := dict.EncodeAll(randomInt64s(rng, n, 1<<20), nil)
+					s.AppendCodes(codes)
+					testBlocks(t, name, s, codes)
+				}
+			}
+		}
+	}
+
+	dict := NewDict(Byte, []int{1})
+	if got := NewSketch(&dict).BlockSize(); got != DefaultBlockSize {
+		t.Errorf("BlockSize() = %d, want %d", got, DefaultBlockSize)
+	}
+	for _, n := range []int{0, -64, 100} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewSketch() with block size %d didn't panic", n)
+				}
+			}()
+			NewSketch(&dict, WithBlockSize(n))
+		}()
+	}
+}
+
+func testBlocks[T Integer](t *testing.T, name string, s *Sketch[T], codes []Code) {
+	t.Helper()
+
+	if got, want := s.NumBlocks(), (len(codes)+s.BlockSize()-1)/s.BlockSize(); got != want {
+		t.Fatalf("%s: NumBlocks() = %d, want %d", name, got, want)
+	}
+
+	for i := 0; i < s.NumBlocks(); i++ {
+		b := s.Block(i)
+		start, end := i*s.BlockSize(), (i+1)*s.BlockSize()
+		if end > len(codes) {
+			end = len(codes)
+		}
+		if b.Start != start || b.End != end || b.Len() != end-start {
+			t.Fatalf("%s: block %d holds rows [%d, %d), want [%d, %d)", name, i, b.Start, b.End, start, end)
+		}
+
+		lo, hi := codes[start], codes[start]
+		for _, c := range codes[start:end] {
+			if c < lo {
+				lo = c
+			}
+			if c > hi {
+				hi = c
+			}
+		}
+		if b.MinCode != lo || b.MaxCode != hi {
+			t.Fatalf("%s: block %d has codes in [%d, %d], want [%d, %d]", name, i, b.MinCode, b.MaxCode, lo, hi)
+		}
+
+		var addr uintptr
+		switch store := s.store.(type) {
+		case *codeSlice[uint8]:
+			addr = uintptr(unsafe.Pointer(&(*store)[start]))
+		case *codeSlice[uint16]:
+			addr = uintptr(unsafe.Pointer(&(*store)[start]))
+		}
+		if addr%cacheLineSize != 0 {
+			t.Fatalf("%s: block %d isn't cache line aligned", name, i)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package colsketch
+
+import "bytes"
+
+// BytesDict is a dictionary over byte slices, ordered as by bytes.Compare,
+// for keys kept as []byte, which doesn't satisfy cmp.Ordered and so can't
+// be used with Dict. It assigns the same codes as a Dict over the same
+// values converted to strings.
+type BytesDict struct {
+	mode Mode
+
+	// A sorted slice of copies of the values assigned exact codes.
+	codes [][]byte
+}
+
+// NewBytesDict builds a dictionary with a given Mode over a provided sample,
+// like NewDict. The dictionary holds copies of the values it keeps, so the
+// sample may be modified or reused afterwards.
+func NewBytesDict(mode Mode, sample [][]byte) BytesDict {
+	strs := make([]string, len(sample))
+	for i, v := range sample {
+		strs[i] = string(v)
+	}
+
+	dict := NewDict(mode, strs)
+	d := BytesDict{mode: mode, codes: make([][]byte, len(dict.codes))}
+	for i, v := range dict.codes {
+		d.codes[i] = []byte(v)
+	}
+	return d
+}
+
+// Encode looks up the code for a value.
+func (d *BytesDict) Encode(value []byte) Code {
+	s := d.codes
+	if len(s) == 0 {
+		return 1
+	}
+
+	// Like encodeSorted, search for the last representative less than or
+	// equal to value.
+	base, n := 0, len(s)
+	for n > 1 {
+		half := n >> 1
+		base += half & -int(b2u(bytes.Compare(value, s[base+half]) >= 0))
+		n -= half
+	}
+
+	switch c := bytes.Compare(s[base], value); {
+	case c == 0:
+		return Code(2 * (base + 1))
+	case c < 0:
+		return Code(2*(base+1) + 1)
+	default:
+		return 1
+	}
+}
+
+// Len returns the number of exact codes in the dictionary.
+func (d *BytesDict) Len() int {
+	return len(d.codes)
+}
+
+// Values returns the representatives in order; the i-th one has the exact
+// code CodeAt(i). The returned slices are the dictionary's own and must not be
+// modified.
+func (d *BytesDict) Values() [][]byte {
+	return d.codes
+}
+
+// CodeAt returns the exact code of the i-th element of Values. It panics if
+// i isn't within `[0, Len())`.
+func (d *BytesDict) CodeAt(i int) Code {
+	return codeAt(i, len(d.codes))
+}
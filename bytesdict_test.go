@@ -0,0 +1,54 @@
+package colsketch
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestBytesDict(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		sample := randomURLs(rng, 50000)
+		sampleBytes := make([][]byte, len(sample))
+		for i, v := range sample {
+			sampleBytes[i] = []byte(v)
+		}
+
+		dict := NewDict(mode, sample)
+		bdict := NewBytesDict(mode, sampleBytes)
+		if bdict.Len() != dict.Len() {
+			t.Fatalf("mode %s: Len() = %d, want %d", mode, bdict.Len(), dict.Len())
+		}
+
+		for _, v := range append(randomURLs(rng, 1000), sample[:1000]...) {
+			if got, want := bdict.Encode([]byte(v)), dict.Encode(v); got != want {
+				t.Fatalf("mode %s: Encode(%q) = %d, want %d", mode, v, got, want)
+			}
+		}
+
+		// The dictionary holds copies of the sample.
+		for _, v := range sampleBytes {
+			for i := range v {
+				v[i] = 0
+			}
+		}
+		for i, v := range bdict.Values() {
+			if want := dict.codes[i]; !bytes.Equal(v, []byte(want)) {
+				t.Fatalf("mode %s: Values()[%d] = %q after modifying the sample, want %q", mode, i, v, want)
+			}
+			if got := bdict.CodeAt(i); got != bdict.Encode(v) {
+				t.Fatalf("mode %s: CodeAt(%d) = %d, want %d", mode, i, got, bdict.Encode(v))
+			}
+		}
+	}
+
+	empty := NewBytesDict(Byte, nil)
+	if got := empty.Encode([]byte("a")); got != 3 {
+		t.Errorf("Encode() with an empty sample = %d, want 3", got)
+	}
+	if got := empty.Encode(nil); got != 2 {
+		t.Errorf("Encode(nil) with an empty sample = %d, want 2", got)
+	}
+}
@@ -0,0 +1,130 @@
+package colsketch
+
+import (
+	"cmp"
+	"sort"
+	"unsafe"
+)
+
+// CategoricalDict is a dictionary for categorical columns, such as enums,
+// country codes or UUID prefixes, that are only ever filtered by equality or
+// set membership. Unlike Dict, its codes don't preserve the order of values:
+// the NumExactCodes most frequent values of the sample get exact codes, in
+// descending order of frequency, and all other values are hashed into the
+// inexact codes. Giving exact codes to the most frequent values rather than
+// to evenly spaced ones makes far more of a skewed column exactly coded.
+//
+// Since codes don't preserve order, range predicates (<, <=, >, >=) can't be
+// evaluated on them. OrderPreserving reports false so that scan layers can
+// refuse them.
+type CategoricalDict[T cmp.Ordered] struct {
+	mode Mode
+
+	// The values assigned exact codes: values[i] has code 2(i+1).
+	values []T
+	exact  map[T]Code
+}
+
+// NewCategoricalDict builds a categorical dictionary with a given Mode over
+// a provided sample. Ties in frequency are broken in favour of smaller
+// values, so the result only depends on the sample's contents. NaNs, which
+// aren't equal to themselves, are never assigned exact codes. If the mode
+// isn't one of Byte or Word, the returned dictionary has no exact codes.
+func NewCategoricalDict[T cmp.Ordered](mode Mode, sample []T) CategoricalDict[T] {
+	d := CategoricalDict[T]{mode: mode, exact: map[T]Code{}}
+	if !mode.IsValid() {
+		return d
+	}
+
+	counts := make(map[T]int)
+	for _, v := range sample {
+		if v == v {
+			counts[v]++
+		}
+	}
+
+	clu := make([]Cluster[T], 0, len(counts))
+	for v, n := range counts {
+		clu = append(clu, Cluster[T]{v, n})
+	}
+	sort.Slice(clu, func(i, j int) bool {
+		if clu[i].Count != clu[j].Count {
+			return clu[i].Count > clu[j].Count
+		}
+		return cmp.Less(clu[i].Value, clu[j].Value)
+	})
+
+	if n := mode.NumExactCodes(); len(clu) > n {
+		clu = clu[:n]
+	}
+
+	d.values = make([]T, len(clu))
+	for i, c := range clu {
+		d.values[i] = c.Value
+		d.exact[c.Value] = Code(2 * (i + 1))
+	}
+	return d
+}
+
+// Encode looks up the code for a value: its exact code if it has one, and
+// otherwise one of the inexact codes, chosen by hashing the value with XXH64
+// so that codes are stable across processes.
+func (d *CategoricalDict[T]) Encode(value T) Code {
+	if c, ok := d.exact[value]; ok {
+		return c
+	}
+	if !d.mode.IsValid() {
+		return 1
+	}
+
+	// There's one more inexact code than there are exact codes.
+	n := uint64(d.mode.NumExactCodes()) + 1
+	return Code(2*(hashValue(value)%n) + 1)
+}
+
+// EncodeAll encodes each of the values into dst like Dict.EncodeAll.
+func (d *CategoricalDict[T]) EncodeAll(values []T, dst []Code) []Code {
+	if cap(dst) < len(values) {
+		dst = make([]Code, len(values))
+	}
+	dst = dst[:len(values)]
+
+	for i, v := range values {
+		dst[i] = d.Encode(v)
+	}
+	return dst
+}
+
+// Len returns the number of values assigned exact codes.
+func (d *CategoricalDict[T]) Len() int {
+	return len(d.values)
+}
+
+// Value returns the value an exact code stands for. It returns false for
+// inexact codes, which stand for arbitrary sets of values, and for codes
+// beyond the dictionary's.
+func (d *CategoricalDict[T]) Value(c Code) (T, bool) {
+	var zero T
+	if !c.IsExact() || int(c/2) > len(d.values) {
+		return zero, false
+	}
+	return d.values[c/2-1], true
+}
+
+// OrderPreserving always returns false: a categorical dictionary's codes
+// only support equality predicates.
+func (d *CategoricalDict[T]) OrderPreserving() bool {
+	return false
+}
+
+// hashValue returns the XXH64 hash, with seed 0, of a value's canonical
+// binary encoding, as written by appendValue, except that strings are hashed
+// without their length prefix.
+func hashValue[T cmp.Ordered](v T) uint64 {
+	if s, ok := any(v).(string); ok {
+		return xxhash64(unsafe.Slice(unsafe.StringData(s), len(s)), 0)
+	}
+
+	var buf [8]byte
+	return xxhash64(appendValue(buf[:0], kindOf[T](), canonical(v)), 0)
+}
@@ -0,0 +1,79 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// skewedCategories returns n category names, half of which are drawn from
+// a head of frequent categories that sort next to each other and the rest
+// from a long tail of rare ones, like sequential IDs of which the most
+// recent are the most popular.
+func skewedCategories(rng *rand.Rand, n, head int) []string {
+	values := make([]string, n)
+	for i := range values {
+		id := rng.Intn(1 << 28)
+		if rng.Intn(2) == 0 {
+			id = 1<<28 + rng.Intn(head)
+		}
+		values[i] = fmt.Sprintf("category-%08x", id)
+	}
+	return values
+}
+
+func TestCategoricalDict(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, mode := range []Mode{Byte, Word} {
+		sample := skewedCategories(rng, 10*mode.NumExactCodes(), mode.NumExactCodes())
+		values := skewedCategories(rng, 100000, mode.NumExactCodes())
+
+		cat := NewCategoricalDict(mode, sample)
+		ord := NewDict(mode, sample)
+
+		var catExact, ordExact int
+		for _, v := range values {
+			c := cat.Encode(v)
+			if c == 0 || c > mode.MaxInexactCode() {
+				t.Fatalf("mode %s: Encode(%q) = %d, outside of the code space", mode, v, c)
+			}
+			if c.IsExact() {
+				catExact++
+				if got, ok := cat.Value(c); !ok || got != v {
+					t.Fatalf("mode %s: Value(Encode(%q)) = %q, %v", mode, v, got, ok)
+				}
+			}
+			if ord.Encode(v).IsExact() {
+				ordExact++
+			}
+		}
+
+		t.Logf("mode %s: exact hit rate %.2f categorical, %.2f ordered", mode,
+			float64(catExact)/float64(len(values)), float64(ordExact)/float64(len(values)))
+		if catExact < 2*ordExact {
+			t.Errorf("mode %s: %d exact hits, not much more than the ordered dictionary's %d", mode, catExact, ordExact)
+		}
+	}
+
+	dict := NewCategoricalDict(Byte, []string{"b", "a", "b", "c", "c", "c"})
+	for v, want := range map[string]Code{"c": 2, "b": 4, "a": 6} {
+		if got := dict.Encode(v); got != want {
+			t.Errorf("Encode(%q) = %d, want %d", v, got, want)
+		}
+	}
+	if dict.OrderPreserving() {
+		t.Errorf("OrderPreserving() = true")
+	}
+	if _, ok := dict.Value(8); ok {
+		t.Errorf("Value() of an unassigned code succeeded")
+	}
+
+	// Inexact codes are stable across processes.
+	if got, want := dict.Encode("zebra"), Code(2*(xxhash64([]byte("zebra"), 0)%128)+1); got != want {
+		t.Errorf("Encode(%q) = %d, want %d", "zebra", got, want)
+	}
+	ints := NewCategoricalDict(Word, []int64{1, 2, 3})
+	if a, b := ints.Encode(1<<40), ints.Encode(1<<40); a != b || a.IsExact() {
+		t.Errorf("Encode() of an unknown value = %d, then %d", a, b)
+	}
+}
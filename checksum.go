@@ -0,0 +1,180 @@
+package colsketch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// WithChecksums keeps an XXH64 checksum of the codes and the header of each
+// block, which WriteTo stores along with the codes, so that Verify, ReadFrom
+// and views opened WithLazyVerification catch codes corrupted in memory or
+// on disk, e.g. by a bit flip in a memory-mapped file. Checksums hash codes
+// as 16-bit integers whatever their storage, and so survive Compress.
+//
+// A block's checksum is computed once rows are appended after it, and
+// again whenever Set changes one of its codes; the last block's is only
+// computed by WriteTo. Sketches built without this option keep no
+// checksums, at no cost.
+func WithChecksums() SketchOption {
+	return func(c *sketchConfig) { c.checksums = true }
+}
+
+// ChecksumError is the error of a block whose codes don't match its
+// checksum.
+type ChecksumError struct {
+	Block int // The index of the block.
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("colsketch: checksum mismatch in block %d", e.Block)
+}
+
+// HasChecksums returns true iff the sketch keeps block checksums; see
+// WithChecksums.
+func (s *Sketch[T]) HasChecksums() bool {
+	return s.checksums != nil
+}
+
+// Verify rehashes the codes of every block with a checksum, and returns a
+// *ChecksumError for the first block that doesn't match it, if any. It
+// returns nil for sketches without checksums.
+func (s *Sketch[T]) Verify() error {
+	buf := make([]byte, 2*s.blockSize)
+	for i, sum := range s.checksums {
+		if s.blockChecksum(i, buf) != sum {
+			return &ChecksumError{Block: i}
+		}
+	}
+	return nil
+}
+
+// blockChecksum returns the checksum of the i-th block: the XXH64 hash of
+// its codes as little-endian uint16s, seeded with its index and bounds. buf
+// is scratch space for stores whose codes it can't hash in place, which is
+// allocated if it's shorter than 2*blockSize bytes.
+func (s *Sketch[T]) blockChecksum(i int, buf []byte) uint64 {
+	start, end := i*s.blockSize, s.blockEnd(i)
+	b := s.bounds[i]
+	seed := uint64(i)<<32 | uint64(b.min)<<16 | uint64(b.max)
+
+	if cs, ok := s.store.(*codeSlice[uint16]); ok && littleEndian {
+		codes := (*cs)[start:end]
+		return xxhash64(unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(codes))), 2*len(codes)), seed)
+	}
+	if cap(buf) < 2*s.blockSize {
+		buf = make([]byte, 0, 2*s.blockSize)
+	}
+	buf = buf[:0]
+	for pos := start; pos < end; pos++ {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(s.store.get(pos)))
+	}
+	return xxhash64(buf, seed)
+}
+
+// checksum returns the kept checksum of the i-th block, or computes that
+// of the last block if it's partially filled; see blockChecksum.
+func (s *Sketch[T]) checksum(i int, buf []byte) uint64 {
+	if i < len(s.checksums) {
+		return s.checksums[i]
+	}
+	return s.blockChecksum(i, buf)
+}
+
+// verifyAgainst returns a *ChecksumError for the first block of the sketch
+// whose checksum isn't the one of sums, which has one per block.
+func (s *Sketch[T]) verifyAgainst(sums []uint64) error {
+	buf := make([]byte, 2*s.blockSize)
+	for i, sum := range sums {
+		if s.checksum(i, buf) != sum {
+			return &ChecksumError{Block: i}
+		}
+	}
+	return nil
+}
+
+// updateChecksums computes the checksums of the blocks, but the last,
+// appended to since they were last updated, if the sketch keeps checksums.
+func (s *Sketch[T]) updateChecksums() {
+	if s.checksums == nil {
+		return
+	}
+	full := len(s.bounds) - 1
+	if len(s.checksums) >= full {
+		return
+	}
+	buf := make([]byte, 2*s.blockSize)
+	for i := len(s.checksums); i < full; i++ {
+		s.checksums = append(s.checksums, s.blockChecksum(i, buf))
+	}
+}
+
+// rehashBlock recomputes the checksum of the i-th block, if it has one,
+// without allocating for stores whose codes are hashed in place.
+func (s *Sketch[T]) rehashBlock(i int) {
+	if i < len(s.checksums) {
+		s.checksums[i] = s.blockChecksum(i, nil)
+	}
+}
+
+// lazyChecks is the state of the lazy verification of a view's blocks; see
+// WithLazyVerification.
+type lazyChecks struct {
+	done []atomic.Bool // Whether each block was verified.
+
+	mu  sync.Mutex
+	buf []byte // Scratch space to hash blocks with.
+	err error  // The first mismatch found.
+}
+
+// verifyScan verifies the blocks a scan for the candidates of p may read,
+// like verifyReads over every row.
+func (v *SketchView[T]) verifyScan(p Predicate[T]) bool {
+	return v.lazy == nil || v.verifyReads(p.candidates(v.s.dict), 0, v.s.Len())
+}
+
+// verifyReads verifies the blocks of the rows `[from, to)` that a scan for
+// the codes of f may read, those that f doesn't rule out and that hold live
+// rows. It returns false if any is corrupt.
+func (v *SketchView[T]) verifyReads(f codeFilter, from, to int) bool {
+	if f.empty() {
+		return true
+	}
+	s := &v.s
+	return v.verifyBlocks(from, to, func(i int) bool {
+		return !s.rulesOut(i, f) && !s.isDead(i)
+	})
+}
+
+// verifyBlocks verifies the blocks of the rows `[from, to)`, clipped to
+// the sketch, for which read returns true, and returns false, recording
+// the mismatch for Err, at the first corrupt one. Each block is only
+// verified the first time.
+func (v *SketchView[T]) verifyBlocks(from, to int, read func(i int) bool) bool {
+	l, s := v.lazy, &v.s
+	if from < 0 {
+		from = 0
+	}
+	if n := s.Len(); to > n {
+		to = n
+	}
+	for i := from / s.blockSize; i*s.blockSize < to; i++ {
+		if l.done[i].Load() || !read(i) {
+			continue
+		}
+
+		l.mu.Lock()
+		ok := s.blockChecksum(i, l.buf) == s.checksums[i]
+		if !ok && l.err == nil {
+			l.err = &ChecksumError{Block: i}
+		}
+		l.mu.Unlock()
+		if !ok {
+			return false
+		}
+		l.done[i].Store(true)
+	}
+	return true
+}
@@ -0,0 +1,260 @@
+package colsketch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSketchChecksums(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]SketchOption{
+		{WithBlockSize(64)},
+		{WithBlockSize(128), WithBitPacking()},
+		{WithBlockSize(64), WithRunLength()},
+	} {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, randomInt64s(rng, 2000, 500))
+			s := NewSketch(&dict, append(opts, WithChecksums())...)
+			name := fmt.Sprintf("%s, %d bits", mode, s.BitsPerCode())
+
+			// Checksums are maintained as rows are appended one at a time
+			// and in batches, set, and appended from other sketches.
+			values := randomInt64s(rng, 1500, 502)
+			s.AppendValues(values[:700])
+			for _, v := range values[700:1000] {
+				s.Append(v)
+			}
+			other := NewSketch(&dict, opts...)
+			other.AppendValues(values[1000:])
+			s.AppendSketch(other)
+			s.AppendSketch(s)
+			for i := 0; i < 300; i++ {
+				s.Set(rng.Intn(s.Len()), rng.Int63n(502))
+				s.Delete(rng.Intn(s.Len()))
+			}
+			if !s.HasChecksums() || other.HasChecksums() {
+				t.Fatalf("%s: HasChecksums() = %v, %v, want true, false", name, s.HasChecksums(), other.HasChecksums())
+			}
+			if err := s.Verify(); err != nil {
+				t.Fatalf("%s: Verify() = %v", name, err)
+			}
+
+			codes := make([]Code, s.Len())
+			for i := range codes {
+				codes[i] = s.Get(i)
+			}
+			fresh := NewSketch(&dict, append(opts, WithChecksums())...)
+			fresh.AppendCodes(codes)
+			if !reflect.DeepEqual(s.checksums, fresh.checksums) || len(s.checksums) != s.NumBlocks()-1 {
+				t.Fatalf("%s: checksums of %d blocks differ from those of the same codes appended", name, len(s.checksums))
+			}
+
+			// The serialized sketch keeps them, whether or not the reader
+			// was built with them.
+			var buf bytes.Buffer
+			if _, err := s.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			read := NewSketch(&dict)
+			if _, err := read.ReadFrom(bytes.NewReader(buf.Bytes())); err != nil {
+				t.Fatalf("%s: ReadFrom() = %v", name, err)
+			}
+			testSameSketch(t, name, read, s)
+			if !reflect.DeepEqual(read.checksums, s.checksums) {
+				t.Fatalf("%s: ReadFrom() read different checksums", name)
+			}
+
+			// Codes corrupted in memory fail verification.
+			pos := s.Len()/2 + 3
+			s.store.set(pos, s.store.get(pos)^1)
+			var cerr *ChecksumError
+			if err := s.Verify(); !errors.As(err, &cerr) || cerr.Block != pos/s.BlockSize() {
+				t.Errorf("%s: Verify() of corrupt row %d = %v, want a mismatch in block %d", name, pos, err, pos/s.BlockSize())
+			}
+		}
+	}
+}
+
+// TestSketchSetChecksumsAllocs checks that Set rehashes the block of a Word
+// mode sketch in place, without allocating.
+func TestSketchSetChecksumsAllocs(t *testing.T) {
+	if !littleEndian {
+		t.Skip("codes are only hashed in place on little-endian hosts")
+	}
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Word, randomInt64s(rng, 2000, 500))
+	s := NewSketch(&dict, WithBlockSize(64), WithChecksums())
+	s.AppendValues(randomInt64s(rng, 1000, 502))
+
+	if n := testing.AllocsPerRun(10, func() { s.Set(100, 7) }); n != 0 {
+		t.Errorf("Set() allocated %v times", n)
+	}
+	if err := s.Verify(); err != nil {
+		t.Errorf("Verify() after Set() = %v", err)
+	}
+}
+
+func TestSketchWithoutChecksums(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 1000, 100))
+	s := NewSketch(&dict, WithBlockSize(64))
+	s.AppendValues(randomInt64s(rng, 500, 120))
+
+	var plain, checked bytes.Buffer
+	if _, err := s.WriteTo(&plain); err != nil {
+		t.Fatal(err)
+	}
+	if s.HasChecksums() || s.Verify() != nil {
+		t.Errorf("sketch without checksums: HasChecksums() = %v, Verify() = %v", s.HasChecksums(), s.Verify())
+	}
+
+	// A reader built with checksums computes them.
+	n := plain.Len()
+	read := NewSketch(&dict, WithChecksums())
+	if _, err := read.ReadFrom(&plain); err != nil {
+		t.Fatal(err)
+	}
+	if !read.HasChecksums() || len(read.checksums) != read.NumBlocks()-1 {
+		t.Fatalf("ReadFrom() into a sketch with checksums kept %d of them", len(read.checksums))
+	}
+	if _, err := read.WriteTo(&checked); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := checked.Len(), n+64; got != want {
+		t.Errorf("WriteTo() with checksums wrote %d bytes, want %d", got, want)
+	}
+}
+
+func TestCorruptSketch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]SketchOption{
+		{WithBlockSize(64)},
+		{WithBlockSize(128), WithBitPacking()},
+	} {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, randomInt64s(rng, 2000, 500))
+			s := NewSketch(&dict, append(opts, WithChecksums())...)
+			s.AppendValues(randomInt64s(rng, 1000, 502))
+			s.Delete(10)
+			name := fmt.Sprintf("%s, %d bits", mode, s.BitsPerCode())
+
+			var buf bytes.Buffer
+			if _, err := s.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			// Flip the lowest bit of a code of at least 2 in the second
+			// to last block, which leaves it a valid code.
+			pos := s.Len() - s.BlockSize() - 5
+			for s.Get(pos) < 2 {
+				pos--
+			}
+			block := pos / s.BlockSize()
+			bit := sketchHeaderSize*8 + pos*s.BitsPerCode()
+			data := withByte(buf.Bytes(), bit/8, buf.Bytes()[bit/8]^1<<(bit%8))
+
+			var cerr *ChecksumError
+			if _, err := NewSketch(&dict).ReadFrom(bytes.NewReader(data)); !errors.As(err, &cerr) || cerr.Block != block {
+				t.Errorf("%s: ReadFrom() of a corrupt sketch = %v, want a mismatch in block %d", name, err, block)
+			}
+
+			v, err := OpenSketch(data, &dict)
+			if err != nil {
+				t.Fatalf("%s: OpenSketch() = %v", name, err)
+			}
+			if err := v.Verify(); !errors.As(err, &cerr) || cerr.Block != block {
+				t.Errorf("%s: Verify() of a corrupt view = %v, want a mismatch in block %d", name, err, block)
+			}
+			if v.Err() != nil {
+				t.Errorf("%s: Err() of a view without lazy verification = %v", name, v.Err())
+			}
+
+			// Lazy verification catches the corrupt block when a scan may
+			// first read it, and not before, and scans that may read it find
+			// no rows, while uncorrupted views verify, and scan, every
+			// block.
+			all := Ge(int64(-1))
+			for _, scan := range []struct {
+				name string
+				rows func(v *SketchView[int64]) int
+			}{
+				{"Scan", func(v *SketchView[int64]) (n int) {
+					v.Scan(all, func(int) bool { n++; return true })
+					return n
+				}},
+				{"ScanCertain", func(v *SketchView[int64]) (n int) {
+					v.ScanCertain(all, func(int, Certainty) bool { n++; return true })
+					return n
+				}},
+				{"ScanAll", func(v *SketchView[int64]) (n int) {
+					v.ScanAll([]Predicate[int64]{all, Lt(int64(1000))}, func(int, Certainty) bool { n++; return true })
+					return n
+				}},
+				{"ScanBitmap", func(v *SketchView[int64]) (n int) {
+					for _, w := range v.ScanBitmap(all, nil) {
+						n += bits.OnesCount64(w)
+					}
+					return n
+				}},
+				{"ScanSet", func(v *SketchView[int64]) (n int) {
+					v.ScanSet(NewCodeSet(mode).Invert(), func(int) bool { n++; return true })
+					return n
+				}},
+				{"ScanInto", func(v *SketchView[int64]) int {
+					var r rangeRecorder
+					v.ScanInto(all, 0, &r)
+					return len(r.rows())
+				}},
+				{"ScanSelection", func(v *SketchView[int64]) int {
+					return len(v.ScanSelection(all, 0, v.Len(), nil))
+				}},
+				{"Count", func(v *SketchView[int64]) int {
+					n, _ := v.Count(all)
+					return n
+				}},
+				{"CountRange", func(v *SketchView[int64]) int {
+					return v.CountRange(0, ^Code(0))
+				}},
+			} {
+				lazy, err := OpenSketch(data, &dict, WithLazyVerification())
+				if err != nil {
+					t.Fatalf("%s: OpenSketch() = %v", name, err)
+				}
+				lazy.Scan(IsNull[int64](), func(int) bool { return true })
+				if lazy.Err() != nil {
+					t.Errorf("%s: Err() after a scan reading no codes = %v", name, lazy.Err())
+				}
+				if n := scan.rows(lazy); n != 0 {
+					t.Errorf("%s: %s of a corrupt view found %d rows", name, scan.name, n)
+				}
+				if err := lazy.Err(); !errors.As(err, &cerr) || cerr.Block != block {
+					t.Errorf("%s: Err() after %s = %v, want a mismatch in block %d", name, scan.name, err, block)
+				}
+
+				clean, err := OpenSketch(buf.Bytes(), &dict, WithLazyVerification())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n := scan.rows(clean); n != s.LiveLen() || clean.Err() != nil {
+					t.Errorf("%s: %s found %d rows, Err() = %v, want %d rows", name, scan.name, n, clean.Err(), s.LiveLen())
+				}
+			}
+
+			// Scans of the rows before the corrupt block don't read it.
+			lazy, err := OpenSketch(data, &dict, WithLazyVerification())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sel := lazy.ScanSelection(all, 0, block*s.BlockSize(), nil); len(sel) != block*s.BlockSize()-1 || lazy.Err() != nil {
+				t.Errorf("%s: ScanSelection() before block %d found %d rows, Err() = %v, want %d", name, block, len(sel), lazy.Err(), block*s.BlockSize()-1)
+			}
+		}
+	}
+}
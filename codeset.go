@@ -0,0 +1,217 @@
+package colsketch
+
+import (
+	"cmp"
+	"math/bits"
+)
+
+// CodeSet is a set of codes backed by a bitmap with one bit per code in a
+// mode's code space: 256 bits for Byte mode and 65536 bits for Word mode.
+// Membership tests are a single mask operation, which makes a CodeSet
+// suitable for testing stored codes against IN-list predicates in hot loops.
+type CodeSet struct {
+	mode Mode
+	bits []uint64
+}
+
+// NewCodeSet returns an empty CodeSet spanning the code space of the given
+// mode.
+func NewCodeSet(mode Mode) CodeSet {
+	return CodeSet{mode, make([]uint64, (int(mode.MaxInexactCode())+1+63)/64)}
+}
+
+// Mode returns the mode whose code space the set spans.
+func (s CodeSet) Mode() Mode {
+	return s.mode
+}
+
+// Add inserts a code into the set. Codes outside the set's mode are ignored.
+func (s *CodeSet) Add(c Code) {
+	if i := int(c >> 6); i < len(s.bits) {
+		s.bits[i] |= 1 << (c & 63)
+	}
+}
+
+// Contains returns true iff the code is in the set.
+func (s CodeSet) Contains(c Code) bool {
+	i := int(c >> 6)
+	return i < len(s.bits) && s.bits[i]&(1<<(c&63)) != 0
+}
+
+// Len returns the number of codes in the set.
+func (s CodeSet) Len() int {
+	n := 0
+	for _, w := range s.bits {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// EncodeSet encodes each of the values and returns the set of resulting
+// codes. Values that aren't assigned exact codes contribute the inexact code
+// of the interval they fall in, so testing a stored code against the set
+// never produces a false negative for the IN-list predicate over `values`.
+func (d *Dict[T]) EncodeSet(values []T) CodeSet {
+	s := NewCodeSet(d.mode)
+	for _, v := range values {
+		s.Add(d.Encode(v))
+	}
+	return s
+}
+
+// ScanSet calls visit with the position of every row of the sketch whose
+// code is in the set, in increasing order, until visit returns false. With
+// the set of EncodeSet(values), it scans for the candidates of the IN-list
+// predicate over values in a single pass, testing each code's membership
+// once, rather than in a scan per value. As with Scan, rows with inexact
+// codes are candidates that must be checked against the values they stand
+// for, deleted rows are never visited, and blocks whose headers rule out
+// every code of the set are skipped.
+func (s *Sketch[T]) ScanSet(cs CodeSet, visit func(pos int) bool) {
+	for i := range s.bounds {
+		if s.isDead(i) || s.rulesOutSet(i, &cs) {
+			continue
+		}
+
+		end := s.blockEnd(i)
+		for pos := i * s.blockSize; pos < end; pos += 64 {
+			k := end - pos
+			if k > 64 {
+				k = 64
+			}
+			m := s.store.setMask(pos, k, &cs) &^ s.deletedWord(pos/64)
+			for ; m != 0; m &= m - 1 {
+				if !visit(pos + bits.TrailingZeros64(m)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// rulesOutSet returns true iff the header of the i-th block rules out
+// every code of the set.
+func (s *Sketch[T]) rulesOutSet(i int, cs *CodeSet) bool {
+	b := s.bounds[i]
+	return !cs.containsAny(b.min, b.max) || (s.presence != nil && !s.presence[i].intersects(cs))
+}
+
+// containsAny returns true iff the set contains any code in `[lo, hi]`.
+func (s CodeSet) containsAny(lo, hi Code) bool {
+	last := int(hi >> 6)
+	if last >= len(s.bits) {
+		last = len(s.bits) - 1
+	}
+	for i := int(lo >> 6); i <= last; i++ {
+		w := s.bits[i]
+		if i == int(lo>>6) {
+			w &= ^uint64(0) << (lo & 63)
+		}
+		if i == int(hi>>6) {
+			w &= ^uint64(0) >> (63 - hi&63)
+		}
+		if w != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEmpty returns true iff the set contains no codes.
+func (s CodeSet) IsEmpty() bool {
+	for _, w := range s.bits {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Invert returns the complement of the set within its mode's code space,
+// i.e. every code in `[1, mode.MaxInexactCode()]` that isn't in the set. Code
+// 0 is never assigned by a dictionary, so it is never part of a complement.
+func (s CodeSet) Invert() CodeSet {
+	out := NewCodeSet(s.mode)
+	for i, w := range s.bits {
+		out.bits[i] = ^w
+	}
+	if len(out.bits) > 0 {
+		out.bits[0] &^= 1
+	}
+	return out
+}
+
+// Union returns the set of codes in either s or other. Both sets must span
+// the same mode.
+func (s CodeSet) Union(other CodeSet) CodeSet {
+	s.mustMatch(other)
+	out := NewCodeSet(s.mode)
+	for i := range out.bits {
+		out.bits[i] = s.bits[i] | other.bits[i]
+	}
+	return out
+}
+
+// Intersect returns the set of codes in both s and other. Both sets must span
+// the same mode.
+func (s CodeSet) Intersect(other CodeSet) CodeSet {
+	s.mustMatch(other)
+	out := NewCodeSet(s.mode)
+	for i := range out.bits {
+		out.bits[i] = s.bits[i] & other.bits[i]
+	}
+	return out
+}
+
+// mustMatch panics if two sets don't span the same code space, which would
+// otherwise silently drop codes from the result of a set operation.
+func (s CodeSet) mustMatch(other CodeSet) {
+	if s.mode != other.mode || len(s.bits) != len(other.bits) {
+		panic("colsketch: combining CodeSets of different modes")
+	}
+}
+
+// EncodeRangeCodes returns the set of codes whose value or interval of values
+// overlaps the closed range `[lo, hi]`, i.e. every code from Encode(lo) to
+// Encode(hi) inclusive. It returns an empty set if lo > hi.
+func (d *Dict[T]) EncodeRangeCodes(lo, hi T) CodeSet {
+	s := NewCodeSet(d.mode)
+	if cmp.Less(hi, lo) {
+		return s
+	}
+	s.addRange(d.Encode(lo), d.Encode(hi))
+	return s
+}
+
+// EncodeGreaterThan returns the set of codes whose values are all greater
+// than value, i.e. every code above Encode(value): the inexact code right
+// above value's exact code, if it has one, and every code above that. The
+// inexact code of a value that isn't exactly coded is left out, since it
+// also stands for values less than it. The set is empty for values past the
+// last representative, and holds every code but 1 for values below the
+// first.
+func (d *Dict[T]) EncodeGreaterThan(value T) CodeSet {
+	s := NewCodeSet(d.mode)
+	// Without wrapping around past the largest Word mode code.
+	if c := d.Encode(value); int(c) < d.TotalCodes() {
+		s.addRange(c+1, Code(d.TotalCodes()))
+	}
+	return s
+}
+
+// EncodeLessThan is the mirror image of EncodeGreaterThan: it returns the
+// set of codes whose values are all less than value, i.e. every code below
+// Encode(value). The set is empty for values below the first
+// representative.
+func (d *Dict[T]) EncodeLessThan(value T) CodeSet {
+	s := NewCodeSet(d.mode)
+	s.addRange(1, d.Encode(value)-1)
+	return s
+}
+
+// addRange inserts every code in `[lo, hi]` into the set.
+func (s *CodeSet) addRange(lo, hi Code) {
+	for c := int(lo); c <= int(hi); c++ {
+		s.Add(Code(c))
+	}
+}
@@ -0,0 +1,306 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestCodeSet(t *testing.T) {
+	for _, mode := range []Mode{Byte, Word} {
+		s := NewCodeSet(mode)
+		if s.Len() != 0 {
+			t.Fatalf("new set has %d codes", s.Len())
+		}
+
+		maxCode := mode.MaxInexactCode()
+		for _, c := range []Code{1, 2, 63, 64, maxCode} {
+			s.Add(c)
+			if !s.Contains(c) {
+				t.Errorf("mode %d: set doesn't contain added code %d", mode, c)
+			}
+		}
+
+		if got, want := s.Len(), 5; got != want {
+			t.Errorf("mode %d: Len() = %d, want %d", mode, got, want)
+		}
+
+		for _, c := range []Code{0, 3, 62, 65, maxCode - 1} {
+			if s.Contains(c) {
+				t.Errorf("mode %d: set contains code %d that wasn't added", mode, c)
+			}
+		}
+	}
+
+	// Codes outside of Byte mode's code space are neither stored nor found.
+	s := NewCodeSet(Byte)
+	s.Add(0x100)
+	if s.Contains(0x100) || s.Len() != 0 {
+		t.Errorf("Byte set accepted an out of range code")
+	}
+}
+
+func TestEncodeSet(t *testing.T) {
+	dict := NewDict(Byte, []string{"Chicago", "LA", "NYC", "SF"})
+
+	in := []string{"NYC", "LA", "Boston", "NYC"}
+	s := dict.EncodeSet(in)
+
+	if got, want := s.Len(), 3; got != want {
+		t.Errorf("Len() = %d, want %d", got, want)
+	}
+
+	for _, v := range in {
+		if !s.Contains(dict.Encode(v)) {
+			t.Errorf("set doesn't contain code of %q", v)
+		}
+	}
+
+	for _, v := range []string{"Chicago", "SF", "Zurich"} {
+		if s.Contains(dict.Encode(v)) {
+			t.Errorf("set contains code of %q", v)
+		}
+	}
+}
+
+func TestSketchScanSet(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+		values := clusteredInt64s(rng, 5000, 20, 12000)
+
+		for _, opts := range [][]SketchOption{
+			nil,
+			{WithBlockSize(64)},
+			{WithBlockSize(128), WithBitPacking()},
+			{WithBlockSize(192), WithRunLength()},
+		} {
+			s := NewSketch(&dict, opts...)
+			s.AppendValues(values)
+			for i := 0; i < s.Len()/8; i++ {
+				s.Delete(rng.Intn(s.Len()))
+			}
+			name := fmt.Sprintf("mode %s, block size %d, %d bits", mode, s.BlockSize(), s.BitsPerCode())
+
+			for i := 0; i < 50; i++ {
+				// IN lists with duplicates and values out of the sample's
+				// range, of up to a few hundred values.
+				in := make([]int64, rng.Intn(1<<uint(rng.Intn(9))))
+				for j := range in {
+					in[j] = rng.Int63n(12002) - 1
+					if j > 0 && rng.Intn(4) == 0 {
+						in[j] = in[rng.Intn(j)]
+					}
+				}
+
+				// The union of the scans of Eq on each value.
+				union := make([]bool, s.Len())
+				for _, v := range in {
+					s.Scan(Eq(v), func(pos int) bool {
+						union[pos] = true
+						return true
+					})
+				}
+				var want []int
+				for pos, ok := range union {
+					if ok {
+						want = append(want, pos)
+					}
+				}
+
+				var got []int
+				s.ScanSet(dict.EncodeSet(in), func(pos int) bool {
+					got = append(got, pos)
+					return true
+				})
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("%s, IN %v: ScanSet() visited %v, want %v", name, in, got, want)
+				}
+
+				if len(want) > 0 {
+					stop := rng.Intn(len(want))
+					got = got[:0]
+					s.ScanSet(dict.EncodeSet(in), func(pos int) bool {
+						got = append(got, pos)
+						return len(got) <= stop
+					})
+					if !reflect.DeepEqual(got, want[:stop+1]) {
+						t.Fatalf("%s, IN %v: ScanSet() stopped after %d visited %v", name, in, stop+1, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCodeSetInvert(t *testing.T) {
+	for _, mode := range []Mode{Byte, Word} {
+		s := NewCodeSet(mode)
+		s.Add(1)
+		s.Add(100)
+
+		inv := s.Invert()
+		if got, want := inv.Len(), int(mode.MaxInexactCode())-2; got != want {
+			t.Errorf("mode %d: inverted Len() = %d, want %d", mode, got, want)
+		}
+
+		for c := Code(0); ; c++ {
+			want := c != 0 && !s.Contains(c)
+			if inv.Contains(c) != want {
+				t.Errorf("mode %d: inverted Contains(%d) = %v, want %v", mode, c, !want, want)
+			}
+			if c == mode.MaxInexactCode() {
+				break
+			}
+		}
+
+		if !NewCodeSet(mode).Invert().Invert().IsEmpty() {
+			t.Errorf("mode %d: double inversion of empty set isn't empty", mode)
+		}
+	}
+}
+
+func TestCodeSetUnionIntersect(t *testing.T) {
+	dict := NewDict(Byte, []int{10, 20, 30, 40})
+
+	a := dict.EncodeSet([]int{10, 20, 25})
+	b := dict.EncodeSet([]int{20, 25, 40})
+
+	union := a.Union(b)
+	for _, v := range []int{10, 20, 25, 40} {
+		if !union.Contains(dict.Encode(v)) {
+			t.Errorf("union doesn't contain code of %d", v)
+		}
+	}
+	if got, want := union.Len(), 4; got != want {
+		t.Errorf("union Len() = %d, want %d", got, want)
+	}
+
+	inter := a.Intersect(b)
+	if got, want := inter.Len(), 2; got != want {
+		t.Errorf("intersection Len() = %d, want %d", got, want)
+	}
+	for _, v := range []int{20, 25} {
+		if !inter.Contains(dict.Encode(v)) {
+			t.Errorf("intersection doesn't contain code of %d", v)
+		}
+	}
+
+	if !a.Intersect(a.Invert()).IsEmpty() {
+		t.Errorf("set intersected with its complement isn't empty")
+	}
+	if a.IsEmpty() || !NewCodeSet(Byte).IsEmpty() {
+		t.Errorf("IsEmpty() disagrees with set contents")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("combining sets of different modes didn't panic")
+		}
+	}()
+	a.Union(NewCodeSet(Word))
+}
+
+func TestEncodeGreaterLessThan(t *testing.T) {
+	dict := NewDict(Byte, []int{10, 20, 30, 40})
+
+	for _, tc := range []struct {
+		value         int
+		greater, less []Code
+	}{
+		{20, []Code{5, 6, 7, 8, 9}, []Code{1, 2, 3}},
+		{25, []Code{6, 7, 8, 9}, []Code{1, 2, 3, 4}},
+		{0, []Code{2, 3, 4, 5, 6, 7, 8, 9}, nil},
+		{10, []Code{3, 4, 5, 6, 7, 8, 9}, []Code{1}},
+		{40, []Code{9}, []Code{1, 2, 3, 4, 5, 6, 7}},
+		{100, nil, []Code{1, 2, 3, 4, 5, 6, 7, 8}},
+	} {
+		for _, op := range []struct {
+			name string
+			got  CodeSet
+			want []Code
+		}{
+			{"EncodeGreaterThan", dict.EncodeGreaterThan(tc.value), tc.greater},
+			{"EncodeLessThan", dict.EncodeLessThan(tc.value), tc.less},
+		} {
+			want := NewCodeSet(Byte)
+			for _, c := range op.want {
+				want.Add(c)
+			}
+			if !reflect.DeepEqual(op.got, want) {
+				t.Errorf("%s(%d) = %v, want %v", op.name, tc.value, op.got, op.want)
+			}
+		}
+	}
+
+	// The sets hold the codes whose values all satisfy Gt and Lt, in both
+	// modes, including the largest Word mode code.
+	rng := rand.New(rand.NewSource(1))
+	full := make([]int64, Word.NumExactCodes())
+	for i := range full {
+		full[i] = int64(2 * i)
+	}
+	for _, d := range []Dict[int64]{
+		NewDict(Byte, randomInt64s(rng, 1000, 2000)),
+		NewDict(Word, randomInt64s(rng, 1000, 2000)),
+		NewDict(Word, full),
+	} {
+		for i := 0; i < 100; i++ {
+			v := rng.Int63n(2*int64(len(full))+2) - 1
+			if i%2 == 0 {
+				v = d.codes[rng.Intn(len(d.codes))]
+			}
+			gt, lt := d.EncodeGreaterThan(v), d.EncodeLessThan(v)
+			last := d.TotalCodes() + 1
+			if top := int(d.mode.MaxInexactCode()); last > top {
+				last = top
+			}
+			for c := 0; c <= last; c++ {
+				iv, ok := d.Bounds(Code(c))
+				if got, want := gt.Contains(Code(c)), ok && mustMatch(iv, Gt(v)); got != want {
+					t.Fatalf("%s: code %d in EncodeGreaterThan(%d) = %v, want %v", d.mode, c, v, got, want)
+				}
+				if got, want := lt.Contains(Code(c)), ok && mustMatch(iv, Lt(v)); got != want {
+					t.Fatalf("%s: code %d in EncodeLessThan(%d) = %v, want %v", d.mode, c, v, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeRangeCodes(t *testing.T) {
+	dict := NewDict(Byte, []int{10, 20, 30, 40})
+
+	for _, tc := range []struct {
+		lo, hi int
+		want   []Code
+	}{
+		{10, 10, []Code{2}},
+		{10, 30, []Code{2, 3, 4, 5, 6}},
+		{11, 29, []Code{3, 4, 5}},
+		{0, 5, []Code{1}},
+		{0, 100, []Code{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{41, 100, []Code{9}},
+		{30, 10, nil},
+	} {
+		s := dict.EncodeRangeCodes(tc.lo, tc.hi)
+		if s.Len() != len(tc.want) {
+			t.Errorf("[%d, %d]: Len() = %d, want %d", tc.lo, tc.hi, s.Len(), len(tc.want))
+		}
+		for _, c := range tc.want {
+			if !s.Contains(c) {
+				t.Errorf("[%d, %d]: set doesn't contain code %d", tc.lo, tc.hi, c)
+			}
+		}
+	}
+
+	// No value in the range may have a code outside the set.
+	s := dict.EncodeRangeCodes(15, 35)
+	for v := 15; v <= 35; v++ {
+		if !s.Contains(dict.Encode(v)) {
+			t.Errorf("code of %d missing from set for [15, 35]", v)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package colsketch
 
 import (
 	"cmp"
+	"errors"
+	"fmt"
 	"sort"
 )
 
@@ -10,9 +12,10 @@ type Code uint16
 
 // IsExact returns true iff the code is an _exact_ code, i.e. a code which
 // represents a single underlying value rather than a range of possible
-// values. This is true iff the code is an even number.
+// values. This is true iff the code is a nonzero even number: NullCode is
+// even, but stands for no value at all.
 func (c Code) IsExact() bool {
-	return c%2 == 0
+	return c != NullCode && c%2 == 0
 }
 
 // Mode indicates whether to build a small Dict of up to 255 values or a larger one of up to 65535 values.
@@ -35,6 +38,11 @@ const (
 	Word
 )
 
+// IsValid returns true iff the mode is one of Byte or Word.
+func (m Mode) IsValid() bool {
+	return m == Byte || m == Word
+}
+
 // NumExactCodes returns the count of exact codes in the mode.
 func (m Mode) NumExactCodes() int {
 	switch m {
@@ -71,9 +79,26 @@ func (m Mode) MaxInexactCode() Code {
 	}
 }
 
+// codeWidth returns the number of bytes needed to store a code of the mode.
+func (m Mode) codeWidth() int {
+	if m == Byte {
+		return 1
+	}
+	return 2
+}
+
 // Dict is dictionary over an underlying type `T` conforming to cmp.Ordered. The
 // dictionary maps underlying values to Codes to use in a sketch, using
 // the Encode method.
+//
+// The zero value is a valid, empty Byte mode dictionary: without
+// representatives, its only code is the inexact code 1, which stands for
+// every value and which Encode returns for all of them. A sketch encoded
+// with it is correct, in that every row is a candidate of every predicate,
+// but useless, since scans skip nothing, so dictionaries are meant to be
+// built with NewDict or read with UnmarshalBinary. A dictionary built over
+// an empty sample isn't the zero value: it holds the zero value of T as its
+// sole representative.
 type Dict[T cmp.Ordered] struct {
 	// The mode the dictionary was built with.
 	mode Mode
@@ -82,15 +107,87 @@ type Dict[T cmp.Ordered] struct {
 	// Implicitly defines both exact and inexact code values based on the
 	// positions of exact codes in the slice.
 	codes []T
+
+	// An optional copy of codes in Eytzinger order, along with the position
+	// in codes of each of its elements. See EnableEytzinger.
+	eytz    []T
+	eytzPos []uint16
+
+	// An optional count of the sample values each code stands for, indexed
+	// by code minus one. It is nil when unknown, e.g. for dictionaries
+	// reconstructed from a BinaryDump. See Recount and Compact.
+	counts []int
+
+	// The number of values in the sample the dictionary was built from, or
+	// last recounted over, or 0 if unknown. See SampleSize.
+	sampleSize int
+
+	// The number of distinct values in the sample the dictionary was built
+	// from, or 0 if unknown. See NumClusters.
+	numClusters int
+
+	// The optional clusters of the sample the dictionary was built from. See
+	// WithStoreClusters.
+	clusters []Cluster[T]
 }
 
-// NewDict builds a dictionary with a given Mode over a provided sample.
+// NewDict builds a dictionary with a given Mode over a provided sample. If
+// the mode isn't one of Byte or Word, the returned dictionary has no codes
+// and reports false from IsValid.
+//
+// Values are ordered as by cmp.Compare, so floating point NaNs are equal to
+// each other and order before all other values, including -Inf. A sample
+// holding NaNs therefore yields a strictly increasing dictionary whose lowest
+// representative may be NaN, and every NaN encodes to the same code.
+//
+// A sample of a single distinct value, however many times it repeats, yields
+// a dictionary of Len 1: the value gets the one exact code, 2, and every
+// other value one of the two inexact codes around it, 1 below it and 3
+// above it. Predicates over the value, such as Eq, then have no false
+// positives, since no other value shares its code.
 func NewDict[T cmp.Ordered](mode Mode, sample []T) Dict[T] {
+	d, _ := NewDictWithStats(mode, sample)
+	return d
+}
+
+// CodeAssignmentStats describes how NewDictWithStats converged on the
+// assignment of exact codes to a sample.
+type CodeAssignmentStats struct {
+	// The number of times the code step was refined after the initial
+	// assignment. It is 0 when every distinct sample value got its own code.
+	Iterations int
+
+	// The number of sample values each code was meant to cover in the
+	// assignment that was kept, or 0 when every distinct sample value got its
+	// own code.
+	FinalCodestep int
+
+	// The number of exact codes assigned.
+	CodesAssigned int
+}
+
+// NewDictWithStats is like NewDict, but also reports how the assignment of
+// codes converged, which is useful for judging how well a sample fits the
+// mode's code space.
+func NewDictWithStats[T cmp.Ordered](mode Mode, sample []T) (Dict[T], CodeAssignmentStats) {
+	d, stats, _ := buildDict(mode, sample)
+	return d, stats
+}
+
+// buildDict builds a dictionary like NewDictWithStats, and also returns the
+// clusters of the sample it was built from.
+func buildDict[T cmp.Ordered](mode Mode, sample []T) (Dict[T], CodeAssignmentStats, []Cluster[T]) {
+	var stats CodeAssignmentStats
+	if !mode.IsValid() {
+		return Dict[T]{mode: mode}, stats, nil
+	}
+
 	if len(sample) == 0 {
 		// For an empty sample we haven't much to work with; assign exact code 2
 		// for the default value in the target type. Any value less than default
 		// will code as 1, any value greater as 3. That's it.
-		return Dict[T]{mode, make([]T, 1)}
+		stats.CodesAssigned = 1
+		return Dict[T]{mode: mode, codes: make([]T, 1), counts: make([]int, 3)}, stats, nil
 	}
 
 	// If we have a real sample, we want to sort it both to assign
@@ -101,7 +198,7 @@ func NewDict[T cmp.Ordered](mode Mode, sample []T) Dict[T] {
 	})
 
 	// Do the frequency analysis.
-	clu := clusters(sortedSample)
+	clu := SampleClusters(sortedSample)
 	ncodes := mode.NumExactCodes()
 
 	// If there are the same or fewer clusters than the codespace, we can
@@ -110,47 +207,293 @@ func NewDict[T cmp.Ordered](mode Mode, sample []T) Dict[T] {
 	if len(clu) <= ncodes {
 		codes := make([]T, len(clu))
 		for i := range clu {
-			codes[i] = clu[i].value
+			codes[i] = clu[i].Value
 		}
-		return Dict[T]{mode, codes}
+		stats.CodesAssigned = len(codes)
+		return Dict[T]{mode: mode, codes: codes, counts: countClusters(codes, clu), sampleSize: len(sample), numClusters: len(clu)}, stats, clu
 	}
 
-	codes := assignCodesWithMinimalStep(len(sample), ncodes, clu)
-	return Dict[T]{mode, codes}
+	codes, stats := assignCodesWithMinimalStep(len(sample), ncodes, clu)
+	return Dict[T]{mode: mode, codes: codes, counts: countClusters(codes, clu), sampleSize: len(sample), numClusters: len(clu)}, stats, clu
 }
 
 // Encode looks up the code for a value of the underlying value type `T`.
+// A dictionary without representatives, such as one with an invalid mode,
+// encodes every value as the inexact code 1.
 func (d *Dict[T]) Encode(value T) Code {
-	idx := sort.Search(len(d.codes), func(i int) bool {
-		return cmp.Compare(d.codes[i], value) >= 0
-	})
+	if d.eytz != nil {
+		idx := d.searchEytzinger(value)
+		code := Code(2 * (idx + 1))
+		if idx >= len(d.codes) || cmp.Compare(d.codes[idx], value) != 0 {
+			code--
+		}
+		return code
+	}
+	if len(d.codes) <= maxSmall {
+		return encodeSmall(d, value)
+	}
+	return encodeSorted(d.codes, value)
+}
+
+// ErrInvalidDict is returned when using a dictionary whose mode is invalid
+// or that holds more representatives than its mode has exact codes.
+var ErrInvalidDict = errors.New("colsketch: invalid dictionary")
+
+// IsValid returns true iff the dictionary's mode is valid and it holds no
+// more representatives than the mode has exact codes. It is a constant-time
+// check; see Validate for an exhaustive one.
+func (d *Dict[T]) IsValid() bool {
+	return d.mode.IsValid() && len(d.codes) <= d.mode.NumExactCodes()
+}
+
+// EncodeChecked is like Encode, but returns ErrInvalidDict instead of a
+// meaningless code when the dictionary isn't valid.
+func (d *Dict[T]) EncodeChecked(value T) (Code, error) {
+	if !d.IsValid() {
+		return 0, ErrInvalidDict
+	}
+	return d.Encode(value), nil
+}
+
+// EncodeOrDefault is like Encode, but returns defaultCode, e.g. a sentinel
+// of the caller's choosing, instead of the meaningless code of a dictionary
+// that is invalid or holds no representatives. defaultCode isn't checked
+// against the dictionary's codes; it is up to callers to pick one that
+// means something to them. Note that NewDict over an empty sample holds a
+// representative, the zero value of `T`.
+func (d *Dict[T]) EncodeOrDefault(value T, defaultCode Code) Code {
+	if len(d.codes) == 0 || !d.IsValid() {
+		return defaultCode
+	}
+	return d.Encode(value)
+}
 
-	code := Code(2 * (idx + 1))
+// search returns the position of the first representative that is greater
+// than or equal to value, or len(d.codes) if there is none.
+func (d *Dict[T]) search(value T) int {
+	if d.eytz != nil {
+		return d.searchEytzinger(value)
+	}
+	return lowerBound(d.codes, value)
+}
+
+// SeekGE returns the smallest exact code whose representative is greater
+// than or equal to value, along with that representative. It returns false if
+// every representative is less than value.
+func (d *Dict[T]) SeekGE(value T) (Code, T, bool) {
+	return d.seek(d.search(value))
+}
+
+// SeekLT returns the largest exact code whose representative is strictly less
+// than value, along with that representative. It returns false if every
+// representative is greater than or equal to value.
+func (d *Dict[T]) SeekLT(value T) (Code, T, bool) {
+	return d.seek(d.search(value) - 1)
+}
+
+// SeekLE returns the largest exact code whose representative is less than or
+// equal to value, along with that representative. It returns false if every
+// representative is greater than value.
+func (d *Dict[T]) SeekLE(value T) (Code, T, bool) {
+	idx := d.search(value)
 	if idx >= len(d.codes) || cmp.Compare(d.codes[idx], value) != 0 {
-		code--
+		idx--
 	}
-	return code
+	return d.seek(idx)
 }
 
-// Len returns the number of codes in the dictionary.
-func (d *Dict[T]) Len() int {
+// SeekCodeGE is like SeekGE, given a code rather than a value: it returns
+// the smallest exact code greater than or equal to c, which is c itself if
+// it is exact, along with its representative. It returns false if there is
+// none.
+func (d *Dict[T]) SeekCodeGE(c Code) (Code, T, bool) {
+	// Exact code 2(i+1) is at least c for every i >= ceil(c/2)-1.
+	idx := (int(c)+1)/2 - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return d.seek(idx)
+}
+
+// SeekCodeLT is like SeekLT, given a code rather than a value: it returns
+// the largest exact code strictly less than c, along with its
+// representative. It returns false if there is none.
+func (d *Dict[T]) SeekCodeLT(c Code) (Code, T, bool) {
+	if c == 0 {
+		var zero T
+		return 0, zero, false
+	}
+	return d.seekBelow((int(c)-1)/2 - 1)
+}
+
+// SeekCodeLE is like SeekLE, given a code rather than a value: it returns
+// the largest exact code less than or equal to c, which is c itself if it
+// is exact, along with its representative. It returns false if there is
+// none.
+func (d *Dict[T]) SeekCodeLE(c Code) (Code, T, bool) {
+	return d.seekBelow(int(c)/2 - 1)
+}
+
+// seek returns the exact code and representative at position idx, or false
+// if there is none.
+func (d *Dict[T]) seek(idx int) (Code, T, bool) {
+	if idx < 0 || idx >= len(d.codes) {
+		var zero T
+		return 0, zero, false
+	}
+	return Code(2 * (idx + 1)), d.codes[idx], true
+}
+
+// seekBelow is seek for a position that may be beyond the last
+// representative, standing for it.
+func (d *Dict[T]) seekBelow(idx int) (Code, T, bool) {
+	if idx >= len(d.codes) {
+		idx = len(d.codes) - 1
+	}
+	return d.seek(idx)
+}
+
+// ForEach calls fn with the index, value and exact code of each of the
+// dictionary's representatives in increasing order, until fn returns false.
+// The code of the i-th representative is CodeAt(i).
+func (d *Dict[T]) ForEach(fn func(i int, value T, code Code) bool) {
+	for i, v := range d.codes {
+		if !fn(i, v, Code(2*(i+1))) {
+			return
+		}
+	}
+}
+
+// CodeAt returns the exact code of the i-th representative, in increasing
+// order, i.e. the one ForEach visits with index i, whose value is that of
+// Value(CodeAt(i)). It panics if i isn't within `[0, NumExactCodes())`.
+// See IndexOf for the inverse.
+func (d *Dict[T]) CodeAt(i int) Code {
+	return codeAt(i, len(d.codes))
+}
+
+// IndexOf returns the index of value among the representatives, in
+// increasing order, and true if it is one of them, i.e. if it is exactly
+// coded, or false otherwise. It is the inverse of CodeAt: the exact code of
+// value is CodeAt(i).
+func (d *Dict[T]) IndexOf(value T) (int, bool) {
+	idx := d.search(value)
+	if idx >= len(d.codes) || cmp.Compare(d.codes[idx], value) != 0 {
+		return 0, false
+	}
+	return idx, true
+}
+
+// ContainsExact returns true iff value is one of the representatives, i.e.
+// iff Encode(value).IsExact(), e.g. to test whether a value is exactly coded
+// before encoding it. It takes a search of the representatives, like Encode.
+func (d *Dict[T]) ContainsExact(value T) bool {
+	return d.Encode(value).IsExact()
+}
+
+// codeAt returns the exact code of the i-th of n representatives.
+func codeAt(i, n int) Code {
+	if i < 0 || i >= n {
+		panic(fmt.Sprintf("colsketch: representative index %d out of range [0:%d]", i, n))
+	}
+	return Code(2 * (i + 1))
+}
+
+// NumExactCodes returns the number of exact codes in the dictionary, i.e.
+// of its representatives.
+func (d *Dict[T]) NumExactCodes() int {
 	return len(d.codes)
 }
 
-// cluster holds information about a cluster of identical values in
-// a sample.
-type cluster[T cmp.Ordered] struct {
-	value T
-	count int
+// TotalCodes returns the number of codes the dictionary encodes values
+// into: its exact codes, and the inexact codes before each of them and after
+// the last, i.e. 2*NumExactCodes()+1. Codes range from 1 to TotalCodes().
+func (d *Dict[T]) TotalCodes() int {
+	return 2*len(d.codes) + 1
 }
 
-// clusters performs frequency analysis on a sorted sample.
-func clusters[T cmp.Ordered](sortedSample []T) []cluster[T] {
+// Len returns the number of exact codes in the dictionary.
+//
+// Deprecated: Use NumExactCodes, or TotalCodes for the number of codes
+// including inexact ones.
+func (d *Dict[T]) Len() int {
+	return d.NumExactCodes()
+}
+
+// OrderPreserving always returns true: a dictionary's codes order like the
+// values they stand for, so they support range predicates as well as
+// equality ones. See CategoricalDict for a dictionary whose codes don't.
+func (d *Dict[T]) OrderPreserving() bool {
+	return true
+}
+
+// Value returns the representative value of an exact code. It returns false
+// for inexact codes and for codes the dictionary doesn't assign.
+func (d *Dict[T]) Value(c Code) (T, bool) {
+	if !c.IsExact() || int(c/2) > len(d.codes) {
+		var zero T
+		return zero, false
+	}
+	return d.codes[c/2-1], true
+}
+
+// Interval describes the values represented by a code.
+type Interval[T cmp.Ordered] struct {
+	// For an exact code, Lo and Hi are both the representative value. For an
+	// inexact code, they are the representatives of the neighbouring exact
+	// codes, which bound the open interval `(Lo, Hi)` of values it covers.
+	Lo, Hi T
+
+	// HasLo and HasHi are false for the unbounded ends of the first and last
+	// inexact codes, in which case Lo or Hi hold the zero value.
+	HasLo, HasHi bool
+
+	// Exact is true iff the code represents the single value Lo.
+	Exact bool
+}
+
+// Bounds returns the interval of values represented by a code. It returns
+// false for codes the dictionary doesn't assign.
+func (d *Dict[T]) Bounds(c Code) (Interval[T], bool) {
+	var iv Interval[T]
+	if c == 0 || int(c) > 2*len(d.codes)+1 {
+		return iv, false
+	}
+
+	if c.IsExact() {
+		v := d.codes[c/2-1]
+		return Interval[T]{v, v, true, true, true}, true
+	}
+
+	// Inexact code 2k+1 lies between exact codes 2k and 2k+2, i.e. between
+	// the representatives at positions k-1 and k.
+	k := int(c / 2)
+	if k > 0 {
+		iv.Lo, iv.HasLo = d.codes[k-1], true
+	}
+	if k < len(d.codes) {
+		iv.Hi, iv.HasHi = d.codes[k], true
+	}
+	return iv, true
+}
+
+// Cluster is a run of identical values in a sorted sample.
+type Cluster[T cmp.Ordered] struct {
+	Value T
+	Count int
+}
+
+// SampleClusters performs frequency analysis on a sorted sample, returning
+// its distinct values in order along with the number of times each occurs.
+// It is the analysis NewDict assigns codes from, exposed for building
+// dictionaries with other strategies. The result is meaningless if the
+// sample isn't sorted as by cmp.Compare.
+func SampleClusters[T cmp.Ordered](sortedSample []T) []Cluster[T] {
 	if len(sortedSample) == 0 {
 		return nil
 	}
 
-	clu := make([]cluster[T], 0, len(sortedSample))
+	clu := make([]Cluster[T], 0, len(sortedSample))
 	curr, count := sortedSample[0], 0
 
 	for _, s := range sortedSample {
@@ -159,11 +502,11 @@ func clusters[T cmp.Ordered](sortedSample []T) []cluster[T] {
 			continue
 		}
 
-		clu = append(clu, cluster[T]{curr, count})
+		clu = append(clu, Cluster[T]{curr, count})
 		curr, count = s, 1
 	}
 
-	return append(clu, cluster[T]{curr, count})
+	return append(clu, Cluster[T]{curr, count})
 }
 
 // assignCodesWithMinimalStep divides a list of clusters into segments and assigns a code to represent each segment.
@@ -172,13 +515,14 @@ func clusters[T cmp.Ordered](sortedSample []T) []cluster[T] {
 // The initial estimation for how many sample values each code should cover might be off due to varying cluster sizes.
 // To correct any inaccuracies, the function iteratively refines the estimation using a bias correction mechanism,
 // ensuring that the resulting number of codes is as close as possible to ncodes without exceeding it.
-func assignCodesWithMinimalStep[T cmp.Ordered](sampleSize, ncodes int, clu []cluster[T]) []T {
+func assignCodesWithMinimalStep[T cmp.Ordered](sampleSize, ncodes int, clu []Cluster[T]) ([]T, CodeAssignmentStats) {
 	// Each code should cover at least codestep worth of the sample.
 	codestep := sampleSize / ncodes
 
 	// We start with a basic dictionary with each code covering `codestep`
 	// sample vaules, calculated by taking elements from the cluster list.
 	codes := assignCodesWithStep(codestep, clu)
+	stats := CodeAssignmentStats{FinalCodestep: codestep}
 
 	// Unfortunately it's possible some of those clusters overshoot the
 	// `codestep`, giving us codes that cover too many sample values and
@@ -207,20 +551,23 @@ func assignCodesWithMinimalStep[T cmp.Ordered](sampleSize, ncodes int, clu []clu
 
 		// Attempt to assign codes again with the adjusted codestep
 		next := assignCodesWithStep(codestep, clu)
+		stats.Iterations++
 		if len(next) < ncodes {
 			codes = next
+			stats.FinalCodestep = codestep
 		} else {
 			break
 		}
 	}
 
-	return codes
+	stats.CodesAssigned = len(codes)
+	return codes, stats
 }
 
 // assignCodesWithStep selects representative codes from a list of clusters based on a given step size (codestep).
 // Each code represents a sequence of clusters such that the sum of their counts is approximately codestep.
 // The representative code for a sequence is chosen as the value of the cluster with the maximum count within that sequence.
-func assignCodesWithStep[T cmp.Ordered](codestep int, clu []cluster[T]) []T {
+func assignCodesWithStep[T cmp.Ordered](codestep int, clu []Cluster[T]) []T {
 	// Initialize an empty list of codes.
 	var codes []T
 	firstIdx := 0
@@ -233,15 +580,15 @@ func assignCodesWithStep[T cmp.Ordered](codestep int, clu []cluster[T]) []T {
 		// Sum the counts of clusters in the sequence until the sum reaches or exceeds codestep.
 		for lastIdx < len(clu) && clusterCountSum < codestep {
 			// Update idxWithMaxVal if the current cluster has a count greater than the previously observed max.
-			if clu[idxWithMaxVal].count < clu[lastIdx].count {
+			if clu[idxWithMaxVal].Count < clu[lastIdx].Count {
 				idxWithMaxVal = lastIdx
 			}
-			clusterCountSum += clu[lastIdx].count
+			clusterCountSum += clu[lastIdx].Count
 			lastIdx++
 		}
 
 		// Add the value of the cluster with the maximum count in this sequence to the list of codes.
-		codes = append(codes, clu[idxWithMaxVal].value)
+		codes = append(codes, clu[idxWithMaxVal].Value)
 
 		// Move to the next cluster for the subsequent sequence.
 		firstIdx = lastIdx + 1
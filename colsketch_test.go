@@ -5,9 +5,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
+	"math/rand"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
+	"testing/quick"
 	"time"
 )
 
@@ -77,6 +81,518 @@ func TestDictionary(t *testing.T) {
 	queryWords := []string{"", "and", "ape", "the", "thorn", "yolo", "zygote"}
 	for _, word := range queryWords {
 		code := dict.Encode(word)
-		t.Logf("query: %s => code 0x%04x\n", word, code)
+		t.Logf("query: %s => code %v\n", word, dict.Fmt(code))
+	}
+}
+
+func TestValueAndBounds(t *testing.T) {
+	dict := NewDict(Byte, []int{10, 20, 30})
+
+	for _, tc := range []struct {
+		code Code
+		want Interval[int]
+		ok   bool
+	}{
+		{0, Interval[int]{}, false},
+		{1, Interval[int]{0, 10, false, true, false}, true},
+		{2, Interval[int]{10, 10, true, true, true}, true},
+		{3, Interval[int]{10, 20, true, true, false}, true},
+		{6, Interval[int]{30, 30, true, true, true}, true},
+		{7, Interval[int]{30, 0, true, false, false}, true},
+		{8, Interval[int]{}, false},
+	} {
+		got, ok := dict.Bounds(tc.code)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("Bounds(%d) = %+v, %v, want %+v, %v", tc.code, got, ok, tc.want, tc.ok)
+		}
+
+		v, ok := dict.Value(tc.code)
+		if wantOK := tc.ok && tc.want.Exact; ok != wantOK || (ok && v != tc.want.Lo) {
+			t.Errorf("Value(%d) = %d, %v, want %d, %v", tc.code, v, ok, tc.want.Lo, wantOK)
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	dict := NewDict(Byte, []int{10, 20, 30})
+
+	for _, tc := range []struct {
+		value                  int
+		geCode, ltCode, leCode Code
+		ge, lt, le             int
+		geOK, ltOK, leOK       bool
+	}{
+		{5, 2, 0, 0, 10, 0, 0, true, false, false},
+		{10, 2, 0, 2, 10, 0, 10, true, false, true},
+		{15, 4, 2, 2, 20, 10, 10, true, true, true},
+		{20, 4, 2, 4, 20, 10, 20, true, true, true},
+		{30, 6, 4, 6, 30, 20, 30, true, true, true},
+		{35, 0, 6, 6, 0, 30, 30, false, true, true},
+	} {
+		if c, v, ok := dict.SeekGE(tc.value); c != tc.geCode || v != tc.ge || ok != tc.geOK {
+			t.Errorf("SeekGE(%d) = %d, %d, %v, want %d, %d, %v", tc.value, c, v, ok, tc.geCode, tc.ge, tc.geOK)
+		}
+		if c, v, ok := dict.SeekLT(tc.value); c != tc.ltCode || v != tc.lt || ok != tc.ltOK {
+			t.Errorf("SeekLT(%d) = %d, %d, %v, want %d, %d, %v", tc.value, c, v, ok, tc.ltCode, tc.lt, tc.ltOK)
+		}
+		if c, v, ok := dict.SeekLE(tc.value); c != tc.leCode || v != tc.le || ok != tc.leOK {
+			t.Errorf("SeekLE(%d) = %d, %d, %v, want %d, %d, %v", tc.value, c, v, ok, tc.leCode, tc.le, tc.leOK)
+		}
+	}
+
+	// Seeking from a code finds the nearest exact code on the given side,
+	// including codes beyond the dictionary's.
+	for c := Code(0); c <= 9; c++ {
+		var ge, lt, le Code
+		for e := Code(6); e >= 2; e -= 2 {
+			if e >= c {
+				ge = e
+			}
+		}
+		for e := Code(2); e <= 6; e += 2 {
+			if e < c {
+				lt = e
+			}
+			if e <= c {
+				le = e
+			}
+		}
+
+		for _, seek := range []struct {
+			name string
+			fn   func(Code) (Code, int, bool)
+			want Code
+		}{
+			{"SeekCodeGE", dict.SeekCodeGE, ge},
+			{"SeekCodeLT", dict.SeekCodeLT, lt},
+			{"SeekCodeLE", dict.SeekCodeLE, le},
+		} {
+			got, v, ok := seek.fn(c)
+			want, _ := dict.Value(seek.want)
+			if got != seek.want || v != want || ok != (seek.want != 0) {
+				t.Errorf("%s(%d) = %d, %d, %v, want %d, %d", seek.name, c, got, v, ok, seek.want, want)
+			}
+		}
+	}
+}
+
+func TestForEach(t *testing.T) {
+	dict := NewDict(Byte, []string{"c", "a", "b", "a"})
+
+	var values []string
+	dict.ForEach(func(i int, v string, c Code) bool {
+		if got, ok := dict.Value(c); !ok || got != v || c != Code(2*(i+1)) {
+			t.Errorf("ForEach() called with %d, %q, %d", i, v, c)
+		}
+		values = append(values, v)
+		return true
+	})
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("ForEach() visited %q, want %q", values, want)
+	}
+
+	calls := 0
+	dict.ForEach(func(i int, v string, c Code) bool {
+		calls++
+		return i < 1
+	})
+	if calls != 2 {
+		t.Errorf("ForEach() made %d calls after returning false on the second, want 2", calls)
+	}
+}
+
+func TestCodeAt(t *testing.T) {
+	dict := NewDict(Word, []int64{30, 10, 20})
+	dict.ForEach(func(i int, v int64, c Code) bool {
+		if got := dict.CodeAt(i); got != c || got != dict.Encode(v) {
+			t.Errorf("CodeAt(%d) = %d, want %d", i, got, c)
+		}
+		return true
+	})
+
+	for _, i := range []int{-1, dict.Len()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("CodeAt(%d) out of range didn't panic", i)
+				}
+			}()
+			dict.CodeAt(i)
+		}()
+	}
+}
+
+func TestDictNumCodes(t *testing.T) {
+	for _, mode := range []Mode{Byte, Word} {
+		for _, sample := range [][]int64{{7}, {30, 10, 20}} {
+			dict := NewDict(mode, sample)
+			if got := dict.NumExactCodes(); got != len(sample) || got != dict.Len() {
+				t.Errorf("%s, %v: NumExactCodes() = %d, Len() = %d, want %d", mode, sample, got, dict.Len(), len(sample))
+			}
+
+			// Every code up to TotalCodes() decodes, and none after it.
+			total := dict.TotalCodes()
+			for c := Code(1); int(c) <= total; c++ {
+				if _, ok := dict.Bounds(c); !ok {
+					t.Errorf("%s, %v: Bounds(%d) within TotalCodes() = %d failed", mode, sample, c, total)
+				}
+			}
+			if _, ok := dict.Bounds(Code(total + 1)); ok {
+				t.Errorf("%s, %v: Bounds(%d) after TotalCodes() succeeded", mode, sample, total+1)
+			}
+		}
+	}
+
+	var empty Dict[int64]
+	if empty.NumExactCodes() != 0 || empty.TotalCodes() != 1 {
+		t.Errorf("empty dictionary: NumExactCodes() = %d, TotalCodes() = %d", empty.NumExactCodes(), empty.TotalCodes())
+	}
+}
+
+func TestIndexOf(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 100000, 1<<20))
+		for i := 0; i < 10000; i++ {
+			v := rng.Int63n(1<<20+2) - 1
+			c := dict.Encode(v)
+			idx, ok := dict.IndexOf(v)
+			if ok != c.IsExact() || (ok && dict.CodeAt(idx) != c) {
+				t.Fatalf("%s: IndexOf(%d) = %d, %v, want the index of code %d", mode, v, idx, ok, c)
+			}
+			if dict.ContainsExact(v) != ok {
+				t.Fatalf("%s: ContainsExact(%d) = %v, want %v", mode, v, !ok, ok)
+			}
+		}
+		dict.ForEach(func(i int, v int64, _ Code) bool {
+			if idx, ok := dict.IndexOf(v); idx != i || !ok {
+				t.Fatalf("%s: IndexOf(%d) = %d, %v, want %d, true", mode, v, idx, ok, i)
+			}
+			if !dict.ContainsExact(v) {
+				t.Fatalf("%s: ContainsExact(%d) = false", mode, v)
+			}
+			return true
+		})
+	}
+
+	var empty Dict[int64]
+	if empty.ContainsExact(0) {
+		t.Errorf("empty dictionary: ContainsExact(0) = true")
+	}
+}
+
+func TestInvalidMode(t *testing.T) {
+	for _, mode := range []Mode{Byte, Word} {
+		if !mode.IsValid() {
+			t.Errorf("%s.IsValid() = false", mode)
+		}
+	}
+
+	mode := Mode(7)
+	if mode.IsValid() {
+		t.Errorf("%s.IsValid() = true", mode)
+	}
+
+	for _, sample := range [][]int{nil, {1, 2, 3}} {
+		dict := NewDict(mode, sample)
+		if dict.IsValid() {
+			t.Errorf("NewDict(%s, %v).IsValid() = true", mode, sample)
+		}
+		if _, err := dict.EncodeChecked(1); err != ErrInvalidDict {
+			t.Errorf("EncodeChecked() error = %v, want %v", err, ErrInvalidDict)
+		}
+	}
+
+	dict := NewDict(Byte, []int{1, 2, 3})
+	if code, err := dict.EncodeChecked(2); err != nil || code != 4 {
+		t.Errorf("EncodeChecked(2) = %d, %v, want 4, nil", code, err)
+	}
+
+	tooLong := Dict[int]{mode: Byte, codes: make([]int, 128)}
+	if tooLong.IsValid() {
+		t.Errorf("dict with more representatives than exact codes is valid")
+	}
+
+	const sentinel = Code(0xbeef)
+	for name, d := range map[string]Dict[int]{
+		"invalid mode": NewDict(mode, []int{1, 2, 3}),
+		"too long":     tooLong,
+		"zero":         {},
+	} {
+		if code := d.EncodeOrDefault(2, sentinel); code != sentinel {
+			t.Errorf("%s: EncodeOrDefault(2) = %d, want %d", name, code, sentinel)
+		}
+	}
+	for _, v := range []int{0, 2, 5} {
+		if code, want := dict.EncodeOrDefault(v, sentinel), dict.Encode(v); code != want {
+			t.Errorf("EncodeOrDefault(%d) = %d, want %d", v, code, want)
+		}
+	}
+}
+
+func TestNewDictWithStats(t *testing.T) {
+	dict, stats := NewDictWithStats(Byte, []int{3, 1, 2, 2})
+	if want := (CodeAssignmentStats{CodesAssigned: 3}); stats != want {
+		t.Errorf("few clusters: stats = %+v, want %+v", stats, want)
+	}
+	if dict.Len() != 3 {
+		t.Errorf("few clusters: Len() = %d, want 3", dict.Len())
+	}
+
+	if _, stats := NewDictWithStats[int](Word, nil); stats != (CodeAssignmentStats{CodesAssigned: 1}) {
+		t.Errorf("empty sample: stats = %+v", stats)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for _, mode := range []Mode{Byte, Word} {
+		// A skewed sample, where some clusters overshoot the initial step.
+		sample := make([]int, 200000)
+		for i := range sample {
+			sample[i] = int(rng.ExpFloat64() * 10000)
+		}
+
+		dict, stats := NewDictWithStats(mode, sample)
+		if stats.CodesAssigned != dict.Len() || dict.Len() > mode.NumExactCodes() {
+			t.Errorf("mode %s: CodesAssigned = %d, Len() = %d", mode, stats.CodesAssigned, dict.Len())
+		}
+		if stats.Iterations < 0 || stats.Iterations > 8 {
+			t.Errorf("mode %s: Iterations = %d outside [0, 8]", mode, stats.Iterations)
+		}
+		if initial := len(sample) / mode.NumExactCodes(); stats.FinalCodestep <= 0 || stats.FinalCodestep > initial {
+			t.Errorf("mode %s: FinalCodestep = %d outside (0, %d]", mode, stats.FinalCodestep, initial)
+		}
+		t.Logf("mode %s: %+v", mode, stats)
+
+		if plain := NewDict(mode, sample); !reflect.DeepEqual(plain, dict) {
+			t.Errorf("mode %s: NewDictWithStats() and NewDict() disagree", mode)
+		}
+	}
+}
+
+func TestNewDictAllSameValues(t *testing.T) {
+	for _, mode := range []Mode{Byte, Word} {
+		sample := make([]int64, 1000)
+		for i := range sample {
+			sample[i] = 42
+		}
+		dict := NewDict(mode, sample)
+		if dict.NumExactCodes() != 1 || dict.TotalCodes() != 3 {
+			t.Fatalf("%s: NumExactCodes(), TotalCodes() = %d, %d, want 1, 3", mode, dict.NumExactCodes(), dict.TotalCodes())
+		}
+		for _, tc := range []struct {
+			v    int64
+			want Code
+		}{{math.MinInt64, 1}, {41, 1}, {42, 2}, {43, 3}, {math.MaxInt64, 3}} {
+			if got := dict.Encode(tc.v); got != tc.want {
+				t.Errorf("%s: Encode(%d) = %d, want %d", mode, tc.v, got, tc.want)
+			}
+		}
+
+		// The sample value's rows are definite matches of Eq, and no other
+		// value's rows are candidates.
+		s := NewSketch(&dict)
+		s.AppendValues(append(sample, 41, 43))
+		if c, d := s.Count(Eq[int64](42)); c != len(sample) || d != len(sample) {
+			t.Errorf("%s: Count(Eq(42)) = %d, %d, want %d, %d", mode, c, d, len(sample), len(sample))
+		}
+	}
+}
+
+func TestNewDictNaN(t *testing.T) {
+	nan := math.NaN()
+	otherNaN := math.Float64frombits(math.Float64bits(nan) ^ 1)
+
+	dict := NewDict(Byte, []float64{3, nan, 1, math.Inf(-1), nan, 2, otherNaN})
+	if err := dict.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if got := dict.Len(); got != 5 {
+		t.Fatalf("Len() = %d, want 5", got)
+	}
+
+	for _, v := range []float64{nan, otherNaN, -nan} {
+		if got := dict.Encode(v); got != 2 {
+			t.Errorf("Encode(%v) = %d, want 2", v, got)
+		}
+	}
+	for i, v := range []float64{math.Inf(-1), 1, 2, 3} {
+		if got, want := dict.Encode(v), Code(2*(i+2)); got != want {
+			t.Errorf("Encode(%v) = %d, want %d", v, got, want)
+		}
+	}
+
+	// Representatives stay strictly increasing when NaNs are a fraction of a
+	// sample too large for every value to get its own code.
+	rng := rand.New(rand.NewSource(1))
+	sample := make([]float32, 10000)
+	for i := range sample {
+		sample[i] = float32(rng.NormFloat64())
+		if rng.Intn(10) == 0 {
+			sample[i] = float32(math.NaN())
+		}
+	}
+	dict32 := NewDict(Byte, sample)
+	if err := dict32.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if got := dict32.Encode(float32(math.NaN())); got > 2 {
+		t.Errorf("Encode(NaN) = %d, want the lowest exact or inexact code", got)
+	}
+}
+
+func TestZeroDict(t *testing.T) {
+	var dict Dict[string]
+	if err := dict.Validate(); err != nil || !dict.IsValid() {
+		t.Errorf("zero value isn't valid: %v", err)
+	}
+	if iv, ok := dict.Bounds(1); !ok || iv.Exact || iv.HasLo || iv.HasHi {
+		t.Errorf("Bounds(1) = %+v, %v, want an unbounded inexact interval", iv, ok)
+	}
+	if _, ok := dict.Bounds(2); ok {
+		t.Errorf("Bounds(2) of the zero value is known")
+	}
+
+	data, err := dict.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Dict[string]
+	if err := got.UnmarshalBinary(data); err != nil || got.Len() != 0 || got.Encode("a") != 1 {
+		t.Errorf("UnmarshalBinary() of the zero value = %+v, %v", got, err)
+	}
+
+	// Every row of a sketch over it is a candidate of every predicate.
+	s := NewSketch(&dict)
+	s.AppendValues([]string{"a", "b", "c"})
+	for _, p := range []Predicate[string]{Eq("a"), Lt(""), Between("x", "y")} {
+		if c, d := s.Count(p); c != 3 || d != 0 {
+			t.Errorf("Count(%+v) = %d, %d, want 3, 0", p, c, d)
+		}
+	}
+
+	if empty := NewDict[string](Byte, nil); empty.Len() != 1 || empty.Encode("") != 2 {
+		t.Errorf("NewDict() of an empty sample has %d representatives", empty.Len())
+	}
+}
+
+func TestEmptyDict(t *testing.T) {
+	for _, dict := range []Dict[string]{{mode: Byte}, NewDict(Mode(9), []string{"a"})} {
+		if got := dict.Encode("a"); got != 1 {
+			t.Errorf("Encode() = %d, want 1", got)
+		}
+		if got := dict.NewMonotonicEncoder().Encode("a"); got != 1 {
+			t.Errorf("MonotonicEncoder.Encode() = %d, want 1", got)
+		}
+		for _, codes := range [][]Code{
+			dict.EncodeAll([]string{"a", "b"}, nil),
+			dict.EncodeAllInterleaved([]string{"a", "b"}, nil),
+		} {
+			if !reflect.DeepEqual(codes, []Code{1, 1}) {
+				t.Errorf("encoded all as %v, want [1 1]", codes)
+			}
+		}
+		if _, _, ok := dict.SeekGE("a"); ok {
+			t.Errorf("SeekGE() found a representative")
+		}
+		if _, _, ok := dict.SeekLT("a"); ok {
+			t.Errorf("SeekLT() found a representative")
+		}
+		if b, ok := dict.Bounds(1); !ok || b.HasLo || b.HasHi {
+			t.Errorf("Bounds(1) = %+v, %v, want an unbounded interval", b, ok)
+		}
+	}
+}
+
+// TestNewDictDeterministic checks that NewDict builds the same dictionary
+// every time from the same sample, so that it stays deterministic if code
+// assignment ever comes to depend on something like map iteration order.
+func TestNewDictDeterministic(t *testing.T) {
+	check := func(seed int64, n uint16, distinct uint16, word bool) bool {
+		mode := Byte
+		if word {
+			mode = Word
+		}
+		rng := rand.New(rand.NewSource(seed))
+		ints := randomInt64s(rng, int(n)%4000, int64(distinct)+1)
+		strs := randomStrings(rng, int(n)%1000, int(distinct)+1)
+
+		wantInts, wantStrs := NewDict(mode, append([]int64(nil), ints...)), NewDict(mode, append([]string(nil), strs...))
+		for i := 0; i < 100; i++ {
+			gotInts := NewDict(mode, append([]int64(nil), ints...))
+			gotStrs := NewDict(mode, append([]string(nil), strs...))
+			if !reflect.DeepEqual(gotInts.codes, wantInts.codes) || !reflect.DeepEqual(gotStrs.codes, wantStrs.codes) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 20}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSampleClusters(t *testing.T) {
+	for _, tc := range []struct {
+		sample []string
+		want   []Cluster[string]
+	}{
+		{nil, nil},
+		{[]string{"a"}, []Cluster[string]{{"a", 1}}},
+		{[]string{"a", "a", "b", "c", "c", "c"}, []Cluster[string]{{"a", 2}, {"b", 1}, {"c", 3}}},
+	} {
+		if got := SampleClusters(tc.sample); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SampleClusters(%q) = %v, want %v", tc.sample, got, tc.want)
+		}
+	}
+}
+
+// BenchmarkDictEncode benchmarks Encode of values with exact and inexact
+// codes, failing if it allocates, which it mustn't on the hot path of
+// appending values to sketches.
+func BenchmarkDictEncode(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		sample := randomInt64s(rng, 1<<16, 1<<20)
+		for i := range sample {
+			sample[i] *= 2
+		}
+		d := NewDict(mode, sample)
+
+		// Representatives have exact codes, and odd values, which the
+		// sample lacks, inexact ones.
+		exact, inexact := make([]int64, 1024), make([]int64, 1024)
+		for i := range exact {
+			exact[i] = d.codes[rng.Intn(len(d.codes))]
+			inexact[i] = exact[i] + 1
+		}
+
+		for _, tc := range []struct {
+			name   string
+			probes []int64
+			exact  bool
+		}{
+			{"exact", exact, true},
+			{"inexact", inexact, false},
+		} {
+			b.Run(fmt.Sprintf("%s/%s", mode, tc.name), func(b *testing.B) {
+				for _, v := range tc.probes {
+					if d.Encode(v).IsExact() != tc.exact {
+						b.Fatalf("Encode(%d).IsExact() = %v, want %v", v, !tc.exact, tc.exact)
+					}
+				}
+				if allocs := testing.AllocsPerRun(100, func() { codeSink = d.Encode(tc.probes[0]) }); allocs != 0 {
+					b.Fatalf("Encode() allocates %v times, want 0", allocs)
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				var sum Code
+				for i := 0; i < b.N; i++ {
+					sum += d.Encode(tc.probes[i%len(tc.probes)])
+				}
+				codeSink = sum
+			})
+		}
 	}
 }
@@ -0,0 +1,281 @@
+package colsketch
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/bits"
+	"unsafe"
+)
+
+// SketchedColumn is a Sketch paired with the dictionary that produced its
+// codes, which it owns. It is the simplest way to go from building a Dict
+// to filtering a column with it, and its Sketch has the scans to filter it
+// with predicates.
+type SketchedColumn[T cmp.Ordered] struct {
+	dict Dict[T]
+	s    *Sketch[T]
+}
+
+// NewSketchedColumn returns an empty column encoding values with dict.
+func NewSketchedColumn[T cmp.Ordered](dict Dict[T]) *SketchedColumn[T] {
+	c := &SketchedColumn[T]{dict: dict}
+	c.s = newColumnSketch(&c.dict)
+	return c
+}
+
+// newColumnSketch returns an empty sketch for the codes of a column, which
+// may hold NullCode; see AppendSparse.
+func newColumnSketch[T cmp.Ordered](dict *Dict[T]) *Sketch[T] {
+	return NewSketch(dict, WithNulls())
+}
+
+// Dict returns the dictionary the column encodes values with.
+func (c *SketchedColumn[T]) Dict() *Dict[T] {
+	return &c.dict
+}
+
+// Sketch returns the sketch holding the codes of the column, e.g. to scan
+// it with predicates. It encodes values with the column's dictionary, and
+// is emptied by Reset, while Deserialize replaces it.
+func (c *SketchedColumn[T]) Sketch() *Sketch[T] {
+	return c.s
+}
+
+// Append encodes a value and appends its code to the column.
+func (c *SketchedColumn[T]) Append(value T) {
+	c.s.Append(value)
+}
+
+// AppendSparse appends a sparse run of values, given as parallel slices of
+// strictly increasing row positions and the values at those positions. Rows
+// skipped over, from the current end of the column up to each position, are
+// filled with NullCode, which no dictionary assigns to a value. It panics if
+// the slices have different lengths or a position isn't beyond every row
+// already in the column, leaving the column unmodified.
+func (c *SketchedColumn[T]) AppendSparse(indices []int, values []T) {
+	indices, codes := c.dict.EncodeSparse(indices, values)
+	next := c.s.Len()
+	for _, idx := range indices {
+		if idx < next {
+			panic("colsketch: AppendSparse positions must be increasing and beyond the end of the column")
+		}
+		next = idx + 1
+	}
+
+	for i, idx := range indices {
+		for c.s.Len() < idx {
+			c.s.appendCode(NullCode)
+		}
+		c.s.appendCode(codes[i])
+	}
+}
+
+// At returns the code at position i. It panics if i is out of range.
+func (c *SketchedColumn[T]) At(i int) Code {
+	return c.s.Get(i)
+}
+
+// Len returns the number of codes in the column.
+func (c *SketchedColumn[T]) Len() int {
+	return c.s.Len()
+}
+
+// Filter returns the positions of the codes for which pred returns true, in
+// increasing order.
+func (c *SketchedColumn[T]) Filter(pred func(Code) bool) []int {
+	var idx []int
+	for i := 0; i < c.s.Len(); i++ {
+		if pred(c.s.Get(i)) {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// CountMatching returns the number of codes in the column that are in the
+// set, without materializing their positions, like counting the rows
+// Sketch.ScanSet visits: blocks whose headers rule out every code of the
+// set are skipped, and the membership bits of the others are gathered a
+// word of codes at a time and popcounted.
+func (c *SketchedColumn[T]) CountMatching(cs CodeSet) int {
+	s, n := c.s, 0
+	for i := range s.bounds {
+		if s.rulesOutSet(i, &cs) {
+			continue
+		}
+
+		end := s.blockEnd(i)
+		for pos := i * s.blockSize; pos < end; pos += 64 {
+			k := end - pos
+			if k > 64 {
+				k = 64
+			}
+			n += bits.OnesCount64(s.store.setMask(pos, k, &cs))
+		}
+	}
+	return n
+}
+
+// Reset empties the column, retaining its storage for reuse.
+func (c *SketchedColumn[T]) Reset() {
+	switch st := c.s.store.(type) {
+	case *codeSlice[uint8]:
+		*st = (*st)[:0]
+	case *codeSlice[uint16]:
+		*st = (*st)[:0]
+	}
+	*c.s = Sketch[T]{
+		dict:      &c.dict,
+		store:     c.s.store,
+		bounds:    c.s.bounds[:0],
+		blockSize: c.s.blockSize,
+		nulls:     true,
+	}
+}
+
+// MemoryFootprint returns an estimate of the number of bytes of memory
+// retained by the column, including its dictionary. See Dict.MemoryFootprint.
+func (c *SketchedColumn[T]) MemoryFootprint() int64 {
+	return int64(unsafe.Sizeof(*c)) - int64(unsafe.Sizeof(c.dict)) +
+		c.dict.MemoryFootprint() + c.s.MemoryFootprint()
+}
+
+// The serialized format of a SketchedColumn is:
+//
+//	version  uint8    columnFormatVersion
+//	length   uint64   number of codes
+//	checksum uint32   CRC-32 (IEEE) of the dict and codes sections
+//	dictLen  uint32   length of the dict section
+//	dict     []byte   the dictionary, as written by Dict.MarshalBinary
+//	codes    []byte   one byte per code in Byte mode, two in Word mode
+//
+// All fixed-width integers are little-endian.
+const (
+	columnFormatVersion = 1
+	columnHeaderSize    = 1 + 8 + 4 + 4
+)
+
+// Serialize writes the column, including its dictionary, to w.
+func (c *SketchedColumn[T]) Serialize(w io.Writer) error {
+	dict, err := c.dict.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	width := c.dict.mode.codeWidth()
+	codes := make([]byte, c.s.Len()*width)
+	for i := 0; i < c.s.Len(); i++ {
+		code := c.s.Get(i)
+		if width == 1 {
+			codes[i] = byte(code)
+		} else {
+			binary.LittleEndian.PutUint16(codes[2*i:], uint16(code))
+		}
+	}
+
+	crc := crc32.NewIEEE()
+	crc.Write(dict)
+	crc.Write(codes)
+
+	var hdr [columnHeaderSize]byte
+	hdr[0] = columnFormatVersion
+	binary.LittleEndian.PutUint64(hdr[1:], uint64(c.s.Len()))
+	binary.LittleEndian.PutUint32(hdr[9:], crc.Sum32())
+	binary.LittleEndian.PutUint32(hdr[13:], uint32(len(dict)))
+
+	for _, b := range [][]byte{hdr[:], dict, codes} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deserialize replaces the contents of the column, including its
+// dictionary, with a column read from r as written by Serialize. The column
+// is left unmodified if an error is returned.
+func (c *SketchedColumn[T]) Deserialize(r io.Reader) error {
+	var hdr [columnHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return noEOF(err)
+	}
+
+	if version := hdr[0]; version != columnFormatVersion {
+		return fmt.Errorf("colsketch: unsupported column format version %d", version)
+	}
+	length := binary.LittleEndian.Uint64(hdr[1:])
+	checksum := binary.LittleEndian.Uint32(hdr[9:])
+
+	dictBytes, err := readN(r, uint64(binary.LittleEndian.Uint32(hdr[13:])))
+	if err != nil {
+		return err
+	}
+
+	var dict Dict[T]
+	if err := dict.UnmarshalBinary(dictBytes); err != nil {
+		return err
+	}
+
+	width := dict.mode.codeWidth()
+	if length > uint64(math.MaxInt)/uint64(width) {
+		return fmt.Errorf("colsketch: invalid column length %d", length)
+	}
+
+	codeBytes, err := readN(r, length*uint64(width))
+	if err != nil {
+		return err
+	}
+
+	crc := crc32.NewIEEE()
+	crc.Write(dictBytes)
+	crc.Write(codeBytes)
+	if crc.Sum32() != checksum {
+		return errors.New("colsketch: column checksum mismatch")
+	}
+
+	limit := Code(2*len(dict.codes) + 1)
+	codes := make([]Code, length)
+	for i := range codes {
+		if width == 1 {
+			codes[i] = Code(codeBytes[i])
+		} else {
+			codes[i] = Code(binary.LittleEndian.Uint16(codeBytes[2*i:]))
+		}
+		if codes[i] > limit {
+			return fmt.Errorf("colsketch: invalid code %s at row %d of column", codes[i], i)
+		}
+	}
+
+	c.dict = dict
+	c.s = newColumnSketch(&c.dict)
+	c.s.appendCodes(codes)
+	return nil
+}
+
+// readN reads exactly n bytes from r. Unlike io.ReadFull into a buffer of
+// size n, it only allocates as data actually arrives, so a corrupt length
+// can't trigger a huge allocation.
+func readN(r io.Reader, n uint64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)) != n {
+		return nil, errTruncated
+	}
+	return b, nil
+}
+
+// noEOF converts io.EOF into errTruncated for readers of fixed-size data,
+// where running out of input is always an error.
+func noEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errTruncated
+	}
+	return err
+}
@@ -0,0 +1,222 @@
+package colsketch
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// columnCodes returns the codes of a column, in order.
+func columnCodes[T cmp.Ordered](col *SketchedColumn[T]) []Code {
+	codes := make([]Code, col.Len())
+	for i := range codes {
+		codes[i] = col.At(i)
+	}
+	return codes
+}
+
+func TestSketchedColumn(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	sample := make([]int, 1000)
+	for i := range sample {
+		sample[i] = rng.Intn(500)
+	}
+	dict := NewDict(Byte, sample)
+
+	col := NewSketchedColumn(dict)
+	values := make([]int, 2000)
+	for i := range values {
+		values[i] = rng.Intn(600)
+		col.Append(values[i])
+	}
+
+	if got, want := col.Len(), len(values); got != want {
+		t.Fatalf("Len() = %d, want %d", got, want)
+	}
+
+	for i, v := range values {
+		if got, want := col.At(i), dict.Encode(v); got != want {
+			t.Fatalf("At(%d) = %d, want %d", i, got, want)
+		}
+	}
+
+	// Filtering on the code of a value must yield every position holding
+	// that value, and only positions sharing its code.
+	target := values[0]
+	code := dict.Encode(target)
+	idx := col.Filter(func(c Code) bool { return c == code })
+
+	var want []int
+	for i, v := range values {
+		if dict.Encode(v) == code {
+			want = append(want, i)
+		}
+		if v == target && (len(want) == 0 || want[len(want)-1] != i) {
+			t.Fatalf("value %d at %d not matched by its code", v, i)
+		}
+	}
+	if !reflect.DeepEqual(idx, want) {
+		t.Errorf("Filter() = %v, want %v", idx, want)
+	}
+
+	// The column's sketch scans for the same rows.
+	var scanned []int
+	col.Sketch().Scan(Eq(target), func(pos int) bool {
+		scanned = append(scanned, pos)
+		return true
+	})
+	if !reflect.DeepEqual(scanned, want) {
+		t.Errorf("Sketch().Scan() = %v, want %v", scanned, want)
+	}
+
+	footprint := col.MemoryFootprint()
+	col.Reset()
+	if col.Len() != 0 {
+		t.Errorf("Len() after Reset() = %d", col.Len())
+	}
+	if col.Filter(func(Code) bool { return true }) != nil {
+		t.Errorf("Filter() on empty column returned positions")
+	}
+	if col.MemoryFootprint() != footprint {
+		t.Errorf("Reset() released storage")
+	}
+	col.Append(target)
+	if n, _ := col.Sketch().Count(Eq(target)); n != 1 || col.At(0) != code {
+		t.Errorf("Count() after Reset() and Append() = %d, At(0) = %d, want 1, %d", n, col.At(0), code)
+	}
+}
+
+func TestSketchedColumnSerialize(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		sample := make([]string, 5000)
+		for i := range sample {
+			sample[i] = fmt.Sprintf("v%05d", rng.Intn(1000))
+		}
+
+		col := NewSketchedColumn(NewDict(mode, sample))
+		for i := 0; i < 3000; i++ {
+			col.Append(fmt.Sprintf("v%05d", rng.Intn(1200)))
+		}
+
+		var buf bytes.Buffer
+		if err := col.Serialize(&buf); err != nil {
+			t.Fatalf("mode %s: Serialize() = %v", mode, err)
+		}
+		data := buf.Bytes()
+
+		if got, want := len(data)-columnHeaderSize, 3000*mode.codeWidth(); got < want {
+			t.Errorf("mode %s: payload of %d bytes can't hold %d bytes of codes", mode, got, want)
+		}
+
+		var got SketchedColumn[string]
+		if err := got.Deserialize(bytes.NewReader(data)); err != nil {
+			t.Fatalf("mode %s: Deserialize() = %v", mode, err)
+		}
+
+		if !reflect.DeepEqual(got.dict, col.dict) {
+			t.Errorf("mode %s: dictionary didn't round-trip", mode)
+		}
+		if !reflect.DeepEqual(columnCodes(&got), columnCodes(col)) {
+			t.Errorf("mode %s: codes didn't round-trip", mode)
+		}
+
+		// Every proper prefix of the data is truncated input.
+		for _, n := range []int{0, 1, columnHeaderSize, columnHeaderSize + 10, len(data) - 1} {
+			if err := got.Deserialize(bytes.NewReader(data[:n])); err == nil {
+				t.Errorf("mode %s: Deserialize() of %d/%d bytes succeeded", mode, n, len(data))
+			}
+		}
+
+		corrupt := append([]byte(nil), data...)
+		corrupt[len(corrupt)-1] ^= 0xff
+		if err := got.Deserialize(bytes.NewReader(corrupt)); err == nil {
+			t.Errorf("mode %s: Deserialize() of corrupt codes succeeded", mode)
+		}
+
+		// Codes the dictionary doesn't assign are rejected, even with a
+		// valid checksum.
+		corrupt = append([]byte(nil), data...)
+		corrupt[len(corrupt)-1] = 0xff
+		crc := crc32.NewIEEE()
+		crc.Write(corrupt[columnHeaderSize:])
+		binary.LittleEndian.PutUint32(corrupt[9:], crc.Sum32())
+		if err := got.Deserialize(bytes.NewReader(corrupt)); err == nil {
+			t.Errorf("mode %s: Deserialize() of an unassigned code succeeded", mode)
+		}
+
+		corrupt = append([]byte(nil), data...)
+		corrupt[0] = 0xff
+		if err := got.Deserialize(bytes.NewReader(corrupt)); err == nil {
+			t.Errorf("mode %s: Deserialize() of unknown version succeeded", mode)
+		}
+
+		var ints SketchedColumn[int]
+		if err := ints.Deserialize(bytes.NewReader(data)); err == nil {
+			t.Errorf("mode %s: Deserialize() into column of another type succeeded", mode)
+		}
+	}
+}
+
+func TestSketchedColumnCountMatching(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		sample := make([]int, 10000)
+		for i := range sample {
+			sample[i] = rng.Intn(100000)
+		}
+		dict := NewDict(mode, sample)
+
+		col := NewSketchedColumn(dict)
+		for i := 0; i < 1000; i++ {
+			col.Append(rng.Intn(110000))
+		}
+
+		for _, cs := range []CodeSet{
+			NewCodeSet(mode),
+			NewCodeSet(mode).Invert(),
+			dict.EncodeSet(sample[:50]),
+			dict.EncodeRangeCodes(1000, 50000),
+			NewCodeSet(Byte).Invert(),
+		} {
+			want := len(col.Filter(cs.Contains))
+			if got := col.CountMatching(cs); got != want {
+				t.Errorf("mode %s: CountMatching() = %d, want %d", mode, got, want)
+			}
+		}
+	}
+}
+
+func TestSketchedColumnAppendSparse(t *testing.T) {
+	col := NewSketchedColumn(NewDict(Byte, []int{10, 20, 30}))
+	col.Append(20)
+	col.AppendSparse([]int{2, 3, 6}, []int{10, 25, 30})
+	col.AppendSparse(nil, nil)
+	col.AppendSparse([]int{7}, []int{40})
+
+	if got, want := columnCodes(col), []Code{4, 0, 2, 5, 0, 0, 6, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("codes = %v, want %v", got, want)
+	}
+
+	for _, indices := range [][]int{{7}, {9, 12, 11}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("AppendSparse(%v) didn't panic", indices)
+				}
+			}()
+			col.AppendSparse(indices, make([]int, len(indices)))
+		}()
+		if col.Len() != 8 {
+			t.Fatalf("AppendSparse(%v) appended %d codes before panicking", indices, col.Len()-8)
+		}
+	}
+}
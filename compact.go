@@ -0,0 +1,111 @@
+package colsketch
+
+import (
+	"cmp"
+	"math"
+	"sort"
+)
+
+// countClusters returns the number of sample values, given as clusters,
+// that each code of a dictionary with the given representatives stands for,
+// indexed by code minus one.
+func countClusters[T cmp.Ordered](codes []T, clu []Cluster[T]) []int {
+	counts := make([]int, 2*len(codes)+1)
+	for _, c := range clu {
+		counts[encodeSorted(codes, c.Value)-1] += c.Count
+	}
+	return counts
+}
+
+// SampleCount returns the number of sample values a code stands for, as
+// counted over the sample the dictionary was built from or last recounted
+// over. It returns false for codes the dictionary doesn't assign and when
+// the counts are unknown, e.g. for dictionaries reconstructed from a
+// BinaryDump.
+func (d *Dict[T]) SampleCount(c Code) (int, bool) {
+	if c == 0 || int(c) > len(d.counts) {
+		return 0, false
+	}
+	return d.counts[c-1], true
+}
+
+// SampleSize returns the number of values in the sample the dictionary was
+// built from or last recounted over, which its sample counts add up to, e.g.
+// for weighing the fraction of the sample a code stands for, or the
+// dictionary against another when merging them. With NewDictWithOptions and
+// WithReservoirSize, that is the size of the reservoir rather than that
+// of the sample given. It returns 0 when the counts are unknown.
+func (d *Dict[T]) SampleSize() int {
+	return d.sampleSize
+}
+
+// NumClusters returns the number of distinct values in the sample the
+// dictionary was built from, or 0 if unknown, e.g. for dictionaries
+// reconstructed from a BinaryDump. Recount doesn't change it.
+func (d *Dict[T]) NumClusters() int {
+	return d.numClusters
+}
+
+// Coverage returns the fraction of the distinct values of the sample the
+// dictionary was built from that received exact codes: 1 when every
+// distinct value is a representative, and less when the sample had more
+// distinct values than the mode has exact codes. It returns NaN when
+// NumClusters is 0.
+func (d *Dict[T]) Coverage() float64 {
+	if d.numClusters == 0 {
+		return math.NaN()
+	}
+	return float64(len(d.codes)) / float64(d.numClusters)
+}
+
+// Recount returns a copy of the dictionary, with the same codes, that counts
+// the values of a new sample each code stands for, e.g. to account for the
+// drift of a column's contents since the dictionary was built. If the
+// dictionary stores clusters, so does the copy, of the new sample.
+func (d *Dict[T]) Recount(sample []T) Dict[T] {
+	r := *d
+	r.counts, r.sampleSize = make([]int, 2*len(d.codes)+1), len(sample)
+	for _, v := range sample {
+		r.counts[d.Encode(v)-1]++
+	}
+
+	if d.clusters != nil {
+		sorted := append([]T(nil), sample...)
+		sort.Slice(sorted, func(i, j int) bool { return cmp.Less(sorted[i], sorted[j]) })
+		clu := SampleClusters(sorted)
+		r.clusters = append(make([]Cluster[T], 0, len(clu)), clu...)
+	}
+	return r
+}
+
+// Compact returns a copy of the dictionary without the representatives that
+// no sample value is equal to, as per the counts of SampleCount, since their
+// exact codes never match and only split the range between their neighbours.
+// Each removed representative's range merges with those of the inexact
+// codes on either side into a single inexact code, and the codes of later
+// representatives shift down accordingly, so sketches must be re-encoded
+// with the compacted dictionary. Stored clusters are kept, since they are
+// of the sample the counts are of, in which no removed representative
+// occurs. If the counts are unknown, it returns an unchanged copy.
+func (d *Dict[T]) Compact() Dict[T] {
+	if d.counts == nil {
+		return *d
+	}
+
+	c := Dict[T]{mode: d.mode, counts: []int{d.counts[0]}, sampleSize: d.sampleSize, numClusters: d.numClusters, clusters: d.clusters}
+	for i, v := range d.codes {
+		exact, above := d.counts[2*i+1], d.counts[2*i+2]
+		if exact == 0 {
+			// Merge the inexact code above into the one below.
+			c.counts[len(c.counts)-1] += above
+			continue
+		}
+		c.codes = append(c.codes, v)
+		c.counts = append(c.counts, exact, above)
+	}
+
+	if d.eytz != nil {
+		c.EnableEytzinger()
+	}
+	return c
+}
@@ -0,0 +1,215 @@
+package colsketch
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSampleCount(t *testing.T) {
+	dict := NewDict(Byte, []int{1, 1, 2, 5, 5, 5})
+
+	// Codes 1 through 7 stand for (-inf, 1), 1, (1, 2), 2, (2, 5), 5, (5, +inf).
+	want := []int{0, 2, 0, 1, 0, 3, 0}
+	for i, w := range want {
+		if got, ok := dict.SampleCount(Code(i + 1)); !ok || got != w {
+			t.Errorf("SampleCount(%d) = %d, %v, want %d, true", i+1, got, ok, w)
+		}
+	}
+
+	for _, c := range []Code{0, Code(len(want) + 1)} {
+		if _, ok := dict.SampleCount(c); ok {
+			t.Errorf("SampleCount(%d) is known", c)
+		}
+	}
+
+	recounted := dict.Recount([]int{0, 3, 4, 5, 9, 9})
+	want = []int{1, 0, 0, 0, 2, 1, 2}
+	for i, w := range want {
+		if got, _ := recounted.SampleCount(Code(i + 1)); got != w {
+			t.Errorf("recounted SampleCount(%d) = %d, want %d", i+1, got, w)
+		}
+	}
+	if got, _ := dict.SampleCount(2); got != 2 {
+		t.Errorf("Recount() modified the receiver's counts")
+	}
+}
+
+func TestSampleSize(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sample := randomInt64s(rng, 100000, 1<<20)
+
+	for name, tc := range map[string]struct {
+		dict Dict[int64]
+		want int
+	}{
+		"empty":     {NewDict(Byte, []int64(nil)), 0},
+		"distinct":  {NewDict(Byte, []int64{3, 1, 2}), 3},
+		"sample":    {NewDict(Word, sample), len(sample)},
+		"reservoir": {NewDictWithOptions(Byte, sample, WithReservoirSize(1000)), 1000},
+		"recount":   {func() Dict[int64] { d := NewDict(Byte, sample); return d.Recount(sample[:10]) }(), 10},
+		"compact":   {func() Dict[int64] { d := NewDict(Byte, []int64{1, 2, 2}); return d.Compact() }(), 3},
+		"zero":      {Dict[int64]{}, 0},
+	} {
+		if got := tc.dict.SampleSize(); got != tc.want {
+			t.Errorf("%s: SampleSize() = %d, want %d", name, got, tc.want)
+		}
+
+		// The sample counts add up to the sample size, and survive
+		// serialization.
+		total := 0
+		for c := 1; c <= tc.dict.TotalCodes(); c++ {
+			n, _ := tc.dict.SampleCount(Code(c))
+			total += n
+		}
+		if total != tc.want {
+			t.Errorf("%s: sample counts add up to %d, want %d", name, total, tc.want)
+		}
+		data, err := tc.dict.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Dict[int64]
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if got.SampleSize() != tc.want {
+			t.Errorf("%s: SampleSize() after UnmarshalBinary() = %d, want %d", name, got.SampleSize(), tc.want)
+		}
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sample := randomInt64s(rng, 100000, 1<<20)
+	distinct := map[int64]bool{}
+	for _, v := range sample {
+		distinct[v] = true
+	}
+
+	for name, tc := range map[string]struct {
+		dict     Dict[int64]
+		clusters int
+	}{
+		"distinct": {NewDict(Byte, []int64{3, 1, 2, 2}), 3},
+		"sample":   {NewDict(Word, sample), len(distinct)},
+		"recount":  {func() Dict[int64] { d := NewDict(Byte, []int64{3, 1, 2}); return d.Recount([]int64{5}) }(), 3},
+		"compact": {func() Dict[int64] {
+			d := NewDict(Byte, []int64{1, 2, 3})
+			d = d.Recount([]int64{1})
+			return d.Compact()
+		}(), 3},
+		"reservoir": {NewDictWithOptions(Byte, []int64{1, 1, 1, 2}, WithReservoirSize(1000)), 2},
+	} {
+		if got := tc.dict.NumClusters(); got != tc.clusters {
+			t.Errorf("%s: NumClusters() = %d, want %d", name, got, tc.clusters)
+		}
+		want := float64(len(tc.dict.codes)) / float64(tc.clusters)
+		if got := tc.dict.Coverage(); got != want || got > 1 {
+			t.Errorf("%s: Coverage() = %v, want %v", name, got, want)
+		}
+
+		data, err := tc.dict.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Dict[int64]
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if got.NumClusters() != tc.clusters {
+			t.Errorf("%s: NumClusters() after UnmarshalBinary() = %d, want %d", name, got.NumClusters(), tc.clusters)
+		}
+	}
+
+	// A Word mode dictionary has an exact code for each of the distinct
+	// values of a small sample, but not a Byte mode one.
+	small := randomInt64s(rng, 10000, 1000)
+	if d := NewDict(Word, small); d.Coverage() != 1 {
+		t.Errorf("Word mode Coverage() = %v, want 1", d.Coverage())
+	}
+	if d := NewDict(Byte, small); d.Coverage() >= 1 {
+		t.Errorf("Byte mode Coverage() = %v, want less than 1", d.Coverage())
+	}
+
+	for name, d := range map[string]Dict[int64]{
+		"empty": NewDict(Byte, []int64(nil)),
+		"zero":  {},
+	} {
+		if !math.IsNaN(d.Coverage()) {
+			t.Errorf("%s: Coverage() = %v, want NaN", name, d.Coverage())
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dict := NewDict(Byte, []int{1, 2, 3, 4, 5})
+	dict = dict.Recount([]int{0, 1, 1, 3, 4, 4, 6})
+
+	compact := dict.Compact()
+	if err := compact.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := compact.codes, []int{1, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Compact() representatives = %v, want %v", got, want)
+	}
+
+	// The sample's values keep their exact codes, and every code counts the
+	// values the original codes it merges counted.
+	want := []int{1, 2, 0, 1, 0, 2, 1}
+	for i, w := range want {
+		if got, _ := compact.SampleCount(Code(i + 1)); got != w {
+			t.Errorf("compacted SampleCount(%d) = %d, want %d", i+1, got, w)
+		}
+	}
+
+	if got := dict.Compact(); !reflect.DeepEqual(got.codes, compact.codes) {
+		t.Errorf("Compact() isn't deterministic")
+	}
+
+	unknown := NewDict(Byte, []int{1, 2})
+	unknown.counts = nil
+	if got := unknown.Compact(); !reflect.DeepEqual(got, unknown) {
+		t.Errorf("Compact() without counts = %v, want %v", got, unknown)
+	}
+}
+
+func TestCompactPreservesOrder(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, eytz := range []bool{false, true} {
+		dict := NewDict(Word, randomInt64s(rng, 1000, 5000))
+		if eytz {
+			dict.EnableEytzinger()
+		}
+
+		// Recounting over a narrower sample leaves most representatives
+		// without exact matches.
+		sample := randomInt64s(rng, 1000, 2000)
+		recounted := dict.Recount(sample)
+		compact := recounted.Compact()
+		if err := compact.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if compact.Len() >= dict.Len() {
+			t.Errorf("Compact() kept %d of %d representatives", compact.Len(), dict.Len())
+		}
+
+		// Compacting maps the codes of the original dictionary to its own
+		// monotonically, and sample values keep exact codes.
+		probes := append(randomInt64s(rng, 1000, 6000), sample...)
+		sort.Slice(probes, func(i, j int) bool { return probes[i] < probes[j] })
+		for i := 1; i < len(probes); i++ {
+			if compact.Encode(probes[i-1]) > compact.Encode(probes[i]) {
+				t.Fatalf("Compact() codes %d above %d", probes[i-1], probes[i])
+			}
+		}
+		for _, v := range sample {
+			if got, want := compact.Encode(v).IsExact(), dict.Encode(v).IsExact(); got != want {
+				t.Fatalf("Compact() coded sample value %d exactly: %v, want %v", v, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package colsketch
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// AppendSketch appends the rows of other to the sketch, along with their
+// deletions, e.g. to assemble the sketches of row groups built in parallel
+// into that of a whole column: row i of other becomes row Len()+i of the
+// sketch. Both sketches must have been encoded with the same dictionary, or
+// with dictionaries of the same fingerprint, and a sketch built WithNulls
+// may only be appended to another one; an error is returned otherwise,
+// without appending anything. A sketch may be appended to itself.
+//
+// Appending takes time proportional to the size of other. The codes are
+// copied in bulk if both sketches store them the same way, i.e. with or
+// without WithBitPacking, and one at a time otherwise. Block headers are
+// copied too if the sketch ends on a block boundary and both have the same
+// block size, and recomputed from the codes of the blocks they cover
+// otherwise, as are the masks of a sketch built WithPresenceMasks. The
+// sketch's inverted index, if any, is dropped; see BuildCodeIndex. The
+// checksums of a sketch built WithChecksums are computed for the blocks the
+// rows fill.
+func (s *Sketch[T]) AppendSketch(other *Sketch[T]) error {
+	if s.dict != other.dict && s.dict.Fingerprint() != other.dict.Fingerprint() {
+		return errors.New("colsketch: sketches encoded with different dictionaries")
+	}
+	if other.nulls && !s.nulls {
+		return errors.New("colsketch: appending a sketch built WithNulls to one without")
+	}
+
+	// Take other's state before modifying the sketch, which may be other.
+	n, m := s.store.len(), other.store.len()
+	bounds, presence, sameBlocks := other.bounds, other.presence, other.blockSize == s.blockSize
+	deleted, numDeleted := other.deleted, other.numDeleted
+	if other == s {
+		deleted = append([]uint64(nil), deleted...)
+	}
+
+	if !s.store.appendStore(other.store) {
+		var buf [1024]Code
+		for i := 0; i < m; {
+			batch := buf[:0]
+			for ; i < m && len(batch) < len(buf); i++ {
+				batch = append(batch, other.store.get(i))
+			}
+			s.store.appendAll(batch)
+		}
+	}
+
+	first := n / s.blockSize
+	if n%s.blockSize == 0 && sameBlocks {
+		s.bounds = append(s.bounds, bounds...)
+		switch {
+		case s.presence != nil && presence != nil:
+			s.presence = append(s.presence, presence...)
+		case s.presence != nil:
+			for i := first; i < len(s.bounds); i++ {
+				s.presence = append(s.presence, s.blockMask(i, false))
+			}
+		}
+	} else {
+		s.bounds = append(s.bounds[:first], make([]blockBounds, (n+m+s.blockSize-1)/s.blockSize-first)...)
+		if s.presence != nil {
+			s.presence = append(s.presence[:first], make([]codeMask, len(s.bounds)-first)...)
+		}
+		for i := first; i < len(s.bounds); i++ {
+			s.rescanBlock(i)
+		}
+	}
+
+	if numDeleted > 0 {
+		s.appendDeleted(n, m, deleted)
+		s.numDeleted += numDeleted
+	}
+	if s.checksums != nil {
+		// The checksum of the block the rows were appended to changes, and
+		// other's blocks may not line up with the sketch's.
+		if len(s.checksums) > first {
+			s.checksums = s.checksums[:first]
+		}
+		s.updateChecksums()
+	}
+	s.index = nil
+	return nil
+}
+
+// appendDeleted merges the tombstones of m rows appended at position n into
+// those of the sketch, and recounts the deletions of the blocks they cover.
+func (s *Sketch[T]) appendDeleted(n, m int, deleted []uint64) {
+	words := (n + m + 63) / 64
+	for len(s.deleted) < words {
+		s.deleted = append(s.deleted, 0)
+	}
+
+	if off := uint(n % 64); off == 0 {
+		copy(s.deleted[n/64:], deleted)
+	} else {
+		for w, x := range deleted {
+			if n/64+w >= len(s.deleted) {
+				break
+			}
+			s.deleted[n/64+w] |= x << off
+			if i := n/64 + w + 1; i < len(s.deleted) {
+				s.deleted[i] |= x >> (64 - off)
+			}
+		}
+	}
+
+	first := n / s.blockSize
+	for len(s.blockDeleted) < len(s.bounds) {
+		s.blockDeleted = append(s.blockDeleted, 0)
+	}
+	for i := first; i < len(s.blockDeleted); i++ {
+		s.blockDeleted[i] = 0
+	}
+	for w := first * s.blockSize / 64; w < len(s.deleted); w++ {
+		s.blockDeleted[64*w/s.blockSize] += bits.OnesCount64(s.deleted[w])
+	}
+}
@@ -0,0 +1,107 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSketchAppendSketch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+		// A copy of the dictionary has the same fingerprint.
+		same := NewDict(mode, dict.codes)
+
+		for _, opts := range [][]SketchOption{
+			nil,
+			{WithBlockSize(64)},
+			{WithBlockSize(128), WithBitPacking()},
+		} {
+			for _, otherOpts := range [][]SketchOption{
+				opts,
+				{WithBlockSize(64), WithBitPacking()},
+				{WithBlockSize(192)},
+			} {
+				want, layout := NewSketch(&dict, opts...), NewSketch(&same, otherOpts...)
+				name := fmt.Sprintf("mode %s, block size %d, %d bits, appending block size %d, %d bits", mode,
+					want.BlockSize(), want.BitsPerCode(), layout.BlockSize(), layout.BitsPerCode())
+
+				// Build the sketch in parts of random lengths, some of them
+				// aligned to blocks or words, deleting random rows of each.
+				got := NewSketch(&dict, opts...)
+				for _, n := range []int{100, 0, 64, 500, 1, 3 * want.BlockSize(), 77} {
+					values := randomInt64s(rng, n, 12000)
+					part := NewSketch(&same, otherOpts...)
+					part.AppendValues(values)
+					want.AppendValues(values)
+					for i := 0; i < n/5; i++ {
+						pos := rng.Intn(n)
+						part.Delete(pos)
+						want.Delete(want.Len() - n + pos)
+					}
+
+					if err := got.AppendSketch(part); err != nil {
+						t.Fatalf("%s: AppendSketch() = %v", name, err)
+					}
+					testSameSketch(t, name, got, want)
+				}
+
+				for i := 0; i < 20; i++ {
+					p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+					var gotPos, wantPos []int
+					got.Scan(p, func(pos int) bool { gotPos = append(gotPos, pos); return true })
+					want.Scan(p, func(pos int) bool { wantPos = append(wantPos, pos); return true })
+					if !reflect.DeepEqual(gotPos, wantPos) {
+						t.Fatalf("%s, %+v: Scan() visited %v, want %v", name, p, gotPos, wantPos)
+					}
+					gc, gd := got.Count(p)
+					if wc, wd := want.Count(p); gc != wc || gd != wd {
+						t.Fatalf("%s, %+v: Count() = %d, %d, want %d, %d", name, p, gc, gd, wc, wd)
+					}
+				}
+
+				// Append the sketch to itself.
+				if err := want.AppendSketch(want); err != nil {
+					t.Fatalf("%s: AppendSketch() of itself = %v", name, err)
+				}
+				if want.Len() != 2*got.Len() || want.LiveLen() != 2*got.LiveLen() {
+					t.Fatalf("%s: sketch appended to itself has %d rows, %d live", name, want.Len(), want.LiveLen())
+				}
+				for i := 0; i < got.Len(); i++ {
+					j := got.Len() + i
+					if want.Get(j) != got.Get(i) || want.IsDeleted(j) != got.IsDeleted(i) {
+						t.Fatalf("%s: row %d of sketch appended to itself is %d, deleted %v, want %d, deleted %v",
+							name, j, want.Get(j), want.IsDeleted(j), got.Get(i), got.IsDeleted(i))
+					}
+				}
+				for i := 0; i < want.NumBlocks(); i++ {
+					b, s := want.Block(i), want.Slice(want.Block(i).Start, want.Block(i).End)
+					if b.Deleted != s.Len()-s.LiveLen() {
+						t.Fatalf("%s: block %d of sketch appended to itself has %d deleted rows, want %d", name, i, b.Deleted, s.Len()-s.LiveLen())
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSketchAppendSketchDifferentDicts(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 1000, 1000))
+	other := NewDict(Byte, randomInt64s(rng, 1000, 2000))
+
+	s := NewSketch(&dict)
+	s.AppendValues([]int64{1, 2, 3})
+	o := NewSketch(&other)
+	o.AppendValues([]int64{4, 5})
+
+	if err := s.AppendSketch(o); err == nil {
+		t.Errorf("AppendSketch() of a sketch of another dictionary succeeded")
+	}
+	if s.Len() != 3 || s.NumBlocks() != 1 {
+		t.Errorf("failed AppendSketch() modified the sketch: %d rows, %d blocks", s.Len(), s.NumBlocks())
+	}
+}
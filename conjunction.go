@@ -0,0 +1,266 @@
+package colsketch
+
+import (
+	"cmp"
+	"fmt"
+	"math/bits"
+)
+
+// ScanAll is like ScanCertain for the conjunction of predicates over the
+// sketch's column, e.g. Ge(a), Lt(b) and Neq(c) for "v >= a AND v < b AND v
+// != c", in a single pass over the codes rather than a pass per predicate.
+// It visits the rows that are candidates of every predicate, as Definite if
+// they definitely match every predicate and as Candidate otherwise, which
+// is what intersecting the rows and certainties of ScanCertain with each
+// predicate would. The conjunction of no predicates holds for every row.
+//
+// The predicates are compiled against the dictionary once, into the
+// intersection of their ranges of codes and the codes of Neq operands
+// excluded from it. Blocks that any predicate rules out are skipped, and
+// codes are compared a word at a time, first against the range and then
+// against each excluded code, stopping as soon as none are left.
+func (s *Sketch[T]) ScanAll(preds []Predicate[T], visit func(pos int, c Certainty) bool) {
+	fs := make([]codeFilter, len(preds))
+	dfs := make([]codeFilter, len(preds))
+	for i, p := range preds {
+		fs[i], dfs[i] = p.candidates(s.dict), p.definite(s.dict)
+	}
+	f, df := conjoin(fs), conjoin(dfs)
+	if f.empty() {
+		return
+	}
+	// Exact operands make most predicates definite wherever they may match.
+	same := f.equal(&df)
+
+	n := s.store.len()
+	for i := 0; i*s.blockSize < n; i++ {
+		if f.rulesOut(s.bounds[i]) || s.isDead(i) || s.rulesOutAny(i, fs) {
+			continue
+		}
+
+		start, end := s.blockRows(i, 0, n)
+		if !df.empty() && df.rulesIn(s.bounds[i]) {
+			for pos := start; pos < end; pos++ {
+				if !s.IsDeleted(pos) && !visit(pos, Definite) {
+					return
+				}
+			}
+			continue
+		}
+
+		// Compare whole words of codes from the word boundary at or before
+		// start, as ScanCertain does.
+		blockEnd := s.blockEnd(i)
+		for pos := start &^ 63; pos < end; pos += 64 {
+			k := blockEnd - pos
+			if k > 64 {
+				k = 64
+			}
+
+			m := f.mask(s.store, pos, k) &^ s.deletedWord(pos/64) & windowMask(pos, start, end)
+			var definite uint64
+			switch {
+			case same:
+				definite = m
+			case m != 0 && !df.empty():
+				definite = df.mask(s.store, pos, k)
+			}
+			for ; m != 0; m &= m - 1 {
+				j := bits.TrailingZeros64(m)
+				if !visit(pos+j, Certainty(definite>>uint(j)&1)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// rulesOutAny returns true iff any of the filters rules out the i-th block,
+// which catches the blocks the presence masks of a single code filter rule
+// out, but the bounds of a conjunction don't.
+func (s *Sketch[T]) rulesOutAny(i int, fs []codeFilter) bool {
+	if s.presence == nil {
+		return false
+	}
+	for _, f := range fs {
+		if s.rulesOut(i, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// conjunction is the conjunction of codeFilters: the codes c with
+// lo <= c <= hi, except for those of ex, which are all within the range.
+// Unlike a codeFilter, it may exclude any number of codes. It is empty,
+// with lo > hi, if no code passes it.
+type conjunction struct {
+	lo, hi Code
+	ex     []Code
+}
+
+// conjoin returns the conjunction of filters, which passes every code if
+// there are none.
+func conjoin(fs []codeFilter) conjunction {
+	c := conjunction{lo: 0, hi: ^Code(0)}
+	for _, f := range fs {
+		if f.empty() {
+			return conjunction{lo: 1, hi: 0}
+		}
+		if f.lo > c.lo {
+			c.lo = f.lo
+		}
+		if f.hi < c.hi {
+			c.hi = f.hi
+		}
+	}
+
+	excluded := func(code Code) bool {
+		for _, f := range fs {
+			if f.except && f.ex == code {
+				return true
+			}
+		}
+		return false
+	}
+	// Narrow the range past excluded codes at its ends, so that a range of
+	// excluded codes alone is empty, without wrapping around either end of
+	// the code space.
+	for c.lo <= c.hi && excluded(c.lo) {
+		if c.lo == c.hi {
+			return conjunction{lo: 1, hi: 0}
+		}
+		c.lo++
+	}
+	for c.lo < c.hi && excluded(c.hi) {
+		c.hi--
+	}
+
+	for _, f := range fs {
+		if f.except && c.lo < f.ex && f.ex < c.hi && !c.excludes(f.ex) {
+			c.ex = append(c.ex, f.ex)
+		}
+	}
+	return c
+}
+
+// empty returns true iff no code passes the conjunction.
+func (c *conjunction) empty() bool {
+	return c.lo > c.hi
+}
+
+// equal returns true iff the conjunctions pass the same codes.
+func (c *conjunction) equal(o *conjunction) bool {
+	if c.empty() || o.empty() {
+		return c.empty() && o.empty()
+	}
+	if c.lo != o.lo || c.hi != o.hi || len(c.ex) != len(o.ex) {
+		return false
+	}
+	for _, ex := range c.ex {
+		if !o.excludes(ex) {
+			return false
+		}
+	}
+	return true
+}
+
+// excludes returns true iff the code is one of the excluded codes.
+func (c *conjunction) excludes(code Code) bool {
+	for _, ex := range c.ex {
+		if ex == code {
+			return true
+		}
+	}
+	return false
+}
+
+// rulesOut returns true iff no code within a block's bounds passes the
+// conjunction. Blocks of a single excluded code are ruled out too.
+func (c *conjunction) rulesOut(b blockBounds) bool {
+	return b.max < c.lo || b.min > c.hi || (b.min == b.max && c.excludes(b.min))
+}
+
+// rulesIn returns true iff every code within a block's bounds passes the
+// conjunction.
+func (c *conjunction) rulesIn(b blockBounds) bool {
+	if c.lo > b.min || b.max > c.hi {
+		return false
+	}
+	for _, ex := range c.ex {
+		if b.min <= ex && ex <= b.max {
+			return false
+		}
+	}
+	return true
+}
+
+// mask is rangeMask for the codes passing the conjunction, which stops
+// comparing codes against excluded ones once no code is left.
+func (c *conjunction) mask(st codeStore, start, n int) uint64 {
+	m := st.rangeMask(start, n, c.lo, c.hi)
+	for _, ex := range c.ex {
+		if m == 0 {
+			break
+		}
+		m &^= st.rangeMask(start, n, ex, ex)
+	}
+	return m
+}
+
+// ScanAnd is Scan for the conjunction of predicates over two columns, e.g.
+// `country = 'DE' AND price > 100`, sketched by sketches of the same rows:
+// it visits the rows that are candidates of both p1 in s1 and p2 in s2, in
+// increasing order, until visit returns false. It walks both sketches'
+// codes in lock-step, a word of rows at a time, rather than materializing
+// the candidates of each to intersect them, and skips the blocks of rows
+// that either sketch's headers rule out, whatever the other's block sizes.
+// Rows deleted from either sketch aren't visited. It returns an error,
+// without visiting any row, if the sketches have different lengths.
+func ScanAnd[T, U cmp.Ordered](s1 *Sketch[T], p1 Predicate[T], s2 *Sketch[U], p2 Predicate[U], visit func(pos int) bool) error {
+	n := s1.store.len()
+	if m := s2.store.len(); m != n {
+		return fmt.Errorf("colsketch: ScanAnd of sketches of %d and %d rows", n, m)
+	}
+
+	f1, f2 := p1.candidates(s1.dict), p2.candidates(s2.dict)
+	if f1.empty() || f2.empty() {
+		return nil
+	}
+
+	for pos := 0; pos < n; {
+		i1, i2 := pos/s1.blockSize, pos/s2.blockSize
+		if s1.rulesOut(i1, f1) || s1.isDead(i1) {
+			pos = (i1 + 1) * s1.blockSize
+			continue
+		}
+		if s2.rulesOut(i2, f2) || s2.isDead(i2) {
+			pos = (i2 + 1) * s2.blockSize
+			continue
+		}
+
+		// Block sizes are multiples of 64, so words of rows never straddle
+		// blocks of either sketch.
+		end := s1.blockEnd(i1)
+		if e := s2.blockEnd(i2); e < end {
+			end = e
+		}
+		for ; pos < end; pos += 64 {
+			k := end - pos
+			if k > 64 {
+				k = 64
+			}
+
+			m := f1.mask(s1.store, pos, k) &^ s1.deletedWord(pos/64)
+			if m != 0 {
+				m &= f2.mask(s2.store, pos, k) &^ s2.deletedWord(pos/64)
+			}
+			for ; m != 0; m &= m - 1 {
+				if !visit(pos + bits.TrailingZeros64(m)) {
+					return nil
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSketchScanAll(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	both := []Mode{Byte, Word}
+	for _, tc := range []struct {
+		opts  []SketchOption
+		modes []Mode
+	}{
+		{nil, both},
+		{[]SketchOption{WithBlockSize(64)}, both},
+		{[]SketchOption{WithBitPacking(), WithBlockSize(128)}, both},
+		{[]SketchOption{WithRunLength(), WithBlockSize(64)}, both},
+		{[]SketchOption{WithPresenceMasks(), WithBlockSize(64), WithNulls()}, []Mode{Byte}},
+	} {
+		for _, mode := range tc.modes {
+			dict := NewDict(mode, randomInt64s(rng, 500, 1000))
+			s := NewSketch(&dict, tc.opts...)
+
+			// Sorted runs make blocks with narrow bounds, for scans to skip
+			// and to rule in.
+			values := randomInt64s(rng, 5000, 1200)
+			for i := 0; i < len(values); i += 1000 {
+				run := values[i : i+500]
+				sort.Slice(run, func(i, j int) bool { return run[i] < run[j] })
+			}
+			for i, v := range values {
+				if s.Nullable() && i%97 == 0 {
+					s.AppendNull()
+					continue
+				}
+				s.Append(v)
+			}
+			for i := 0; i < 300; i++ {
+				s.Delete(rng.Intn(s.Len()))
+			}
+
+			operand := func() int64 { return rng.Int63n(1202) - 1 }
+			for i := 0; i < 300; i++ {
+				preds := make([]Predicate[int64], rng.Intn(5))
+				for j := range preds {
+					switch rng.Intn(8) {
+					case 0:
+						preds[j] = Neq(dict.codes[rng.Intn(len(dict.codes))])
+					case 1:
+						preds[j] = IsNotNull[int64]()
+					default:
+						preds[j] = randomPredicate(rng, operand)
+					}
+				}
+				testScanAll(t, s, preds)
+			}
+			testScanAll(t, s, []Predicate[int64]{IsNull[int64]()})
+			testScanAll(t, s, []Predicate[int64]{IsNull[int64](), IsNotNull[int64]()})
+		}
+	}
+}
+
+// testScanAll checks ScanAll against intersecting the bitmaps of
+// ScanBitmapDefinite with each predicate.
+func testScanAll(t *testing.T, s *Sketch[int64], preds []Predicate[int64]) {
+	t.Helper()
+
+	words := (s.Len() + 63) / 64
+	candidates, definite := make([]uint64, words), make([]uint64, words)
+	for i := range candidates {
+		candidates[i], definite[i] = ^uint64(0), ^uint64(0)
+	}
+	for _, p := range preds {
+		c, d := s.ScanBitmapDefinite(p, nil, nil)
+		for i := range candidates {
+			candidates[i] &= c[i]
+			definite[i] &= d[i]
+		}
+	}
+
+	var want []int
+	var wantCertain []Certainty
+	for pos := 0; pos < s.Len(); pos++ {
+		if s.IsDeleted(pos) || candidates[pos/64]>>(pos%64)&1 == 0 {
+			continue
+		}
+		want = append(want, pos)
+		wantCertain = append(wantCertain, Certainty(definite[pos/64]>>(pos%64)&1))
+	}
+
+	var got []int
+	var gotCertain []Certainty
+	s.ScanAll(preds, func(pos int, c Certainty) bool {
+		got = append(got, pos)
+		gotCertain = append(gotCertain, c)
+		return true
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%+v: ScanAll() visited %v, want %v", preds, got, want)
+	}
+	if !reflect.DeepEqual(gotCertain, wantCertain) {
+		t.Fatalf("%+v: ScanAll() certainties %v, want %v", preds, gotCertain, wantCertain)
+	}
+
+	// Scans stop as soon as visit returns false.
+	if len(want) > 1 {
+		stop := len(want) / 2
+		var n int
+		s.ScanAll(preds, func(pos int, c Certainty) bool {
+			n++
+			return pos != want[stop]
+		})
+		if n != stop+1 {
+			t.Fatalf("%+v: ScanAll() visited %d rows after stopping at the %d-th", preds, n, stop+1)
+		}
+	}
+}
+
+func TestConjoin(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fs   []codeFilter
+		want conjunction
+	}{
+		{"none", nil, conjunction{lo: 0, hi: ^Code(0)}},
+		{"ranges", []codeFilter{{lo: 3, hi: 9}, {lo: 1, hi: 7}}, conjunction{lo: 3, hi: 7}},
+		{"disjoint", []codeFilter{{lo: 1, hi: 3}, {lo: 5, hi: 7}}, conjunction{lo: 5, hi: 3}},
+		{"empty", []codeFilter{{lo: 1, hi: 9}, emptyFilter}, conjunction{lo: 1, hi: 0}},
+		{"excepts", []codeFilter{{lo: 1, hi: 9, ex: 4, except: true}, {lo: 1, hi: 9, ex: 6, except: true}, {lo: 1, hi: 9, ex: 4, except: true}},
+			conjunction{lo: 1, hi: 9, ex: []Code{4, 6}}},
+		{"ends", []codeFilter{{lo: 2, hi: 6}, {lo: 1, hi: 9, ex: 2, except: true}, {lo: 1, hi: 9, ex: 6, except: true}},
+			conjunction{lo: 3, hi: 5}},
+		{"excluded", []codeFilter{{lo: 4, hi: 4}, {lo: 1, hi: 9, ex: 4, except: true}}, conjunction{lo: 1, hi: 0}},
+		{"top", []codeFilter{{lo: ^Code(0), hi: ^Code(0)}, {lo: 0, hi: ^Code(0), ex: ^Code(0), except: true}}, conjunction{lo: 1, hi: 0}},
+	} {
+		got := conjoin(tc.fs)
+		if got.empty() != tc.want.empty() || (!got.empty() && !reflect.DeepEqual(got, tc.want)) {
+			t.Errorf("%s: conjoin() = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func BenchmarkSketchScanAll(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 100000, 1<<30))
+
+	// 100M codes, generated directly rather than encoded from values.
+	const n = 100_000_000
+	codes := make([]Code, n)
+	for i := range codes {
+		codes[i] = Code(1 + rng.Intn(dict.TotalCodes()))
+	}
+	s := NewSketch(&dict)
+	s.AppendCodes(codes)
+	codes = nil
+
+	// "v >= a AND v < b AND v != c".
+	a, c, e := dict.codes[40], dict.codes[80], dict.codes[120]
+	preds := []Predicate[int64]{Ge(a), Lt(e), Neq(c)}
+
+	b.Run("ScanAll", func(b *testing.B) {
+		b.SetBytes(int64(s.Len()))
+		for i := 0; i < b.N; i++ {
+			k := 0
+			s.ScanAll(preds, func(int, Certainty) bool {
+				k++
+				return true
+			})
+			codeSink = Code(k)
+		}
+	})
+	// The same classification from a bitmap of candidates and one of
+	// definite rows per predicate, intersected.
+	b.Run("ScanBitmapDefinite", func(b *testing.B) {
+		var candidates, definite, c, d []uint64
+		b.SetBytes(int64(s.Len()))
+		for i := 0; i < b.N; i++ {
+			candidates, definite = s.ScanBitmapDefinite(preds[0], candidates, definite)
+			for _, p := range preds[1:] {
+				c, d = s.ScanBitmapDefinite(p, c, d)
+				for j := range candidates {
+					candidates[j] &= c[j]
+					definite[j] &= d[j]
+				}
+			}
+			k := 0
+			visit := func(int, Certainty) bool {
+				k++
+				return true
+			}
+			for j, w := range candidates {
+				for ; w != 0; w &= w - 1 {
+					bit := bits.TrailingZeros64(w)
+					visit(64*j+bit, Certainty(definite[j]>>uint(bit)&1))
+				}
+			}
+			codeSink = Code(k)
+		}
+	})
+}
+
+func TestScanAnd(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, tc := range []struct {
+		opts1, opts2 []SketchOption
+	}{
+		{nil, nil},
+		{[]SketchOption{WithBlockSize(64)}, []SketchOption{WithBlockSize(192)}},
+		{[]SketchOption{WithBitPacking(), WithBlockSize(128)}, []SketchOption{WithRunLength(), WithBlockSize(64)}},
+		{[]SketchOption{WithPresenceMasks(), WithBlockSize(64)}, []SketchOption{WithBlockSize(256)}},
+	} {
+		ints := NewDict(Byte, randomInt64s(rng, 500, 1000))
+		strs := NewDict(Word, randomStrings(rng, 500, 1000))
+		s1, s2 := NewSketch(&ints, tc.opts1...), NewSketch(&strs, tc.opts2...)
+
+		// Sorted runs make blocks with narrow bounds, for scans to skip.
+		const n = 5000
+		values := randomInt64s(rng, n, 1200)
+		for i := 0; i < n; i += 1000 {
+			run := values[i : i+500]
+			sort.Slice(run, func(i, j int) bool { return run[i] < run[j] })
+		}
+		s1.AppendValues(values)
+		s2.AppendValues(randomStrings(rng, n, 1200))
+		for i := 0; i < 100; i++ {
+			s1.Delete(rng.Intn(n))
+			s2.Delete(rng.Intn(n))
+		}
+
+		for i := 0; i < 200; i++ {
+			p1 := randomPredicate(rng, func() int64 { return rng.Int63n(1202) - 1 })
+			p2 := randomPredicate(rng, func() string { return randomStrings(rng, 1, 1202)[0] })
+
+			// The candidates of both predicates, visited by scanning each
+			// sketch.
+			in1, in2 := make([]bool, n), make([]bool, n)
+			s1.Scan(p1, func(pos int) bool {
+				in1[pos] = true
+				return true
+			})
+			s2.Scan(p2, func(pos int) bool {
+				in2[pos] = true
+				return true
+			})
+			var want []int
+			for pos := range in1 {
+				if in1[pos] && in2[pos] && !s1.IsDeleted(pos) && !s2.IsDeleted(pos) {
+					want = append(want, pos)
+				}
+			}
+
+			var got []int
+			if err := ScanAnd(s1, p1, s2, p2, func(pos int) bool {
+				got = append(got, pos)
+				return true
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%+v, %+v: ScanAnd() visited %v, want %v", p1, p2, got, want)
+			}
+
+			// Scans stop as soon as visit returns false.
+			if len(want) > 1 {
+				stop := len(want) / 2
+				var k int
+				ScanAnd(s1, p1, s2, p2, func(pos int) bool {
+					k++
+					return pos != want[stop]
+				})
+				if k != stop+1 {
+					t.Fatalf("%+v, %+v: ScanAnd() visited %d rows after stopping at the %d-th", p1, p2, k, stop+1)
+				}
+			}
+		}
+	}
+
+	ints := NewDict(Byte, []int64{1, 2, 3})
+	s1, s2 := NewSketch(&ints), NewSketch(&ints)
+	s1.AppendValues([]int64{1, 2, 3})
+	s2.AppendValues([]int64{1, 2})
+	visited := false
+	if err := ScanAnd(s1, Ge(int64(0)), s2, Ge(int64(0)), func(int) bool {
+		visited = true
+		return true
+	}); err == nil || visited {
+		t.Errorf("ScanAnd() of sketches of different lengths = %v, visited rows: %v", err, visited)
+	}
+}
+
+func BenchmarkScanAnd(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 24
+
+	// "country = 'DE' AND price > 100", over columns of 64 countries and of
+	// prices up to 1000.
+	countries := make([]string, 64)
+	for i := range countries {
+		countries[i] = fmt.Sprintf("%c%c", 'A'+i/8, 'A'+i%8)
+	}
+	country := make([]string, n)
+	for i := range country {
+		country[i] = countries[rng.Intn(len(countries))]
+	}
+	price := randomInt64s(rng, n, 1000)
+
+	cdict, pdict := NewDict(Byte, country[:100000]), NewDict(Byte, price[:100000])
+	s1, s2 := NewSketch(&cdict), NewSketch(&pdict)
+	s1.AppendValues(country)
+	s2.AppendValues(price)
+	p1, p2 := Eq(countries[27]), Gt(int64(100))
+
+	b.Run("ScanAnd", func(b *testing.B) {
+		b.SetBytes(2 * n)
+		for i := 0; i < b.N; i++ {
+			k := 0
+			ScanAnd(s1, p1, s2, p2, func(int) bool {
+				k++
+				return true
+			})
+			codeSink = Code(k)
+		}
+	})
+	// A bitmap of the candidates of each sketch, intersected.
+	b.Run("ScanBitmap", func(b *testing.B) {
+		var dst1, dst2 []uint64
+		b.SetBytes(2 * n)
+		for i := 0; i < b.N; i++ {
+			dst1, dst2 = s1.ScanBitmap(p1, dst1), s2.ScanBitmap(p2, dst2)
+			k := 0
+			visit := func(int) bool {
+				k++
+				return true
+			}
+			for j, w := range dst1 {
+				for w &= dst2[j]; w != 0; w &= w - 1 {
+					visit(64*j + bits.TrailingZeros64(w))
+				}
+			}
+			codeSink = Code(k)
+		}
+	})
+}
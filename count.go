@@ -0,0 +1,49 @@
+package colsketch
+
+// Count returns the number of rows Scan would visit for a predicate, and
+// how many of them ScanCertain would classify as Definite, without
+// materializing their positions: e.g. to estimate the selectivity of a
+// predicate or to plan a LIMIT. It counts both in a single pass over the
+// codes, comparing a word of codes at a time and summing the lanes in range
+// without gathering them into a bitmap, and counts blocks whose headers rule
+// them in or out without reading their codes. Deleted rows are counted out
+// by their tombstones.
+func (s *Sketch[T]) Count(p Predicate[T]) (candidates, definite int) {
+	return s.countRows(0, s.store.len(), p)
+}
+
+// countRows is Count over the rows `[from, to)`.
+func (s *Sketch[T]) countRows(from, to int, p Predicate[T]) (candidates, definite int) {
+	f := p.candidates(s.dict)
+	if f.empty() {
+		return 0, 0
+	}
+	df := p.definite(s.dict)
+	if positions, ok := s.indexed(f); ok {
+		n := len(indexedRows(positions, from, to))
+		return n, n * int(b2u(df.contains(f.lo)))
+	}
+
+	for i := from / s.blockSize; i*s.blockSize < to; i++ {
+		b := s.bounds[i]
+		start, end := s.blockRows(i, from, to)
+
+		var c, d int
+		switch {
+		case s.rulesOut(i, f) || s.isDead(i):
+			continue
+		case df.rulesIn(b):
+			c, d = end-start, end-start
+		default:
+			c, d = f.count(s.store, start, end, df)
+		}
+
+		if s.blockDeletions(i) > 0 {
+			dc, dd := s.countDeleted(start, end, f, df)
+			c, d = c-dc, d-dd
+		}
+		candidates += c
+		definite += d
+	}
+	return candidates, definite
+}
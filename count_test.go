@@ -0,0 +1,88 @@
+package colsketch
+
+import (
+	"math/bits"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSketchCount(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]SketchOption{
+		nil,
+		{WithBlockSize(64)},
+		{WithBitPacking(), WithBlockSize(128)},
+	} {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+			s := NewSketch(&dict, opts...)
+
+			values := randomInt64s(rng, 10000+rng.Intn(64), 12000)
+			sort.Slice(values[:5000], func(i, j int) bool { return values[i] < values[j] })
+			s.AppendCodes(dict.EncodeAll(values, nil))
+
+			for i := 0; i < 200; i++ {
+				p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+
+				var wantCandidates, wantDefinite int
+				s.ScanCertain(p, func(pos int, c Certainty) bool {
+					wantCandidates++
+					wantDefinite += int(c)
+					return true
+				})
+
+				candidates, definite := s.Count(p)
+				if candidates != wantCandidates || definite != wantDefinite {
+					t.Fatalf("%+v: Count() = %d, %d, want %d, %d", p, candidates, definite, wantCandidates, wantDefinite)
+				}
+			}
+		}
+	}
+
+	var empty Dict[int]
+	if c, d := NewSketch(&empty).Count(Eq(1)); c != 0 || d != 0 {
+		t.Errorf("Count() of an empty sketch = %d, %d", c, d)
+	}
+}
+
+func BenchmarkSketchCount(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 100000, 1<<30))
+	s := NewSketch(&dict)
+	s.AppendCodes(dict.EncodeAll(randomInt64s(rng, 1<<20, 1<<30), nil))
+
+	// Inexact operands leave the codes holding them as candidates, which
+	// Count must tell apart from the definite codes between them.
+	lo, _ := dict.Value(40)
+	hi, _ := dict.Value(80)
+	for _, bc := range []struct {
+		name string
+		p    Predicate[int64]
+	}{
+		{"Exact", Between(lo, hi)},
+		{"Inexact", Between(lo+1, hi+1)},
+	} {
+		p := bc.p
+		b.Run(bc.name+"/Count", func(b *testing.B) {
+			b.SetBytes(int64(s.Len()))
+			for i := 0; i < b.N; i++ {
+				n, _ := s.Count(p)
+				codeSink = Code(n)
+			}
+		})
+		b.Run(bc.name+"/ScanBitmap", func(b *testing.B) {
+			b.SetBytes(int64(s.Len()))
+			dst := s.ScanBitmap(p, nil)
+			for i := 0; i < b.N; i++ {
+				dst = s.ScanBitmap(p, dst)
+				n := 0
+				for _, w := range dst {
+					n += bits.OnesCount64(w)
+				}
+				codeSink = Code(n)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Delete marks the row at position i as deleted, e.g. to mirror a deletion
+// in the table the sketch indexes, so that scans and counts no longer
+// report it as a candidate. The row keeps its position, and its code, which
+// Get still returns, so the positions of the rows after it don't change.
+// Deleting a deleted row does nothing. It panics if i is out of range.
+//
+// Deletions are recorded as tombstones in a bitmap of a bit per row,
+// allocated on the first deletion, and counted per block, so that blocks
+// whose rows are all deleted are skipped by their headers.
+func (s *Sketch[T]) Delete(i int) {
+	if n := s.store.len(); i < 0 || i >= n {
+		panic(fmt.Sprintf("colsketch: index %d out of range [0:%d]", i, n))
+	}
+	if s.IsDeleted(i) {
+		return
+	}
+
+	for len(s.deleted) <= i/64 {
+		s.deleted = append(s.deleted, 0)
+	}
+	for len(s.blockDeleted) <= i/s.blockSize {
+		s.blockDeleted = append(s.blockDeleted, 0)
+	}
+	s.indexRemove(i, s.store.get(i))
+	s.deleted[i/64] |= 1 << uint(i%64)
+	s.blockDeleted[i/s.blockSize]++
+	s.numDeleted++
+}
+
+// IsDeleted returns true iff the row at position i was deleted. It returns
+// false for positions out of range.
+func (s *Sketch[T]) IsDeleted(i int) bool {
+	return i >= 0 && s.deletedWord(i/64)>>uint(i%64)&1 != 0
+}
+
+// LiveLen returns the number of rows of the sketch that weren't deleted,
+// out of the Len rows it holds.
+func (s *Sketch[T]) LiveLen() int {
+	return s.store.len() - s.numDeleted
+}
+
+// deletedIn returns the number of deleted rows within `[start, end)`.
+func (s *Sketch[T]) deletedIn(start, end int) int {
+	n := 0
+	for w := start / 64; w < len(s.deleted) && 64*w < end; w++ {
+		n += bits.OnesCount64(s.deleted[w] & windowMask(64*w, start, end))
+	}
+	return n
+}
+
+// deletedWord returns the w-th word of the tombstones, whose bits stand for
+// the rows `[64*w, 64*w+64)`. Rows appended since the last deletion are
+// beyond the tombstones, and live.
+func (s *Sketch[T]) deletedWord(w int) uint64 {
+	if w >= len(s.deleted) {
+		return 0
+	}
+	return s.deleted[w]
+}
+
+// blockDeletions returns the number of deleted rows in the i-th block.
+func (s *Sketch[T]) blockDeletions(i int) int {
+	if i >= len(s.blockDeleted) {
+		return 0
+	}
+	return s.blockDeleted[i]
+}
+
+// countDeleted returns the numbers of deleted rows in `[start, end)` whose
+// codes pass the filters f and df, for subtracting from counts of all rows.
+// It visits the tombstones rather than the codes, so it is cheap for rows
+// with few deletions.
+func (s *Sketch[T]) countDeleted(start, end int, f, df codeFilter) (n, nd int) {
+	for w := start / 64; w < len(s.deleted) && 64*w < end; w++ {
+		m := s.deleted[w]
+		if 64*w < start {
+			m &= ^uint64(0) << uint(start-64*w)
+		}
+		if 64*w+64 > end {
+			m &= ^uint64(0) >> uint(64*w+64-end)
+		}
+
+		for ; m != 0; m &= m - 1 {
+			c := s.store.get(64*w + bits.TrailingZeros64(m))
+			n += int(b2u(f.contains(c)))
+			nd += int(b2u(df.contains(c)))
+		}
+	}
+	return n, nd
+}
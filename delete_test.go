@@ -0,0 +1,190 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSketchDelete(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+
+		for _, opts := range [][]SketchOption{
+			nil,
+			{WithBlockSize(64)},
+			{WithBlockSize(128), WithBitPacking()},
+		} {
+			s := NewSketch(&dict, opts...)
+			name := fmt.Sprintf("mode %s, block size %d, %d bits", mode, s.BlockSize(), s.BitsPerCode())
+
+			values := randomInt64s(rng, 3*s.BlockSize()+rng.Intn(64), 12000)
+			sort.Slice(values[:s.BlockSize()], func(i, j int) bool { return values[i] < values[j] })
+			s.AppendCodes(dict.EncodeAll(values, nil))
+
+			// Delete every row of the second block, and random others, some
+			// twice.
+			deleted := make([]bool, len(values))
+			for i := s.BlockSize(); i < 2*s.BlockSize(); i++ {
+				deleted[i] = true
+			}
+			for i := 0; i < len(values)/4; i++ {
+				deleted[rng.Intn(len(values))] = true
+			}
+			for i, d := range deleted {
+				if d {
+					s.Delete(i)
+					if rng.Intn(4) == 0 {
+						s.Delete(i)
+					}
+				}
+			}
+
+			// Rows appended after deletions are beyond the tombstones.
+			more := randomInt64s(rng, 100, 12000)
+			for _, v := range more {
+				s.Append(v)
+			}
+			values = append(values, more...)
+			deleted = append(deleted, make([]bool, len(more))...)
+
+			live := 0
+			for i, d := range deleted {
+				if got := s.IsDeleted(i); got != d {
+					t.Fatalf("%s: IsDeleted(%d) = %v, want %v", name, i, got, d)
+				}
+				live += int(b2u(!d))
+			}
+			if got := s.LiveLen(); got != live || s.Len() != len(values) {
+				t.Fatalf("%s: Len(), LiveLen() = %d, %d, want %d, %d", name, s.Len(), got, len(values), live)
+			}
+			for i := 0; i < s.NumBlocks(); i++ {
+				b, want := s.Block(i), 0
+				for pos := b.Start; pos < b.End; pos++ {
+					want += int(b2u(deleted[pos]))
+				}
+				if b.Deleted != want {
+					t.Fatalf("%s: block %d has %d deleted rows, want %d", name, i, b.Deleted, want)
+				}
+			}
+			if got := s.Block(1); got.Deleted != got.Len() {
+				t.Fatalf("%s: block 1 has %d of %d rows deleted", name, got.Deleted, got.Len())
+			}
+
+			for i := 0; i < 100; i++ {
+				p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+				testDeletedScans(t, name, s, deleted, p)
+			}
+
+			for i := 0; i < 20; i++ {
+				lo := Code(rng.Intn(int(s.store.maxCode()) + 1))
+				hi := lo + Code(rng.Intn(int(s.store.maxCode()-lo)+1))
+				want := 0
+				for pos, d := range deleted {
+					if c := s.Get(pos); !d && lo <= c && c <= hi {
+						want++
+					}
+				}
+				if got := s.CountRange(lo, hi); got != want {
+					t.Fatalf("%s: CountRange(%d, %d) = %d, want %d", name, lo, hi, got, want)
+				}
+			}
+
+			for _, pos := range []int{-1, len(values)} {
+				func() {
+					defer func() {
+						if recover() == nil {
+							t.Errorf("%s: Delete(%d) out of range didn't panic", name, pos)
+						}
+					}()
+					s.Delete(pos)
+				}()
+			}
+		}
+	}
+}
+
+// testDeletedScans checks every form of scan of a sketch with deleted rows
+// against the live rows whose codes may and must match the predicate.
+func testDeletedScans(t *testing.T, name string, s *Sketch[int64], deleted []bool, p Predicate[int64]) {
+	t.Helper()
+
+	words := (s.Len() + 63) / 64
+	wantBitmap, wantDefinite := make([]uint64, words), make([]uint64, words)
+	var want []int
+	var wantCertain []Certainty
+	for pos, d := range deleted {
+		iv, _ := s.Dict().Bounds(s.Get(pos))
+		if d || !mayMatch(iv, p) {
+			continue
+		}
+		want = append(want, pos)
+		wantBitmap[pos/64] |= 1 << (pos % 64)
+		c := Certainty(b2u(mustMatch(iv, p)))
+		wantCertain = append(wantCertain, c)
+		wantDefinite[pos/64] |= uint64(c) << (pos % 64)
+	}
+	numDefinite := 0
+	for _, c := range wantCertain {
+		numDefinite += int(c)
+	}
+
+	var got []int
+	s.Scan(p, func(pos int) bool {
+		got = append(got, pos)
+		return true
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%s, %+v: Scan() visited %v, want %v", name, p, got, want)
+	}
+
+	got = got[:0]
+	var certain []Certainty
+	s.ScanCertain(p, func(pos int, c Certainty) bool {
+		got = append(got, pos)
+		certain = append(certain, c)
+		return true
+	})
+	if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(certain, wantCertain) {
+		t.Fatalf("%s, %+v: ScanCertain() visited %v, %v, want %v, %v", name, p, got, certain, want, wantCertain)
+	}
+
+	bitmap, definite := s.ScanBitmapDefinite(p, nil, nil)
+	if !reflect.DeepEqual(bitmap, wantBitmap) || !reflect.DeepEqual(definite, wantDefinite) {
+		t.Fatalf("%s, %+v: ScanBitmapDefinite() = %#x, %#x, want %#x, %#x", name, p, bitmap, definite, wantBitmap, wantDefinite)
+	}
+	all := make([]uint64, words)
+	for i := range all {
+		all[i] = ^uint64(0)
+	}
+	if got := s.ScanBitmapAnd(p, all); !reflect.DeepEqual(got, wantBitmap) {
+		t.Fatalf("%s, %+v: ScanBitmapAnd() = %#x, want %#x", name, p, got, wantBitmap)
+	}
+
+	var adder bitsetAdder
+	s.ScanInto(p, 0, &adder)
+	adder.words = append(adder.words, make([]uint64, words)...)[:words]
+	if !reflect.DeepEqual(adder.words, wantBitmap) {
+		t.Fatalf("%s, %+v: ScanInto() = %#x, want %#x", name, p, adder.words, wantBitmap)
+	}
+
+	var sel []uint32
+	got = got[:0]
+	for start := 0; start < s.Len(); start += 100 {
+		sel = s.ScanSelection(p, start, 100, sel)
+		for _, off := range sel {
+			got = append(got, start+int(off))
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%s, %+v: ScanSelection() selected %v, want %v", name, p, got, want)
+	}
+
+	if c, d := s.Count(p); c != len(want) || d != numDefinite {
+		t.Fatalf("%s, %+v: Count() = %d, %d, want %d, %d", name, p, c, d, len(want), numDefinite)
+	}
+}
@@ -0,0 +1,80 @@
+package colsketch
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// DictDiff describes how the representatives of one dictionary differ from
+// those of another. See Dict.Diff.
+type DictDiff[T cmp.Ordered] struct {
+	// Representatives of the other dictionary that aren't in this one.
+	Added []T
+
+	// Representatives of this dictionary that aren't in the other one.
+	Removed []T
+
+	// Representatives of both dictionaries that have different codes in each.
+	Changed []CodeChange[T]
+
+	// Compatible is true iff both dictionaries encode every representative
+	// of either one identically, meaning codes persisted with one can be
+	// interpreted with the other.
+	Compatible bool
+}
+
+// CodeChange records a representative whose exact code differs between two
+// dictionaries.
+type CodeChange[T cmp.Ordered] struct {
+	Value    T
+	Old, New Code
+}
+
+// Diff compares the dictionary with other, which is typically a rebuilt
+// version of it, in a single merge walk over both sets of representatives.
+func (d *Dict[T]) Diff(other Dict[T]) DictDiff[T] {
+	var diff DictDiff[T]
+
+	i, j := 0, 0
+	for i < len(d.codes) || j < len(other.codes) {
+		switch {
+		case j == len(other.codes) || (i < len(d.codes) && cmp.Less(d.codes[i], other.codes[j])):
+			diff.Removed = append(diff.Removed, d.codes[i])
+			i++
+		case i == len(d.codes) || cmp.Less(other.codes[j], d.codes[i]):
+			diff.Added = append(diff.Added, other.codes[j])
+			j++
+		default:
+			if i != j {
+				diff.Changed = append(diff.Changed, CodeChange[T]{d.codes[i], Code(2 * (i + 1)), Code(2 * (j + 1))})
+			}
+			i++
+			j++
+		}
+	}
+
+	// An added or removed representative is exactly coded by only one of the
+	// dictionaries, and a moved one by different codes, so compatibility
+	// comes down to there being no differences at all.
+	diff.Compatible = len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0
+
+	return diff
+}
+
+// String renders the diff one representative per line: "+v" for added,
+// "-v" for removed and "~v 0x0004 -> 0x0006" for changed representatives.
+func (dd DictDiff[T]) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "compatible: %v\n", dd.Compatible)
+	for _, v := range dd.Added {
+		fmt.Fprintf(&sb, "+%v\n", v)
+	}
+	for _, v := range dd.Removed {
+		fmt.Fprintf(&sb, "-%v\n", v)
+	}
+	for _, c := range dd.Changed {
+		fmt.Fprintf(&sb, "~%v %s -> %s\n", c.Value, c.Old, c.New)
+	}
+	return sb.String()
+}
@@ -0,0 +1,69 @@
+package colsketch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		old, new []int
+		want     DictDiff[int]
+		str      string
+	}{
+		{
+			name: "identical",
+			old:  []int{1, 2, 3},
+			new:  []int{3, 2, 1},
+			want: DictDiff[int]{Compatible: true},
+			str:  "compatible: true\n",
+		},
+		{
+			name: "disjoint",
+			old:  []int{1, 2},
+			new:  []int{3, 4},
+			want: DictDiff[int]{Added: []int{3, 4}, Removed: []int{1, 2}},
+			str:  "compatible: false\n+3\n+4\n-1\n-2\n",
+		},
+		{
+			name: "single value shift",
+			old:  []int{10, 20, 30},
+			new:  []int{5, 10, 20, 30},
+			want: DictDiff[int]{
+				Added: []int{5},
+				Changed: []CodeChange[int]{
+					{10, 2, 4},
+					{20, 4, 6},
+					{30, 6, 8},
+				},
+			},
+			str: "compatible: false\n+5\n~10 0x0002 -> 0x0004\n~20 0x0004 -> 0x0006\n~30 0x0006 -> 0x0008\n",
+		},
+		{
+			name: "removal at end",
+			old:  []int{10, 20, 30},
+			new:  []int{10, 20},
+			want: DictDiff[int]{Removed: []int{30}},
+			str:  "compatible: false\n-30\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			old, new := NewDict(Byte, tc.old), NewDict(Byte, tc.new)
+			got := old.Diff(new)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tc.want)
+			}
+			if got.String() != tc.str {
+				t.Errorf("String() = %q, want %q", got.String(), tc.str)
+			}
+
+			// Changed codes must be the actual codes in each dictionary.
+			for _, c := range got.Changed {
+				if old.Encode(c.Value) != c.Old || new.Encode(c.Value) != c.New {
+					t.Errorf("change %+v disagrees with Encode", c)
+				}
+			}
+		})
+	}
+}
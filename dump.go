@@ -0,0 +1,327 @@
+package colsketch
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// DumpMode selects how BinaryDump encodes a dictionary's representatives.
+type DumpMode uint8
+
+const (
+	// DumpRaw writes integer and float representatives little-endian at
+	// their natural width, and strings prefixed by their length.
+	DumpRaw DumpMode = iota
+
+	// DumpVarint writes integer representatives as varints, zig-zag encoded
+	// if signed, so that small magnitudes take few bytes. Floats and strings
+	// are written as with DumpRaw.
+	DumpVarint
+
+	// DumpDelta writes the first integer representative as with DumpVarint,
+	// and every other one as the uvarint difference from the one before it,
+	// so that closely spaced representatives take few bytes whatever their
+	// magnitude. Floats and strings are written as with DumpRaw.
+	DumpDelta
+)
+
+// IsValid returns true iff the dump mode is one of DumpRaw, DumpVarint and
+// DumpDelta.
+func (m DumpMode) IsValid() bool {
+	return m <= DumpDelta
+}
+
+// String returns the name of the dump mode.
+func (m DumpMode) String() string {
+	switch m {
+	case DumpRaw:
+		return "DumpRaw"
+	case DumpVarint:
+		return "DumpVarint"
+	case DumpDelta:
+		return "DumpDelta"
+	default:
+		return fmt.Sprintf("DumpMode(%d)", uint8(m))
+	}
+}
+
+// The dump format of a dictionary is:
+//
+//	magic    [4]byte  "CSDD"
+//	version  uint8    dumpFormatVersion
+//	dumpMode uint8    the DumpMode of the values
+//	mode     uint8    Byte or Word
+//	kind     uint8    the valueKind of T
+//	count    uvarint  number of representatives
+//	values   ...      count representatives in increasing order
+//
+// Unlike the format of MarshalBinary, it holds no sample counts.
+const (
+	dumpMagic         = "CSDD"
+	dumpFormatVersion = 1
+)
+
+// BinaryDump returns the dictionary's mode and representatives in a compact
+// binary format, with the representatives encoded as the dump mode says.
+// DumpDelta suits integer dictionaries best, since representatives are
+// sorted, and those of samples of clustered or uniform values are closely
+// spaced: e.g. the representatives of a sample of Unix timestamps take a
+// byte or two each rather than eight. Sample counts aren't dumped; use
+// MarshalBinary to keep them. It panics if the dump mode isn't valid.
+func (d *Dict[T]) BinaryDump(mode DumpMode) []byte {
+	if !mode.IsValid() {
+		panic(fmt.Sprintf("colsketch: invalid dump mode %d", uint8(mode)))
+	}
+
+	k := kindOf[T]()
+	b := append([]byte(dumpMagic), dumpFormatVersion, byte(mode), byte(d.mode), byte(k))
+	b = binary.AppendUvarint(b, uint64(len(d.codes)))
+
+	if mode == DumpRaw || !k.isInteger() {
+		for _, v := range d.codes {
+			b = appendValue(b, k, v)
+		}
+		return b
+	}
+
+	var prev uint64
+	signed := k.isSigned()
+	for i, x := range integerBits(k, d.codes) {
+		switch {
+		case mode == DumpDelta && i > 0:
+			// Representatives increase, so differences are positive, even
+			// when they wrap around as uint64s.
+			b = binary.AppendUvarint(b, x-prev)
+		case signed:
+			b = binary.AppendVarint(b, int64(x))
+		default:
+			b = binary.AppendUvarint(b, x)
+		}
+		prev = x
+	}
+	return b
+}
+
+// isInteger returns true iff k is a signed or unsigned integer kind.
+func (k valueKind) isInteger() bool {
+	return k >= kindInt && k <= kindUintptr
+}
+
+// isSigned returns true iff k is a signed integer kind.
+func (k valueKind) isSigned() bool {
+	return k >= kindInt && k <= kindInt64
+}
+
+// integerBits returns the bits of integer values of kind k, sign-extended to
+// 64 if k is signed. The values are read as k's underlying type, once for
+// all of them rather than through reflection for each.
+func integerBits[T cmp.Ordered](k valueKind, values []T) []uint64 {
+	xs := make([]uint64, len(values))
+	if len(values) == 0 {
+		return xs
+	}
+
+	p := unsafe.Pointer(unsafe.SliceData(values))
+	switch k {
+	case kindInt:
+		widen[int](xs, p)
+	case kindInt8:
+		widen[int8](xs, p)
+	case kindInt16:
+		widen[int16](xs, p)
+	case kindInt32:
+		widen[int32](xs, p)
+	case kindInt64:
+		widen[int64](xs, p)
+	case kindUint:
+		widen[uint](xs, p)
+	case kindUint8:
+		widen[uint8](xs, p)
+	case kindUint16:
+		widen[uint16](xs, p)
+	case kindUint32:
+		widen[uint32](xs, p)
+	case kindUint64:
+		widen[uint64](xs, p)
+	case kindUintptr:
+		widen[uintptr](xs, p)
+	default:
+		panic(fmt.Sprintf("colsketch: integerBits of %s values", k))
+	}
+	return xs
+}
+
+// widen stores the len(xs) values of type E at p into xs.
+func widen[E Integer](xs []uint64, p unsafe.Pointer) {
+	for i, v := range unsafe.Slice((*E)(p), len(xs)) {
+		xs[i] = uint64(v)
+	}
+}
+
+// ReconstructFromBinaryDump replaces the dictionary with the one dumped in
+// data by BinaryDump in the given dump mode, which must be the one the
+// data's header records. It rejects data dumped from a dictionary of a
+// different value type, and data that fails Validate, leaving the
+// dictionary unmodified on error. The reconstructed dictionary has no
+// sample counts, and searches its representatives by binary search until
+// EnableEytzinger is called again.
+func (d *Dict[T]) ReconstructFromBinaryDump(data []byte, dumpMode DumpMode) error {
+	b := data
+	if len(b) < len(dumpMagic)+4 {
+		return errTruncated
+	}
+	if string(b[:len(dumpMagic)]) != dumpMagic {
+		return errors.New("colsketch: not a dictionary dump")
+	}
+	b = b[len(dumpMagic):]
+
+	if version := b[0]; version != dumpFormatVersion {
+		return fmt.Errorf("colsketch: unsupported dictionary dump version %d", version)
+	}
+	if got := DumpMode(b[1]); got != dumpMode {
+		return fmt.Errorf("colsketch: dump in mode %s, not %s", got, dumpMode)
+	}
+	if !dumpMode.IsValid() {
+		return fmt.Errorf("colsketch: invalid dump mode %d", uint8(dumpMode))
+	}
+	mode := Mode(b[2])
+	if !mode.IsValid() {
+		return fmt.Errorf("colsketch: invalid mode %d", mode)
+	}
+	k := valueKind(b[3])
+	if want := kindOf[T](); k != want {
+		return fmt.Errorf("colsketch: cannot decode %s dictionary into Dict[%T]", k, *new(T))
+	}
+	b = b[4:]
+
+	n, w := binary.Uvarint(b)
+	if w <= 0 {
+		return errTruncated
+	}
+	b = b[w:]
+
+	// Every value takes at least a byte, so don't trust the count for
+	// preallocation beyond what the input could hold.
+	if n > uint64(mode.NumExactCodes()) || n > uint64(len(b)) {
+		return fmt.Errorf("colsketch: invalid representative count %d", n)
+	}
+
+	dec := Dict[T]{mode: mode, codes: make([]T, n)}
+	if dumpMode == DumpRaw || !k.isInteger() {
+		for i := range dec.codes {
+			var err error
+			if dec.codes[i], b, err = readValue[T](b, k); err != nil {
+				return err
+			}
+		}
+	} else {
+		var err error
+		if b, err = readIntegers(b, k, dumpMode, dec.codes); err != nil {
+			return err
+		}
+	}
+	if len(b) > 0 {
+		return fmt.Errorf("colsketch: %d trailing bytes after dictionary dump", len(b))
+	}
+
+	if err := dec.Validate(); err != nil {
+		return err
+	}
+	*d = dec
+	return nil
+}
+
+// readIntegers decodes len(dst) integer representatives of kind k, dumped
+// in the given dump mode, from the front of b into dst, returning the rest
+// of b.
+func readIntegers[T cmp.Ordered](b []byte, k valueKind, dumpMode DumpMode, dst []T) ([]byte, error) {
+	xs := make([]uint64, len(dst))
+	signed := k.isSigned()
+	var prev uint64
+	for i := range xs {
+		var x uint64
+		switch {
+		case dumpMode == DumpDelta && i > 0:
+			delta, w := binary.Uvarint(b)
+			if w <= 0 {
+				return nil, errTruncated
+			}
+			x, b = prev+delta, b[w:]
+		case signed:
+			v, w := binary.Varint(b)
+			if w <= 0 {
+				return nil, errTruncated
+			}
+			x, b = uint64(v), b[w:]
+		default:
+			v, w := binary.Uvarint(b)
+			if w <= 0 {
+				return nil, errTruncated
+			}
+			x, b = v, b[w:]
+		}
+		xs[i], prev = x, x
+	}
+
+	if i := integersFromBits(k, xs, dst); i >= 0 {
+		if signed {
+			return nil, fmt.Errorf("colsketch: value %d overflows %T", int64(xs[i]), dst[i])
+		}
+		return nil, fmt.Errorf("colsketch: value %d overflows %T", xs[i], dst[i])
+	}
+	return b, nil
+}
+
+// integersFromBits is the inverse of integerBits, storing the values into
+// dst. It returns the index of the first value whose bits don't fit k, or
+// -1 if they all do.
+func integersFromBits[T cmp.Ordered](k valueKind, xs []uint64, dst []T) int {
+	if len(xs) == 0 {
+		return -1
+	}
+
+	p := unsafe.Pointer(unsafe.SliceData(dst))
+	switch k {
+	case kindInt:
+		return narrow[int](xs, p)
+	case kindInt8:
+		return narrow[int8](xs, p)
+	case kindInt16:
+		return narrow[int16](xs, p)
+	case kindInt32:
+		return narrow[int32](xs, p)
+	case kindInt64:
+		return narrow[int64](xs, p)
+	case kindUint:
+		return narrow[uint](xs, p)
+	case kindUint8:
+		return narrow[uint8](xs, p)
+	case kindUint16:
+		return narrow[uint16](xs, p)
+	case kindUint32:
+		return narrow[uint32](xs, p)
+	case kindUint64:
+		return narrow[uint64](xs, p)
+	case kindUintptr:
+		return narrow[uintptr](xs, p)
+	default:
+		panic(fmt.Sprintf("colsketch: integersFromBits of %s values", k))
+	}
+}
+
+// narrow stores xs as values of type E at p, returning the index of the
+// first that doesn't fit E, or -1 if they all do.
+func narrow[E Integer](xs []uint64, p unsafe.Pointer) int {
+	dst := unsafe.Slice((*E)(p), len(xs))
+	for i, x := range xs {
+		// Widening back recovers x iff it fits, for signed E as sign-extended
+		// bits.
+		if dst[i] = E(x); uint64(dst[i]) != x {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,187 @@
+package colsketch
+
+import (
+	"bytes"
+	"cmp"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestBinaryDump(t *testing.T) {
+	dict := NewDict(Word, []int64{300, -1, 5})
+	header := []byte{'C', 'S', 'D', 'D', dumpFormatVersion, 0, byte(Word), byte(kindInt64), 3}
+
+	for _, tc := range []struct {
+		mode   DumpMode
+		values []byte
+	}{
+		{DumpRaw, []byte{
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			5, 0, 0, 0, 0, 0, 0, 0,
+			0x2c, 1, 0, 0, 0, 0, 0, 0,
+		}},
+		// Zig-zag encoded -1, 5 and 300.
+		{DumpVarint, []byte{1, 10, 0xd8, 4}},
+		// -1, then the differences 6 and 295.
+		{DumpDelta, []byte{1, 6, 0xa7, 2}},
+	} {
+		header[5] = byte(tc.mode)
+		want := append(append([]byte(nil), header...), tc.values...)
+		if got := dict.BinaryDump(tc.mode); !bytes.Equal(got, want) {
+			t.Errorf("BinaryDump(%s) = %x, want %x", tc.mode, got, want)
+		}
+	}
+
+	// Floats and strings are dumped raw in every mode.
+	strs := NewDict(Byte, []string{"a", "bc"})
+	raw := strs.BinaryDump(DumpRaw)
+	for _, mode := range []DumpMode{DumpVarint, DumpDelta} {
+		got := strs.BinaryDump(mode)
+		if got[5] != byte(mode) || !bytes.Equal(got[6:], raw[6:]) {
+			t.Errorf("BinaryDump(%s) of strings = %x, want %x in mode %d", mode, got, raw[6:], mode)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("BinaryDump() in an invalid dump mode didn't panic")
+		}
+	}()
+	dict.BinaryDump(DumpDelta + 1)
+}
+
+func TestBinaryDumpSize(t *testing.T) {
+	// Closely spaced timestamps: deltas take a byte or two, varints more,
+	// and raw values eight bytes.
+	rng := rand.New(rand.NewSource(1))
+	sample := make([]int64, 10000)
+	for i := range sample {
+		sample[i] = 1700000000 + rng.Int63n(86400)
+	}
+	dict := NewDict(Word, sample)
+
+	raw, varint, delta := len(dict.BinaryDump(DumpRaw)), len(dict.BinaryDump(DumpVarint)), len(dict.BinaryDump(DumpDelta))
+	if !(delta < varint && varint < raw) {
+		t.Errorf("dump sizes of %d timestamps: raw %d, varint %d, delta %d", dict.NumExactCodes(), raw, varint, delta)
+	}
+	if n := dict.NumExactCodes(); delta > 2*n+16 {
+		t.Errorf("DumpDelta of %d timestamps took %d bytes", n, delta)
+	}
+}
+
+// testDumpRoundTrip checks that a dictionary reconstructs from its dumps in
+// every mode.
+func testDumpRoundTrip[T cmp.Ordered](t *testing.T, dict Dict[T]) {
+	t.Helper()
+	for _, mode := range []DumpMode{DumpRaw, DumpVarint, DumpDelta} {
+		var got Dict[T]
+		if err := got.ReconstructFromBinaryDump(dict.BinaryDump(mode), mode); err != nil {
+			t.Fatalf("%s, %d %T representatives: ReconstructFromBinaryDump() = %v", mode, dict.NumExactCodes(), *new(T), err)
+		}
+		if got.mode != dict.mode || got.NumExactCodes() != dict.NumExactCodes() ||
+			(len(got.codes) > 0 && !reflect.DeepEqual(got.codes, dict.codes)) {
+			t.Fatalf("%s: round trip = %s %v, want %s %v", mode, got.mode, got.codes, dict.mode, dict.codes)
+		}
+	}
+}
+
+func TestReconstructFromBinaryDump(t *testing.T) {
+	testDumpRoundTrip(t, Dict[int64]{})
+	testDumpRoundTrip(t, NewDict(Word, []int64{42}))
+	testDumpRoundTrip(t, NewDict(Byte, []int{-5, 0, 7, math.MaxInt, math.MinInt}))
+	testDumpRoundTrip(t, NewDict(Word, []int8{-128, -1, 0, 127}))
+	testDumpRoundTrip(t, NewDict(Byte, []int16{-300, 300}))
+	testDumpRoundTrip(t, NewDict(Byte, []int32{math.MinInt32, 1}))
+	testDumpRoundTrip(t, NewDict(Byte, []int64{math.MinInt64, math.MaxInt64}))
+	testDumpRoundTrip(t, NewDict(Byte, []uint{0, math.MaxUint}))
+	testDumpRoundTrip(t, NewDict(Byte, []uint8{0, 255}))
+	testDumpRoundTrip(t, NewDict(Byte, []uint16{0, 65535}))
+	testDumpRoundTrip(t, NewDict(Byte, []uint32{0, math.MaxUint32}))
+	testDumpRoundTrip(t, NewDict(Byte, []uint64{0, math.MaxUint64}))
+	testDumpRoundTrip(t, NewDict(Byte, []uintptr{0, 42}))
+	testDumpRoundTrip(t, NewDict(Byte, []float32{-1.5, 0, 3.25}))
+	testDumpRoundTrip(t, NewDict(Word, []float64{math.Inf(-1), -0.5, math.MaxFloat64}))
+	testDumpRoundTrip(t, NewDict(Byte, []string{"", "a", "zz"}))
+
+	// Integers of named types are read as their underlying types.
+	type celsius int16
+	testDumpRoundTrip(t, NewDict(Byte, []celsius{-40, 0, 100}))
+
+	// Dictionaries with as many representatives as their modes have exact
+	// codes, spread over the whole range of int64.
+	rng := rand.New(rand.NewSource(1))
+	for _, mode := range []Mode{Byte, Word} {
+		seen := map[int64]bool{math.MinInt64: true, math.MaxInt64: true}
+		for len(seen) < mode.NumExactCodes() {
+			seen[int64(rng.Uint64())] = true
+		}
+		dict := Dict[int64]{mode: mode}
+		for v := range seen {
+			dict.codes = append(dict.codes, v)
+		}
+		sort.Slice(dict.codes, func(i, j int) bool { return dict.codes[i] < dict.codes[j] })
+		testDumpRoundTrip(t, dict)
+	}
+}
+
+func TestReconstructFromBinaryDumpInvalid(t *testing.T) {
+	dict := NewDict(Byte, []int16{-300, 5, 300})
+	varint := dict.BinaryDump(DumpVarint)
+
+	var d Dict[int16]
+	if err := d.ReconstructFromBinaryDump(varint, DumpDelta); err == nil {
+		t.Errorf("ReconstructFromBinaryDump() in the wrong dump mode succeeded")
+	}
+	var d64 Dict[int64]
+	if err := d64.ReconstructFromBinaryDump(varint, DumpVarint); err == nil {
+		t.Errorf("ReconstructFromBinaryDump() into the wrong value type succeeded")
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("CSKD"), varint[4:]...),
+		"version":   withByte(varint, 4, dumpFormatVersion+1),
+		"mode":      withByte(varint, 6, 7),
+		"count":     withByte(varint, 8, 200),
+		"truncated": varint[:len(varint)-1],
+		"trailing":  append(append([]byte(nil), varint...), 0),
+	} {
+		if err := d.ReconstructFromBinaryDump(data, DumpVarint); err == nil {
+			t.Errorf("%s: ReconstructFromBinaryDump() succeeded", name)
+		}
+	}
+
+	// 300 doesn't fit an int8.
+	var d8 Dict[int8]
+	if err := d8.ReconstructFromBinaryDump(withByte(varint, 7, byte(kindInt8)), DumpVarint); err == nil {
+		t.Errorf("ReconstructFromBinaryDump() of values overflowing int8 succeeded")
+	}
+	d16 := NewDict(Byte, []uint16{5, 300})
+	unsigned := d16.BinaryDump(DumpDelta)
+	var du8 Dict[uint8]
+	if err := du8.ReconstructFromBinaryDump(withByte(unsigned, 7, byte(kindUint8)), DumpDelta); err == nil {
+		t.Errorf("ReconstructFromBinaryDump() of values overflowing uint8 succeeded")
+	}
+
+	// Representatives out of order fail validation.
+	unsorted := dict.BinaryDump(DumpRaw)
+	unsorted[9], unsorted[11] = unsorted[11], unsorted[9]
+	unsorted[10], unsorted[12] = unsorted[12], unsorted[10]
+	if err := d.ReconstructFromBinaryDump(unsorted, DumpRaw); err == nil {
+		t.Errorf("ReconstructFromBinaryDump() of unsorted representatives succeeded")
+	}
+
+	if d.NumExactCodes() != 0 {
+		t.Errorf("failed ReconstructFromBinaryDump() modified the dictionary")
+	}
+}
+
+// withByte returns a copy of b with the byte at i set to x.
+func withByte(b []byte, i int, x byte) []byte {
+	b = append([]byte(nil), b...)
+	b[i] = x
+	return b
+}
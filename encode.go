@@ -0,0 +1,250 @@
+package colsketch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// EncodeAll encodes each of the values into dst, reusing its storage when
+// it has capacity for len(values) codes, and returns the resulting slice of
+// codes: dst[i] == d.Encode(values[i]). It doesn't allocate if dst has
+// enough capacity, so encoding a column chunk by chunk can reuse one buffer:
+//
+//	for _, chunk := range chunks {
+//		buf = d.EncodeAll(chunk, buf)
+//		// Consume buf before the next chunk.
+//	}
+//
+// Values may be encoded in place, with dst reinterpreting the backing array
+// of values as codes, e.g. with unsafe.Slice, as long as T is a numeric type
+// at least as wide as a Code, such as uint16 or int64: values are encoded in
+// order, and each code is then written over values that have already been
+// encoded. Otherwise dst and values must not share memory. In particular,
+// codes are wider than int8 and uint8 values, so writing them in place
+// would overwrite values yet to be encoded, and string headers can't be
+// overwritten with codes without corrupting memory.
+func (d *Dict[T]) EncodeAll(values []T, dst []Code) []Code {
+	if cap(dst) < len(values) {
+		dst = make([]Code, len(values))
+	}
+	dst = dst[:len(values)]
+
+	if d.eytz == nil {
+		if s, ok := any(d.codes).([]int64); ok && encodeBatchInt64SIMD(s, any(values).([]int64), dst) {
+			return dst
+		}
+		if s, ok := any(d.codes).([]int32); ok && encodeBatchInt32SIMD(s, any(values).([]int32), dst) {
+			return dst
+		}
+		if s, ok := any(d.codes).([]float32); ok && encodeBatchFloat32SIMD(s, any(values).([]float32), dst) {
+			return dst
+		}
+		if len(d.codes) >= interleaveMinLen {
+			encodeInterleaved(d.codes, values, dst)
+			return dst
+		}
+	}
+
+	for i, v := range values {
+		dst[i] = d.Encode(v)
+	}
+	return dst
+}
+
+// EncodeAllParallel is like EncodeAll, but splits values into contiguous
+// chunks encoded concurrently by up to parallelism goroutines, each writing
+// its codes straight into its part of dst. A parallelism of 0 or less means
+// runtime.GOMAXPROCS(0). Inputs too small to amortize starting goroutines are
+// encoded serially, without allocating if dst has enough capacity. The
+// dictionary must not be modified concurrently, e.g. by EnableEytzinger.
+// Unlike with EncodeAll, dst and values must never share memory, since
+// chunks are encoded out of order.
+func (d *Dict[T]) EncodeAllParallel(values []T, dst []Code, parallelism int) []Code {
+	if cap(dst) < len(values) {
+		dst = make([]Code, len(values))
+	}
+	dst = dst[:len(values)]
+
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	if n := len(values) / parallelMinChunk; n < parallelism {
+		parallelism = n
+	}
+	if parallelism <= 1 {
+		return d.EncodeAll(values, dst)
+	}
+
+	d.encodeParallel(values, dst, parallelism)
+	return dst
+}
+
+// encodeParallel encodes values into dst, which must be as long, with
+// parallelism goroutines. It is split out of EncodeAllParallel so that the
+// state shared with the goroutines is only allocated when they are started.
+func (d *Dict[T]) encodeParallel(values []T, dst []Code, parallelism int) {
+	var wg sync.WaitGroup
+	chunk := (len(values) + parallelism - 1) / parallelism
+	for lo := 0; lo < len(values); lo += chunk {
+		hi := lo + chunk
+		if hi > len(values) {
+			hi = len(values)
+		}
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			d.EncodeAll(values[lo:hi], dst[lo:hi])
+		}(lo, hi)
+	}
+	wg.Wait()
+}
+
+// parallelMinChunk is the smallest number of values EncodeAllParallel hands
+// to a goroutine.
+const parallelMinChunk = 16 << 10
+
+// EncodeAllUnsafe is like EncodeAll, but its binary searches read the
+// representatives without bounds checks, which saves a compare and branch
+// per step of every search. Its results are identical to EncodeAll's; it is
+// "unsafe" only in that it relies on the searches' invariants rather than
+// on the runtime to stay within the representatives. Dictionaries for which
+// EncodeAll vectorizes or interleaves searches are encoded as by EncodeAll,
+// which is faster still.
+func (d *Dict[T]) EncodeAllUnsafe(values []T, dst []Code) []Code {
+	if d.eytz != nil || len(d.codes) >= interleaveMinLen {
+		return d.EncodeAll(values, dst)
+	}
+
+	if cap(dst) < len(values) {
+		dst = make([]Code, len(values))
+	}
+	dst = dst[:len(values)]
+
+	if s, ok := any(d.codes).([]int64); ok && encodeBatchInt64SIMD(s, any(values).([]int64), dst) {
+		return dst
+	}
+	if s, ok := any(d.codes).([]int32); ok && encodeBatchInt32SIMD(s, any(values).([]int32), dst) {
+		return dst
+	}
+	if s, ok := any(d.codes).([]float32); ok && encodeBatchFloat32SIMD(s, any(values).([]float32), dst) {
+		return dst
+	}
+	for i, v := range values {
+		dst[i] = encodeSortedUnchecked(d.codes, v)
+	}
+	return dst
+}
+
+// interleaveMinLen is the number of representatives from which EncodeAll
+// interleaves searches. Below it, the representatives stay in cache and
+// there are no misses to overlap.
+const interleaveMinLen = 4096
+
+// EncodeAllInterleaved is like EncodeAll, but always runs several searches
+// in lock-step so that their memory accesses overlap, which approaches
+// memory bandwidth rather than latency limits when encoding random values
+// against dictionaries larger than the CPU caches. EncodeAll already does so
+// for large dictionaries that don't use the Eytzinger layout.
+func (d *Dict[T]) EncodeAllInterleaved(values []T, dst []Code) []Code {
+	if cap(dst) < len(values) {
+		dst = make([]Code, len(values))
+	}
+	dst = dst[:len(values)]
+	encodeInterleaved(d.codes, values, dst)
+	return dst
+}
+
+// EncodeAllBytes is like EncodeAll, but writes one byte per code for
+// Byte-mode dictionaries, whose codes always fit in a byte. It saves callers
+// storing byte-wide sketches a conversion pass, and doesn't allocate if dst
+// has capacity for len(values) codes. It returns an error if the dictionary
+// isn't in Byte mode.
+func (d *Dict[T]) EncodeAllBytes(values []T, dst []uint8) ([]uint8, error) {
+	if d.mode != Byte {
+		return dst, fmt.Errorf("colsketch: EncodeAllBytes on a %s mode dictionary", d.mode)
+	}
+
+	if cap(dst) < len(values) {
+		dst = make([]uint8, len(values))
+	}
+	dst = dst[:len(values)]
+
+	for i, v := range values {
+		dst[i] = uint8(d.Encode(v))
+	}
+	return dst, nil
+}
+
+// EncodeReader encodes a column read from r, one value per line, as parsed
+// by parse, and returns the codes of its values in order. Lines are split
+// as by bufio.ScanLines, dropping a trailing carriage return, and may be at
+// most bufio.MaxScanTokenSize bytes long. The line passed to parse is only
+// valid until it returns, as it may be overwritten by the next read.
+//
+// On error, it returns the codes of the lines before the one that failed
+// along with the error, which tells the line's number, counting from 1, if
+// it failed to parse.
+func (d *Dict[T]) EncodeReader(r io.Reader, parse func([]byte) (T, error)) ([]Code, error) {
+	var codes []Code
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		v, err := parse(sc.Bytes())
+		if err != nil {
+			return codes, fmt.Errorf("colsketch: line %d: %w", len(codes)+1, err)
+		}
+		codes = append(codes, d.Encode(v))
+	}
+	return codes, sc.Err()
+}
+
+// EncodeSparse encodes a sparse column given as parallel slices of row
+// indices and values, as found in compressed-sparse-row layouts where null
+// rows have no value. It returns the indices unchanged alongside the codes of
+// the values. It panics if the slices have different lengths.
+func (d *Dict[T]) EncodeSparse(indices []int, values []T) ([]int, []Code) {
+	if len(indices) != len(values) {
+		panic("colsketch: EncodeSparse with mismatched indices and values")
+	}
+	return indices, d.EncodeAll(values, nil)
+}
+
+// EncodeRunLength encodes a sorted column into runs of identical codes,
+// returning each run's code along with its length. Since codes preserve
+// order, the end of each run is found with a binary search for the upper
+// bound of the run's code rather than by encoding every value, so long runs
+// are cheap. The result is only meaningful if sortedValues is sorted.
+func (d *Dict[T]) EncodeRunLength(sortedValues []T) (codes []Code, runLengths []int) {
+	for i := 0; i < len(sortedValues); {
+		c := d.Encode(sortedValues[i])
+		rest := sortedValues[i:]
+
+		var n int
+		switch iv, _ := d.Bounds(c); {
+		case iv.Exact:
+			n = upperBound(rest, iv.Lo)
+		case iv.HasHi:
+			n = lowerBound(rest, iv.Hi)
+		default:
+			n = len(rest)
+		}
+
+		codes = append(codes, c)
+		runLengths = append(runLengths, n)
+		i += n
+	}
+	return codes, runLengths
+}
+
+// ExpandRuns is the inverse of EncodeRunLength: it appends runLengths[i]
+// copies of codes[i] to dst for each run and returns the extended slice.
+func ExpandRuns(dst []Code, codes []Code, runLengths []int) []Code {
+	for i, c := range codes {
+		for j := 0; j < runLengths[i]; j++ {
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,74 @@
+package colsketch
+
+import (
+	"math"
+
+	"golang.org/x/sys/cpu"
+)
+
+// hasAVX2 reports whether the CPU and OS support the AVX2 instructions used
+// by encodeInt64sAVX2 and its siblings, along with POPCNT. It is a variable
+// so that tests can exercise the fallback.
+var hasAVX2 = cpu.X86.HasAVX2 && cpu.X86.HasPOPCNT
+
+// encodeBatchInt64SIMD encodes values against the sorted representatives s
+// into dst, which must be at least as long as values, with AVX2 if the CPU
+// supports it. It reports whether it did; if not, the caller must encode the
+// values itself.
+func encodeBatchInt64SIMD(s, values []int64, dst []Code) bool {
+	if !hasAVX2 || len(s) < avx2Window {
+		return false
+	}
+	encodeInt64sAVX2(s, values, dst[:len(values)])
+	return true
+}
+
+// encodeBatchInt32SIMD is encodeBatchInt64SIMD for int32s.
+func encodeBatchInt32SIMD(s, values []int32, dst []Code) bool {
+	if !hasAVX2 || len(s) < avx2Window {
+		return false
+	}
+	encodeInt32sAVX2(s, values, dst[:len(values)])
+	return true
+}
+
+// encodeBatchFloat32SIMD is encodeBatchInt64SIMD for float32s. It also
+// reports false for representatives encodeFloat32sAVX2 can't compare by
+// their bits: a NaN, which can only be the first, and -0, which cmp.Compare
+// holds equal to +0.
+func encodeBatchFloat32SIMD(s, values []float32, dst []Code) bool {
+	if !hasAVX2 || len(s) < avx2Window || s[0] != s[0] {
+		return false
+	}
+	if c := encodeSorted(s, 0); c.IsExact() && math.Signbit(float64(s[c/2-1])) {
+		return false
+	}
+	encodeFloat32sAVX2(s, values, dst[:len(values)])
+	return true
+}
+
+// avx2Window is the number of representatives encodeInt64sAVX2 compares a
+// value with at once, after narrowing the search down to them.
+const avx2Window = 16
+
+// encodeInt64sAVX2 is encodeSorted over each of values, storing codes in
+// dst. It runs a branch-free binary search until at most avx2Window
+// representatives are left, then compares the value with all of them at
+// once and counts those less than or equal to it. s must hold at least
+// avx2Window representatives.
+//
+//go:noescape
+func encodeInt64sAVX2(s, values []int64, dst []Code)
+
+// encodeInt32sAVX2 is encodeInt64sAVX2 for int32s, which fit twice as many
+// to a register.
+//
+//go:noescape
+func encodeInt32sAVX2(s, values []int32, dst []Code)
+
+// encodeFloat32sAVX2 is encodeInt64sAVX2 for float32s, which it compares
+// as int32 keys derived from their bits that order like them, with NaNs
+// before every other value and -0 equal to +0. s must not hold NaN nor -0.
+//
+//go:noescape
+func encodeFloat32sAVX2(s, values []float32, dst []Code)
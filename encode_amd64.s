@@ -0,0 +1,269 @@
+#include "textflag.h"
+
+// func encodeInt64sAVX2(s, values []int64, dst []Code)
+TEXT ·encodeInt64sAVX2(SB), NOSPLIT, $0-72
+	MOVQ s_base+0(FP), SI
+	MOVQ s_len+8(FP), R8
+	MOVQ values_base+24(FP), DI
+	MOVQ values_len+32(FP), R9
+	MOVQ dst_base+48(FP), R10
+	LEAQ -16(R8), R11 // The last position a window can start at.
+	XORQ R12, R12     // The position in values.
+
+next:
+	CMPQ R12, R9
+	JGE  done
+	MOVQ (DI)(R12*8), AX // The value.
+	XORQ BX, BX          // base
+	MOVQ R8, CX          // n
+
+	// Advance base past representatives less than or equal to the value
+	// while more than a window of them are left.
+search:
+	CMPQ    CX, $16
+	JLE     window
+	MOVQ    CX, DX
+	SHRQ    $1, DX
+	LEAQ    (BX)(DX*1), R13
+	CMPQ    (SI)(R13*8), AX
+	CMOVQLE R13, BX
+	SUBQ    DX, CX
+	JMP     search
+
+	// Count the representatives greater than the value in the window of 16
+	// starting at base, or ending at the last one if base is too close to it.
+window:
+	CMPQ         BX, R11
+	CMOVQGT      R11, BX
+	VMOVQ        AX, X0
+	VPBROADCASTQ X0, Y0
+	LEAQ         (SI)(BX*8), R13
+	VMOVDQU      0(R13), Y1
+	VMOVDQU      32(R13), Y2
+	VMOVDQU      64(R13), Y3
+	VMOVDQU      96(R13), Y4
+	VPCMPGTQ     Y0, Y1, Y1
+	VPCMPGTQ     Y0, Y2, Y2
+	VPCMPGTQ     Y0, Y3, Y3
+	VPCMPGTQ     Y0, Y4, Y4
+	VMOVMSKPD    Y1, DX
+	VMOVMSKPD    Y2, R14
+	SHLQ         $4, R14
+	ORQ          R14, DX
+	VMOVMSKPD    Y3, R14
+	SHLQ         $8, R14
+	ORQ          R14, DX
+	VMOVMSKPD    Y4, R14
+	SHLQ         $12, R14
+	ORQ          R14, DX
+	POPCNTQ      DX, DX
+
+	// If all of the window is greater, so is every representative.
+	CMPQ DX, $16
+	JEQ  below
+
+	// Otherwise the last representative less than or equal to the value is
+	// at position base+15-greater, and the code is exact if it's equal.
+	LEAQ    15(BX), R13
+	SUBQ    DX, R13
+	LEAQ    2(R13)(R13*1), R14
+	CMPQ    (SI)(R13*8), AX
+	SETNE   DL
+	MOVBQZX DL, DX
+	ADDQ    DX, R14
+	MOVW    R14, (R10)(R12*2)
+	INCQ    R12
+	JMP     next
+
+below:
+	MOVW $1, (R10)(R12*2)
+	INCQ R12
+	JMP  next
+
+done:
+	VZEROUPPER
+	RET
+
+// func encodeFloat32sAVX2(s, values []float32, dst []Code)
+//
+// Floats are compared by their bits as int32 keys, b^((b>>31)>>>1), which
+// flips the magnitude bits of negative floats so that keys order like the
+// floats they stand for.
+TEXT ·encodeFloat32sAVX2(SB), NOSPLIT, $0-72
+	MOVQ s_base+0(FP), SI
+	MOVQ s_len+8(FP), R8
+	MOVQ values_base+24(FP), DI
+	MOVQ values_len+32(FP), R9
+	MOVQ dst_base+48(FP), R10
+	LEAQ -16(R8), R11 // The last position a window can start at.
+	XORQ R12, R12     // The position in values.
+
+fnext:
+	CMPQ R12, R9
+	JGE  fdone
+	MOVL (DI)(R12*4), AX // The value's bits.
+
+	// NaNs order before every representative, none of which is NaN, and
+	// -0 is equal to +0.
+	MOVL    AX, DX
+	ANDL    $0x7fffffff, DX
+	CMPL    DX, $0x7f800000
+	JHI     fbelow
+	TESTL   DX, DX
+	CMOVLEQ DX, AX
+
+	// The value's key.
+	MOVL AX, DX
+	SARL $31, DX
+	SHRL $1, DX
+	XORL DX, AX
+
+	XORQ BX, BX // base
+	MOVQ R8, CX // n
+
+	// Advance base past representatives less than or equal to the value
+	// while more than a window of them are left.
+fsearch:
+	CMPQ    CX, $16
+	JLE     fwindow
+	MOVQ    CX, DX
+	SHRQ    $1, DX
+	LEAQ    (BX)(DX*1), R13
+	SUBQ    DX, CX
+	MOVL    (SI)(R13*4), R14
+	MOVL    R14, DX
+	SARL    $31, DX
+	SHRL    $1, DX
+	XORL    DX, R14
+	CMPL    R14, AX
+	CMOVQLE R13, BX
+	JMP     fsearch
+
+	// Count the representatives greater than the value in the window of 16
+	// starting at base, or ending at the last one if base is too close to it.
+fwindow:
+	CMPQ         BX, R11
+	CMOVQGT      R11, BX
+	VMOVD        AX, X0
+	VPBROADCASTD X0, Y0
+	LEAQ         (SI)(BX*4), R13
+	VMOVDQU      0(R13), Y1
+	VMOVDQU      32(R13), Y2
+	VPSRAD       $31, Y1, Y3
+	VPSRLD       $1, Y3, Y3
+	VPXOR        Y3, Y1, Y1
+	VPSRAD       $31, Y2, Y4
+	VPSRLD       $1, Y4, Y4
+	VPXOR        Y4, Y2, Y2
+	VPCMPGTD     Y0, Y1, Y1
+	VPCMPGTD     Y0, Y2, Y2
+	VMOVMSKPS    Y1, DX
+	VMOVMSKPS    Y2, R14
+	SHLQ         $8, R14
+	ORQ          R14, DX
+	POPCNTQ      DX, DX
+
+	// If all of the window is greater, so is every representative.
+	CMPQ DX, $16
+	JEQ  fbelow
+
+	// Otherwise the last representative less than or equal to the value is
+	// at position base+15-greater, and the code is exact if it's equal.
+	LEAQ    15(BX), R13
+	SUBQ    DX, R13
+	LEAQ    2(R13)(R13*1), R14
+	MOVL    (SI)(R13*4), DX
+	MOVL    DX, CX
+	SARL    $31, CX
+	SHRL    $1, CX
+	XORL    CX, DX
+	CMPL    DX, AX
+	SETNE   DL
+	MOVBQZX DL, DX
+	ADDQ    DX, R14
+	MOVW    R14, (R10)(R12*2)
+	INCQ    R12
+	JMP     fnext
+
+fbelow:
+	MOVW $1, (R10)(R12*2)
+	INCQ R12
+	JMP  fnext
+
+fdone:
+	VZEROUPPER
+	RET
+
+// func encodeInt32sAVX2(s, values []int32, dst []Code)
+TEXT ·encodeInt32sAVX2(SB), NOSPLIT, $0-72
+	MOVQ s_base+0(FP), SI
+	MOVQ s_len+8(FP), R8
+	MOVQ values_base+24(FP), DI
+	MOVQ values_len+32(FP), R9
+	MOVQ dst_base+48(FP), R10
+	LEAQ -16(R8), R11 // The last position a window can start at.
+	XORQ R12, R12     // The position in values.
+
+inext:
+	CMPQ R12, R9
+	JGE  idone
+	MOVL (DI)(R12*4), AX // The value.
+	XORQ BX, BX          // base
+	MOVQ R8, CX          // n
+
+	// Advance base past representatives less than or equal to the value
+	// while more than a window of them are left.
+isearch:
+	CMPQ    CX, $16
+	JLE     iwindow
+	MOVQ    CX, DX
+	SHRQ    $1, DX
+	LEAQ    (BX)(DX*1), R13
+	CMPL    (SI)(R13*4), AX
+	CMOVQLE R13, BX
+	SUBQ    DX, CX
+	JMP     isearch
+
+	// Count the representatives greater than the value in the window of 16
+	// starting at base, or ending at the last one if base is too close to it.
+iwindow:
+	CMPQ         BX, R11
+	CMOVQGT      R11, BX
+	VMOVD        AX, X0
+	VPBROADCASTD X0, Y0
+	LEAQ         (SI)(BX*4), R13
+	VMOVDQU      0(R13), Y1
+	VMOVDQU      32(R13), Y2
+	VPCMPGTD     Y0, Y1, Y1
+	VPCMPGTD     Y0, Y2, Y2
+	VMOVMSKPS    Y1, DX
+	VMOVMSKPS    Y2, R14
+	SHLQ         $8, R14
+	ORQ          R14, DX
+	POPCNTQ      DX, DX
+
+	// If all of the window is greater, so is every representative.
+	CMPQ DX, $16
+	JEQ  ibelow
+
+	// Otherwise the last representative less than or equal to the value is
+	// at position base+15-greater, and the code is exact if it's equal.
+	LEAQ    15(BX), R13
+	SUBQ    DX, R13
+	LEAQ    2(R13)(R13*1), R14
+	CMPL    (SI)(R13*4), AX
+	SETNE   DL
+	MOVBQZX DL, DX
+	ADDQ    DX, R14
+	MOVW    R14, (R10)(R12*2)
+	INCQ    R12
+	JMP     inext
+
+ibelow:
+	MOVW $1, (R10)(R12*2)
+	INCQ R12
+	JMP  inext
+
+idone:
+	VZEROUPPER
+	RET
@@ -0,0 +1,38 @@
+package colsketch
+
+import (
+	"cmp"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeAllWithoutAVX2(t *testing.T) {
+	if !hasAVX2 {
+		t.Skip("no AVX2 support to compare the fallback with")
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	ints := randomInt64s(rng, 100000, 1<<20)
+	values := randomInt64s(rng, 10000, 1<<21)
+	for _, mode := range []Mode{Byte, Word} {
+		testEncodeAllWithoutAVX2(t, NewDict(mode, ints), values)
+		testEncodeAllWithoutAVX2(t, NewDict(mode, convertInts[int32](ints)), convertInts[int32](values))
+		testEncodeAllWithoutAVX2(t, NewDict(mode, convertInts[float32](ints)), convertInts[float32](values))
+	}
+}
+
+// testEncodeAllWithoutAVX2 checks that EncodeAll and EncodeAllUnsafe encode
+// values alike with and without AVX2.
+func testEncodeAllWithoutAVX2[T cmp.Ordered](t *testing.T, dict Dict[T], values []T) {
+	t.Helper()
+	vector, vectorUnsafe := dict.EncodeAll(values, nil), dict.EncodeAllUnsafe(values, nil)
+
+	hasAVX2 = false
+	defer func() { hasAVX2 = true }()
+	scalar, scalarUnsafe := dict.EncodeAll(values, nil), dict.EncodeAllUnsafe(values, nil)
+
+	if !reflect.DeepEqual(vector, scalar) || !reflect.DeepEqual(vectorUnsafe, scalarUnsafe) {
+		t.Errorf("%T, mode %s: EncodeAll() differs without AVX2", values[0], dict.mode)
+	}
+}
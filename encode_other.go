@@ -0,0 +1,21 @@
+//go:build !amd64
+
+package colsketch
+
+// encodeBatchInt64SIMD reports false: there is no vectorized encoding on
+// this architecture.
+func encodeBatchInt64SIMD(s, values []int64, dst []Code) bool {
+	return false
+}
+
+// encodeBatchInt32SIMD reports false: there is no vectorized encoding on
+// this architecture.
+func encodeBatchInt32SIMD(s, values []int32, dst []Code) bool {
+	return false
+}
+
+// encodeBatchFloat32SIMD reports false: there is no vectorized encoding on
+// this architecture.
+func encodeBatchFloat32SIMD(s, values []float32, dst []Code) bool {
+	return false
+}
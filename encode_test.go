@@ -0,0 +1,681 @@
+package colsketch
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+// randomInt64s returns n int64s drawn uniformly from [0, limit).
+func randomInt64s(rng *rand.Rand, n int, limit int64) []int64 {
+	s := make([]int64, n)
+	for i := range s {
+		s[i] = rng.Int63n(limit)
+	}
+	return s
+}
+
+// randomStrings returns n strings of a random word from a vocabulary of size
+// vocab, with a common prefix to make comparisons non-trivial.
+func randomStrings(rng *rand.Rand, n, vocab int) []string {
+	s := make([]string, n)
+	for i := range s {
+		s[i] = fmt.Sprintf("word-%08d", rng.Intn(vocab))
+	}
+	return s
+}
+
+func TestEncodeAll(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		ints := NewDict(mode, randomInt64s(rng, 100000, 1<<20))
+		values := randomInt64s(rng, 10000, 1<<21)
+		testEncodeAll(t, &ints, values)
+
+		strs := NewDict(mode, randomStrings(rng, 100000, 50000))
+		testEncodeAll(t, &strs, randomStrings(rng, 10000, 60000))
+	}
+}
+
+func testEncodeAll[T cmp.Ordered](t *testing.T, d *Dict[T], values []T) {
+	t.Helper()
+
+	for _, dst := range [][]Code{nil, make([]Code, 5), make([]Code, 0, len(values)+10)} {
+		got := d.EncodeAll(values, dst)
+		if len(got) != len(values) {
+			t.Fatalf("len(EncodeAll()) = %d, want %d", len(got), len(values))
+		}
+		for i, v := range values {
+			if want := d.Encode(v); got[i] != want {
+				t.Fatalf("EncodeAll()[%d] = %d, want Encode(%v) = %d", i, got[i], v, want)
+			}
+		}
+		if cap(dst) >= len(values) && &got[0] != &dst[:1][0] {
+			t.Errorf("EncodeAll() didn't reuse dst with sufficient capacity")
+		}
+	}
+
+	dst := make([]Code, len(values))
+	if allocs := testing.AllocsPerRun(10, func() { d.EncodeAll(values, dst) }); allocs != 0 {
+		t.Errorf("EncodeAll() into sufficient dst allocated %v times", allocs)
+	}
+}
+
+func TestEncodeAllChunks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Word, randomInt64s(rng, 10000, 1<<20))
+
+	var chunks [][]int64
+	for _, n := range []int{1000, 10, 0, 999, 1000} {
+		chunks = append(chunks, randomInt64s(rng, n, 1<<20))
+	}
+
+	buf := make([]Code, 0, 1000)
+	allocs := testing.AllocsPerRun(10, func() {
+		for _, chunk := range chunks {
+			buf = dict.EncodeAll(chunk, buf)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("encoding chunks into a reused buffer allocated %v times", allocs)
+	}
+}
+
+func TestEncodeAllInPlace(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ints := randomInt64s(rng, 20000, 1<<15)
+
+	testEncodeInPlace(t, convertInts[uint16](ints))
+	testEncodeInPlace(t, convertInts[int16](ints))
+	testEncodeInPlace(t, convertInts[int32](ints))
+	testEncodeInPlace(t, ints)
+	testEncodeInPlace(t, convertInts[float32](ints))
+	testEncodeInPlace(t, convertInts[float64](ints))
+}
+
+// testEncodeInPlace checks that encoding values into codes sharing their
+// backing array yields the same codes as encoding them out of place, with
+// dictionaries of sizes and layouts that take every path of EncodeAll.
+func testEncodeInPlace[T Integer | ~float32 | ~float64](t *testing.T, sample []T) {
+	t.Helper()
+
+	for _, n := range []int{10, 1000, 20000} {
+		d := NewDict(Word, sample[:n])
+		eytz := NewDict(Word, sample[:n])
+		eytz.EnableEytzinger()
+
+		for _, d := range []*Dict[T]{&d, &eytz} {
+			want := d.EncodeAll(sample, nil)
+
+			values := append([]T(nil), sample...)
+			dst := unsafe.Slice((*Code)(unsafe.Pointer(unsafe.SliceData(values))), len(values))
+			got := d.EncodeAll(values, dst)
+
+			if &got[0] != &dst[0] {
+				t.Fatalf("%T, %d representatives: EncodeAll() in place didn't reuse dst", sample[0], n)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%T, %d representatives: EncodeAll() in place = %v..., want %v...", sample[0], n, got[:10], want[:10])
+			}
+		}
+	}
+}
+
+// TestEncodeZeroAlloc guards the encode paths against allocating for any
+// supported value type, e.g. through values escaping via interface
+// conversions in type-specific fast paths.
+func TestEncodeZeroAlloc(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ints := randomInt64s(rng, 50000, 1<<15)
+
+	testZeroAlloc(t, convertInts[int](ints))
+	testZeroAlloc(t, convertInts[int8](ints))
+	testZeroAlloc(t, convertInts[int16](ints))
+	testZeroAlloc(t, convertInts[int32](ints))
+	testZeroAlloc(t, ints)
+	testZeroAlloc(t, convertInts[uint](ints))
+	testZeroAlloc(t, convertInts[uint8](ints))
+	testZeroAlloc(t, convertInts[uint16](ints))
+	testZeroAlloc(t, convertInts[uint32](ints))
+	testZeroAlloc(t, convertInts[uint64](ints))
+	testZeroAlloc(t, convertInts[uintptr](ints))
+	testZeroAlloc(t, convertInts[float32](ints))
+	testZeroAlloc(t, convertInts[float64](ints))
+	testZeroAlloc(t, randomStrings(rng, 50000, 1<<15))
+}
+
+func convertInts[T Integer | ~float32 | ~float64](s []int64) []T {
+	out := make([]T, len(s))
+	for i, v := range s {
+		out[i] = T(v)
+	}
+	return out
+}
+
+func testZeroAlloc[T cmp.Ordered](t *testing.T, sample []T) {
+	t.Helper()
+
+	for _, mode := range []Mode{Byte, Word} {
+		d := NewDict(mode, sample)
+		eytz := NewDict(mode, sample)
+		eytz.EnableEytzinger()
+		cat := NewCategoricalDict(mode, sample[:len(sample)/2])
+		enc := d.NewMonotonicEncoder()
+
+		values := sample[:1000]
+		dst := make([]Code, len(values))
+		bytes := make([]uint8, len(values))
+
+		for _, tc := range []struct {
+			name string
+			f    func()
+		}{
+			{"Encode", func() {
+				for _, v := range values {
+					codeSink = d.Encode(v)
+				}
+			}},
+			{"EncodeChecked", func() { codeSink, _ = d.EncodeChecked(values[0]) }},
+			{"Eytzinger Encode", func() { codeSink = eytz.Encode(values[0]) }},
+			{"EncodeAll", func() { d.EncodeAll(values, dst) }},
+			{"EncodeAllInterleaved", func() { d.EncodeAllInterleaved(values, dst) }},
+			{"EncodeAllUnsafe", func() { d.EncodeAllUnsafe(values, dst) }},
+			{"EncodeAllParallel", func() { d.EncodeAllParallel(values, dst, 0) }},
+			{"EncodeAllBytes", func() {
+				if mode == Byte {
+					d.EncodeAllBytes(values, bytes)
+				}
+			}},
+			{"MonotonicEncoder", func() {
+				for _, v := range values {
+					codeSink = enc.Encode(v)
+				}
+			}},
+			{"CategoricalDict", func() { cat.EncodeAll(values, dst) }},
+		} {
+			if allocs := testing.AllocsPerRun(5, tc.f); allocs != 0 {
+				t.Errorf("%T, mode %s: %s allocated %v times", values[0], mode, tc.name, allocs)
+			}
+		}
+	}
+}
+
+func TestEncodeBatchInt64SIMD(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, n := range []int{1, 15, 16, 17, 31, 32, 33, 100, 127, 1000, 32767} {
+		s := randomInt64s(rng, n, 1<<20)
+		s[0], s[n-1] = math.MinInt64, math.MaxInt64
+		s = NewDict(Word, s).codes
+
+		values := randomInt64s(rng, 1000, 1<<20)
+		values = append(values, s...)
+		values = append(values, math.MinInt64, math.MaxInt64, -1, 0, 1<<20)
+
+		dst := make([]Code, len(values))
+		if !encodeBatchInt64SIMD(s, values, dst) {
+			// Too few representatives, or no SIMD support.
+			continue
+		}
+		for i, v := range values {
+			if want := encodeSorted(s, v); dst[i] != want {
+				t.Fatalf("%d representatives: code of %d = %d, want %d", n, v, dst[i], want)
+			}
+		}
+	}
+}
+
+func TestEncodeBatchInt32SIMD(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, n := range []int{1, 15, 16, 17, 31, 32, 33, 100, 127, 1000, 32767} {
+		s := convertInts[int32](randomInt64s(rng, n, 1<<20))
+		s[0], s[n-1] = math.MinInt32, math.MaxInt32
+		s = NewDict(Word, s).codes
+
+		values := convertInts[int32](randomInt64s(rng, 1000, 1<<20))
+		values = append(values, s...)
+		values = append(values, math.MinInt32, math.MaxInt32, -1, 0, 1<<20)
+
+		dst := make([]Code, len(values))
+		if !encodeBatchInt32SIMD(s, values, dst) {
+			// Too few representatives, or no SIMD support.
+			continue
+		}
+		for i, v := range values {
+			if want := encodeSorted(s, v); dst[i] != want {
+				t.Fatalf("%d representatives: code of %d = %d, want %d", n, v, dst[i], want)
+			}
+		}
+	}
+}
+
+func TestEncodeBatchFloat32SIMD(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	nan, inf := float32(math.NaN()), float32(math.Inf(1))
+	specials := []float32{nan, -nan, inf, -inf, 0, float32(math.Copysign(0, -1)),
+		math.SmallestNonzeroFloat32, -math.SmallestNonzeroFloat32, math.MaxFloat32, -math.MaxFloat32}
+
+	for _, n := range []int{1, 15, 16, 17, 31, 32, 33, 100, 127, 1000, 32767} {
+		for _, sample := range []string{"normal", "with zero", "with -0", "with NaN"} {
+			floats := make([]float32, n)
+			for i := range floats {
+				floats[i] = float32(rng.NormFloat64() * 100)
+			}
+			switch sample {
+			case "with zero":
+				floats[0], floats[n-1] = 0, -inf
+			case "with -0":
+				floats[0] = float32(math.Copysign(0, -1))
+			case "with NaN":
+				floats[0] = nan
+			}
+			s := NewDict(Word, floats).codes
+
+			values := make([]float32, 1000)
+			for i := range values {
+				values[i] = float32(rng.NormFloat64() * 100)
+			}
+			values = append(values, s...)
+			values = append(values, specials...)
+
+			dst := make([]Code, len(values))
+			if !encodeBatchFloat32SIMD(s, values, dst) {
+				// Too few representatives, representatives it can't
+				// compare, or no SIMD support.
+				continue
+			}
+			for i, v := range values {
+				if want := encodeSorted(s, v); dst[i] != want {
+					t.Fatalf("%d representatives, %s: code of %v = %d, want %d", n, sample, v, dst[i], want)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkEncodeAll(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 20
+
+	for _, mode := range []Mode{Byte, Word} {
+		ints := NewDict(mode, randomInt64s(rng, n, 1<<40))
+		intValues := randomInt64s(rng, n, 1<<40)
+		b.Run(fmt.Sprintf("int64/%s", mode), func(b *testing.B) {
+			benchmarkEncodeAll(b, &ints, intValues)
+		})
+
+		int32s := NewDict(mode, convertInts[int32](randomInt64s(rng, n, 1<<30)))
+		int32Values := convertInts[int32](randomInt64s(rng, n, 1<<30))
+		b.Run(fmt.Sprintf("int32/%s", mode), func(b *testing.B) {
+			benchmarkEncodeAll(b, &int32s, int32Values)
+		})
+
+		floatSample, floatValues := make([]float32, n), make([]float32, n)
+		for i := range floatSample {
+			floatSample[i], floatValues[i] = float32(rng.NormFloat64()), float32(rng.NormFloat64())
+		}
+		floats := NewDict(mode, floatSample)
+		b.Run(fmt.Sprintf("float32/%s", mode), func(b *testing.B) {
+			benchmarkEncodeAll(b, &floats, floatValues)
+		})
+
+		strs := NewDict(mode, randomStrings(rng, n, n))
+		strValues := randomStrings(rng, n, n)
+		b.Run(fmt.Sprintf("string/%s", mode), func(b *testing.B) {
+			benchmarkEncodeAll(b, &strs, strValues)
+		})
+	}
+}
+
+func benchmarkEncodeAll[T cmp.Ordered](b *testing.B, d *Dict[T], values []T) {
+	dst := make([]Code, len(values))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst = d.EncodeAll(values, dst)
+	}
+	b.ReportMetric(float64(b.N*len(values))/b.Elapsed().Seconds(), "values/s")
+}
+
+func TestEncodeAllParallel(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	dict := NewDict(Word, randomInt64s(rng, 100000, 1<<20))
+	for _, n := range []int{0, 1, 1000, parallelMinChunk*3 + 7, 200000} {
+		values := randomInt64s(rng, n, 1<<20)
+		want := dict.EncodeAll(values, nil)
+
+		for _, parallelism := range []int{-1, 0, 1, 3, 8} {
+			got := dict.EncodeAllParallel(values, nil, parallelism)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("n = %d, parallelism = %d: codes differ from EncodeAll()", n, parallelism)
+			}
+		}
+	}
+}
+
+func BenchmarkEncodeAllParallel(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 22
+
+	dict := NewDict(Word, randomInt64s(rng, 1<<20, 1<<40))
+	values := randomInt64s(rng, n, 1<<40)
+	dst := make([]Code, n)
+
+	for _, parallelism := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("parallelism=%d", parallelism), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				dst = dict.EncodeAllParallel(values, dst, parallelism)
+			}
+			b.ReportMetric(float64(b.N*len(values))/b.Elapsed().Seconds(), "values/s")
+		})
+	}
+}
+
+func TestEncodeAllUnsafe(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		for _, n := range []int{0, 1, 2, 100, 100000} {
+			ints := NewDict(mode, randomInt64s(rng, n, 1<<20))
+			testEncodeAllUnsafe(t, &ints, randomInt64s(rng, 5000, 1<<21))
+
+			strs := NewDict(mode, randomStrings(rng, n, 1<<20))
+			testEncodeAllUnsafe(t, &strs, randomStrings(rng, 5000, 1<<21))
+		}
+	}
+}
+
+// testEncodeAllUnsafe checks EncodeAllUnsafe against EncodeAll, encoding
+// from several goroutines at once so that the race detector can tell that
+// the dictionary is only read.
+func testEncodeAllUnsafe[T cmp.Ordered](t *testing.T, d *Dict[T], values []T) {
+	t.Helper()
+
+	want := d.EncodeAll(values, nil)
+	results := make([][]Code, 4)
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = d.EncodeAllUnsafe(values, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, got := range results {
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%d representatives: EncodeAllUnsafe() differs from EncodeAll()", d.Len())
+		}
+	}
+}
+
+func BenchmarkEncodeAllUnsafe(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 20
+
+	// Word mode dictionaries with fewer representatives than EncodeAll
+	// interleaves searches over.
+	for _, mode := range []Mode{Byte, Word} {
+		floatSample, floatValues := make([]float64, n), make([]float64, n)
+		for i := range floatSample {
+			floatSample[i] = float64(rng.Intn(interleaveMinLen - 1))
+			floatValues[i] = rng.NormFloat64() * interleaveMinLen
+		}
+		floats := NewDict(mode, floatSample)
+		strs := NewDict(mode, randomStrings(rng, n, interleaveMinLen-1))
+		strValues := randomStrings(rng, n, n)
+
+		b.Run(fmt.Sprintf("float64/%s/EncodeAll", mode), func(b *testing.B) {
+			benchmarkEncodeAll(b, &floats, floatValues)
+		})
+		b.Run(fmt.Sprintf("float64/%s/EncodeAllUnsafe", mode), func(b *testing.B) {
+			benchmarkEncodeAllUnsafe(b, &floats, floatValues)
+		})
+		b.Run(fmt.Sprintf("string/%s/EncodeAll", mode), func(b *testing.B) {
+			benchmarkEncodeAll(b, &strs, strValues)
+		})
+		b.Run(fmt.Sprintf("string/%s/EncodeAllUnsafe", mode), func(b *testing.B) {
+			benchmarkEncodeAllUnsafe(b, &strs, strValues)
+		})
+	}
+}
+
+func benchmarkEncodeAllUnsafe[T cmp.Ordered](b *testing.B, d *Dict[T], values []T) {
+	dst := make([]Code, len(values))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst = d.EncodeAllUnsafe(values, dst)
+	}
+	b.ReportMetric(float64(b.N*len(values))/b.Elapsed().Seconds(), "values/s")
+}
+
+func TestEncodeAllBytes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	dict := NewDict(Byte, randomStrings(rng, 10000, 1000))
+	values := randomStrings(rng, 5000, 1200)
+
+	got, err := dict.EncodeAllBytes(values, nil)
+	if err != nil {
+		t.Fatalf("EncodeAllBytes() = %v", err)
+	}
+
+	want := dict.EncodeAll(values, nil)
+	for i := range want {
+		if Code(got[i]) != want[i] {
+			t.Fatalf("EncodeAllBytes()[%d] = %d, want %d", i, got[i], want[i])
+		}
+	}
+
+	dst := make([]uint8, 0, len(values))
+	if allocs := testing.AllocsPerRun(10, func() { dict.EncodeAllBytes(values, dst) }); allocs != 0 {
+		t.Errorf("EncodeAllBytes() into sufficient dst allocated %v times", allocs)
+	}
+
+	word := NewDict(Word, values)
+	if _, err := word.EncodeAllBytes(values, nil); err == nil {
+		t.Errorf("EncodeAllBytes() on a Word mode dictionary succeeded")
+	}
+}
+
+func BenchmarkEncodeAllBytes(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 20
+
+	ints := NewDict(Byte, randomInt64s(rng, n, 1<<40))
+	intValues := randomInt64s(rng, n, 1<<40)
+	b.Run("int64", func(b *testing.B) {
+		benchmarkEncodeAllBytes(b, &ints, intValues)
+	})
+
+	strs := NewDict(Byte, randomStrings(rng, n, n))
+	strValues := randomStrings(rng, n, n)
+	b.Run("string", func(b *testing.B) {
+		benchmarkEncodeAllBytes(b, &strs, strValues)
+	})
+}
+
+func benchmarkEncodeAllBytes[T cmp.Ordered](b *testing.B, d *Dict[T], values []T) {
+	dst := make([]uint8, len(values))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst, _ = d.EncodeAllBytes(values, dst)
+	}
+	b.ReportMetric(float64(b.N*len(values))/b.Elapsed().Seconds(), "values/s")
+}
+
+func TestEncodeReader(t *testing.T) {
+	dict := NewDict(Byte, []int{10, 20, 30})
+	parse := func(b []byte) (int, error) { return strconv.Atoi(string(b)) }
+
+	codes, err := dict.EncodeReader(strings.NewReader("10\n25\r\n30\n5"), parse)
+	if err != nil {
+		t.Fatalf("EncodeReader() = %v", err)
+	}
+	if want := []Code{2, 5, 6, 1}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("EncodeReader() = %v, want %v", codes, want)
+	}
+
+	if codes, err := dict.EncodeReader(strings.NewReader(""), parse); err != nil || len(codes) != 0 {
+		t.Errorf("EncodeReader() of an empty stream = %v, %v", codes, err)
+	}
+
+	// A bad line fails with its number, and the codes of the lines before.
+	codes, err = dict.EncodeReader(strings.NewReader("10\n20\nx\n30\n"), parse)
+	if want := []Code{2, 4}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("EncodeReader() of a bad line = %v, want %v", codes, want)
+	}
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("EncodeReader() of a bad line = %v, want a parse error on line 3", err)
+	}
+
+	long := strings.Repeat("1", bufio.MaxScanTokenSize+1)
+	if _, err := dict.EncodeReader(strings.NewReader("10\n"+long), parse); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("EncodeReader() of a too long line = %v, want %v", err, bufio.ErrTooLong)
+	}
+}
+
+func TestEncodeSparse(t *testing.T) {
+	dict := NewDict(Byte, []int{10, 20, 30})
+
+	indices := []int{1, 4, 7}
+	gotIdx, codes := dict.EncodeSparse(indices, []int{10, 25, 30})
+	if !reflect.DeepEqual(gotIdx, indices) {
+		t.Errorf("EncodeSparse() indices = %v, want %v", gotIdx, indices)
+	}
+	if want := []Code{2, 5, 6}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("EncodeSparse() codes = %v, want %v", codes, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("EncodeSparse() with mismatched lengths didn't panic")
+		}
+	}()
+	dict.EncodeSparse([]int{1}, nil)
+}
+
+func TestEncodeAllInterleaved(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, n := range []int{0, 1, 2, 15, 16, 17, 100, 5000, 40000} {
+		ints := NewDict(Word, randomInt64s(rng, 2*n, int64(3*n+1)))
+		intValues := randomInt64s(rng, 1000+n%16, int64(3*n+2))
+		strs := NewDict(Word, randomStrings(rng, 2*n, 3*n+1))
+		strValues := randomStrings(rng, 1000+n%16, 3*n+2)
+		if n == 0 {
+			ints.codes, strs.codes = nil, nil
+		}
+
+		for i, got := range ints.EncodeAllInterleaved(intValues, nil) {
+			if want := ints.Encode(intValues[i]); got != want {
+				t.Fatalf("%d representatives: code %d = %d, want %d", ints.Len(), i, got, want)
+			}
+		}
+		for i, got := range strs.EncodeAllInterleaved(strValues, nil) {
+			if want := strs.Encode(strValues[i]); got != want {
+				t.Fatalf("%d representatives: code %d = %d, want %d", strs.Len(), i, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkEncodeAllInterleaved(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 20
+
+	// Word-mode dictionaries of 32767 strings are well over the size of a
+	// typical L2 cache; int64 ones are around it.
+	ints := NewDict(Word, randomInt64s(rng, n, 1<<40))
+	intValues := randomInt64s(rng, n, 1<<40)
+	strs := NewDict(Word, randomStrings(rng, n, n))
+	strValues := randomStrings(rng, n, n)
+
+	scalar := func(d *Dict[int64], values []int64, dst []Code) {
+		for i, v := range values {
+			dst[i] = d.Encode(v)
+		}
+	}
+	scalarStr := func(d *Dict[string], values []string, dst []Code) {
+		for i, v := range values {
+			dst[i] = d.Encode(v)
+		}
+	}
+
+	dst := make([]Code, n)
+	for _, bc := range []struct {
+		name string
+		fn   func()
+	}{
+		{"int64/scalar", func() { scalar(&ints, intValues, dst) }},
+		{"int64/interleaved", func() { ints.EncodeAllInterleaved(intValues, dst) }},
+		{"string/scalar", func() { scalarStr(&strs, strValues, dst) }},
+		{"string/interleaved", func() { strs.EncodeAllInterleaved(strValues, dst) }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bc.fn()
+			}
+			b.ReportMetric(float64(b.N*n)/b.Elapsed().Seconds(), "values/s")
+		})
+	}
+}
+
+func TestEncodeRunLength(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	dict := NewDict(Byte, randomInt64s(rng, 10000, 1000))
+
+	for _, n := range []int{0, 1, 10, 100000} {
+		values := randomInt64s(rng, n, 1200)
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+		codes, runs := dict.EncodeRunLength(values)
+		if len(codes) != len(runs) {
+			t.Fatalf("%d codes but %d run lengths", len(codes), len(runs))
+		}
+
+		for i := range codes {
+			if runs[i] <= 0 {
+				t.Fatalf("run %d has length %d", i, runs[i])
+			}
+			if i > 0 && codes[i] == codes[i-1] {
+				t.Fatalf("runs %d and %d share code %d", i-1, i, codes[i])
+			}
+		}
+
+		want := dict.EncodeAll(values, nil)
+		got := ExpandRuns(nil, codes, runs)
+		switch {
+		case len(want) == 0:
+			// Empty slices compare as equal whether or not they're nil.
+			if len(got) != 0 {
+				t.Fatalf("%d values: expanded runs = %v, want none", n, got)
+			}
+		case !reflect.DeepEqual(got, want):
+			t.Fatalf("%d values: expanded runs differ from EncodeAll()", n)
+		}
+		if n > 1000 && len(codes) > 2*dict.Len()+1 {
+			t.Errorf("%d runs for a sorted column, more than the %d codes", len(codes), 2*dict.Len()+1)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package colsketch
+
+import (
+	"math/bits"
+	"unsafe"
+)
+
+// The kernels below compare byte codes with a single code, as equality
+// predicates compile to, a word of eight codes at a time: XORing a word with
+// the code broadcast to every byte zeroes the bytes equal to it, which
+// laneZero flags in their top bits. That takes half the operations of the
+// range comparison of countRanges and rangeMask, and lets scan visit the
+// flagged bytes of each word rather than branch on every code.
+
+const (
+	byteOnes = ^uint64(0) / 0xff
+	byteHigh = byteOnes << 7
+)
+
+// bytesOf returns byte codes as a slice of bytes.
+func bytesOf[E uint8 | uint16](codes []E) []uint8 {
+	return unsafe.Slice((*uint8)(unsafe.Pointer(unsafe.SliceData(codes))), len(codes))
+}
+
+// splitWords splits codes into the codes before the first word boundary,
+// the whole aligned words after it, and the codes after them.
+func splitWords(codes []uint8) (head []uint8, words []uint64, tail []uint8) {
+	i := 0
+	for i < len(codes) && uintptr(unsafe.Pointer(&codes[i]))%8 != 0 {
+		i++
+	}
+	n := (len(codes) - i) / 8
+	if n > 0 {
+		words = unsafe.Slice((*uint64)(unsafe.Pointer(&codes[i])), n)
+	}
+	return codes[:i], words, codes[i+8*n:]
+}
+
+// countEqual returns the number of codes equal to c.
+func countEqual(codes []uint8, c uint8) int {
+	head, words, tail := splitWords(codes)
+	n := 0
+	for _, x := range head {
+		n += int(b2u(x == c))
+	}
+	b := uint64(c) * byteOnes
+	for _, x := range words {
+		n += bits.OnesCount64(laneZero(x^b, byteHigh))
+	}
+	for _, x := range tail {
+		n += int(b2u(x == c))
+	}
+	return n
+}
+
+// scanEqual calls visit with start plus the index of every code equal to c,
+// in increasing order, until visit returns false, which it returns.
+func scanEqual(codes []uint8, start int, c uint8, visit func(pos int) bool) bool {
+	head, words, tail := splitWords(codes)
+	for i, x := range head {
+		if x == c && !visit(start+i) {
+			return false
+		}
+	}
+
+	pos := start + len(head)
+	b := uint64(c) * byteOnes
+	for _, x := range words {
+		for m := laneZero(x^b, byteHigh); m != 0; m &= m - 1 {
+			if !visit(pos + bits.TrailingZeros64(m)>>3) {
+				return false
+			}
+		}
+		pos += 8
+	}
+
+	for i, x := range tail {
+		if x == c && !visit(pos+i) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestEqualKernels(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	buf := make([]uint8, 1000)
+
+	for i := 0; i < 2000; i++ {
+		// Codes from few values, so that most are equal to some, at random
+		// offsets from word boundaries.
+		values := 1 + rng.Intn(8)
+		for j := range buf {
+			buf[j] = uint8(rng.Intn(values))
+		}
+		lo := rng.Intn(len(buf))
+		codes := buf[lo : lo+rng.Intn(len(buf)-lo+1)]
+		c := uint8(rng.Intn(values + 1))
+		start := rng.Intn(100)
+
+		var want []int
+		for j, x := range codes {
+			if x == c {
+				want = append(want, start+j)
+			}
+		}
+
+		if got := countEqual(codes, c); got != len(want) {
+			t.Fatalf("countEqual(%d codes at %d, %d) = %d, want %d", len(codes), lo, c, got, len(want))
+		}
+
+		var got []int
+		scanEqual(codes, start, c, func(pos int) bool {
+			got = append(got, pos)
+			return true
+		})
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("scanEqual(%d codes at %d, %d) visited %v, want %v", len(codes), lo, c, got, want)
+		}
+
+		if len(want) > 0 {
+			stop := rng.Intn(len(want))
+			got = got[:0]
+			if scanEqual(codes, start, c, func(pos int) bool {
+				got = append(got, pos)
+				return len(got) <= stop
+			}) {
+				t.Fatalf("scanEqual() stopped by visit returned true")
+			}
+			if !reflect.DeepEqual(got, want[:stop+1]) {
+				t.Fatalf("scanEqual() stopped after %d visited %v, want %v", stop+1, got, want[:stop+1])
+			}
+		}
+	}
+}
+
+func TestSketchScanEqual(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 1000, 1000))
+
+	for _, blockSize := range []int{64, 192, DefaultBlockSize} {
+		s := NewSketch(&dict, WithBlockSize(blockSize))
+		s.AppendValues(randomInt64s(rng, 5*blockSize+rng.Intn(64), 1002))
+		deleted := make([]bool, s.Len())
+		for i := 0; i < s.Len()/8; i++ {
+			pos := rng.Intn(s.Len())
+			deleted[pos] = true
+			s.Delete(pos)
+		}
+
+		name := fmt.Sprintf("block size %d", blockSize)
+		for i := 0; i < 200; i++ {
+			testDeletedScans(t, name, s, deleted, Eq(rng.Int63n(1003)-1))
+		}
+		for i := 0; i < 5; i++ {
+			lo := rng.Intn(s.Len() + 1)
+			hi := lo + rng.Intn(s.Len()-lo+1)
+			testSketchSlice(t, fmt.Sprintf("%s, [%d:%d]", name, lo, hi), s.Slice(lo, hi), s, rng)
+		}
+	}
+}
+
+func BenchmarkSketchScanEqual(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 100000, 1<<30))
+	s := NewSketch(&dict)
+	s.AppendCodes(dict.EncodeAll(randomInt64s(rng, 1<<20, 1<<30), nil))
+	v, _ := dict.Value(80)
+	p := Eq(v)
+
+	b.Run("Scan", func(b *testing.B) {
+		b.SetBytes(int64(s.Len()))
+		for i := 0; i < b.N; i++ {
+			n := 0
+			s.Scan(p, func(int) bool {
+				n++
+				return true
+			})
+			codeSink = Code(n)
+		}
+	})
+	b.Run("Count", func(b *testing.B) {
+		b.SetBytes(int64(s.Len()))
+		for i := 0; i < b.N; i++ {
+			n, _ := s.Count(p)
+			codeSink = Code(n)
+		}
+	})
+	b.Run("ScanBitmap", func(b *testing.B) {
+		b.SetBytes(int64(s.Len()))
+		dst := s.ScanBitmap(p, nil)
+		for i := 0; i < b.N; i++ {
+			dst = s.ScanBitmap(p, dst)
+			n := 0
+			for _, w := range dst {
+				n += bits.OnesCount64(w)
+			}
+			codeSink = Code(n)
+		}
+	})
+
+	// The loop the kernels replace, comparing a code at a time.
+	b.Run("Scalar", func(b *testing.B) {
+		codes := *s.store.(*codeSlice[uint8])
+		c := uint8(dict.Encode(v))
+		b.SetBytes(int64(s.Len()))
+		for i := 0; i < b.N; i++ {
+			n := 0
+			for _, x := range codes {
+				if x == c {
+					n++
+				}
+			}
+			codeSink = Code(n)
+		}
+	})
+}
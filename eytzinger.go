@@ -0,0 +1,69 @@
+package colsketch
+
+import (
+	"cmp"
+	"math/bits"
+)
+
+// eytzingerMinLen is the number of representatives below which the sorted
+// slice fits comfortably in cache and the Eytzinger layout doesn't pay for
+// its extra memory.
+const eytzingerMinLen = 512
+
+// EnableEytzinger builds an alternative layout of the representatives that
+// Encode and its relatives then search instead of the sorted slice. The
+// layout stores the implicit binary search tree over the representatives in
+// breadth-first (Eytzinger) order, so that the first levels of every search
+// share a handful of cache lines and each descent step is a branch-free
+// index computation, which pays off for large Word-mode dictionaries over
+// fixed-width types; for strings the cost of comparisons dominates. Go has
+// no prefetch intrinsic, so the deeper levels rely on the hardware
+// prefetcher and out-of-order execution to overlap misses.
+//
+// Results are identical with and without the layout. It costs one extra
+// copy of the representatives plus two bytes each, is shared by copies of
+// the dictionary made afterwards, and is a no-op for dictionaries too small
+// to benefit. It must not be called concurrently with other methods.
+func (d *Dict[T]) EnableEytzinger() {
+	if d.eytz != nil || len(d.codes) < eytzingerMinLen {
+		return
+	}
+
+	// Slot 0 is unused so that the children of slot k are 2k and 2k+1.
+	eytz := make([]T, len(d.codes)+1)
+	pos := make([]uint16, len(d.codes)+1)
+
+	// An in-order walk of the implicit tree visits slots in sorted order.
+	i := 0
+	var fill func(k int)
+	fill = func(k int) {
+		if k >= len(eytz) {
+			return
+		}
+		fill(2 * k)
+		eytz[k], pos[k] = d.codes[i], uint16(i)
+		i++
+		fill(2*k + 1)
+	}
+	fill(1)
+
+	d.eytz, d.eytzPos = eytz, pos
+}
+
+// searchEytzinger is search over the Eytzinger layout.
+func (d *Dict[T]) searchEytzinger(value T) int {
+	e := d.eytz
+	k := 1
+	for k < len(e) {
+		k = 2*k + int(b2u(cmp.Less(e[k], value)))
+	}
+
+	// The descent went right for every representative less than value and
+	// left otherwise, so the lower bound is the last node where it went left:
+	// strip the trailing right turns and that final left turn.
+	k >>= bits.TrailingZeros(^uint(k)) + 1
+	if k == 0 {
+		return len(d.codes)
+	}
+	return int(d.eytzPos[k])
+}
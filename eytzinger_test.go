@@ -0,0 +1,84 @@
+package colsketch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestEytzinger(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, n := range []int{eytzingerMinLen, 1000, 1023, 1024, 1025, 32767} {
+		sample := randomInt64s(rng, 4*n, 1<<30)
+		plain := NewDict(Word, sample)
+		eytz := plain
+		eytz.EnableEytzinger()
+		if eytz.eytz == nil {
+			t.Fatalf("%d representatives: layout not built", plain.Len())
+		}
+
+		probes := append(randomInt64s(rng, 10000, 1<<30), -1, 1<<31)
+		probes = append(probes, plain.codes...)
+		for _, v := range probes {
+			if got, want := eytz.Encode(v), plain.Encode(v); got != want {
+				t.Fatalf("%d representatives: Encode(%d) = %d, want %d", plain.Len(), v, got, want)
+			}
+		}
+	}
+
+	small := NewDict(Byte, randomInt64s(rng, 1000, 1000))
+	small.EnableEytzinger()
+	if small.eytz != nil {
+		t.Errorf("layout built for a dictionary with %d representatives", small.Len())
+	}
+}
+
+func FuzzEytzinger(f *testing.F) {
+	f.Add([]byte{1, 2, 3}, []byte{2})
+	f.Fuzz(func(t *testing.T, sampleData, probeData []byte) {
+		// Widen the sample so that it always exceeds eytzingerMinLen.
+		sample := make([]uint16, 0, 2*eytzingerMinLen+len(sampleData)/2)
+		for i := 0; i+1 < len(sampleData); i += 2 {
+			sample = append(sample, binary.LittleEndian.Uint16(sampleData[i:]))
+		}
+		for i := 0; i < 2*eytzingerMinLen; i++ {
+			sample = append(sample, uint16(i*31))
+		}
+
+		plain := NewDict(Word, sample)
+		eytz := plain
+		eytz.EnableEytzinger()
+
+		for _, p := range probeData {
+			v := uint16(p) * 257
+			if got, want := eytz.Encode(v), plain.Encode(v); got != want {
+				t.Fatalf("Encode(%d) = %d, want %d", v, got, want)
+			}
+		}
+	})
+}
+
+func BenchmarkEytzinger(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 20
+
+	ints := NewDict(Word, randomInt64s(rng, n, 1<<40))
+	intValues := randomInt64s(rng, n, 1<<40)
+	strs := NewDict(Word, randomStrings(rng, n, n))
+	strValues := randomStrings(rng, n, n)
+
+	for _, layout := range []string{"sorted", "eytzinger"} {
+		if layout == "eytzinger" {
+			ints.EnableEytzinger()
+			strs.EnableEytzinger()
+		}
+		b.Run(fmt.Sprintf("int64/%s", layout), func(b *testing.B) {
+			benchmarkEncodeAll(b, &ints, intValues)
+		})
+		b.Run(fmt.Sprintf("string/%s", layout), func(b *testing.B) {
+			benchmarkEncodeAll(b, &strs, strValues)
+		})
+	}
+}
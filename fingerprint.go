@@ -0,0 +1,44 @@
+package colsketch
+
+import (
+	"cmp"
+	"encoding/binary"
+	"math"
+	"reflect"
+)
+
+// Fingerprint returns a 64-bit hash of the dictionary's content that is
+// stable across processes, Go versions and architectures, so it can be
+// persisted alongside derived sketches and compared later. Dictionaries with
+// equal fingerprints encode every value identically (barring hash
+// collisions).
+//
+// The fingerprint is the XXH64 hash, with seed 0, of the mode byte, the
+// value kind byte, the uvarint number of representatives and then each
+// representative in the encoding used by MarshalBinary: fixed-width
+// little-endian for numbers and uvarint length-prefixed bytes for strings.
+// Floating point zeros and NaNs are canonicalized to +0 and a single NaN bit
+// pattern first, since the dictionary can't tell them apart either.
+func (d *Dict[T]) Fingerprint() uint64 {
+	k := kindOf[T]()
+	b := []byte{byte(d.mode), byte(k)}
+	b = binary.AppendUvarint(b, uint64(len(d.codes)))
+	for _, v := range d.codes {
+		b = appendValue(b, k, canonical(v))
+	}
+	return xxhash64(b, 0)
+}
+
+// canonical maps values that compare equal under cmp.Compare but have
+// different representations, i.e. floating point -0 and NaNs, to a single
+// representative.
+func canonical[T cmp.Ordered](v T) T {
+	var zero T
+	switch {
+	case v == zero:
+		return zero
+	case v != v:
+		reflect.ValueOf(&v).Elem().SetFloat(math.NaN())
+	}
+	return v
+}
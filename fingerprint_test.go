@@ -0,0 +1,67 @@
+package colsketch
+
+import (
+	"cmp"
+	"math"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	words := NewDict(Byte, []string{"ape", "bee", "cat"})
+	if got, want := words.Fingerprint(), words.Fingerprint(); got != want {
+		t.Fatalf("Fingerprint() unstable: %#x then %#x", got, want)
+	}
+
+	// Golden values guard against accidental changes to the hashing scheme,
+	// which would invalidate every persisted fingerprint.
+	for _, tc := range []struct {
+		name string
+		got  uint64
+		want uint64
+	}{
+		{"strings", words.Fingerprint(), 0x3f936081695df1b8},
+		{"int64", fingerprintOf(Word, []int64{-1, 0, 1 << 40}), 0x5d469c0d5565114f},
+		{"float64", fingerprintOf(Byte, []float64{-0.5, 0, math.Inf(1)}), 0xc837a1e8b5d575ff},
+		{"empty", fingerprintOf[uint8](Byte, nil), 0x1b962912002ecb88},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s: Fingerprint() = %#x, want %#x", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestFingerprintCollisions(t *testing.T) {
+	seen := map[uint64]string{}
+	for name, fp := range map[string]uint64{
+		"byte":       fingerprintOf(Byte, []int64{1, 2, 3}),
+		"word":       fingerprintOf(Word, []int64{1, 2, 3}),
+		"int32":      fingerprintOf(Byte, []int32{1, 2, 3}),
+		"uint64":     fingerprintOf(Byte, []uint64{1, 2, 3}),
+		"fewer":      fingerprintOf(Byte, []int64{1, 2}),
+		"shifted":    fingerprintOf(Byte, []int64{1, 2, 4}),
+		"ab,c":       fingerprintOf(Byte, []string{"ab", "c"}),
+		"a,bc":       fingerprintOf(Byte, []string{"a", "bc"}),
+		"abc":        fingerprintOf(Byte, []string{"abc"}),
+		"empty word": fingerprintOf[int64](Word, nil),
+	} {
+		if other, ok := seen[fp]; ok {
+			t.Errorf("%s and %s have the same fingerprint %#x", name, other, fp)
+		}
+		seen[fp] = name
+	}
+
+	// Dictionaries that encode identically fingerprint identically.
+	negZero := math.Copysign(0, -1)
+	if fingerprintOf(Byte, []float64{negZero, 1}) != fingerprintOf(Byte, []float64{0, 1}) {
+		t.Errorf("-0 and +0 representatives have different fingerprints")
+	}
+	nan := math.Float64frombits(0x7ff8000000000001)
+	if fingerprintOf(Byte, []float64{nan, 1}) != fingerprintOf(Byte, []float64{math.NaN(), 1}) {
+		t.Errorf("NaN representatives with different payloads have different fingerprints")
+	}
+}
+
+func fingerprintOf[T cmp.Ordered](mode Mode, sample []T) uint64 {
+	d := NewDict(mode, sample)
+	return d.Fingerprint()
+}
@@ -0,0 +1,61 @@
+package colsketch
+
+import (
+	"cmp"
+	"reflect"
+	"unsafe"
+)
+
+// MemoryFootprint returns an estimate of the number of bytes of memory
+// retained by the dictionary: the Dict struct itself, the backing arrays of
+// its representative values (including the optional Eytzinger layout),
+// sample counts and clusters and, for string-kinded `T`, the bytes of each
+// string. It doesn't account for allocator size-class rounding or for
+// string payloads shared with other values, so it should be treated as a
+// weight rather than an exact measurement. The result is stable across
+// calls.
+func (d *Dict[T]) MemoryFootprint() int64 {
+	// The Eytzinger layout shares string payloads with codes.
+	var zero T
+	return int64(unsafe.Sizeof(*d)) + sliceFootprint(d.codes) +
+		int64(cap(d.eytz))*int64(unsafe.Sizeof(zero)) + int64(cap(d.eytzPos))*2 +
+		int64(cap(d.counts))*int64(unsafe.Sizeof(int(0))) +
+		clustersFootprint(d.clusters, d.codes)
+}
+
+// clustersFootprint returns the size of the backing array of clusters plus
+// the payload of their values when they are strings, except for those of
+// values also in codes, which share their payload.
+func clustersFootprint[T cmp.Ordered](clu []Cluster[T], codes []T) int64 {
+	var zero T
+	n := int64(cap(clu)) * int64(unsafe.Sizeof(Cluster[T]{}))
+	if reflect.TypeOf(zero).Kind() != reflect.String {
+		return n
+	}
+
+	// Both are sorted, so representatives are found by a merge.
+	j := 0
+	for _, c := range clu {
+		for j < len(codes) && cmp.Less(codes[j], c.Value) {
+			j++
+		}
+		if j < len(codes) && cmp.Compare(codes[j], c.Value) == 0 {
+			continue
+		}
+		n += int64(reflect.ValueOf(c.Value).Len())
+	}
+	return n
+}
+
+// sliceFootprint returns the size of the backing array of a slice plus the
+// payload of its elements when they are strings.
+func sliceFootprint[T cmp.Ordered](s []T) int64 {
+	var zero T
+	n := int64(cap(s)) * int64(unsafe.Sizeof(zero))
+	if reflect.TypeOf(zero).Kind() == reflect.String {
+		for i := range s {
+			n += int64(reflect.ValueOf(s[i]).Len())
+		}
+	}
+	return n
+}
@@ -0,0 +1,108 @@
+package colsketch
+
+import (
+	"cmp"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// allocatedBytes returns the number of heap bytes allocated while running f.
+func allocatedBytes(f func()) int64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return int64(after.TotalAlloc - before.TotalAlloc)
+}
+
+// cloneDict deep-copies a dictionary onto the heap so that its allocation can
+// be measured independently of how it was built.
+func cloneDict[T cmp.Ordered](d *Dict[T], clone func(T) T) *Dict[T] {
+	c := &Dict[T]{mode: d.mode, codes: make([]T, len(d.codes)), counts: append([]int(nil), d.counts...)}
+	for i, v := range d.codes {
+		c.codes[i] = clone(v)
+	}
+	return c
+}
+
+func TestMemoryFootprint(t *testing.T) {
+	ints := make([]int64, 1024)
+	for i := range ints {
+		ints[i] = int64(i)
+	}
+	intDict := NewDict(Word, ints)
+
+	strs := make([]string, 1024)
+	for i := range strs {
+		strs[i] = fmt.Sprintf("%032d", i)
+	}
+	strDict := NewDict(Word, strs)
+	byteStrDict := NewDict(Byte, strs)
+
+	for _, tc := range []struct {
+		name      string
+		footprint func() int64
+		clone     func()
+	}{
+		{"int64/Word", intDict.MemoryFootprint, func() {
+			sink = cloneDict(&intDict, func(v int64) int64 { return v })
+		}},
+		{"string/Word", strDict.MemoryFootprint, func() {
+			sink = cloneDict(&strDict, strings.Clone)
+		}},
+		{"string/Byte", byteStrDict.MemoryFootprint, func() {
+			sink = cloneDict(&byteStrDict, strings.Clone)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.footprint()
+			if again := tc.footprint(); again != got {
+				t.Fatalf("unstable footprint: %d then %d", got, again)
+			}
+
+			want := allocatedBytes(tc.clone)
+			if diff := got - want; diff < -want/10 || diff > want/10 {
+				t.Errorf("footprint %d not within 10%% of measured %d", got, want)
+			}
+		})
+	}
+}
+
+func TestMemoryFootprintPayload(t *testing.T) {
+	short := NewDict(Byte, []string{"a", "b"})
+	long := NewDict(Byte, []string{strings.Repeat("a", 1000), strings.Repeat("b", 1000)})
+
+	if got, want := long.MemoryFootprint()-short.MemoryFootprint(), int64(1998); got != want {
+		t.Errorf("payload difference = %d, want %d", got, want)
+	}
+
+	empty := NewDict[int64](Byte, nil)
+	// One representative and the sample counts of its three codes.
+	if got, want := empty.MemoryFootprint(), int64(unsafe.Sizeof(empty)+8+3*8); got != want {
+		t.Errorf("empty footprint = %d, want %d", got, want)
+	}
+}
+
+func TestMemoryFootprintClusters(t *testing.T) {
+	// Byte mode has fewer codes than the sample has values, so some of the
+	// stored values aren't representatives.
+	strs := make([]string, 1000)
+	for i := range strs {
+		strs[i] = fmt.Sprintf("%010d", i)
+	}
+	plain := NewDict(Byte, strs)
+	stored := NewDictWithOptions(Byte, strs, WithStoreClusters())
+
+	// Only the payloads of values that aren't representatives are new.
+	want := plain.MemoryFootprint() + int64(len(strs))*int64(unsafe.Sizeof(Cluster[string]{})) +
+		int64(len(strs)-plain.Len())*10
+	if got := stored.MemoryFootprint(); got != want {
+		t.Errorf("footprint with clusters = %d, want %d", got, want)
+	}
+}
+
+var sink any
@@ -0,0 +1,116 @@
+package colsketch
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// String renders the code in hexadecimal, e.g. "0x00a7".
+func (c Code) String() string {
+	return fmt.Sprintf("0x%04x", uint16(c))
+}
+
+// String returns the name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case Byte:
+		return "Byte"
+	case Word:
+		return "Word"
+	default:
+		return fmt.Sprintf("Mode(%d)", uint16(m))
+	}
+}
+
+// FormatCode renders a code in terms of the values it represents: "=v" for an
+// exact code with representative v, and "(lo, hi)" for an inexact code lying
+// between representatives lo and hi, with "-inf" and "+inf" standing in for
+// the unbounded ends of the first and last inexact codes. Codes the
+// dictionary doesn't assign are rendered as "invalid(0x....)".
+func (d *Dict[T]) FormatCode(c Code) string {
+	iv, ok := d.Bounds(c)
+	switch {
+	case !ok:
+		return fmt.Sprintf("invalid(%s)", c)
+	case iv.Exact:
+		return fmt.Sprintf("=%v", iv.Lo)
+	}
+
+	lo, hi := "-inf", "+inf"
+	if iv.HasLo {
+		lo = fmt.Sprint(iv.Lo)
+	}
+	if iv.HasHi {
+		hi = fmt.Sprint(iv.Hi)
+	}
+	return fmt.Sprintf("(%s, %s)", lo, hi)
+}
+
+// Fmt pairs a code with the dictionary that assigned it so that it can be
+// passed to the fmt functions. See FormattedCode.
+func (d *Dict[T]) Fmt(c Code) FormattedCode[T] {
+	return FormattedCode[T]{d, c}
+}
+
+// FormattedCode is a code paired with its dictionary. With the %v and %s
+// verbs it renders as the code followed by FormatCode's rendering, e.g.
+// "0x0003(10, 20)"; other verbs format the bare code.
+type FormattedCode[T cmp.Ordered] struct {
+	Dict *Dict[T]
+	Code Code
+}
+
+// Format implements fmt.Formatter.
+func (fc FormattedCode[T]) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's':
+		fmt.Fprintf(f, "%s%s", fc.Code, fc.Dict.FormatCode(fc.Code))
+	default:
+		fmt.Fprintf(f, fmt.FormatString(f, verb), uint16(fc.Code))
+	}
+}
+
+// GoString implements fmt.GoStringer, rendering the dictionary as the Go
+// expression that rebuilds it, e.g.
+// `colsketch.NewDict(colsketch.Byte, []string{"apple", "banana"})`, so that
+// a dictionary printed with %#v in a test failure can be pasted into a
+// reproduction. NewDict over the representatives of a dictionary assigns
+// them the same codes, but the expression holds neither sample counts nor
+// Eytzinger layouts, and a dictionary without representatives renders as
+// the zero Dict, whatever its mode. It has a value receiver, like the
+// marshalers of Dict, so that dictionaries printed by value use it too.
+func (d Dict[T]) GoString() string {
+	typ := fmt.Sprintf("%T", *new(T))
+	if len(d.codes) == 0 && d.mode.IsValid() {
+		return fmt.Sprintf("colsketch.Dict[%s]{}", typ)
+	}
+
+	var b strings.Builder
+	if d.mode.IsValid() {
+		fmt.Fprintf(&b, "colsketch.NewDict(colsketch.%s, []%s{", d.mode, typ)
+	} else {
+		fmt.Fprintf(&b, "colsketch.NewDict(colsketch.Mode(%d), []%s{", uint16(d.mode), typ)
+	}
+	k := kindOf[T]()
+	for i, v := range d.codes {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		switch f := reflect.ValueOf(v); {
+		case k == kindString:
+			b.WriteString(strconv.Quote(f.String()))
+		case (k == kindFloat32 || k == kindFloat64) && math.IsNaN(f.Float()):
+			fmt.Fprintf(&b, "%s(math.NaN())", typ)
+		case (k == kindFloat32 || k == kindFloat64) && math.IsInf(f.Float(), 0):
+			fmt.Fprintf(&b, "%s(math.Inf(%d))", typ, int(math.Copysign(1, f.Float())))
+		default:
+			fmt.Fprint(&b, v)
+		}
+	}
+	b.WriteString("})")
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package colsketch
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	if got, want := Code(0xa7).String(), "0x00a7"; got != want {
+		t.Errorf("Code.String() = %q, want %q", got, want)
+	}
+
+	for mode, want := range map[Mode]string{Byte: "Byte", Word: "Word", Mode(7): "Mode(7)"} {
+		if got := mode.String(); got != want {
+			t.Errorf("Mode.String() = %q, want %q", got, want)
+		}
+	}
+
+	dict := NewDict(Byte, []string{"ape", "bee", "cat"})
+	for code, want := range map[Code]string{
+		0: "invalid(0x0000)",
+		1: "(-inf, ape)",
+		2: "=ape",
+		3: "(ape, bee)",
+		4: "=bee",
+		5: "(bee, cat)",
+		6: "=cat",
+		7: "(cat, +inf)",
+		8: "invalid(0x0008)",
+	} {
+		if got := dict.FormatCode(code); got != want {
+			t.Errorf("FormatCode(%d) = %q, want %q", code, got, want)
+		}
+	}
+
+	for format, want := range map[string]string{
+		"%v":   "0x0003(ape, bee)",
+		"%s":   "0x0003(ape, bee)",
+		"%d":   "3",
+		"%04x": "0003",
+	} {
+		if got := fmt.Sprintf(format, dict.Fmt(3)); got != want {
+			t.Errorf("Sprintf(%q) = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestDictGoString(t *testing.T) {
+	strs := NewDict(Byte, []string{"banana", "apple", "a\"b\xff"})
+	floats := NewDict(Word, []float64{math.NaN(), math.Inf(-1), -1.5, 0, 1e300, math.Inf(1)})
+	for _, tc := range []struct {
+		got, want string
+	}{
+		{fmt.Sprintf("%#v", strs), `colsketch.NewDict(colsketch.Byte, []string{"a\"b\xff", "apple", "banana"})`},
+		{fmt.Sprintf("%#v", &strs), `colsketch.NewDict(colsketch.Byte, []string{"a\"b\xff", "apple", "banana"})`},
+		{fmt.Sprintf("%#v", NewDict(Word, []uint8{7, 255, 0})), `colsketch.NewDict(colsketch.Word, []uint8{0, 7, 255})`},
+		{fmt.Sprintf("%#v", floats), `colsketch.NewDict(colsketch.Word, []float64{float64(math.NaN()), float64(math.Inf(-1)), -1.5, 0, 1e+300, float64(math.Inf(1))})`},
+		{fmt.Sprintf("%#v", NewDict(Byte, []float32{float32(math.NaN()), 0.1})), `colsketch.NewDict(colsketch.Byte, []float32{float32(math.NaN()), 0.1})`},
+		{fmt.Sprintf("%#v", Dict[int64]{}), `colsketch.Dict[int64]{}`},
+		{fmt.Sprintf("%#v", NewDict(Mode(7), []int{1})), `colsketch.NewDict(colsketch.Mode(7), []int{})`},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("GoString() = %s, want %s", tc.got, tc.want)
+		}
+	}
+
+	// The rendering rebuilds a dictionary with the same codes.
+	rebuilt := NewDict(Byte, []string{"a\"b\xff", "apple", "banana"})
+	if !reflect.DeepEqual(rebuilt.codes, strs.codes) {
+		t.Errorf("rebuilt representatives %q, want %q", rebuilt.codes, strs.codes)
+	}
+}
@@ -0,0 +1,48 @@
+package colsketch
+
+// CodeHistogram returns the number of live rows of the sketch holding each
+// code, indexed by code, from NullCode's, which only sketches built
+// WithNulls hold, to TotalCodes' of the dictionary. It tells, e.g., whether
+// a handful of inexact codes dominate the column, which scans can't skip
+// blocks of and which usually means the dictionary was built from a stale
+// sample. It takes a pass over the codes, but counts the rows of blocks of
+// a single code by their headers, and those of runs a run at a time. See
+// SketchSlice.CodeHistogram for that of a range of rows, or of a Block.
+func (s *Sketch[T]) CodeHistogram() []uint64 {
+	return s.codeHistogram(0, s.store.len())
+}
+
+// CodeHistogram is like Sketch.CodeHistogram over the rows of the slice.
+func (v SketchSlice[T]) CodeHistogram() []uint64 {
+	return v.s.codeHistogram(v.lo, v.hi)
+}
+
+// codeHistogram is CodeHistogram over the rows `[from, to)`.
+func (s *Sketch[T]) codeHistogram(from, to int) []uint64 {
+	h := make([]uint64, s.dict.TotalCodes()+1)
+	rs, runs := s.store.(*runStore)
+
+	for i := from / s.blockSize; i*s.blockSize < to; i++ {
+		if s.isDead(i) {
+			continue
+		}
+
+		start, end := s.blockRows(i, from, to)
+		switch b := s.bounds[i]; {
+		case b.min == b.max:
+			h[b.min] += uint64(end - start - s.deletedIn(start, end))
+		case runs:
+			rs.runs(start, end, func(c Code, from, to int) bool {
+				h[c] += uint64(to - from - s.deletedIn(from, to))
+				return true
+			})
+		default:
+			for pos := start; pos < end; pos++ {
+				if !s.IsDeleted(pos) {
+					h[s.store.get(pos)]++
+				}
+			}
+		}
+	}
+	return h
+}
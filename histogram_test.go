@@ -0,0 +1,118 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSketchCodeHistogram(t *testing.T) {
+	dict := NewDict(Byte, []int64{10, 20, 30})
+
+	// 64 rows of code 2, 64 rows cycling through codes 1 to 7, and 32 rows
+	// of NULLs and code 4 in alternating runs of 8.
+	var codes []Code
+	for i := 0; i < 64; i++ {
+		codes = append(codes, 2)
+	}
+	for i := 0; i < 64; i++ {
+		codes = append(codes, Code(1+i%7))
+	}
+	for i := 0; i < 32; i++ {
+		codes = append(codes, Code(4*(i/8%2)))
+	}
+
+	for _, opts := range [][]SketchOption{
+		{WithNulls(), WithBlockSize(64)},
+		{WithNulls(), WithBlockSize(64), WithBitPacking()},
+		{WithNulls(), WithBlockSize(64), WithRunLength()},
+		{WithNulls(), WithBlockSize(128)},
+	} {
+		s := NewSketch(&dict, opts...)
+		s.AppendCodes(codes)
+		name := fmt.Sprintf("block size %d, %d bits", s.BlockSize(), s.BitsPerCode())
+
+		for _, tc := range []struct {
+			name      string
+			got, want []uint64
+		}{
+			{"sketch", s.CodeHistogram(), []uint64{16, 10, 73, 9, 25, 9, 9, 9}},
+			{"first rows", s.Slice(0, 64).CodeHistogram(), []uint64{0, 0, 64, 0, 0, 0, 0, 0}},
+			{"middle rows", s.Slice(64, 128).CodeHistogram(), []uint64{0, 10, 9, 9, 9, 9, 9, 9}},
+			{"last rows", s.Slice(128, 160).CodeHistogram(), []uint64{16, 0, 0, 0, 16, 0, 0, 0}},
+			{"straddling", s.Slice(60, 72).CodeHistogram(), []uint64{0, 2, 5, 1, 1, 1, 1, 1}},
+			{"empty", s.Slice(10, 10).CodeHistogram(), make([]uint64, 8)},
+		} {
+			if !reflect.DeepEqual(tc.got, tc.want) {
+				t.Errorf("%s: %s CodeHistogram() = %v, want %v", name, tc.name, tc.got, tc.want)
+			}
+		}
+
+		// Deleted rows aren't counted.
+		for _, pos := range []int{0, 1, 64, 65, 129, 136} {
+			s.Delete(pos)
+		}
+		for pos := 144; pos < 160; pos++ {
+			s.Delete(pos)
+		}
+		if got, want := s.CodeHistogram(), []uint64{7, 9, 70, 9, 16, 9, 9, 9}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: CodeHistogram() after deletions = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSketchCodeHistogramRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]SketchOption{
+		nil,
+		{WithBlockSize(64), WithNulls()},
+		{WithBitPacking(), WithBlockSize(128)},
+		{WithRunLength(), WithBlockSize(64)},
+	} {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, randomInt64s(rng, 3000, 1000))
+			s := NewSketch(&dict, opts...)
+			values := randomInt64s(rng, 10000, 1002)
+			for i := 0; i < len(values); i += 1000 {
+				// Runs of a single value make blocks of a single code.
+				for j := i; j < i+300; j++ {
+					values[j] = values[i]
+				}
+			}
+			for i, v := range values {
+				if s.Nullable() && i%13 == 0 {
+					s.AppendNull()
+				} else {
+					s.Append(v)
+				}
+			}
+			for i := 0; i < 500; i++ {
+				s.Delete(rng.Intn(s.Len()))
+			}
+
+			for i := 0; i < 20; i++ {
+				lo := rng.Intn(s.Len())
+				hi := lo + rng.Intn(s.Len()-lo+1)
+				want := make([]uint64, dict.TotalCodes()+1)
+				for pos := lo; pos < hi; pos++ {
+					if !s.IsDeleted(pos) {
+						want[s.Get(pos)]++
+					}
+				}
+				if got := s.Slice(lo, hi).CodeHistogram(); !reflect.DeepEqual(got, want) {
+					t.Fatalf("%s, %d bits: CodeHistogram() of rows [%d, %d) differs", mode, s.BitsPerCode(), lo, hi)
+				}
+			}
+
+			var total uint64
+			for _, n := range s.CodeHistogram() {
+				total += n
+			}
+			if total != uint64(s.LiveLen()) {
+				t.Errorf("%s, %d bits: CodeHistogram() counts %d rows, want %d", mode, s.BitsPerCode(), total, s.LiveLen())
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package colsketch
+
+import "unsafe"
+
+// BuildCodeIndex builds an inverted index of the sketch's codes: the sorted
+// positions of the live rows holding each code. With it, PositionsOfCode,
+// and scans and counts of predicates that compile to a single code, such as
+// Eq, take time proportional to the number of rows they return rather than
+// to the length of the sketch, e.g. for repeated point lookups of hot
+// values. It takes a pass over the codes, and a word of memory per live
+// row, which MemoryFootprint accounts for.
+//
+// The index is kept up to date as rows are appended, set and deleted, at
+// the cost of a binary search and a copy of part of the positions of the
+// codes involved for Set and Delete. AppendSketch drops it, as does
+// ReadFrom, and it's only rebuilt by calling BuildCodeIndex again: reads
+// never build it, since a Sketch may be read concurrently.
+func (s *Sketch[T]) BuildCodeIndex() {
+	// Carve the positions of every code out of a single array, capping
+	// their capacities so that appends to one reallocate rather than
+	// overwrite the next.
+	h := s.CodeHistogram()
+	all := make([]int, s.LiveLen())
+	s.index = make([][]int, len(h))
+	for c, n := range h {
+		s.index[c], all = all[:0:n], all[n:]
+	}
+
+	for pos := 0; pos < s.store.len(); pos++ {
+		if !s.IsDeleted(pos) {
+			c := s.store.get(pos)
+			s.index[c] = append(s.index[c], pos)
+		}
+	}
+}
+
+// DropCodeIndex drops the sketch's inverted index, if any, releasing its
+// memory. See BuildCodeIndex.
+func (s *Sketch[T]) DropCodeIndex() {
+	s.index = nil
+}
+
+// HasCodeIndex returns true iff the sketch holds an inverted index. See
+// BuildCodeIndex.
+func (s *Sketch[T]) HasCodeIndex() bool {
+	return s.index != nil
+}
+
+// PositionsOfCode returns the positions of the live rows holding the code,
+// in increasing order, from the sketch's inverted index, and true. It
+// returns nil for codes no row holds, including codes the dictionary
+// doesn't assign, and false if the sketch holds no index; see
+// BuildCodeIndex.
+func (s *Sketch[T]) PositionsOfCode(c Code) ([]int, bool) {
+	if s.index == nil {
+		return nil, false
+	}
+	if int(c) >= len(s.index) || len(s.index[c]) == 0 {
+		return nil, true
+	}
+	return append([]int(nil), s.index[c]...), true
+}
+
+// indexed returns the positions of the live rows holding the code of a
+// filter of a single code, and true, if the sketch holds an index.
+func (s *Sketch[T]) indexed(f codeFilter) ([]int, bool) {
+	if s.index == nil || f.lo != f.hi || f.except {
+		return nil, false
+	}
+	if int(f.lo) >= len(s.index) {
+		return nil, true
+	}
+	return s.index[f.lo], true
+}
+
+// indexedRows returns the positions within `[from, to)` of an index's
+// positions of a code.
+func indexedRows(positions []int, from, to int) []int {
+	return positions[lowerBound(positions, from):lowerBound(positions, to)]
+}
+
+// indexAppend adds the positions of rows appended at position n with the
+// codes to the index, if any.
+func (s *Sketch[T]) indexAppend(n int, codes []Code) {
+	if s.index == nil {
+		return
+	}
+	for j, c := range codes {
+		s.index[c] = append(s.index[c], n+j)
+	}
+}
+
+// indexRemove removes the position of a row from the positions of its code
+// in the index, if any.
+func (s *Sketch[T]) indexRemove(pos int, c Code) {
+	if s.index == nil {
+		return
+	}
+	p := s.index[c]
+	if j := lowerBound(p, pos); j < len(p) && p[j] == pos {
+		s.index[c] = append(p[:j], p[j+1:]...)
+	}
+}
+
+// indexInsert inserts the position of a row into the positions of its code
+// in the index, if any.
+func (s *Sketch[T]) indexInsert(pos int, c Code) {
+	if s.index == nil {
+		return
+	}
+	p := s.index[c]
+	j := lowerBound(p, pos)
+	p = append(p, 0)
+	copy(p[j+1:], p[j:])
+	p[j] = pos
+	s.index[c] = p
+}
+
+// indexFootprint returns the number of bytes of memory the index retains.
+func (s *Sketch[T]) indexFootprint() int64 {
+	n := int64(cap(s.index)) * int64(unsafe.Sizeof([]int(nil)))
+	for _, p := range s.index {
+		n += int64(cap(p)) * int64(unsafe.Sizeof(0))
+	}
+	return n
+}
@@ -0,0 +1,174 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSketchCodeIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]SketchOption{
+		nil,
+		{WithBlockSize(64), WithNulls()},
+		{WithBitPacking(), WithBlockSize(128)},
+		{WithRunLength(), WithBlockSize(64)},
+	} {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, randomInt64s(rng, 2000, 500))
+
+			// The same rows in a sketch with an index and in one without,
+			// to compare the scans of.
+			indexed, scanned := NewSketch(&dict, opts...), NewSketch(&dict, opts...)
+			values := randomInt64s(rng, 3000, 502)
+			indexed.AppendValues(values[:1000])
+			scanned.AppendValues(values[:1000])
+			if indexed.HasCodeIndex() {
+				t.Fatalf("sketch holds an index before it's built")
+			}
+			indexed.BuildCodeIndex()
+			name := fmt.Sprintf("%s, %d bits", mode, indexed.BitsPerCode())
+
+			// The index is maintained as rows are appended, set and deleted.
+			for _, s := range []*Sketch[int64]{indexed, scanned} {
+				s.AppendValues(values[1000:2000])
+				for _, v := range values[2000:] {
+					s.Append(v)
+				}
+				if s.Nullable() {
+					s.AppendNull()
+					s.AppendCodes([]Code{NullCode, 2})
+				}
+			}
+			for i := 0; i < 500; i++ {
+				pos, v := rng.Intn(indexed.Len()), rng.Int63n(502)
+				indexed.Set(pos, v)
+				scanned.Set(pos, v)
+				pos = rng.Intn(indexed.Len())
+				indexed.Delete(pos)
+				scanned.Delete(pos)
+			}
+			if !indexed.HasCodeIndex() || scanned.HasCodeIndex() {
+				t.Fatalf("%s: HasCodeIndex() = %v, %v, want true, false", name, indexed.HasCodeIndex(), scanned.HasCodeIndex())
+			}
+			testCodeIndex(t, name, indexed, scanned)
+
+			// Appending a sketch drops the index, which reads don't
+			// rebuild.
+			indexed.AppendSketch(scanned)
+			scanned.AppendSketch(scanned)
+			if indexed.HasCodeIndex() {
+				t.Fatalf("%s: AppendSketch() kept the index", name)
+			}
+			if p, ok := indexed.PositionsOfCode(1); ok || p != nil || indexed.HasCodeIndex() {
+				t.Fatalf("%s: PositionsOfCode() without an index = %v, %v", name, p, ok)
+			}
+			indexed.BuildCodeIndex()
+			testCodeIndex(t, name+", appended", indexed, scanned)
+
+			before := indexed.MemoryFootprint()
+			indexed.DropCodeIndex()
+			if after := indexed.MemoryFootprint(); after+int64(8*indexed.LiveLen()) > before {
+				t.Errorf("%s: MemoryFootprint() with the index = %d, without = %d", name, before, after)
+			}
+		}
+	}
+}
+
+// testCodeIndex checks the positions of the index of a sketch, and its
+// scans, against the codes and scans of a sketch of the same rows without
+// an index.
+func testCodeIndex(t *testing.T, name string, indexed, scanned *Sketch[int64]) {
+	t.Helper()
+
+	want := make([][]int, indexed.Dict().TotalCodes()+2)
+	for pos := 0; pos < scanned.Len(); pos++ {
+		if !scanned.IsDeleted(pos) {
+			c := scanned.Get(pos)
+			want[c] = append(want[c], pos)
+		}
+	}
+	for c := range want {
+		if got, ok := indexed.PositionsOfCode(Code(c)); !ok || !reflect.DeepEqual(got, want[c]) {
+			t.Fatalf("%s: PositionsOfCode(%d) = %v, %v, want %v", name, c, got, ok, want[c])
+		}
+	}
+
+	preds := []Predicate[int64]{IsNull[int64](), Lt(int64(-1)), Gt(int64(600))}
+	for _, v := range indexed.Dict().codes[:10] {
+		preds = append(preds, Eq(v), Eq(v+1), Between(v, v))
+	}
+	for _, p := range preds {
+		for _, s := range []SketchSlice[int64]{indexed.Slice(0, indexed.Len()), indexed.Slice(100, 2100)} {
+			ss := scanned.Slice(s.Offset(), s.Offset()+s.Len())
+
+			type row struct {
+				pos int
+				c   Certainty
+			}
+			var got, want []row
+			s.ScanCertain(p, func(pos int, c Certainty) bool {
+				got = append(got, row{pos, c})
+				return true
+			})
+			ss.ScanCertain(p, func(pos int, c Certainty) bool {
+				want = append(want, row{pos, c})
+				return true
+			})
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s: %+v: indexed ScanCertain() visited %v, want %v", name, p, got, want)
+			}
+
+			var gotScan, wantScan []int
+			s.Scan(p, func(pos int) bool {
+				gotScan = append(gotScan, pos)
+				return true
+			})
+			ss.Scan(p, func(pos int) bool {
+				wantScan = append(wantScan, pos)
+				return true
+			})
+			if !reflect.DeepEqual(gotScan, wantScan) {
+				t.Fatalf("%s: %+v: indexed Scan() visited %v, want %v", name, p, gotScan, wantScan)
+			}
+
+			gc, gd := s.Count(p)
+			wc, wd := ss.Count(p)
+			if gc != wc || gd != wd {
+				t.Fatalf("%s: %+v: indexed Count() = %d, %d, want %d, %d", name, p, gc, gd, wc, wd)
+			}
+		}
+	}
+}
+
+func BenchmarkSketchPositionsOfCode(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Word, randomInt64s(rng, 100000, 1<<20))
+	s := NewSketch(&dict)
+	s.AppendValues(randomInt64s(rng, 1<<22, 1<<20))
+	p := Eq(dict.codes[len(dict.codes)/2])
+
+	b.Run("Scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			n := 0
+			s.Scan(p, func(int) bool {
+				n++
+				return true
+			})
+			codeSink = Code(n)
+		}
+	})
+	s.BuildCodeIndex()
+	b.Run("Index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			n := 0
+			s.Scan(p, func(int) bool {
+				n++
+				return true
+			})
+			codeSink = Code(n)
+		}
+	})
+}
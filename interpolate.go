@@ -0,0 +1,104 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// EncodeAllInt64 is EncodeAll for dictionaries of int64 values, searching
+// the representatives by interpolation rather than by bisection: each probe
+// estimates the position of a value from where it falls between the
+// representatives bounding the search, which takes O(log log n) probes
+// rather than O(log n) for representatives spread uniformly, as those of
+// samples of uniformly distributed values are. Each probe costs a division,
+// though, so it pays off over binary searching a value at a time for large
+// dictionaries, whose binary searches miss the cache, rather than small ones.
+// It doesn't overlap the searches of different values as EncodeAll does for
+// large batches, which hides more of those misses.
+//
+// Skewed representatives make estimates undershoot or overshoot the value
+// over and over. Searches that haven't converged within a few probes finish
+// by binary search, and once a quarter of the values encoded so far needed
+// to, the rest are encoded by EncodeAll. Codes are the same either way. It
+// panics if the dictionary isn't a Dict[int64].
+func (d *Dict[T]) EncodeAllInt64(values []int64, dst []Code) []Code {
+	s, ok := any(d.codes).([]int64)
+	if !ok {
+		panic(fmt.Sprintf("colsketch: EncodeAllInt64 on a Dict[%T]", *new(T)))
+	}
+
+	if cap(dst) < len(values) {
+		dst = make([]Code, len(values))
+	}
+	dst = dst[:len(values)]
+
+	fallbacks := 0
+	for i, v := range values {
+		if i >= interpolationTrial && 4*fallbacks > i {
+			d.EncodeAll(any(values[i:]).([]T), dst[i:])
+			break
+		}
+
+		var fell bool
+		dst[i], fell = encodeInterpolated(s, v)
+		fallbacks += int(b2u(fell))
+	}
+	return dst
+}
+
+const (
+	// interpolationProbes is the number of probes after which a search by
+	// interpolation falls back to binary search.
+	interpolationProbes = 4
+
+	// interpolationTrial is the number of values EncodeAllInt64 encodes by
+	// interpolation before judging whether it pays off.
+	interpolationTrial = 64
+)
+
+// encodeInterpolated returns the code of value in a dictionary whose sorted
+// representatives are s, found by interpolation search, and whether the
+// search fell back to binary search.
+func encodeInterpolated(s []int64, value int64) (Code, bool) {
+	n := len(s)
+	switch {
+	case n == 0 || value < s[0]:
+		return 1, false
+	case value == s[n-1]:
+		return Code(2 * n), false
+	case value > s[n-1]:
+		return Code(2*n + 1), false
+	}
+
+	// s[a] <= value < s[b]. The estimate of the position of value, in
+	// `[a, b)`, is computed in 128 bits, since neither the distance between
+	// two int64s nor its product with a number of representatives need fit
+	// in 64.
+	a, b := 0, n-1
+	fell := false
+	for probes := 0; b-a > 1; probes++ {
+		if probes == interpolationProbes {
+			a += upperBound(s[a+1:b], value)
+			fell = true
+			break
+		}
+
+		hi, lo := bits.Mul64(uint64(value)-uint64(s[a]), uint64(b-a))
+		q, _ := bits.Div64(hi, lo, uint64(s[b])-uint64(s[a]))
+		m := a + int(q)
+		if m == a {
+			m++
+		}
+
+		if s[m] <= value {
+			a = m
+		} else {
+			b = m
+		}
+	}
+
+	if s[a] == value {
+		return Code(2 * (a + 1)), fell
+	}
+	return Code(2*(a+1) + 1), fell
+}
@@ -0,0 +1,113 @@
+package colsketch
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// exponentialInt64s returns n int64s with exponentially distributed magnitudes,
+// so that most are small and a few are huge, of either sign.
+func exponentialInt64s(rng *rand.Rand, n int) []int64 {
+	s := make([]int64, n)
+	for i := range s {
+		s[i] = int64(math.Exp(rng.Float64() * 43))
+		if rng.Intn(2) == 0 {
+			s[i] = -s[i]
+		}
+	}
+	return s
+}
+
+func TestEncodeAllInt64(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	samples := map[string][]int64{
+		"single":   {42},
+		"uniform":  randomInt64s(rng, 100000, 1<<40),
+		"skewed":   exponentialInt64s(rng, 100000),
+		"extremes": {math.MinInt64, -1, 0, 1, math.MaxInt64},
+		"clustered": append(randomInt64s(rng, 50000, 1000),
+			randomInt64s(rng, 10, math.MaxInt64)...),
+	}
+	for name, sample := range samples {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, sample)
+
+			values := append(randomInt64s(rng, 3000, 1<<40), exponentialInt64s(rng, 3000)...)
+			values = append(values, sample[:len(sample)/10+1]...)
+			values = append(values, math.MinInt64, math.MaxInt64, 0, -1)
+			for _, v := range dict.codes {
+				values = append(values, v-1, v, v+1)
+			}
+			rng.Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
+
+			got := dict.EncodeAllInt64(values, nil)
+			for i, v := range values {
+				if want := dict.Encode(v); got[i] != want {
+					t.Fatalf("%s, %s: EncodeAllInt64()[%d] of %d = %d, want %d", name, mode, i, v, got[i], want)
+				}
+			}
+		}
+	}
+
+	var empty Dict[int64]
+	if got := empty.EncodeAllInt64([]int64{-5, 0, 5}, nil); len(got) != 3 || got[0] != 1 || got[2] != 1 {
+		t.Errorf("EncodeAllInt64() on an empty dictionary = %v, want all 1", got)
+	}
+
+	// dst is reused when it has room.
+	dict := NewDict(Byte, []int64{1, 2, 3})
+	dst := make([]Code, 0, 8)
+	if got := dict.EncodeAllInt64([]int64{2, 3}, dst); &got[0] != &dst[:1][0] {
+		t.Errorf("EncodeAllInt64() didn't reuse dst")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("EncodeAllInt64() on a Dict[int] didn't panic")
+		}
+	}()
+	ints := NewDict(Byte, []int{1, 2, 3})
+	ints.EncodeAllInt64([]int64{1}, nil)
+}
+
+func BenchmarkEncodeAllInt64(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 20
+
+	for _, tc := range []struct {
+		name           string
+		sample, values []int64
+	}{
+		{"uniform", randomInt64s(rng, n, 1<<40), randomInt64s(rng, n, 1<<40)},
+		{"skewed", exponentialInt64s(rng, n), exponentialInt64s(rng, n)},
+	} {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, tc.sample)
+			dst := make([]Code, len(tc.values))
+			name := fmt.Sprintf("%s/%s", tc.name, mode)
+
+			b.Run(name+"/Interpolation", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					dst = dict.EncodeAllInt64(tc.values, dst)
+				}
+				b.ReportMetric(float64(b.N*len(tc.values))/b.Elapsed().Seconds(), "values/s")
+			})
+			b.Run(name+"/EncodeAll", func(b *testing.B) {
+				benchmarkEncodeAll(b, &dict, tc.values)
+			})
+			// Binary search a value at a time, as EncodeAll does for
+			// dictionaries too small for its batched paths.
+			b.Run(name+"/Binary", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					for j, v := range tc.values {
+						dst[j] = dict.Encode(v)
+					}
+				}
+				b.ReportMetric(float64(b.N*len(tc.values))/b.Elapsed().Seconds(), "values/s")
+			})
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package colsketch
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// dictJSON is the JSON encoding of a dictionary. Its field names are part
+// of the format and must never change.
+type dictJSON struct {
+	Mode     string            `json:"mode"`
+	Kind     string            `json:"kind"`
+	Values   []json.RawMessage `json:"values"`
+	Counts   []int             `json:"counts,omitempty"`
+	Distinct int               `json:"distinct,omitempty"`
+}
+
+// maxJSONInt is the magnitude beyond which JSON numbers can't be relied on
+// to hold integers exactly: that of the largest integers float64s hold.
+const maxJSONInt = 1 << 53
+
+// MarshalJSON implements json.Marshaler, encoding the dictionary as an
+// object for debugging and small, hand-maintained dictionaries, e.g.
+//
+//	{"mode":"Byte","kind":"int64","values":[1,2,3],"counts":[0,4,0,2,1,5,0],"distinct":3}
+//
+// where values are the representatives in increasing order, counts the
+// sample counts by code, if retained, and distinct the number of distinct
+// sample values, if known. Values JSON numbers can't hold faithfully are
+// written as strings: NaN and ±Inf floats as "NaN", "+Inf" and "-Inf", and
+// integers of a magnitude beyond 2^53, such as large uint64s, in decimal.
+// Strings must be valid UTF-8, which JSON strings are.
+//
+// It has a value receiver, so that encoding/json uses it for dictionaries
+// held by value, such as fields of a configuration struct marshaled by
+// value, which it would otherwise encode as {}.
+func (d Dict[T]) MarshalJSON() ([]byte, error) {
+	k := kindOf[T]()
+	if k == kindInvalid {
+		return nil, fmt.Errorf("colsketch: unsupported value type %T", *new(T))
+	}
+
+	enc := dictJSON{
+		Mode:     d.mode.String(),
+		Kind:     k.String(),
+		Values:   make([]json.RawMessage, len(d.codes)),
+		Counts:   d.counts,
+		Distinct: d.numClusters,
+	}
+	for i, v := range d.codes {
+		b, err := appendValueJSON(nil, k, v)
+		if err != nil {
+			return nil, fmt.Errorf("colsketch: representative %d: %w", i, err)
+		}
+		enc.Values[i] = b
+	}
+	return json.Marshal(enc)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, decoding the format of
+// MarshalJSON, in which integers may be numbers or decimal strings whatever
+// their magnitude. Like other Unmarshalers, it takes null as a no-op. It
+// rejects input other than an object, objects with unknown fields or of
+// another value type, and dictionaries that fail Validate, and only
+// modifies the receiver if decoding succeeds.
+func (d *Dict[T]) UnmarshalJSON(data []byte) error {
+	k := kindOf[T]()
+	if k == kindInvalid {
+		return fmt.Errorf("colsketch: unsupported value type %T", *new(T))
+	}
+
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) == 0 || data[0] != '{' {
+		return fmt.Errorf("colsketch: invalid dictionary JSON: not an object")
+	}
+
+	var enc dictJSON
+	dj := json.NewDecoder(bytes.NewReader(data))
+	dj.DisallowUnknownFields()
+	if err := dj.Decode(&enc); err != nil {
+		return fmt.Errorf("colsketch: invalid dictionary JSON: %w", err)
+	}
+
+	var dec Dict[T]
+	switch enc.Mode {
+	case "Byte":
+		dec.mode = Byte
+	case "Word":
+		dec.mode = Word
+	default:
+		return fmt.Errorf("colsketch: invalid mode %q", enc.Mode)
+	}
+	if enc.Kind != k.String() {
+		return fmt.Errorf("colsketch: cannot decode %s dictionary into Dict[%T]", enc.Kind, *new(T))
+	}
+	if n := dec.mode.NumExactCodes(); len(enc.Values) > n {
+		return fmt.Errorf("colsketch: %d representatives exceed the %d exact codes of the mode", len(enc.Values), n)
+	}
+
+	dec.codes = make([]T, len(enc.Values))
+	for i, raw := range enc.Values {
+		v, err := parseValueJSON[T](raw, k)
+		if err != nil {
+			return fmt.Errorf("colsketch: representative %d: %w", i, err)
+		}
+		dec.codes[i] = v
+	}
+
+	if enc.Counts != nil {
+		for _, c := range enc.Counts {
+			if c < 0 || c > math.MaxInt-dec.sampleSize {
+				return fmt.Errorf("colsketch: invalid sample count %d", c)
+			}
+			dec.sampleSize += c
+		}
+		dec.counts = enc.Counts
+	}
+	// Each representative is one of the distinct sample values.
+	if enc.Distinct < 0 || (enc.Distinct > 0 && enc.Distinct < len(dec.codes)) {
+		return fmt.Errorf("colsketch: invalid distinct sample value count %d", enc.Distinct)
+	}
+	dec.numClusters = enc.Distinct
+
+	if err := dec.Validate(); err != nil {
+		return err
+	}
+	*d = dec
+	return nil
+}
+
+// appendValueJSON appends the JSON encoding of a value of kind k.
+func appendValueJSON[T cmp.Ordered](b []byte, k valueKind, v T) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	switch {
+	case k == kindString:
+		if !utf8.ValidString(rv.String()) {
+			return nil, fmt.Errorf("invalid UTF-8 string %q", rv.String())
+		}
+		s, err := json.Marshal(rv.String())
+		return append(b, s...), err
+	case k == kindFloat32 || k == kindFloat64:
+		switch f := rv.Float(); {
+		case math.IsNaN(f):
+			return append(b, `"NaN"`...), nil
+		case math.IsInf(f, 1):
+			return append(b, `"+Inf"`...), nil
+		case math.IsInf(f, -1):
+			return append(b, `"-Inf"`...), nil
+		}
+	case rv.CanInt():
+		if n := rv.Int(); n > maxJSONInt || n < -maxJSONInt {
+			return strconv.AppendQuote(b, strconv.FormatInt(n, 10)), nil
+		}
+	default:
+		if n := rv.Uint(); n > maxJSONInt {
+			return strconv.AppendQuote(b, strconv.FormatUint(n, 10)), nil
+		}
+	}
+	return appendValueText(b, k, v), nil
+}
+
+// parseValueJSON parses the JSON encoding of a value of kind k.
+func parseValueJSON[T cmp.Ordered](raw json.RawMessage, k valueKind) (T, error) {
+	var s string
+	quoted := len(raw) > 0 && raw[0] == '"'
+	if quoted {
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return *new(T), err
+		}
+	}
+
+	switch {
+	case k == kindString:
+		if !quoted {
+			return *new(T), fmt.Errorf("invalid string %s", raw)
+		}
+		var v T
+		reflect.ValueOf(&v).Elem().SetString(s)
+		return v, nil
+	case !quoted:
+		s = string(raw)
+	case (k == kindFloat32 || k == kindFloat64) && s != "NaN" && s != "+Inf" && s != "-Inf":
+		return *new(T), fmt.Errorf("invalid float %s", raw)
+	}
+	return parseValueText[T](s, k)
+}
@@ -0,0 +1,211 @@
+package colsketch
+
+import (
+	"cmp"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var (
+	_ json.Marshaler   = Dict[int]{}
+	_ json.Unmarshaler = (*Dict[int])(nil)
+)
+
+func testMarshalJSONRoundTrip[T cmp.Ordered](t *testing.T, mode Mode, sample []T) {
+	t.Helper()
+
+	dict := NewDict(mode, sample)
+	data, err := json.Marshal(&dict)
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+
+	var got Dict[T]
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s) = %v", data, err)
+	}
+	if !reflect.DeepEqual(got, dict) {
+		t.Errorf("round trip of %s = %v, want %v", data, got, dict)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	testMarshalJSONRoundTrip(t, Byte, []int{-5, 0, 7, math.MaxInt, math.MinInt})
+	testMarshalJSONRoundTrip(t, Word, []int8{-128, -1, 0, 127})
+	testMarshalJSONRoundTrip(t, Byte, []int16{-300, 300})
+	testMarshalJSONRoundTrip(t, Byte, []int32{math.MinInt32, 1})
+	testMarshalJSONRoundTrip(t, Byte, []int64{math.MinInt64, -1 << 53, 1<<53 + 1, math.MaxInt64})
+	testMarshalJSONRoundTrip(t, Byte, []uint{0, math.MaxUint})
+	testMarshalJSONRoundTrip(t, Byte, []uint8{0, 255})
+	testMarshalJSONRoundTrip(t, Byte, []uint16{0, 65535})
+	testMarshalJSONRoundTrip(t, Byte, []uint32{0, math.MaxUint32})
+	testMarshalJSONRoundTrip(t, Byte, []uint64{0, 1 << 53, math.MaxUint64})
+	testMarshalJSONRoundTrip(t, Byte, []uintptr{0, 42})
+	testMarshalJSONRoundTrip(t, Byte, []float32{-1.5, 0, math.MaxFloat32, float32(math.Inf(1))})
+	testMarshalJSONRoundTrip(t, Word, []float64{math.Inf(-1), -1.5, 0, math.SmallestNonzeroFloat64, math.Inf(1)})
+	testMarshalJSONRoundTrip(t, Word, []string{"", "a", "ab", "\x00é\n\"<"})
+	testMarshalJSONRoundTrip[string](t, Byte, nil)
+
+	// Dictionaries without sample counts, such as those decoded from text,
+	// omit them.
+	var dict Dict[int64]
+	if err := dict.UnmarshalText([]byte("mode:Word\n1\n2\n")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(&dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"mode":"Word","kind":"int64","values":[1,2]}`; string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+}
+
+// TestMarshalJSONFormat checks that the field names and the encoding of
+// values don't change.
+func TestMarshalJSONFormat(t *testing.T) {
+	for _, tc := range []struct {
+		dict json.Marshaler
+		want string
+	}{
+		{
+			ptr(NewDict(Byte, []int64{3, 1, 2, 2, -1 << 60})),
+			`{"mode":"Byte","kind":"int64","values":["-1152921504606846976",1,2,3],"counts":[0,1,0,1,0,2,0,1,0],"distinct":4}`,
+		},
+		{
+			ptr(NewDict(Word, []float64{math.NaN(), math.Inf(-1), 0.5, math.Inf(1)})),
+			`{"mode":"Word","kind":"float64","values":["NaN","-Inf",0.5,"+Inf"],"counts":[0,1,0,1,0,1,0,1,0],"distinct":4}`,
+		},
+		{
+			ptr(NewDict(Byte, []string{"b", "a"})),
+			`{"mode":"Byte","kind":"string","values":["a","b"],"counts":[0,1,0,1,0],"distinct":2}`,
+		},
+		{
+			ptr(NewDict[uint64](Byte, nil)),
+			`{"mode":"Byte","kind":"uint64","values":[0],"counts":[0,0,0]}`,
+		},
+	} {
+		data, err := json.Marshal(tc.dict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.want {
+			t.Errorf("json.Marshal() = %s, want %s", data, tc.want)
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// TestMarshalJSONByValue checks that dictionaries held by value, which
+// encoding/json can't take the address of, are encoded like any other.
+func TestMarshalJSONByValue(t *testing.T) {
+	type config struct {
+		Name string
+		Dict Dict[int64]
+	}
+
+	want := config{"prices", NewDict(Byte, []int64{3, 1, 2, 2})}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"values":[1,2,3]`) {
+		t.Fatalf("json.Marshal() of a dictionary by value = %s", data)
+	}
+
+	var got config
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip of %s = %v, want %v", data, got, want)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	// Integers may be numbers or strings, and whitespace is allowed.
+	var d Dict[uint64]
+	if err := json.Unmarshal([]byte(` {"mode": "Byte", "kind": "uint64", "values": [1, "2", 18446744073709551615]} `), &d); err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{1, 2, math.MaxUint64}; !reflect.DeepEqual(d.codes, want) || d.counts != nil {
+		t.Errorf("json.Unmarshal() = %v, want representatives %v without counts", d.codes, want)
+	}
+
+	// NaN, which DeepEqual doesn't compare equal to itself, orders first.
+	var f Dict[float64]
+	if err := json.Unmarshal([]byte(`{"mode":"Word","kind":"float64","values":["NaN","-Inf",0.5,"+Inf"]}`), &f); err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(f.codes[0]) || f.Encode(math.NaN()) != 2 || f.Encode(math.Inf(1)) != 8 {
+		t.Errorf("json.Unmarshal() = %v, want NaN, -Inf, 0.5, +Inf", f.codes)
+	}
+
+	// null leaves the dictionary as it was.
+	if err := json.Unmarshal([]byte("null"), &f); err != nil || !math.IsNaN(f.codes[0]) {
+		t.Errorf("json.Unmarshal(null) = %v, modified the dictionary: %v", err, f.codes)
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	// One more representative than the exact codes of Byte mode.
+	values := make([]string, Byte.NumExactCodes()+1)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	budget := strings.Join(values, ",")
+
+	for _, tc := range []struct {
+		name, data string
+	}{
+		{"array", `[1,2]`},
+		{"mode", `{"mode":"Nibble","kind":"int64","values":[1]}`},
+		{"kind", `{"mode":"Byte","kind":"uint64","values":[1]}`},
+		{"unknown field", `{"mode":"Byte","kind":"int64","values":[1],"extra":1}`},
+		{"unsorted", `{"mode":"Byte","kind":"int64","values":[2,1]}`},
+		{"duplicate", `{"mode":"Byte","kind":"int64","values":[1,1]}`},
+		{"fraction", `{"mode":"Byte","kind":"int64","values":[1.5]}`},
+		{"overflow", `{"mode":"Byte","kind":"int64","values":["9223372036854775808"]}`},
+		{"bool", `{"mode":"Byte","kind":"int64","values":[true]}`},
+		{"null value", `{"mode":"Byte","kind":"int64","values":[null]}`},
+		{"counts", `{"mode":"Byte","kind":"int64","values":[1],"counts":[1,2]}`},
+		{"negative count", `{"mode":"Byte","kind":"int64","values":[1],"counts":[1,-2,0]}`},
+		{"distinct", `{"mode":"Byte","kind":"int64","values":[1,2],"distinct":1}`},
+		{"budget", `{"mode":"Byte","kind":"int64","values":[` + budget + `]}`},
+		{"text", `"mode:Byte\n1\n2\n"`},
+		{"number", `1`},
+	} {
+		var d Dict[int64]
+		if err := json.Unmarshal([]byte(tc.data), &d); err == nil {
+			t.Errorf("%s: json.Unmarshal(%s) succeeded", tc.name, tc.data)
+		}
+		if !reflect.DeepEqual(d, Dict[int64]{}) {
+			t.Errorf("%s: failed json.Unmarshal() modified the receiver: %v", tc.name, d)
+		}
+	}
+
+	for _, data := range []string{
+		`{"mode":"Byte","kind":"float64","values":["Infinity"]}`,
+		`{"mode":"Byte","kind":"float64","values":["0.5"]}`,
+		`{"mode":"Byte","kind":"float64","values":[1e400]}`,
+	} {
+		var d Dict[float64]
+		if err := json.Unmarshal([]byte(data), &d); err == nil {
+			t.Errorf("json.Unmarshal(%s) succeeded", data)
+		}
+	}
+
+	var s Dict[string]
+	if err := json.Unmarshal([]byte(`{"mode":"Byte","kind":"string","values":[1]}`), &s); err == nil {
+		t.Errorf("json.Unmarshal() of a number into Dict[string] succeeded")
+	}
+	invalid := NewDict(Byte, []string{"\xff"})
+	if _, err := json.Marshal(&invalid); err == nil {
+		t.Errorf("json.Marshal() of an invalid UTF-8 string succeeded")
+	}
+}
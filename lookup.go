@@ -0,0 +1,26 @@
+package colsketch
+
+// Integer is a constraint that permits any integer type.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// BuildLookupTable materializes the codes of a dictionary over an integer
+// type into a table indexed by value, turning the O(log n) Encode into a
+// single load: entry i holds d.Encode(T(i)). The table has 256 entries for
+// Byte-mode dictionaries and 65536 for Word mode, so it is only a substitute
+// for Encode over values in `[0, len(table))`; for types narrower than the
+// table, T(i) wraps around. The table isn't retained by the dictionary.
+func BuildLookupTable[T Integer](d *Dict[T]) []Code {
+	n := 256
+	if d.mode == Word {
+		n = 65536
+	}
+
+	table := make([]Code, n)
+	for i := range table {
+		table[i] = d.Encode(T(i))
+	}
+	return table
+}
@@ -0,0 +1,41 @@
+package colsketch
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBuildLookupTable(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	sample := make([]uint32, 100000)
+	for i := range sample {
+		sample[i] = uint32(rng.Intn(70000))
+	}
+
+	for _, tc := range []struct {
+		mode Mode
+		size int
+	}{
+		{Byte, 256},
+		{Word, 65536},
+	} {
+		dict := NewDict(tc.mode, sample)
+		table := BuildLookupTable(&dict)
+		if len(table) != tc.size {
+			t.Fatalf("mode %s: len(table) = %d, want %d", tc.mode, len(table), tc.size)
+		}
+		for i, code := range table {
+			if want := dict.Encode(uint32(i)); code != want {
+				t.Fatalf("mode %s: table[%d] = %d, want %d", tc.mode, i, code, want)
+			}
+		}
+	}
+
+	// Narrow signed types wrap around.
+	dict := NewDict(Byte, []int8{-100, 0, 100})
+	table := BuildLookupTable(&dict)
+	if got, want := table[156], dict.Encode(-100); got != want {
+		t.Errorf("table[156] = %d, want Encode(-100) = %d", got, want)
+	}
+}
@@ -0,0 +1,360 @@
+package colsketch
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// The binary format of a dictionary is:
+//
+//	magic    [4]byte  "CSKD"
+//	version  uint8    dictFormatVersion
+//	mode     uint8    Byte or Word
+//	kind     uint8    the valueKind of T
+//	count    uvarint  number of representatives
+//	values   ...      count representatives in increasing order
+//	counted  uint8    1 if sample counts follow, 0 otherwise
+//	counts   uvarint  2*count+1 sample counts, by code, if counted
+//	distinct uvarint  number of distinct sample values, 0 if unknown
+//
+// Values are written as by appendValue.
+const (
+	dictMagic         = "CSKD"
+	dictFormatVersion = 1
+)
+
+// errTruncated is returned when decoding runs out of input.
+var errTruncated = errors.New("colsketch: truncated input")
+
+// valueKind identifies the underlying type of dictionary values in
+// serialized forms. Its values are written out, so they must never be
+// renumbered.
+type valueKind uint8
+
+const (
+	kindInvalid valueKind = iota
+	kindInt
+	kindInt8
+	kindInt16
+	kindInt32
+	kindInt64
+	kindUint
+	kindUint8
+	kindUint16
+	kindUint32
+	kindUint64
+	kindUintptr
+	kindFloat32
+	kindFloat64
+	kindString
+)
+
+var kindNames = [...]string{
+	kindInvalid: "invalid",
+	kindInt:     "int",
+	kindInt8:    "int8",
+	kindInt16:   "int16",
+	kindInt32:   "int32",
+	kindInt64:   "int64",
+	kindUint:    "uint",
+	kindUint8:   "uint8",
+	kindUint16:  "uint16",
+	kindUint32:  "uint32",
+	kindUint64:  "uint64",
+	kindUintptr: "uintptr",
+	kindFloat32: "float32",
+	kindFloat64: "float64",
+	kindString:  "string",
+}
+
+func (k valueKind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("valueKind(%d)", uint8(k))
+}
+
+// kindOf returns the valueKind of the underlying type of `T`.
+func kindOf[T cmp.Ordered]() valueKind {
+	var zero T
+	switch reflect.TypeOf(zero).Kind() {
+	case reflect.Int:
+		return kindInt
+	case reflect.Int8:
+		return kindInt8
+	case reflect.Int16:
+		return kindInt16
+	case reflect.Int32:
+		return kindInt32
+	case reflect.Int64:
+		return kindInt64
+	case reflect.Uint:
+		return kindUint
+	case reflect.Uint8:
+		return kindUint8
+	case reflect.Uint16:
+		return kindUint16
+	case reflect.Uint32:
+		return kindUint32
+	case reflect.Uint64:
+		return kindUint64
+	case reflect.Uintptr:
+		return kindUintptr
+	case reflect.Float32:
+		return kindFloat32
+	case reflect.Float64:
+		return kindFloat64
+	case reflect.String:
+		return kindString
+	default:
+		return kindInvalid
+	}
+}
+
+// size returns the encoded width of fixed-width kinds, or 0 for strings.
+func (k valueKind) size() int {
+	switch k {
+	case kindInt8, kindUint8:
+		return 1
+	case kindInt16, kindUint16:
+		return 2
+	case kindInt32, kindUint32, kindFloat32:
+		return 4
+	case kindString:
+		return 0
+	default:
+		return 8
+	}
+}
+
+// appendValue appends the binary encoding of a value of kind k. Fixed-width
+// integers and floats are written little-endian at their natural width, with
+// int, uint and uintptr always widened to 64 bits. Strings are written as a
+// uvarint length followed by their bytes.
+func appendValue[T cmp.Ordered](b []byte, k valueKind, v T) []byte {
+	rv := reflect.ValueOf(v)
+	switch k {
+	case kindString:
+		s := rv.String()
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		return append(b, s...)
+	case kindFloat32:
+		return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(rv.Float())))
+	case kindFloat64:
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(rv.Float()))
+	}
+
+	var x uint64
+	if rv.CanInt() {
+		x = uint64(rv.Int())
+	} else {
+		x = rv.Uint()
+	}
+
+	switch k.size() {
+	case 1:
+		return append(b, byte(x))
+	case 2:
+		return binary.LittleEndian.AppendUint16(b, uint16(x))
+	case 4:
+		return binary.LittleEndian.AppendUint32(b, uint32(x))
+	default:
+		return binary.LittleEndian.AppendUint64(b, x)
+	}
+}
+
+// readValue decodes a value of kind k from the front of b, returning the
+// rest of b.
+func readValue[T cmp.Ordered](b []byte, k valueKind) (T, []byte, error) {
+	var v T
+	rv := reflect.ValueOf(&v).Elem()
+
+	if k == kindString {
+		n, w := binary.Uvarint(b)
+		if w <= 0 || uint64(len(b)-w) < n {
+			return v, nil, errTruncated
+		}
+		rv.SetString(string(b[w : w+int(n)]))
+		return v, b[w+int(n):], nil
+	}
+
+	size := k.size()
+	if len(b) < size {
+		return v, nil, errTruncated
+	}
+
+	var x uint64
+	switch size {
+	case 1:
+		x = uint64(b[0])
+	case 2:
+		x = uint64(binary.LittleEndian.Uint16(b))
+	case 4:
+		x = uint64(binary.LittleEndian.Uint32(b))
+	default:
+		x = binary.LittleEndian.Uint64(b)
+	}
+
+	switch {
+	case k == kindFloat32:
+		rv.SetFloat(float64(math.Float32frombits(uint32(x))))
+	case k == kindFloat64:
+		rv.SetFloat(math.Float64frombits(x))
+	case rv.CanInt():
+		// Sign-extend narrower integers before widening.
+		shift := 64 - 8*size
+		rv.SetInt(int64(x<<shift) >> shift)
+	default:
+		rv.SetUint(x)
+	}
+
+	return v, b[size:], nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. Like MarshalJSON and
+// MarshalText, it has a value receiver, so that encoders find it on
+// dictionaries they can't take the address of.
+func (d Dict[T]) MarshalBinary() ([]byte, error) {
+	return d.appendBinary(nil)
+}
+
+// appendBinary appends the binary encoding of the dictionary to b.
+func (d *Dict[T]) appendBinary(b []byte) ([]byte, error) {
+	k := kindOf[T]()
+	if k == kindInvalid {
+		return nil, fmt.Errorf("colsketch: unsupported value type %T", *new(T))
+	}
+
+	b = append(b, dictMagic...)
+	b = append(b, dictFormatVersion, byte(d.mode), byte(k))
+	b = binary.AppendUvarint(b, uint64(len(d.codes)))
+	for _, v := range d.codes {
+		b = appendValue(b, k, v)
+	}
+
+	if d.counts == nil {
+		b = append(b, 0)
+	} else {
+		b = append(b, 1)
+		for _, n := range d.counts {
+			b = binary.AppendUvarint(b, uint64(n))
+		}
+	}
+	return binary.AppendUvarint(b, uint64(d.numClusters)), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It rejects data
+// written for a different value type and data that fails Validate.
+func (d *Dict[T]) UnmarshalBinary(data []byte) error {
+	var dec Dict[T]
+	rest, err := dec.readBinary(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("colsketch: %d trailing bytes after dictionary", len(rest))
+	}
+	*d = dec
+	return nil
+}
+
+// readBinary decodes a dictionary from the front of b, returning the rest of
+// b. The receiver is only modified if decoding succeeds.
+func (d *Dict[T]) readBinary(b []byte) ([]byte, error) {
+	if len(b) < len(dictMagic)+1 {
+		return nil, errTruncated
+	}
+
+	if string(b[:len(dictMagic)]) != dictMagic {
+		return nil, errors.New("colsketch: not a serialized dictionary")
+	}
+	b = b[len(dictMagic):]
+
+	if version := b[0]; version != dictFormatVersion {
+		return nil, fmt.Errorf("colsketch: unsupported dictionary format version %d", version)
+	}
+	b = b[1:]
+	if len(b) < 2 {
+		return nil, errTruncated
+	}
+
+	mode := Mode(b[0])
+	if !mode.IsValid() {
+		return nil, fmt.Errorf("colsketch: invalid mode %d", mode)
+	}
+
+	if got, want := valueKind(b[1]), kindOf[T](); got != want {
+		return nil, fmt.Errorf("colsketch: cannot decode %s dictionary into Dict[%T]", got, *new(T))
+	}
+	k := valueKind(b[1])
+	b = b[2:]
+
+	n, w := binary.Uvarint(b)
+	if w <= 0 {
+		return nil, errTruncated
+	}
+	b = b[w:]
+
+	// Don't trust the count for preallocation beyond what the input could
+	// possibly hold.
+	if n > uint64(mode.NumExactCodes()) || (k.size() > 0 && n > uint64(len(b)/k.size())) {
+		return nil, fmt.Errorf("colsketch: invalid representative count %d", n)
+	}
+
+	dec := Dict[T]{mode: mode, codes: make([]T, n)}
+	for i := range dec.codes {
+		var err error
+		if dec.codes[i], b, err = readValue[T](b, k); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(b) == 0 {
+		return nil, errTruncated
+	}
+	counted := b[0]
+	b = b[1:]
+
+	switch {
+	case counted > 1:
+		return nil, fmt.Errorf("colsketch: invalid sample counts flag %d", counted)
+	case counted == 1:
+		// Every count takes at least a byte.
+		if len(b) < 2*len(dec.codes)+1 {
+			return nil, errTruncated
+		}
+		dec.counts = make([]int, 2*len(dec.codes)+1)
+		for i := range dec.counts {
+			c, w := binary.Uvarint(b)
+			if w <= 0 {
+				return nil, errTruncated
+			}
+			if c > uint64(math.MaxInt-dec.sampleSize) {
+				return nil, fmt.Errorf("colsketch: invalid sample count %d", c)
+			}
+			dec.counts[i], b = int(c), b[w:]
+			dec.sampleSize += int(c)
+		}
+	}
+
+	distinct, w := binary.Uvarint(b)
+	if w <= 0 {
+		return nil, errTruncated
+	}
+	// Each representative is one of the distinct sample values.
+	if distinct > uint64(math.MaxInt) || (distinct > 0 && int(distinct) < len(dec.codes)) {
+		return nil, fmt.Errorf("colsketch: invalid distinct sample value count %d", distinct)
+	}
+	dec.numClusters, b = int(distinct), b[w:]
+
+	if err := dec.Validate(); err != nil {
+		return nil, err
+	}
+
+	*d = dec
+	return b, nil
+}
@@ -0,0 +1,152 @@
+package colsketch
+
+import (
+	"bytes"
+	"cmp"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testMarshalRoundTrip[T cmp.Ordered](t *testing.T, mode Mode, sample []T) {
+	t.Helper()
+
+	dict := NewDict(mode, sample)
+	data, err := dict.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() = %v", err)
+	}
+
+	var got Dict[T]
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, dict) {
+		t.Errorf("round trip = %v, want %v", got, dict)
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	testMarshalRoundTrip(t, Byte, []int{-5, 0, 7, math.MaxInt, math.MinInt})
+	testMarshalRoundTrip(t, Word, []int8{-128, -1, 0, 127})
+	testMarshalRoundTrip(t, Byte, []int16{-300, 300})
+	testMarshalRoundTrip(t, Byte, []int32{math.MinInt32, 1})
+	testMarshalRoundTrip(t, Byte, []int64{math.MinInt64, math.MaxInt64})
+	testMarshalRoundTrip(t, Byte, []uint{0, math.MaxUint})
+	testMarshalRoundTrip(t, Byte, []uint8{0, 255})
+	testMarshalRoundTrip(t, Byte, []uint16{0, 65535})
+	testMarshalRoundTrip(t, Byte, []uint32{0, math.MaxUint32})
+	testMarshalRoundTrip(t, Byte, []uint64{0, math.MaxUint64})
+	testMarshalRoundTrip(t, Byte, []uintptr{0, 42})
+	testMarshalRoundTrip(t, Byte, []float32{-1.5, 0, math.MaxFloat32, float32(math.Inf(1))})
+	testMarshalRoundTrip(t, Word, []float64{-1.5, 0, math.SmallestNonzeroFloat64, math.Inf(-1)})
+	testMarshalRoundTrip(t, Word, []string{"", "a", "ab", "\x00\xff"})
+	testMarshalRoundTrip[string](t, Byte, nil)
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	dict := NewDict(Byte, []int64{1, 2, 3})
+	data, err := dict.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var d Dict[int64]
+	for i := 0; i < len(data); i++ {
+		if err := d.UnmarshalBinary(data[:i]); err == nil {
+			t.Errorf("UnmarshalBinary() of %d/%d bytes succeeded", i, len(data))
+		}
+	}
+
+	if err := d.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Errorf("UnmarshalBinary() with trailing data succeeded")
+	}
+
+	var s Dict[string]
+	if err := s.UnmarshalBinary(data); err == nil {
+		t.Errorf("UnmarshalBinary() of int64 data into Dict[string] succeeded")
+	}
+
+	var u Dict[uint64]
+	if err := u.UnmarshalBinary(data); err == nil {
+		t.Errorf("UnmarshalBinary() of int64 data into Dict[uint64] succeeded")
+	}
+
+	// The last representative is followed by the counts flag, the seven
+	// sample counts and the number of distinct values, which take a byte
+	// each.
+	unsorted := append([]byte(nil), data...)
+	unsorted[len(unsorted)-9-8] = 0
+	if err := d.UnmarshalBinary(unsorted); err == nil {
+		t.Errorf("UnmarshalBinary() of unsorted representatives succeeded")
+	}
+
+	flag := append([]byte(nil), data...)
+	flag[len(flag)-9] = 2
+	if err := d.UnmarshalBinary(flag); err == nil {
+		t.Errorf("UnmarshalBinary() with an invalid counts flag succeeded")
+	}
+
+	distinct := append([]byte(nil), data...)
+	distinct[len(distinct)-1] = 2
+	if err := d.UnmarshalBinary(distinct); err == nil {
+		t.Errorf("UnmarshalBinary() with fewer distinct sample values than representatives succeeded")
+	}
+
+	if !reflect.DeepEqual(d, Dict[int64]{}) {
+		t.Errorf("failed UnmarshalBinary() modified the receiver: %v", d)
+	}
+}
+
+// TestDictGolden checks that the binary format of dictionaries doesn't
+// change, for dictionaries of fixed-width values, of strings and of an empty
+// sample. Run with -update to rewrite the golden files after a deliberate
+// change.
+func TestDictGolden(t *testing.T) {
+	ints := make([]int64, 300)
+	floats := make([]float64, 300)
+	strs := make([]string, 300)
+	for i := range ints {
+		ints[i] = int64(i*i%97) - 40
+		floats[i] = float64(ints[i]) / 8
+		strs[i] = string(rune('a' + i%26))
+	}
+
+	testDictGolden(t, "dict_int64.golden", NewDict(Byte, ints))
+	testDictGolden(t, "dict_float64.golden", NewDict(Word, floats))
+	testDictGolden(t, "dict_string.golden", NewDict(Byte, strs))
+	testDictGolden(t, "dict_empty.golden", NewDict[uint32](Byte, nil))
+}
+
+func testDictGolden[T cmp.Ordered](t *testing.T, name string, dict Dict[T]) {
+	t.Helper()
+
+	data, err := dict.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, golden) {
+		t.Errorf("MarshalBinary() wrote %d bytes differing from the %d of %s", len(data), len(golden), path)
+	}
+
+	var got Dict[T]
+	if err := got.UnmarshalBinary(golden); err != nil {
+		t.Fatalf("%s: UnmarshalBinary() = %v", name, err)
+	}
+	if !reflect.DeepEqual(got, dict) {
+		t.Errorf("%s: UnmarshalBinary() = %v, want %v", name, got, dict)
+	}
+}
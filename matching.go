@@ -0,0 +1,110 @@
+package colsketch
+
+import (
+	"cmp"
+	"sort"
+)
+
+// WithStoreClusters keeps the distinct values of the sample a dictionary is
+// built from, or last recounted over, along with their number of
+// occurrences, for MatchingValues to tell which of them each code stands
+// for, e.g. to debug false positives, and for FrequencyOf and TopN to tell
+// how often they occur. It costs as much memory as the distinct values
+// themselves, which for samples of mostly distinct values may be many times
+// the dictionary's size, and the clusters aren't serialized.
+func WithStoreClusters() DictOption {
+	return func(c *dictConfig) { c.storeClusters = true }
+}
+
+// MatchingValues returns the distinct values of the sample the dictionary
+// was built from that it encodes to a code, in increasing order: the
+// representative of an exact code, if the sample held it, and the values
+// between the neighbouring representatives of an inexact one. It returns
+// false for codes the dictionary doesn't assign and if the dictionary
+// wasn't built with WithStoreClusters.
+func (d *Dict[T]) MatchingValues(c Code) ([]T, bool) {
+	iv, ok := d.Bounds(c)
+	if !ok || d.clusters == nil {
+		return nil, false
+	}
+
+	clu := d.clusters
+	start, end := 0, len(clu)
+	switch {
+	case iv.Exact:
+		start = sort.Search(len(clu), func(i int) bool { return !cmp.Less(clu[i].Value, iv.Lo) })
+		end = sort.Search(len(clu), func(i int) bool { return cmp.Less(iv.Lo, clu[i].Value) })
+	default:
+		if iv.HasLo {
+			start = sort.Search(len(clu), func(i int) bool { return cmp.Less(iv.Lo, clu[i].Value) })
+		}
+		if iv.HasHi {
+			end = sort.Search(len(clu), func(i int) bool { return !cmp.Less(clu[i].Value, iv.Hi) })
+		}
+	}
+
+	values := make([]T, 0, end-start)
+	for _, c := range clu[start:end] {
+		values = append(values, c.Value)
+	}
+	return values, true
+}
+
+// FrequencyOf returns the number of times a value occurs in the sample the
+// dictionary was built from or last recounted over, e.g. to decide whether
+// it is frequent enough to index. It returns false if the value isn't
+// exactly coded, having been merged into an inexact code, and if the
+// dictionary wasn't built with WithStoreClusters.
+func (d *Dict[T]) FrequencyOf(value T) (int, bool) {
+	if d.clusters == nil || !d.Encode(value).IsExact() {
+		return 0, false
+	}
+
+	clu := d.clusters
+	i := sort.Search(len(clu), func(i int) bool { return !cmp.Less(clu[i].Value, value) })
+	if i == len(clu) || cmp.Compare(clu[i].Value, value) != 0 {
+		// Representatives not drawn from the sample, such as that of an
+		// empty one, occur zero times.
+		return 0, true
+	}
+	return clu[i].Count, true
+}
+
+// TopN returns up to n of the most frequent values of the sample the
+// dictionary was built from, in decreasing order of frequency, with ties in
+// increasing order of value: the heavy hitters of the column, as far as the
+// sample tells. It returns nil if the dictionary wasn't built with
+// WithStoreClusters.
+func (d *Dict[T]) TopN(n int) []T {
+	top := d.TopNWithCounts(n)
+	if top == nil {
+		return nil
+	}
+
+	values := make([]T, len(top))
+	for i, c := range top {
+		values[i] = c.Value
+	}
+	return values
+}
+
+// TopNWithCounts is like TopN, but also returns the number of times each
+// value occurs in the sample.
+func (d *Dict[T]) TopNWithCounts(n int) []Cluster[T] {
+	if d.clusters == nil {
+		return nil
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > len(d.clusters) {
+		n = len(d.clusters)
+	}
+
+	// The clusters are in increasing order of value, which a stable sort
+	// keeps among values of equal frequency.
+	top := make([]Cluster[T], len(d.clusters))
+	copy(top, d.clusters)
+	sort.SliceStable(top, func(i, j int) bool { return top[i].Count > top[j].Count })
+	return top[:n:n]
+}
@@ -0,0 +1,165 @@
+package colsketch
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMatchingValues(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, n := range []int{10, 1000} {
+		sample := randomStrings(rng, 5*n, n)
+		d := NewDictWithOptions(Byte, sample, WithStoreClusters())
+
+		sorted := append([]string(nil), sample...)
+		sort.Strings(sorted)
+		var distinct []string
+		for _, c := range SampleClusters(sorted) {
+			distinct = append(distinct, c.Value)
+		}
+
+		// Codes partition the distinct values of the sample, in order.
+		var all []string
+		for c := Code(1); int(c) <= 2*d.Len()+1; c++ {
+			values, ok := d.MatchingValues(c)
+			if !ok {
+				t.Fatalf("%d values: MatchingValues(%d) isn't known", n, c)
+			}
+			if c.IsExact() && len(values) != 1 {
+				t.Fatalf("%d values: MatchingValues(%d) of an exact code = %v", n, c, values)
+			}
+			for _, v := range values {
+				if got := d.Encode(v); got != c {
+					t.Fatalf("%d values: MatchingValues(%d) holds %q, which encodes to %d", n, c, v, got)
+				}
+			}
+			all = append(all, values...)
+		}
+		if !reflect.DeepEqual(all, distinct) {
+			t.Errorf("%d values: MatchingValues() of all codes = %v, want %v", n, all, distinct)
+		}
+
+		for _, c := range []Code{0, Code(2*d.Len() + 2)} {
+			if _, ok := d.MatchingValues(c); ok {
+				t.Errorf("%d values: MatchingValues(%d) is known", n, c)
+			}
+		}
+	}
+
+	plain := NewDict(Byte, []int{1, 2, 3})
+	if values, ok := plain.MatchingValues(2); ok || values != nil {
+		t.Errorf("MatchingValues() without stored clusters = %v, %v", values, ok)
+	}
+
+	empty := NewDictWithOptions[int](Byte, nil, WithStoreClusters())
+	if values, ok := empty.MatchingValues(2); !ok || len(values) != 0 {
+		t.Errorf("MatchingValues() of an empty sample = %v, %v", values, ok)
+	}
+
+	// Recounting replaces the clusters, which compacting keeps.
+	d := NewDictWithOptions(Byte, []int{1, 2, 3}, WithStoreClusters())
+	d = d.Recount([]int{1, 3, 4})
+	if values, _ := d.MatchingValues(4); len(values) != 0 {
+		t.Errorf("MatchingValues(4) of recounted dictionary = %v, want none", values)
+	}
+	compact := d.Compact()
+	if values, _ := compact.MatchingValues(5); !reflect.DeepEqual(values, []int{4}) {
+		t.Errorf("MatchingValues(5) of compacted dictionary = %v, want [4]", values)
+	}
+}
+
+func TestFrequencyOf(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sample := randomStrings(rng, 5000, 1000)
+	d := NewDictWithOptions(Byte, sample, WithStoreClusters())
+
+	freq := make(map[string]int)
+	for _, v := range sample {
+		freq[v]++
+	}
+
+	exact := 0
+	for v, want := range freq {
+		got, ok := d.FrequencyOf(v)
+		if ok != d.Encode(v).IsExact() {
+			t.Fatalf("FrequencyOf(%q) = %d, %v for code %d", v, got, ok, d.Encode(v))
+		}
+		if ok {
+			exact++
+			if got != want {
+				t.Fatalf("FrequencyOf(%q) = %d, want %d", v, got, want)
+			}
+		}
+	}
+	if exact != d.Len() {
+		t.Errorf("FrequencyOf() known for %d values, want %d", exact, d.Len())
+	}
+
+	// Recounting counts frequencies over the new sample.
+	recounted := d.Recount(sample[:10])
+	for v := range freq {
+		want := 0
+		for _, s := range sample[:10] {
+			if s == v {
+				want++
+			}
+		}
+		if got, ok := recounted.FrequencyOf(v); ok && got != want {
+			t.Fatalf("FrequencyOf(%q) after Recount() = %d, want %d", v, got, want)
+		}
+	}
+
+	if _, ok := d.FrequencyOf("not in the sample"); ok {
+		t.Errorf("FrequencyOf() of an inexactly coded value is known")
+	}
+	plain := NewDict(Byte, sample)
+	if _, ok := plain.FrequencyOf(sample[0]); ok {
+		t.Errorf("FrequencyOf() without stored clusters is known")
+	}
+	empty := NewDictWithOptions[int](Byte, nil, WithStoreClusters())
+	if got, ok := empty.FrequencyOf(0); !ok || got != 0 {
+		t.Errorf("FrequencyOf() of the representative of an empty sample = %d, %v, want 0, true", got, ok)
+	}
+}
+
+func TestTopN(t *testing.T) {
+	d := NewDictWithOptions(Byte, []string{"c", "a", "b", "c", "b", "d", "c", "b", "a"}, WithStoreClusters())
+
+	want := []Cluster[string]{{"b", 3}, {"c", 3}, {"a", 2}, {"d", 1}}
+	for n := -1; n <= len(want)+1; n++ {
+		k := n
+		if k < 0 {
+			k = 0
+		}
+		if k > len(want) {
+			k = len(want)
+		}
+
+		if got := d.TopNWithCounts(n); !reflect.DeepEqual(got, want[:k]) {
+			t.Errorf("TopNWithCounts(%d) = %v, want %v", n, got, want[:k])
+		}
+		values := make([]string, k)
+		for i, c := range want[:k] {
+			values[i] = c.Value
+		}
+		if got := d.TopN(n); !reflect.DeepEqual(got, values) {
+			t.Errorf("TopN(%d) = %v, want %v", n, got, values)
+		}
+	}
+
+	plain := NewDict(Byte, []int{1, 1, 2})
+	if top := plain.TopN(1); top != nil {
+		t.Errorf("TopN() without stored clusters = %v", top)
+	}
+	if top := plain.TopNWithCounts(1); top != nil {
+		t.Errorf("TopNWithCounts() without stored clusters = %v", top)
+	}
+
+	empty := NewDictWithOptions[int](Byte, nil, WithStoreClusters())
+	if top := empty.TopNWithCounts(1); top == nil || len(top) != 0 {
+		t.Errorf("TopNWithCounts() of an empty sample = %#v, want an empty slice", top)
+	}
+}
@@ -0,0 +1,72 @@
+package colsketch
+
+import "cmp"
+
+// monotonicBackwardProbes is the number of representatives a
+// MonotonicEncoder steps back over, one at a time, before falling back to a
+// full binary search when a value is smaller than the previous one.
+const monotonicBackwardProbes = 4
+
+// MonotonicEncoder encodes values with a dictionary, exploiting values that
+// arrive in (nearly) sorted order: it remembers where the previous value
+// fell among the representatives, and searches forward from there by
+// galloping, i.e. with exponentially growing steps, so encoding a value that
+// maps to the same code or a nearby one costs a comparison or two instead of
+// a full binary search. Values smaller than the previous one are handled by
+// a short backward probe and then a full search. Results always equal
+// Dict.Encode's; only the speed depends on the input order, and for random
+// order it is slower than Dict.Encode.
+//
+// A MonotonicEncoder isn't safe for concurrent use.
+type MonotonicEncoder[T cmp.Ordered] struct {
+	codes []T
+
+	// The number of representatives less than or equal to the last value.
+	pos int
+}
+
+// NewMonotonicEncoder returns a MonotonicEncoder over the dictionary.
+func (d *Dict[T]) NewMonotonicEncoder() *MonotonicEncoder[T] {
+	return &MonotonicEncoder[T]{codes: d.codes}
+}
+
+// Encode returns the code of value, like Dict.Encode.
+func (e *MonotonicEncoder[T]) Encode(value T) Code {
+	s, pos := e.codes, e.pos
+
+	switch {
+	case pos < len(s) && !cmp.Less(value, s[pos]):
+		// The value is at or beyond the next representative. Gallop forward
+		// until overshooting it, then search the last step.
+		lo, hi, step := pos+1, pos+1, 1
+		for hi < len(s) && !cmp.Less(value, s[hi]) {
+			lo = hi + 1
+			hi = lo + step
+			step <<= 1
+		}
+		if hi > len(s) {
+			hi = len(s)
+		}
+		pos = lo + upperBound(s[lo:hi], value)
+
+	case pos > 0 && cmp.Less(value, s[pos-1]):
+		// The value is before the last representative. Step back a few
+		// representatives to absorb slight disorder before giving up.
+		for i := 0; ; i++ {
+			pos--
+			if pos == 0 || !cmp.Less(value, s[pos-1]) {
+				break
+			}
+			if i == monotonicBackwardProbes {
+				pos = upperBound(s, value)
+				break
+			}
+		}
+	}
+
+	e.pos = pos
+	if pos > 0 && cmp.Compare(s[pos-1], value) == 0 {
+		return Code(2 * pos)
+	}
+	return Code(2*pos + 1)
+}
@@ -0,0 +1,94 @@
+package colsketch
+
+import (
+	"cmp"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// nearlySorted returns a sorted copy of values with a fraction of its
+// elements swapped with a random nearby element.
+func nearlySorted[T cmp.Ordered](rng *rand.Rand, values []T, disorder float64) []T {
+	s := append([]T(nil), values...)
+	sort.Slice(s, func(i, j int) bool { return cmp.Less(s[i], s[j]) })
+	for i := range s {
+		if rng.Float64() < disorder {
+			j := i + rng.Intn(100) - 50
+			if j >= 0 && j < len(s) {
+				s[i], s[j] = s[j], s[i]
+			}
+		}
+	}
+	return s
+}
+
+func TestMonotonicEncoder(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 100000, 50000))
+		values := randomInt64s(rng, 20000, 60000)
+
+		for _, order := range []struct {
+			name   string
+			values []int64
+		}{
+			{"sorted", nearlySorted(rng, values, 0)},
+			{"99% sorted", nearlySorted(rng, values, 0.01)},
+			{"50% sorted", nearlySorted(rng, values, 0.5)},
+			{"random", values},
+			{"descending", func() []int64 {
+				s := nearlySorted(rng, values, 0)
+				for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+					s[i], s[j] = s[j], s[i]
+				}
+				return s
+			}()},
+		} {
+			enc := dict.NewMonotonicEncoder()
+			for i, v := range order.values {
+				if got, want := enc.Encode(v), dict.Encode(v); got != want {
+					t.Fatalf("mode %s, %s: value %d (%d) encoded as %d, want %d", mode, order.name, i, v, got, want)
+				}
+			}
+		}
+	}
+
+	empty := Dict[int]{mode: Byte}
+	if got := empty.NewMonotonicEncoder().Encode(1); got != 1 {
+		t.Errorf("Encode() over empty dict = %d, want 1", got)
+	}
+}
+
+func BenchmarkMonotonicEncoder(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	dict := NewDict(Word, randomInt64s(rng, 1<<20, 1<<40))
+	values := randomInt64s(rng, 1<<20, 1<<40)
+
+	for _, order := range []struct {
+		name   string
+		values []int64
+	}{
+		{"sorted", nearlySorted(rng, values, 0)},
+		{"99%sorted", nearlySorted(rng, values, 0.01)},
+		{"random", values},
+	} {
+		b.Run(order.name+"/Encode", func(b *testing.B) {
+			var sum Code
+			for i := 0; i < b.N; i++ {
+				sum += dict.Encode(order.values[i%len(order.values)])
+			}
+			codeSink = sum
+		})
+		b.Run(order.name+"/MonotonicEncoder", func(b *testing.B) {
+			enc := dict.NewMonotonicEncoder()
+			var sum Code
+			for i := 0; i < b.N; i++ {
+				sum += enc.Encode(order.values[i%len(order.values)])
+			}
+			codeSink = sum
+		})
+	}
+}
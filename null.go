@@ -0,0 +1,66 @@
+package colsketch
+
+import "cmp"
+
+// NullCode is the code of NULL rows in sketches built WithNulls. It is
+// reserved for NULL: no dictionary assigns it to a value, since codes start
+// at 1, so Encode never returns it.
+const NullCode Code = 0
+
+// IsNull returns true iff the code is NullCode.
+func (c Code) IsNull() bool {
+	return c == NullCode
+}
+
+// WithNulls lets the sketch hold NULL rows, coded as NullCode, appended with
+// AppendNull, or AppendCodes. Comparison predicates never match NULL rows,
+// which scans rule out as definitely as the rows whose codes are out of the
+// predicates' ranges; IsNull and IsNotNull select them, or the others.
+// Sketches built WithNulls are serialized with a flag allowing NullCode in
+// their codes, which versions of the package predating it reject.
+func WithNulls() SketchOption {
+	return func(c *sketchConfig) { c.nulls = true }
+}
+
+// EncodeNull returns the code of NULL, NullCode, for building columns of
+// codes to append to sketches built WithNulls with AppendCodes.
+func (d *Dict[T]) EncodeNull() Code {
+	return NullCode
+}
+
+// EncodeNullable encodes a nullable value: it returns NullCode if value is
+// nil, and the code of *value otherwise, e.g. for appending columns of
+// pointers to sketches built WithNulls with AppendCodes.
+func (d *Dict[T]) EncodeNullable(value *T) Code {
+	if value == nil {
+		return NullCode
+	}
+	return d.Encode(*value)
+}
+
+// IsNull returns the predicate `v IS NULL`, which only the NULL rows of a
+// sketch built WithNulls match.
+func IsNull[T cmp.Ordered]() Predicate[T] {
+	return Predicate[T]{op: opIsNull}
+}
+
+// IsNotNull returns the predicate `v IS NOT NULL`, which every row of a
+// sketch matches but its NULL rows.
+func IsNotNull[T cmp.Ordered]() Predicate[T] {
+	return Predicate[T]{op: opIsNotNull}
+}
+
+// Nullable returns true iff the sketch was built WithNulls, or read from
+// one that was.
+func (s *Sketch[T]) Nullable() bool {
+	return s.nulls
+}
+
+// AppendNull appends a NULL row to the sketch. It panics if the sketch
+// wasn't built WithNulls.
+func (s *Sketch[T]) AppendNull() {
+	if !s.nulls {
+		panic("colsketch: AppendNull on a sketch built without WithNulls")
+	}
+	s.appendCode(NullCode)
+}
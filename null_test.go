@@ -0,0 +1,211 @@
+package colsketch
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSketchNulls(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 3000, 1000))
+		for _, opts := range [][]SketchOption{
+			{WithNulls()},
+			{WithNulls(), WithBlockSize(64), WithBitPacking()},
+			{WithNulls(), WithBlockSize(128), WithRunLength()},
+		} {
+			for _, nullRate := range []float64{0, 0.3, 0.98, 1} {
+				s := NewSketch(&dict, opts...)
+				n := 5000
+				values, nulls := randomInt64s(rng, n, 1002), make([]bool, n)
+				for i := range values {
+					// Interleave NULLs in runs, so that some blocks hold
+					// nothing else.
+					nulls[i] = rng.Float64() < nullRate || (i/300)%4 == 1 && nullRate > 0
+					switch {
+					case !nulls[i]:
+						s.Append(values[i])
+					case i%2 == 0:
+						s.AppendNull()
+					default:
+						s.AppendCodes([]Code{dict.EncodeNull()})
+					}
+				}
+
+				name := fmt.Sprintf("%s, block size %d, %d bits, %.0f%% NULL", mode, s.BlockSize(), s.BitsPerCode(), 100*nullRate)
+				testNullScans(t, name, s, nulls, IsNull[int64]())
+				testNullScans(t, name, s, nulls, IsNotNull[int64]())
+				for i := 0; i < 30; i++ {
+					testNullScans(t, name, s, nulls, randomPredicate(rng, func() int64 { return rng.Int63n(1004) - 1 }))
+				}
+
+				// Nulls survive serialization, into sketches built without
+				// WithNulls too.
+				var buf bytes.Buffer
+				if _, err := s.WriteTo(&buf); err != nil {
+					t.Fatal(err)
+				}
+				read := NewSketch(&dict)
+				if _, err := read.ReadFrom(bytes.NewReader(buf.Bytes())); err != nil {
+					t.Fatalf("%s: ReadFrom() = %v", name, err)
+				}
+				v, err := OpenSketch(buf.Bytes(), &dict)
+				if err != nil {
+					t.Fatalf("%s: OpenSketch() = %v", name, err)
+				}
+				if !read.Nullable() || !v.Nullable() {
+					t.Fatalf("%s: read sketch isn't nullable", name)
+				}
+				for pos := 0; pos < n; pos++ {
+					if read.Get(pos) != s.Get(pos) || v.Get(pos) != s.Get(pos) {
+						t.Fatalf("%s: code %d read as %d and %d, want %d", name, pos, read.Get(pos), v.Get(pos), s.Get(pos))
+					}
+				}
+				testNullScans(t, name+", read", read, nulls, IsNull[int64]())
+				if got, want := ExactStats(codesOf(s), 0).NullFraction, float64(countTrue(nulls))/float64(n); got != want {
+					t.Errorf("%s: NullFraction = %v, want %v", name, got, want)
+				}
+			}
+		}
+	}
+}
+
+// testNullScans checks that scans of a sketch with the given NULL rows visit
+// and count the rows the predicate may match, and that comparisons don't
+// match NULL rows.
+func testNullScans(t *testing.T, name string, s *Sketch[int64], nulls []bool, p Predicate[int64]) {
+	t.Helper()
+
+	var want []int
+	var wantCertain []Certainty
+	for pos, null := range nulls {
+		var may, must bool
+		if null {
+			may, must = p.op == opIsNull, p.op == opIsNull
+		} else {
+			iv, _ := s.Dict().Bounds(s.Get(pos))
+			may, must = mayMatch(iv, p), mustMatch(iv, p)
+		}
+		if may {
+			want = append(want, pos)
+			wantCertain = append(wantCertain, Certainty(b2u(must)))
+		}
+	}
+
+	var got []int
+	var certain []Certainty
+	s.ScanCertain(p, func(pos int, c Certainty) bool {
+		got = append(got, pos)
+		certain = append(certain, c)
+		return true
+	})
+	if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(certain, wantCertain) {
+		t.Fatalf("%s, %+v: ScanCertain() visited %d rows, want %d", name, p, len(got), len(want))
+	}
+
+	numDefinite := 0
+	for _, c := range wantCertain {
+		numDefinite += int(c)
+	}
+	if n, nd := s.Count(p); n != len(want) || nd != numDefinite {
+		t.Fatalf("%s, %+v: Count() = %d, %d, want %d, %d", name, p, n, nd, len(want), numDefinite)
+	}
+
+	ones := 0
+	for _, w := range s.ScanBitmap(p, nil) {
+		ones += bits.OnesCount64(w)
+	}
+	if ones != len(want) {
+		t.Fatalf("%s, %+v: ScanBitmap() set %d bits, want %d", name, p, ones, len(want))
+	}
+}
+
+// codesOf returns the codes of a sketch.
+func codesOf(s *Sketch[int64]) []Code {
+	codes := make([]Code, s.Len())
+	for i := range codes {
+		codes[i] = s.Get(i)
+	}
+	return codes
+}
+
+// countTrue returns the number of true elements of b.
+func countTrue(b []bool) int {
+	n := 0
+	for _, x := range b {
+		n += int(b2u(x))
+	}
+	return n
+}
+
+func TestSketchNullsInvalid(t *testing.T) {
+	dict := NewDict(Byte, []int64{1, 2, 3})
+	s := NewSketch(&dict)
+
+	for name, f := range map[string]func(){
+		"AppendNull":  s.AppendNull,
+		"AppendCodes": func() { s.AppendCodes([]Code{2, NullCode}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s of NULL to a sketch built without WithNulls didn't panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+	if s.Len() != 0 {
+		t.Errorf("failed appends appended %d rows", s.Len())
+	}
+
+	nullable := NewSketch(&dict, WithNulls())
+	nullable.Append(2)
+	nullable.AppendNull()
+	if err := s.AppendSketch(nullable); err == nil {
+		t.Errorf("AppendSketch() of a sketch built WithNulls to one without succeeded")
+	}
+	if err := nullable.AppendSketch(s); err != nil || nullable.Len() != 2 {
+		t.Errorf("AppendSketch() to a sketch built WithNulls = %v", err)
+	}
+
+	// NULL codes are invalid in sketches serialized without the flag.
+	var buf bytes.Buffer
+	if _, err := nullable.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := withByte(buf.Bytes(), 7, buf.Bytes()[7]&^sketchFlagNulls)
+	if _, err := s.ReadFrom(bytes.NewReader(data)); err == nil {
+		t.Errorf("ReadFrom() of NULL codes without the flag succeeded")
+	}
+	if _, err := OpenSketch(data, &dict); err == nil {
+		t.Errorf("OpenSketch() of NULL codes without the flag succeeded")
+	}
+}
+
+func TestEncodeNullable(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		for _, dict := range []Dict[int64]{NewDict(mode, randomInt64s(rng, 3000, 1000)), NewDict(mode, []int64(nil)), {}} {
+			if c := dict.EncodeNullable(nil); c != NullCode || !c.IsNull() || c.IsExact() {
+				t.Errorf("%s: EncodeNullable(nil) = %d, want %d", mode, c, NullCode)
+			}
+			for i := 0; i < 1000; i++ {
+				v := rng.Int63n(1002) - 1
+				c := dict.EncodeNullable(&v)
+				if want := dict.Encode(v); c != want {
+					t.Fatalf("%s: EncodeNullable(&%d) = %d, want %d", mode, v, c, want)
+				}
+				if c.IsNull() {
+					t.Fatalf("%s: EncodeNullable(&%d) = NullCode", mode, v)
+				}
+			}
+		}
+	}
+}
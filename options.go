@@ -0,0 +1,38 @@
+package colsketch
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+)
+
+// A DictOption configures a dictionary built by NewDictWithOptions.
+type DictOption func(*dictConfig)
+
+type dictConfig struct {
+	reservoir     bool
+	reservoirSize int
+	storeClusters bool
+}
+
+// NewDictWithOptions is like NewDict, but configured by options. It panics if
+// given an invalid option, e.g. a reservoir size that isn't positive.
+func NewDictWithOptions[T cmp.Ordered](mode Mode, sample []T, opts ...DictOption) Dict[T] {
+	var cfg dictConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.reservoir && cfg.reservoirSize <= 0 {
+		panic(fmt.Sprintf("colsketch: reservoir size %d isn't positive", cfg.reservoirSize))
+	}
+	if cfg.reservoir && len(sample) > cfg.reservoirSize {
+		sample = reservoirSample(sample, cfg.reservoirSize, rand.New(rand.NewSource(reservoirSeed)))
+	}
+
+	d, _, clu := buildDict(mode, sample)
+	if cfg.storeClusters && mode.IsValid() {
+		d.clusters = append(make([]Cluster[T], 0, len(clu)), clu...)
+	}
+	return d
+}
@@ -0,0 +1,228 @@
+package colsketch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"unsafe"
+)
+
+// packedStore is a codeStore holding codes in a fixed number of bits each,
+// packed back to back into cache line aligned 64-bit words, least
+// significant bits first. A code may straddle two words. Every group of 64
+// codes fills exactly as many words as codes have bits, so groups start on
+// word boundaries, which lets scans unpack whole groups at once.
+type packedStore struct {
+	words []uint64
+	n     int
+	bits  uint
+	mask  uint64
+}
+
+// newPackedStore returns an empty packedStore for codes up to maxCode.
+func newPackedStore(maxCode Code) *packedStore {
+	b := uint(bits.Len16(uint16(maxCode)))
+	if b == 0 {
+		b = 1
+	}
+	return &packedStore{bits: b, mask: 1<<b - 1}
+}
+
+func (s *packedStore) append(c Code) {
+	// Words are added as codes need them, so the one the code starts in
+	// exists unless the code starts on a word boundary.
+	bit := uint(s.n) * s.bits
+	w, off := bit/64, bit%64
+	if off == 0 {
+		s.appendWord(0)
+	}
+	s.words[w] |= uint64(c) << off
+	if off+s.bits > 64 {
+		s.appendWord(uint64(c) >> (64 - off))
+	}
+	s.n++
+}
+
+// appendWord appends a word, keeping the words cache line aligned.
+func (s *packedStore) appendWord(w uint64) {
+	if len(s.words) == cap(s.words) {
+		s.words = growAligned(s.words, 1)
+	}
+	s.words = append(s.words, w)
+}
+
+func (s *packedStore) reserve(n int) {
+	words := int((uint(s.n+n)*s.bits + 63) / 64)
+	if words > cap(s.words) {
+		s.words = growAligned(s.words, words-len(s.words))
+	}
+}
+
+func (s *packedStore) appendAll(codes []Code) {
+	s.reserve(len(codes))
+	for _, c := range codes {
+		s.append(c)
+	}
+}
+
+// appendStore appends the words of another packedStore of the same width,
+// shifted into place unless the codes end on a word boundary.
+func (s *packedStore) appendStore(o codeStore) bool {
+	src, ok := o.(*packedStore)
+	if !ok || src.bits != s.bits {
+		return false
+	}
+	words, n := src.words, src.n
+	if src == s {
+		words = append([]uint64(nil), words...)
+	}
+
+	s.reserve(n)
+	if off := uint(s.n) * s.bits % 64; off == 0 {
+		s.words = append(s.words, words...)
+	} else {
+		for _, w := range words {
+			s.words[len(s.words)-1] |= w << off
+			s.appendWord(w >> (64 - off))
+		}
+		// The last word may have been only for bits past the codes, which
+		// are 0.
+		s.words = s.words[:(uint(s.n+n)*s.bits+63)/64]
+	}
+	s.n += n
+	return true
+}
+
+func (s *packedStore) writePayload(w *chunkWriter) {
+	for _, word := range s.words {
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, word)
+		w.flushIfFull()
+	}
+}
+
+func (s *packedStore) get(i int) Code {
+	if i < 0 || i >= s.n {
+		panic(fmt.Sprintf("colsketch: index %d out of range [0:%d]", i, s.n))
+	}
+	return unpack(s.words, uint(i)*s.bits, s.bits, s.mask)
+}
+
+func (s *packedStore) set(i int, c Code) {
+	if i < 0 || i >= s.n {
+		panic(fmt.Sprintf("colsketch: index %d out of range [0:%d]", i, s.n))
+	}
+	bit := uint(i) * s.bits
+	w, off := bit/64, bit%64
+	s.words[w] = s.words[w]&^(s.mask<<off) | uint64(c)<<off
+	if off+s.bits > 64 {
+		s.words[w+1] = s.words[w+1]&^(s.mask>>(64-off)) | uint64(c)>>(64-off)
+	}
+}
+
+// unpack returns the code of the given width starting at bit of words.
+func unpack(words []uint64, bit, width uint, mask uint64) Code {
+	w, off := bit/64, bit%64
+	v := words[w] >> off
+	if off+width > 64 {
+		v |= words[w+1] << (64 - off)
+	}
+	return Code(v & mask)
+}
+
+// unpackGroup decodes the g-th group of 64 codes, which must be complete.
+func (s *packedStore) unpackGroup(g int, dst *[64]Code) {
+	words := s.words[g*int(s.bits):][:s.bits]
+	for j, bit := 0, uint(0); j < len(dst); j, bit = j+1, bit+s.bits {
+		dst[j] = unpack(words, bit, s.bits, s.mask)
+	}
+}
+
+func (s *packedStore) countRanges(start, end int, lo, hi, dlo, dhi Code) (int, int) {
+	n, nd := 0, 0
+	count := func(c Code) {
+		n += int(b2u(c-lo <= hi-lo))
+		nd += int(b2u(dlo <= c && c <= dhi))
+	}
+
+	// Unpack the whole groups of 64 codes a group at a time.
+	var group [64]Code
+	i := start
+	for ; i < end && i%64 != 0; i++ {
+		count(s.get(i))
+	}
+	for ; i+64 <= end; i += 64 {
+		s.unpackGroup(i/64, &group)
+		for _, c := range group {
+			count(c)
+		}
+	}
+	for ; i < end; i++ {
+		count(s.get(i))
+	}
+	return n, nd
+}
+
+// scan unpacks the codes a group at a time, except for those before the
+// first group boundary at or after start and after the last one before end.
+func (s *packedStore) scan(start, end int, lo, hi Code, visit func(pos int) bool) bool {
+	var group [64]Code
+	i := start
+	for ; i < end && i%64 != 0; i++ {
+		if s.get(i)-lo <= hi-lo && !visit(i) {
+			return false
+		}
+	}
+	for ; i+64 <= end; i += 64 {
+		s.unpackGroup(i/64, &group)
+		for j, c := range group {
+			if c-lo <= hi-lo && !visit(i+j) {
+				return false
+			}
+		}
+	}
+	for ; i < end; i++ {
+		if s.get(i)-lo <= hi-lo && !visit(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *packedStore) rangeMask(start, n int, lo, hi Code) uint64 {
+	var m uint64
+	if n == 64 {
+		var group [64]Code
+		s.unpackGroup(start/64, &group)
+		for j, c := range group {
+			m |= uint64(b2u(c-lo <= hi-lo)) << j
+		}
+		return m
+	}
+	for j := 0; j < n; j++ {
+		m |= uint64(b2u(s.get(start+j)-lo <= hi-lo)) << j
+	}
+	return m
+}
+
+func (s *packedStore) setMask(start, n int, cs *CodeSet) uint64 {
+	var m uint64
+	if n == 64 {
+		var group [64]Code
+		s.unpackGroup(start/64, &group)
+		for j, c := range group {
+			m |= uint64(b2u(cs.Contains(c))) << j
+		}
+		return m
+	}
+	for j := 0; j < n; j++ {
+		m |= uint64(b2u(cs.Contains(s.get(start+j)))) << j
+	}
+	return m
+}
+
+func (s *packedStore) len() int         { return s.n }
+func (s *packedStore) maxCode() Code    { return Code(s.mask) }
+func (s *packedStore) bitsPerCode() int { return int(s.bits) }
+func (s *packedStore) footprint() int64 {
+	return int64(unsafe.Sizeof(*s)) + int64(cap(s.words))*8
+}
@@ -0,0 +1,141 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestPackedStore(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for width := 1; width <= 16; width++ {
+		maxCode := Code(1<<width - 1)
+		s := newPackedStore(maxCode)
+		if s.bitsPerCode() != width {
+			t.Fatalf("width %d: bitsPerCode() = %d", width, s.bitsPerCode())
+		}
+
+		// Enough codes for several groups, cross-word codes and a tail.
+		codes := make([]Code, 64*3+37)
+		for i := range codes {
+			codes[i] = Code(rng.Intn(int(maxCode) + 1))
+			s.append(codes[i])
+		}
+		if got, want := len(s.words), (len(codes)*width+63)/64; got != want {
+			t.Errorf("width %d: %d words, want %d", width, got, want)
+		}
+
+		for i, want := range codes {
+			if got := s.get(i); got != want {
+				t.Fatalf("width %d: get(%d) = %d, want %d", width, i, got, want)
+			}
+		}
+
+		for _, r := range [][2]Code{{0, maxCode}, {0, 0}, {maxCode, maxCode}, {maxCode / 3, maxCode / 2}} {
+			want := 0
+			for _, c := range codes {
+				if r[0] <= c && c <= r[1] {
+					want++
+				}
+			}
+			if got, gotd := s.countRanges(0, len(codes), r[0], r[1], r[0], r[1]); got != want || gotd != want {
+				t.Errorf("width %d: countRanges(%d, %d) = %d, %d, want %d, %d", width, r[0], r[1], got, gotd, want, want)
+			}
+		}
+	}
+}
+
+func TestSketchBitPacking(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	// 31 representatives make for codes up to 63, i.e. 6 bits.
+	dict := NewDict(Byte, randomInt64s(rng, 10000, 31))
+	if dict.Len() != 31 {
+		t.Fatalf("Len() = %d, want 31", dict.Len())
+	}
+
+	const n = 1 << 20
+	values := randomInt64s(rng, n, 40)
+	packed := NewSketch(&dict, WithBitPacking())
+	for _, v := range values[:1000] {
+		packed.Append(v)
+	}
+	packed.AppendCodes(dict.EncodeAll(values[1000:], nil))
+
+	if got := packed.BitsPerCode(); got != 6 {
+		t.Errorf("BitsPerCode() = %d, want 6", got)
+	}
+	if got := packed.BytesPerCode(); got != 1 {
+		t.Errorf("BytesPerCode() = %d, want 1", got)
+	}
+	if got, want := packed.MemoryFootprint(), int64(n*6/8); got < want || got > want+want/4 {
+		t.Errorf("MemoryFootprint() = %d for %d codes, want about %d", got, n, want)
+	}
+
+	for i, v := range values {
+		if got, want := packed.Get(i), dict.Encode(v); got != want {
+			t.Fatalf("Get(%d) = %d, want %d", i, got, want)
+		}
+	}
+
+	unpacked := NewSketch(&dict)
+	unpacked.AppendCodes(dict.EncodeAll(values, nil))
+	for _, r := range [][2]Code{{0, 63}, {10, 20}, {2, 2}, {40, 0xff}} {
+		if got, want := packed.CountRange(r[0], r[1]), unpacked.CountRange(r[0], r[1]); got != want {
+			t.Errorf("CountRange(%d, %d) = %d, want %d", r[0], r[1], got, want)
+		}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("AppendCodes() with a code beyond the dictionary didn't panic")
+			}
+		}()
+		packed.AppendCodes([]Code{64})
+	}()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Get() beyond the end didn't panic")
+			}
+		}()
+		packed.Get(n)
+	}()
+}
+
+// BenchmarkSketchBitPacking compares bit-packed and byte storage of the same
+// codes, reporting their memory use along with scan throughput.
+func BenchmarkSketchBitPacking(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 22
+
+	for _, distinct := range []int64{3, 31, 127} {
+		dict := NewDict(Byte, randomInt64s(rng, 100000, distinct))
+		codes := dict.EncodeAll(randomInt64s(rng, n, distinct), nil)
+
+		for _, storage := range []struct {
+			name string
+			opts []SketchOption
+		}{
+			{"byte", nil},
+			{"packed", []SketchOption{WithBitPacking()}},
+		} {
+			s := NewSketch(&dict, storage.opts...)
+			s.AppendCodes(codes)
+			lo, hi := dict.Encode(distinct/4), dict.Encode(distinct/2)
+
+			b.Run(fmt.Sprintf("codes=%d/%s", 2*dict.Len()+1, storage.name), func(b *testing.B) {
+				b.ReportMetric(float64(s.MemoryFootprint())/n, "bytes/code")
+				var count int
+				for i := 0; i < b.N; i++ {
+					count += s.CountRange(lo, hi)
+				}
+				sink = count
+				b.ReportMetric(float64(b.N)*n/b.Elapsed().Seconds(), "codes/s")
+			})
+		}
+	}
+}
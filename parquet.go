@@ -0,0 +1,396 @@
+package colsketch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The subset of the Parquet format needed to write and read a column chunk
+// of codes: a sequence of version 1 data pages of an INT32 column, each a
+// Thrift PageHeader in the compact protocol followed by its values in PLAIN
+// encoding, i.e. as 4-byte little-endian integers, uncompressed. Required
+// columns have no repetition or definition levels. Optional ones, of
+// sketches built WithNulls, have no repetition levels, and precede the
+// values of each page with a definition level per row, 0 for NULL and 1
+// otherwise, in the RLE encoding prefixed by its 4-byte length; only the
+// values of the rows that aren't NULL follow.
+const (
+	parquetDataPage = 0 // PageType.DATA_PAGE
+	parquetPlain    = 0 // Encoding.PLAIN
+	parquetRLE      = 3 // Encoding.RLE, that of the levels
+
+	// Thrift compact protocol field types.
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftStruct = 12
+)
+
+// SerializeToParquet writes the sketch's codes to w as a Parquet column
+// chunk, i.e. the data pages of a column, of up to pageSize bytes of values
+// each, which must be at least 4. The column is to be declared in the
+// schema of the file holding it as an INT32, annotated as an unsigned
+// INT(16) or INT(8) depending on the dictionary's mode, whose chunk
+// metadata records PLAIN encoding and no compression. It is required,
+// unless the sketch was built WithNulls: then it is optional, and NULL
+// rows are written as undefined values rather than as NullCode. The codes
+// of deleted rows are written too, to keep the positions of the rows.
+//
+// It is a method of Sketch rather than of Dict, which holds representatives
+// rather than a column of codes, and it doesn't write the dictionary:
+// storing it, e.g. as MarshalBinary's output in the file's key-value
+// metadata, is up to the caller, like writing the file's footer.
+func (s *Sketch[T]) SerializeToParquet(w io.Writer, pageSize int) error {
+	if pageSize < 4 {
+		return fmt.Errorf("colsketch: page size %d can't hold a value", pageSize)
+	}
+	perPage := pageSize / 4
+	if perPage > math.MaxInt32/4 {
+		perPage = math.MaxInt32 / 4
+	}
+
+	var page, data []byte
+	for start, n := 0, s.store.len(); start < n; start += perPage {
+		end := start + perPage
+		if end > n {
+			end = n
+		}
+
+		data = data[:0]
+		if s.nulls {
+			data = s.appendDefinitionLevels(data, start, end)
+		}
+		for i := start; i < end; i++ {
+			if c := s.store.get(i); !s.nulls || c != NullCode {
+				data = binary.LittleEndian.AppendUint32(data, uint32(c))
+			}
+		}
+
+		// PageHeader{type, uncompressed_page_size, compressed_page_size,
+		// data_page_header: DataPageHeader{num_values, encoding,
+		// definition_level_encoding, repetition_level_encoding}}.
+		page = appendThriftI32(page[:0], 1, parquetDataPage)
+		page = appendThriftI32(page, 1, int64(len(data)))
+		page = appendThriftI32(page, 1, int64(len(data)))
+		page = append(page, 2<<4|thriftStruct)
+		page = appendThriftI32(page, 1, int64(end-start))
+		page = appendThriftI32(page, 1, parquetPlain)
+		page = appendThriftI32(page, 1, parquetRLE)
+		page = appendThriftI32(page, 1, parquetRLE)
+		page = append(page, 0, 0)
+
+		if _, err := w.Write(append(page, data...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendDefinitionLevels appends the definition levels of the rows from
+// start to end, prefixed by their length, as RLE runs of the
+// RLE/bit-packed hybrid encoding at a bit width of 1.
+func (s *Sketch[T]) appendDefinitionLevels(b []byte, start, end int) []byte {
+	at := len(b)
+	b = append(b, 0, 0, 0, 0)
+	for i := start; i < end; {
+		defined := s.store.get(i) != NullCode
+		j := i + 1
+		for j < end && (s.store.get(j) != NullCode) == defined {
+			j++
+		}
+		b = binary.AppendUvarint(b, uint64(j-i)<<1)
+		b = append(b, b2u(defined))
+		i = j
+	}
+	binary.LittleEndian.PutUint32(b[at:], uint32(len(b)-at-4))
+	return b
+}
+
+// appendThriftI32 appends an i32 field, whose id is delta more than that of
+// the previous field of its struct, in the Thrift compact protocol.
+func appendThriftI32(b []byte, delta int, v int64) []byte {
+	b = append(b, byte(delta<<4|thriftI32))
+	return binary.AppendUvarint(b, uint64(v<<1^v>>63))
+}
+
+// ReadParquetCodes reads a Parquet column chunk of codes, as written by
+// Sketch.SerializeToParquet, from r until EOF. It accepts chunks written
+// by other Parquet writers as long as they only hold uncompressed, PLAIN
+// encoded version 1 data pages of a required INT32 column whose values are
+// valid codes.
+func ReadParquetCodes(r io.Reader) ([]Code, error) {
+	return readParquetCodes(r, false)
+}
+
+// ReadParquetNullableCodes is like ReadParquetCodes, for the chunks of
+// optional columns written for sketches built WithNulls, whose undefined
+// values it returns as NullCode. Their definition levels must be RLE
+// encoded, with a maximum of 1.
+func ReadParquetNullableCodes(r io.Reader) ([]Code, error) {
+	return readParquetCodes(r, true)
+}
+
+func readParquetCodes(r io.Reader, optional bool) ([]Code, error) {
+	br := bufio.NewReader(r)
+
+	var codes []Code
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			return codes, nil
+		}
+
+		h, err := readPageHeader(br)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case h.typ != parquetDataPage || !h.hasData:
+			return nil, fmt.Errorf("colsketch: unsupported Parquet page type %d", h.typ)
+		case h.encoding != parquetPlain:
+			return nil, fmt.Errorf("colsketch: unsupported Parquet encoding %d", h.encoding)
+		case optional && h.levelEncoding != parquetRLE:
+			return nil, fmt.Errorf("colsketch: unsupported Parquet definition level encoding %d", h.levelEncoding)
+		case h.numValues < 0 || h.numValues > math.MaxInt32 || h.compressedSize < 0 || h.uncompressedSize != h.compressedSize ||
+			(!optional && h.compressedSize != 4*h.numValues):
+			return nil, fmt.Errorf("colsketch: %d bytes of Parquet page data for %d INT32 values", h.compressedSize, h.numValues)
+		}
+
+		data, err := readN(br, uint64(h.compressedSize))
+		if err != nil {
+			return nil, err
+		}
+
+		var defined []bool
+		if optional {
+			if defined, data, err = readDefinitionLevels(data, int(h.numValues)); err != nil {
+				return nil, err
+			}
+		}
+
+		n := int(h.numValues)
+		for _, d := range defined {
+			n -= int(b2u(!d))
+		}
+		if len(data) != 4*n {
+			return nil, fmt.Errorf("colsketch: %d bytes of Parquet page data for %d INT32 values", len(data), n)
+		}
+
+		for i := 0; i < int(h.numValues); i++ {
+			if optional && !defined[i] {
+				codes = append(codes, NullCode)
+				continue
+			}
+			v := binary.LittleEndian.Uint32(data)
+			if v > math.MaxUint16 {
+				return nil, fmt.Errorf("colsketch: invalid code %d in Parquet page", v)
+			}
+			codes, data = append(codes, Code(v)), data[4:]
+		}
+	}
+}
+
+// readDefinitionLevels decodes n definition levels of at most 1, prefixed
+// by their length, in the RLE/bit-packed hybrid encoding from the front of
+// b, returning whether each value is defined and the rest of b.
+func readDefinitionLevels(b []byte, n int) ([]bool, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, errTruncated
+	}
+	size := binary.LittleEndian.Uint32(b)
+	if uint64(size) > uint64(len(b)-4) {
+		return nil, nil, errTruncated
+	}
+	levels, rest := b[4:4+size], b[4+size:]
+
+	// Runs may stand for many levels in a few bytes, so don't trust n for
+	// preallocation beyond what bit-packed levels could hold.
+	capacity := n
+	if capacity > 8*len(levels) {
+		capacity = 8 * len(levels)
+	}
+	defined := make([]bool, 0, capacity)
+	for len(defined) < n {
+		h, w := binary.Uvarint(levels)
+		if w <= 0 {
+			return nil, nil, errTruncated
+		}
+		levels = levels[w:]
+
+		count := h >> 1
+		if h&1 == 0 {
+			// A run of count repetitions of a level, in a byte.
+			if len(levels) < 1 {
+				return nil, nil, errTruncated
+			}
+			if levels[0] > 1 || count > uint64(n-len(defined)) {
+				return nil, nil, errors.New("colsketch: invalid Parquet definition levels")
+			}
+			for ; count > 0; count-- {
+				defined = append(defined, levels[0] == 1)
+			}
+			levels = levels[1:]
+			continue
+		}
+
+		// count groups of 8 levels, a bit each, least significant first,
+		// the last of which may be padded beyond n.
+		if count > uint64(len(levels)) {
+			return nil, nil, errTruncated
+		}
+		for _, x := range levels[:count] {
+			for k := 0; k < 8 && len(defined) < n; k++ {
+				defined = append(defined, x>>k&1 == 1)
+			}
+		}
+		levels = levels[count:]
+	}
+	return defined, rest, nil
+}
+
+// pageHeader holds the fields of a Parquet PageHeader that ReadParquetCodes
+// understands.
+type pageHeader struct {
+	typ, uncompressedSize, compressedSize int64
+	hasData                               bool
+	numValues, encoding, levelEncoding    int64
+}
+
+func readPageHeader(r *bufio.Reader) (pageHeader, error) {
+	var h pageHeader
+	err := readThriftStruct(r, func(id int16, typ byte) error {
+		switch {
+		case id == 1 && typ == thriftI32:
+			return readThriftInt(r, &h.typ)
+		case id == 2 && typ == thriftI32:
+			return readThriftInt(r, &h.uncompressedSize)
+		case id == 3 && typ == thriftI32:
+			return readThriftInt(r, &h.compressedSize)
+		case id == 5 && typ == thriftStruct:
+			h.hasData = true
+			return readThriftStruct(r, func(id int16, typ byte) error {
+				switch {
+				case id == 1 && typ == thriftI32:
+					return readThriftInt(r, &h.numValues)
+				case id == 2 && typ == thriftI32:
+					return readThriftInt(r, &h.encoding)
+				case id == 3 && typ == thriftI32:
+					return readThriftInt(r, &h.levelEncoding)
+				default:
+					return skipThrift(r, typ, 0)
+				}
+			})
+		default:
+			return skipThrift(r, typ, 0)
+		}
+	})
+	return h, err
+}
+
+// readThriftStruct reads the fields of a struct in the Thrift compact
+// protocol, calling field to read or skip each of them.
+func readThriftStruct(r *bufio.Reader, field func(id int16, typ byte) error) error {
+	var id int16
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return noEOF(err)
+		}
+		if b == 0 {
+			return nil
+		}
+
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			var v int64
+			if err := readThriftInt(r, &v); err != nil {
+				return err
+			}
+			id = int16(v)
+		}
+		if err := field(id, b&0x0f); err != nil {
+			return err
+		}
+	}
+}
+
+// readThriftInt reads a zigzag varint, as integers of all widths are written
+// in the Thrift compact protocol.
+func readThriftInt(r *bufio.Reader, v *int64) error {
+	u, err := binary.ReadUvarint(r)
+	if err != nil {
+		return noEOF(err)
+	}
+	*v = int64(u>>1) ^ -int64(u&1)
+	return nil
+}
+
+// maxThriftDepth bounds the nesting of the values skipThrift skips.
+const maxThriftDepth = 16
+
+// skipThrift skips a value of the given type in the Thrift compact protocol.
+func skipThrift(r *bufio.Reader, typ byte, depth int) error {
+	if depth > maxThriftDepth {
+		return errors.New("colsketch: Parquet page header nested too deeply")
+	}
+
+	var v int64
+	switch typ {
+	case thriftTrue, thriftFalse:
+		return nil
+	case thriftByte:
+		_, err := r.ReadByte()
+		return noEOF(err)
+	case thriftI16, thriftI32, thriftI64:
+		return readThriftInt(r, &v)
+	case thriftDouble:
+		_, err := r.Discard(8)
+		return noEOF(err)
+	case thriftBinary:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return noEOF(err)
+		}
+		_, err = readN(r, n)
+		return err
+	case thriftList, thriftSet:
+		b, err := r.ReadByte()
+		if err != nil {
+			return noEOF(err)
+		}
+		n := uint64(b >> 4)
+		if n == 15 {
+			if n, err = binary.ReadUvarint(r); err != nil {
+				return noEOF(err)
+			}
+		}
+		for ; n > 0; n-- {
+			// Booleans in lists take a byte each.
+			elem := b & 0x0f
+			if elem == thriftTrue || elem == thriftFalse {
+				elem = thriftByte
+			}
+			if err := skipThrift(r, elem, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftStruct:
+		return readThriftStruct(r, func(_ int16, typ byte) error {
+			return skipThrift(r, typ, depth+1)
+		})
+	default:
+		return fmt.Errorf("colsketch: unsupported Thrift type %d in Parquet page header", typ)
+	}
+}
@@ -0,0 +1,177 @@
+package colsketch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSerializeToParquet(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 100000, 1<<20))
+		s := NewSketch(&dict)
+		for _, v := range randomInt64s(rng, 10000, 1<<20) {
+			s.Append(v)
+		}
+
+		want := make([]Code, s.Len())
+		for i := range want {
+			want[i] = s.Get(i)
+		}
+
+		for _, pageSize := range []int{4, 1000, 4096, 1 << 20} {
+			var buf bytes.Buffer
+			if err := s.SerializeToParquet(&buf, pageSize); err != nil {
+				t.Fatalf("mode %s, page size %d: SerializeToParquet() = %v", mode, pageSize, err)
+			}
+			got, err := ReadParquetCodes(&buf)
+			if err != nil {
+				t.Fatalf("mode %s, page size %d: ReadParquetCodes() = %v", mode, pageSize, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("mode %s, page size %d: round trip doesn't preserve codes", mode, pageSize)
+			}
+		}
+	}
+
+	dict := NewDict(Byte, []int{1})
+	if err := NewSketch(&dict).SerializeToParquet(new(bytes.Buffer), 3); err == nil {
+		t.Errorf("SerializeToParquet() with a page size of 3 succeeded")
+	}
+}
+
+func TestSerializeToParquetNulls(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Word, randomInt64s(rng, 100000, 1<<20))
+	s := NewSketch(&dict, WithNulls())
+	for i, v := range randomInt64s(rng, 10000, 1<<20) {
+		// Runs of NULL rows of all lengths, including ones spanning pages.
+		if i%97 < i%13 {
+			s.AppendNull()
+			continue
+		}
+		s.Append(v)
+	}
+
+	want := make([]Code, s.Len())
+	for i := range want {
+		want[i] = s.Get(i)
+	}
+
+	for _, pageSize := range []int{4, 1000, 1 << 20} {
+		var buf bytes.Buffer
+		if err := s.SerializeToParquet(&buf, pageSize); err != nil {
+			t.Fatalf("page size %d: SerializeToParquet() = %v", pageSize, err)
+		}
+		got, err := ReadParquetNullableCodes(&buf)
+		if err != nil {
+			t.Fatalf("page size %d: ReadParquetNullableCodes() = %v", pageSize, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("page size %d: round trip doesn't preserve codes", pageSize)
+		}
+	}
+}
+
+func TestReadParquetCodes(t *testing.T) {
+	dict := NewDict(Word, []int{1, 2, 3})
+	s := NewSketch(&dict)
+	for _, v := range []int{0, 1, 2, 3, 4} {
+		s.Append(v)
+	}
+
+	var buf bytes.Buffer
+	if err := s.SerializeToParquet(&buf, 8); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// Truncated chunks fail to read, unless they end with a page.
+	codes := []Code{1, 2, 4, 6, 7}
+	for i := 1; i < len(data); i++ {
+		got, err := ReadParquetCodes(bytes.NewReader(data[:i]))
+		if err == nil && (len(got)%2 != 0 || !reflect.DeepEqual(got, codes[:len(got)])) {
+			t.Errorf("ReadParquetCodes() of %d/%d bytes = %v", i, len(data), got)
+		}
+	}
+
+	// A page header with fields of other writers, such as a CRC and
+	// statistics with a long-form field id, and a value beyond the codes.
+	page := appendThriftI32(nil, 1, parquetDataPage)
+	page = appendThriftI32(page, 1, 8)
+	page = appendThriftI32(page, 1, 8)
+	page = appendThriftI32(page, 1, 12345)
+	page = append(page, 1<<4|thriftStruct)
+	page = appendThriftI32(page, 1, 2)
+	page = appendThriftI32(page, 1, parquetPlain)
+	page = append(page, thriftStruct, 2*5) // Field 5 in long form.
+	page = append(page, 1<<4|thriftBinary, 3, 'a', 'b', 'c')
+	page = append(page, 1<<4|thriftList, 2<<4|thriftI32, 2, 4, 0, 0, 0)
+	page = binary.LittleEndian.AppendUint32(page, 7)
+
+	got, err := ReadParquetCodes(bytes.NewReader(binary.LittleEndian.AppendUint32(page, 9)))
+	if err != nil {
+		t.Fatalf("ReadParquetCodes() of a foreign page = %v", err)
+	}
+	if want := []Code{7, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadParquetCodes() of a foreign page = %v, want %v", got, want)
+	}
+	if _, err := ReadParquetCodes(bytes.NewReader(binary.LittleEndian.AppendUint32(page, 1<<16))); err == nil {
+		t.Errorf("ReadParquetCodes() of an out of range code succeeded")
+	}
+
+	// Nothing is read from an empty chunk.
+	if got, err := ReadParquetCodes(bytes.NewReader(nil)); err != nil || len(got) != 0 {
+		t.Errorf("ReadParquetCodes() of an empty chunk = %v, %v", got, err)
+	}
+	if _, err := ReadParquetCodes(bytes.NewReader([]byte{1<<4 | thriftI32})); !errors.Is(err, errTruncated) {
+		t.Errorf("ReadParquetCodes() of a truncated header = %v, want %v", err, errTruncated)
+	}
+}
+
+func TestReadParquetNullableCodes(t *testing.T) {
+	// A page of 10 values, 3 of them NULL, whose definition levels another
+	// writer bit-packed into two groups of 8, the second padded.
+	levels := []byte{2<<1 | 1, 0b11011011, 0b00000001}
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	data = append(data, levels...)
+	for _, c := range []uint32{2, 3, 5, 6, 8, 10, 12} {
+		data = binary.LittleEndian.AppendUint32(data, c)
+	}
+	page := func(data []byte) []byte {
+		page := appendThriftI32(nil, 1, parquetDataPage)
+		page = appendThriftI32(page, 1, int64(len(data)))
+		page = appendThriftI32(page, 1, int64(len(data)))
+		page = append(page, 2<<4|thriftStruct)
+		page = appendThriftI32(page, 1, 10)
+		page = appendThriftI32(page, 1, parquetPlain)
+		page = appendThriftI32(page, 1, parquetRLE)
+		page = append(page, 0, 0)
+		return append(page, data...)
+	}
+
+	got, err := ReadParquetNullableCodes(bytes.NewReader(page(data)))
+	if err != nil {
+		t.Fatalf("ReadParquetNullableCodes() of bit-packed levels = %v", err)
+	}
+	if want := []Code{2, 3, 0, 5, 6, 0, 8, 10, 12, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadParquetNullableCodes() of bit-packed levels = %v, want %v", got, want)
+	}
+
+	for name, data := range map[string][]byte{
+		"missing value": data[:len(data)-4],
+		"extra value":   binary.LittleEndian.AppendUint32(append([]byte(nil), data...), 1),
+		"levels length": append([]byte{0xff, 0, 0, 0}, data[4:]...),
+		"level":         append([]byte{2, 0, 0, 0, 10 << 1, 2}, data[4+len(levels):]...),
+		"long run":      append([]byte{2, 0, 0, 0, 11 << 1, 1}, data[4+len(levels):]...),
+	} {
+		if _, err := ReadParquetNullableCodes(bytes.NewReader(page(data))); err == nil {
+			t.Errorf("%s: ReadParquetNullableCodes() succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package colsketch
+
+import "cmp"
+
+// Predicate is a condition on the values of a column, such as `v < 10`,
+// built with one of Eq, Neq, Lt, Le, Gt, Ge, Between or, for strings,
+// Prefix, or whether it is NULL, built with IsNull or IsNotNull. A sketch
+// evaluates it over codes rather than values: since the codes of a
+// dictionary preserve the order of values, the codes that may stand for
+// values satisfying a predicate form a single range, bar the code of the
+// operand of Neq, which a predicate compiles to once per dictionary. As in
+// SQL, comparisons never match NULL rows.
+type Predicate[T cmp.Ordered] struct {
+	op     predicateOp
+	lo, hi T
+}
+
+// predicateOp is the comparison a Predicate performs.
+type predicateOp uint8
+
+const (
+	opEq predicateOp = iota
+	opLt
+	opLe
+	opGt
+	opGe
+	opBetween
+	opNeq
+	opPrefix
+	opIsNull
+	opIsNotNull
+)
+
+// Eq returns the predicate `v == value`.
+func Eq[T cmp.Ordered](value T) Predicate[T] {
+	return Predicate[T]{op: opEq, lo: value, hi: value}
+}
+
+// Neq returns the predicate `v != value`. Rows whose codes are exact are
+// definite matches unless their representative is value, which rules them
+// out. Rows whose codes are inexact are definite matches too, unless value
+// is among the values their code stands for, i.e. it was encoded to it,
+// which makes them candidates: the code can't tell whether they hold value
+// or another one. Scans of Neq classify rows this way, rather than as the
+// complement of the scans of Eq, whose candidates include rows that don't
+// hold value.
+func Neq[T cmp.Ordered](value T) Predicate[T] {
+	return Predicate[T]{op: opNeq, lo: value, hi: value}
+}
+
+// Lt returns the predicate `v < value`.
+func Lt[T cmp.Ordered](value T) Predicate[T] {
+	return Predicate[T]{op: opLt, hi: value}
+}
+
+// Le returns the predicate `v <= value`.
+func Le[T cmp.Ordered](value T) Predicate[T] {
+	return Predicate[T]{op: opLe, hi: value}
+}
+
+// Gt returns the predicate `v > value`.
+func Gt[T cmp.Ordered](value T) Predicate[T] {
+	return Predicate[T]{op: opGt, lo: value}
+}
+
+// Ge returns the predicate `v >= value`.
+func Ge[T cmp.Ordered](value T) Predicate[T] {
+	return Predicate[T]{op: opGe, lo: value}
+}
+
+// Between returns the predicate `lo <= v && v <= hi`, which no value
+// satisfies if lo > hi.
+func Between[T cmp.Ordered](lo, hi T) Predicate[T] {
+	return Predicate[T]{op: opBetween, lo: lo, hi: hi}
+}
+
+// Matches returns true iff value satisfies the predicate, comparing as
+// cmp.Compare does, so that NaNs equal each other and order before all
+// other floats. It is meant for checking the candidates a sketch yields
+// against the values they stand for, which aren't NULL: IsNull matches none
+// of them, and IsNotNull all.
+func (p Predicate[T]) Matches(value T) bool {
+	switch p.op {
+	case opIsNull:
+		return false
+	case opIsNotNull:
+		return true
+	case opEq:
+		return cmp.Compare(value, p.lo) == 0
+	case opNeq:
+		return cmp.Compare(value, p.lo) != 0
+	case opLt:
+		return cmp.Less(value, p.hi)
+	case opLe:
+		return !cmp.Less(p.hi, value)
+	case opGt:
+		return cmp.Less(p.lo, value)
+	case opGe:
+		return !cmp.Less(value, p.lo)
+	case opPrefix:
+		return !cmp.Less(value, p.lo) && cmp.Less(value, p.hi)
+	default:
+		return !cmp.Less(value, p.lo) && !cmp.Less(p.hi, value)
+	}
+}
+
+// codeFilter is a predicate compiled against a dictionary: the codes c
+// with lo <= c <= hi, except for the code ex if except is set, which is
+// within the range. It is empty, with lo > hi, if no code passes it.
+type codeFilter struct {
+	lo, hi Code
+	ex     Code
+	except bool
+}
+
+// emptyFilter is the filter no code passes.
+var emptyFilter = codeFilter{lo: 1, hi: 0}
+
+// empty returns true iff no code passes the filter.
+func (f codeFilter) empty() bool {
+	return f.lo > f.hi
+}
+
+// contains returns true iff c passes the filter.
+func (f codeFilter) contains(c Code) bool {
+	return f.lo <= c && c <= f.hi && !(f.except && c == f.ex)
+}
+
+// rulesOut returns true iff no code within a block's bounds passes the
+// filter.
+func (f codeFilter) rulesOut(b blockBounds) bool {
+	return b.max < f.lo || b.min > f.hi || (f.except && b.min == f.ex && b.max == f.ex)
+}
+
+// rulesIn returns true iff every code within a block's bounds passes the
+// filter.
+func (f codeFilter) rulesIn(b blockBounds) bool {
+	return f.lo <= b.min && b.max <= f.hi && !(f.except && b.min <= f.ex && f.ex <= b.max)
+}
+
+// mask is rangeMask for the codes passing the filter.
+func (f codeFilter) mask(st codeStore, start, n int) uint64 {
+	m := st.rangeMask(start, n, f.lo, f.hi)
+	if f.except && m != 0 {
+		m &^= st.rangeMask(start, n, f.ex, f.ex)
+	}
+	return m
+}
+
+// count is countRanges for the codes passing the filter and a filter of
+// definite codes d, which passes no code f doesn't.
+func (f codeFilter) count(st codeStore, start, end int, d codeFilter) (n, nd int) {
+	n, nd = st.countRanges(start, end, f.lo, f.hi, d.lo, d.hi)
+	if f.except {
+		x, _ := st.countRanges(start, end, f.ex, f.ex, 1, 0)
+		n -= x
+	}
+	if d.except && !d.empty() {
+		x, _ := st.countRanges(start, end, d.ex, d.ex, 1, 0)
+		nd -= x
+	}
+	return n, nd
+}
+
+// candidates returns the filter of the codes of the dictionary that may
+// stand for values satisfying the predicate. Exact codes pass it iff their
+// representative satisfies the predicate, and inexact codes iff any of the
+// values between their neighbouring representatives may. Every code but
+// the exact code of the operand of Neq, if any, may stand for a value other
+// than it. NullCode passes IsNull alone.
+func (p Predicate[T]) candidates(d *Dict[T]) codeFilter {
+	lo, hi := Code(1), Code(2*len(d.codes)+1)
+
+	switch p.op {
+	case opIsNull:
+		return codeFilter{lo: NullCode, hi: NullCode}
+	case opEq:
+		c := d.Encode(p.lo)
+		return codeFilter{lo: c, hi: c}
+	case opNeq:
+		c := d.Encode(p.lo)
+		return codeFilter{lo: lo, hi: hi, ex: c, except: c.IsExact()}
+	case opLt:
+		// Values below an exact operand are all coded below it, while an
+		// inexact code spans values on either side of its operand.
+		hi = d.Encode(p.hi)
+		if hi.IsExact() {
+			hi--
+		}
+	case opLe:
+		hi = d.Encode(p.hi)
+	case opGt:
+		lo = d.Encode(p.lo)
+		if lo.IsExact() {
+			lo++
+		}
+	case opGe:
+		lo = d.Encode(p.lo)
+	case opBetween:
+		if cmp.Less(p.hi, p.lo) {
+			return emptyFilter
+		}
+		lo, hi = d.Encode(p.lo), d.Encode(p.hi)
+	case opPrefix:
+		// As Ge of the prefix and Lt of its successor.
+		lo, hi = d.Encode(p.lo), d.Encode(p.hi)
+		if hi.IsExact() {
+			hi--
+		}
+	}
+	return codeFilter{lo: lo, hi: hi}
+}
+
+// definite returns the filter of the codes of the dictionary whose values
+// all satisfy the predicate, which passes no code the candidates don't:
+// every exact candidate, since an exact code stands for its representative
+// alone, and every inexact one but those holding an operand, which stand
+// for values on either side of it. The operand of Neq rules out the code it
+// is encoded to, whether or not it is exact. Whether a row is NULL is
+// certain from its code.
+func (p Predicate[T]) definite(d *Dict[T]) codeFilter {
+	f := p.candidates(d)
+	if f.empty() {
+		return f
+	}
+	switch p.op {
+	case opNeq:
+		f.ex, f.except = d.Encode(p.lo), true
+		return f
+	case opIsNull, opIsNotNull:
+		return f
+	}
+
+	if p.op != opLt && p.op != opLe && !f.lo.IsExact() && f.lo == d.Encode(p.lo) {
+		// Without incrementing the largest Word mode code past the range.
+		if f.lo == f.hi {
+			return emptyFilter
+		}
+		f.lo++
+	}
+	if p.op != opGt && p.op != opGe && !f.hi.IsExact() && f.hi == d.Encode(p.hi) {
+		f.hi--
+	}
+	return f
+}
@@ -0,0 +1,182 @@
+package colsketch
+
+import (
+	"cmp"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPredicateMatches(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		p     Predicate[float64]
+		match []float64
+		miss  []float64
+	}{
+		{"Eq", Eq(2.0), []float64{2}, []float64{1, 3, math.NaN()}},
+		{"EqNaN", Eq(math.NaN()), []float64{math.NaN()}, []float64{0, math.Inf(-1)}},
+		{"Neq", Neq(2.0), []float64{1, 3, math.NaN()}, []float64{2}},
+		{"NeqNaN", Neq(math.NaN()), []float64{0, math.Inf(-1)}, []float64{math.NaN()}},
+		{"Lt", Lt(2.0), []float64{math.NaN(), math.Inf(-1), 1}, []float64{2, 3}},
+		{"Le", Le(2.0), []float64{1, 2}, []float64{3, math.Inf(1)}},
+		{"Gt", Gt(2.0), []float64{3, math.Inf(1)}, []float64{2, math.NaN()}},
+		{"Ge", Ge(2.0), []float64{2, 3}, []float64{1}},
+		{"Between", Between(1.0, 3.0), []float64{1, 2, 3}, []float64{0, 4, math.NaN()}},
+		{"BetweenEmpty", Between(3.0, 1.0), nil, []float64{0, 1, 2, 3, 4}},
+		{"IsNull", IsNull[float64](), nil, []float64{0, math.NaN()}},
+		{"IsNotNull", IsNotNull[float64](), []float64{0, math.NaN()}, nil},
+	} {
+		for _, v := range tc.match {
+			if !tc.p.Matches(v) {
+				t.Errorf("%s: Matches(%v) = false", tc.name, v)
+			}
+		}
+		for _, v := range tc.miss {
+			if tc.p.Matches(v) {
+				t.Errorf("%s: Matches(%v) = true", tc.name, v)
+			}
+		}
+	}
+}
+
+// mayMatch is the reference for the codes a predicate compiles to: whether
+// any value of a code's interval satisfies the predicate, taking the open
+// interval of an inexact code to hold every value between its bounds, as if
+// values were real numbers.
+func mayMatch[T cmp.Ordered](iv Interval[T], p Predicate[T]) bool {
+	if iv.Exact {
+		return p.Matches(iv.Lo)
+	}
+
+	// Whether the interval reaches below hi and above lo.
+	below := func(hi T) bool { return !iv.HasLo || cmp.Less(iv.Lo, hi) }
+	above := func(lo T) bool { return !iv.HasHi || cmp.Less(lo, iv.Hi) }
+
+	switch p.op {
+	case opIsNull:
+		return false
+	case opEq:
+		return below(p.lo) && above(p.lo)
+	case opNeq, opIsNotNull:
+		// An open interval holds values other than any one.
+		return true
+	case opLt, opLe:
+		return below(p.hi)
+	case opGt, opGe:
+		return above(p.lo)
+	default:
+		return !cmp.Less(p.hi, p.lo) && below(p.hi) && above(p.lo)
+	}
+}
+
+// mustMatch is the reference for the codes a predicate definitely matches:
+// whether every value of a code's interval satisfies the predicate, with the
+// same view of intervals as mayMatch.
+func mustMatch[T cmp.Ordered](iv Interval[T], p Predicate[T]) bool {
+	if iv.Exact {
+		return p.Matches(iv.Lo)
+	}
+
+	// Whether the interval ends at or below hi and starts at or above lo.
+	within := func(hi T) bool { return iv.HasHi && !cmp.Less(hi, iv.Hi) }
+	beyond := func(lo T) bool { return iv.HasLo && !cmp.Less(iv.Lo, lo) }
+
+	switch p.op {
+	case opEq, opIsNull:
+		return false
+	case opIsNotNull:
+		return true
+	case opNeq:
+		return !mayMatch(iv, Eq(p.lo))
+	case opLt, opLe:
+		return within(p.hi)
+	case opGt, opGe:
+		return beyond(p.lo)
+	default:
+		return !cmp.Less(p.hi, p.lo) && within(p.hi) && beyond(p.lo)
+	}
+}
+
+// randomPredicate returns a predicate of a random kind over operands drawn
+// by value.
+func randomPredicate[T cmp.Ordered](rng *rand.Rand, value func() T) Predicate[T] {
+	switch rng.Intn(7) {
+	case 0:
+		return Eq(value())
+	case 6:
+		return Neq(value())
+	case 1:
+		return Lt(value())
+	case 2:
+		return Le(value())
+	case 3:
+		return Gt(value())
+	case 4:
+		return Ge(value())
+	default:
+		return Between(value(), value())
+	}
+}
+
+func testCandidates[T cmp.Ordered](t *testing.T, d *Dict[T], p Predicate[T]) {
+	t.Helper()
+	f, df := p.candidates(d), p.definite(d)
+	if got, want := f.contains(NullCode), p.op == opIsNull; got != want || df.contains(NullCode) != want {
+		t.Fatalf("%+v: NullCode in candidates %+v, definite %+v, want %v", p, f, df, want)
+	}
+	for i := 1; i <= d.TotalCodes(); i++ {
+		c := Code(i)
+		iv, _ := d.Bounds(c)
+		if got, want := f.contains(c), mayMatch(iv, p); got != want {
+			t.Fatalf("%+v: code %d in candidates %+v = %v, want %v", p, c, f, got, want)
+		}
+		if got, want := df.contains(c), mustMatch(iv, p); got != want {
+			t.Fatalf("%+v: code %d in definite %+v = %v, want %v", p, c, df, got, want)
+		}
+		if df.contains(c) && !f.contains(c) {
+			t.Fatalf("%+v: code %d is definite but not a candidate", p, c)
+		}
+	}
+}
+
+func TestPredicateCandidates(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 200; i++ {
+		n := 1 + rng.Intn(500)
+		ints := NewDict(Byte, randomInt64s(rng, n, int64(2*n)))
+		strs := NewDict(Word, randomStrings(rng, n, 2*n))
+		for j := 0; j < 20; j++ {
+			testCandidates(t, &ints, randomPredicate(rng, func() int64 { return rng.Int63n(int64(2*n+2)) - 1 }))
+			testCandidates(t, &strs, randomPredicate(rng, func() string { return randomStrings(rng, 1, 2*n+2)[0] }))
+		}
+	}
+
+	floats := NewDict(Byte, []float64{math.NaN(), math.Inf(-1), -1, 0, 1})
+	for _, v := range []float64{math.NaN(), math.Inf(-1), -1, -0.5, 0, 2, math.Inf(1)} {
+		for _, p := range []Predicate[float64]{Eq(v), Lt(v), Le(v), Gt(v), Ge(v), Between(v, 0.5), Between(-0.5, v)} {
+			testCandidates(t, &floats, p)
+		}
+	}
+
+	// The largest Word mode code holds operands past the last representative.
+	full := make([]int, Word.NumExactCodes())
+	for i := range full {
+		full[i] = 2 * i
+	}
+	fullDict := NewDict(Word, full)
+	for _, v := range []int{-1, 0, 1, 2*len(full) - 2, 2*len(full) - 1} {
+		for _, p := range []Predicate[int]{Eq(v), Lt(v), Le(v), Gt(v), Ge(v), Between(v, v), Between(v, v+1)} {
+			testCandidates(t, &fullDict, p)
+		}
+	}
+
+	var empty Dict[int]
+	testCandidates(t, &empty, Eq(1))
+	testCandidates(t, &empty, Between(2, 1))
+	for _, d := range []*Dict[int]{&empty, &fullDict} {
+		testCandidates(t, d, IsNull[int]())
+		testCandidates(t, d, IsNotNull[int]())
+	}
+}
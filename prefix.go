@@ -0,0 +1,43 @@
+package colsketch
+
+import "fmt"
+
+// Prefix returns the predicate `strings.HasPrefix(v, prefix)`, as of SQL's
+// `v LIKE 'prefix%'`. The strings with a prefix are those from the prefix
+// itself up to, excluding, its successor: the prefix with its trailing 0xFF
+// bytes dropped and its last byte incremented, e.g. "ac" for "ab\xff". A
+// prefix without a successor, which is empty or all 0xFF bytes, is a prefix
+// of every string not below it, so the predicate is Ge(prefix), which the
+// empty prefix makes match every string.
+func Prefix(prefix string) Predicate[string] {
+	succ, ok := prefixSuccessor(prefix)
+	if !ok {
+		return Ge(prefix)
+	}
+	return Predicate[string]{op: opPrefix, lo: prefix, hi: succ}
+}
+
+// prefixSuccessor returns the smallest string greater than every string with
+// the prefix, if there is one.
+func prefixSuccessor(prefix string) (string, bool) {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			b := []byte(prefix[:i+1])
+			b[i]++
+			return string(b), true
+		}
+	}
+	return "", false
+}
+
+// CompilePrefix returns the range of codes `[lo, hi]` of the dictionary that
+// may stand for strings with the prefix, which Prefix(prefix) compiles to.
+// It panics if the dictionary isn't a Dict[string].
+func (d *Dict[T]) CompilePrefix(prefix string) (lo, hi Code) {
+	ds, ok := any(d).(*Dict[string])
+	if !ok {
+		panic(fmt.Sprintf("colsketch: CompilePrefix on a Dict[%T]", *new(T)))
+	}
+	f := Prefix(prefix).candidates(ds)
+	return f.lo, f.hi
+}
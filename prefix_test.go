@@ -0,0 +1,106 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestPrefixSuccessor(t *testing.T) {
+	for _, tc := range []struct {
+		prefix, want string
+		ok           bool
+	}{
+		{"", "", false},
+		{"a", "b", true},
+		{"ab", "ac", true},
+		{"ab\xff", "ac", true},
+		{"a\xff\xff", "b", true},
+		{"\xfe\xff", "\xff", true},
+		{"\xff", "", false},
+		{"\xff\xff\xff", "", false},
+	} {
+		if got, ok := prefixSuccessor(tc.prefix); got != tc.want || ok != tc.ok {
+			t.Errorf("prefixSuccessor(%q) = %q, %v, want %q, %v", tc.prefix, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+// prefixCorpus returns n strings of a few levels of shared prefixes, some
+// with 0xFF bytes.
+func prefixCorpus(rng *rand.Rand, n int) []string {
+	tables := []string{"order", "user", "user\xff", "\xff", "\xff\xff"}
+	s := make([]string, n)
+	for i := range s {
+		s[i] = fmt.Sprintf("%s/%03d/%d", tables[rng.Intn(len(tables))], rng.Intn(100), rng.Intn(1000))
+	}
+	return s
+}
+
+func TestPrefix(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	values := prefixCorpus(rng, 20000)
+
+	prefixes := []string{
+		"", "o", "order", "order/", "order/04", "order/042/", "user", "user/",
+		"user\xff", "user\xff/", "us\xff", "\xff", "\xff\xff", "\xff\xff\xff",
+		"\xff/00", "a", "zzz", "order/100",
+	}
+	for i := 0; i < 50; i++ {
+		v := values[rng.Intn(len(values))]
+		prefixes = append(prefixes, v[:rng.Intn(len(v)+1)])
+	}
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, prefixCorpus(rng, 2000))
+		s := NewSketch(&dict)
+		s.AppendValues(values)
+
+		for _, prefix := range prefixes {
+			p := Prefix(prefix)
+			for _, v := range values {
+				if got, want := p.Matches(v), strings.HasPrefix(v, prefix); got != want {
+					t.Fatalf("%s: Prefix(%q).Matches(%q) = %v, want %v", mode, prefix, v, got, want)
+				}
+			}
+			testCandidates(t, &dict, p)
+
+			// Rows with the prefix are visited, and rows without it aren't
+			// definite.
+			lo, hi := dict.CompilePrefix(prefix)
+			visited := make([]bool, len(values))
+			s.ScanCertain(p, func(pos int, c Certainty) bool {
+				visited[pos] = true
+				if c == Definite && !strings.HasPrefix(values[pos], prefix) {
+					t.Fatalf("%s, %q: row %d with %q is definite", mode, prefix, pos, values[pos])
+				}
+				return true
+			})
+			for pos, v := range values {
+				if !strings.HasPrefix(v, prefix) {
+					continue
+				}
+				if !visited[pos] {
+					t.Fatalf("%s, %q: row %d with %q not visited", mode, prefix, pos, v)
+				}
+				if c := s.Get(pos); c < lo || c > hi {
+					t.Fatalf("%s, %q: code %d of %q outside of CompilePrefix() = [%d, %d]", mode, prefix, c, v, lo, hi)
+				}
+			}
+		}
+
+		// The empty prefix matches everything.
+		if lo, hi := dict.CompilePrefix(""); lo != 1 || int(hi) != dict.TotalCodes() {
+			t.Errorf("%s: CompilePrefix(\"\") = [%d, %d], want [1, %d]", mode, lo, hi, dict.TotalCodes())
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("CompilePrefix() on a Dict[int] didn't panic")
+		}
+	}()
+	ints := NewDict(Byte, []int{1, 2, 3})
+	ints.CompilePrefix("1")
+}
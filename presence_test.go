@@ -0,0 +1,196 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSketchPresenceMasks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 1000, 1000))
+
+	for _, opts := range [][]SketchOption{
+		{WithBlockSize(64)},
+		{WithBlockSize(128), WithBitPacking()},
+		{WithBlockSize(64), WithRunLength()},
+		{WithNulls()},
+	} {
+		s := NewSketch(&dict, append(opts, WithPresenceMasks())...)
+		name := fmt.Sprintf("block size %d, %d bits", s.BlockSize(), s.BitsPerCode())
+
+		// Values of a few common codes, among which rare ones are scattered,
+		// so that blocks' bounds span codes that aren't in them.
+		common := randomInt64s(rng, 8, 1000)
+		n := 20*s.BlockSize() + rng.Intn(64)
+		values := make([]int64, n)
+		for i := range values {
+			values[i] = common[rng.Intn(len(common))]
+			if rng.Intn(50) == 0 {
+				values[i] = rng.Int63n(1002) - 1
+			}
+		}
+		s.AppendValues(values[:n/2])
+		for _, v := range values[n/2:] {
+			s.Append(v)
+		}
+
+		deleted := make([]bool, n)
+		check := func(phase string) {
+			t.Helper()
+			testPresenceMasks(t, name+", "+phase, s, deleted)
+			for i := 0; i < 30; i++ {
+				testDeletedScans(t, name+", "+phase, s, deleted, Eq(values[rng.Intn(len(values))]))
+			}
+			for i := 0; i < 10; i++ {
+				testDeletedScans(t, name+", "+phase, s, deleted, randomPredicate(rng, func() int64 { return rng.Int63n(1002) - 1 }))
+			}
+		}
+		check("appended")
+
+		// Sets replacing the only occurrences of codes in their blocks.
+		for i := 0; i < n/20; i++ {
+			pos := rng.Intn(n)
+			values[pos] = rng.Int63n(1002) - 1
+			s.Set(pos, values[pos])
+		}
+		check("set")
+
+		for i := 0; i < n/4; i++ {
+			pos := rng.Intn(n)
+			deleted[pos] = true
+			s.Delete(pos)
+		}
+		check("deleted")
+
+		s.RecomputePresenceMasks()
+		check("recomputed")
+
+		for i := 0; i < n/20; i++ {
+			pos := rng.Intn(n)
+			values[pos] = rng.Int63n(1002) - 1
+			s.Set(pos, values[pos])
+		}
+		check("set after recomputing")
+
+		// Appending sketches with and without masks, at and off block
+		// boundaries.
+		for _, other := range []*Sketch[int64]{NewSketch(&dict, WithBlockSize(s.BlockSize())), NewSketch(&dict, append(opts, WithPresenceMasks())...), s} {
+			vs := values
+			if other != s {
+				vs = randomInt64s(rng, 3*s.BlockSize()+rng.Intn(64), 1000)
+				other.AppendValues(vs)
+			}
+			if err := s.AppendSketch(other); err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, vs...)
+			deleted = append(deleted, make([]bool, len(vs))...)
+			if other == s {
+				copy(deleted[len(deleted)-len(vs):], deleted)
+			}
+		}
+		check("appended sketches")
+	}
+
+	word := NewDict(Word, []int64{1, 2, 3})
+	defer func() {
+		if recover() == nil {
+			t.Errorf("NewSketch() of a Word mode dictionary WithPresenceMasks() didn't panic")
+		}
+	}()
+	NewSketch(&word, WithPresenceMasks())
+}
+
+// testPresenceMasks checks that the masks of a sketch hold every code of
+// the live rows of their blocks, so that scans never skip a block holding a
+// code they look for, and that ScanSet visits the rows it would without
+// them.
+func testPresenceMasks(t *testing.T, name string, s *Sketch[int64], deleted []bool) {
+	t.Helper()
+	if len(s.presence) != s.NumBlocks() {
+		t.Fatalf("%s: %d masks for %d blocks", name, len(s.presence), s.NumBlocks())
+	}
+	for pos := 0; pos < s.Len(); pos++ {
+		if c := s.Get(pos); !deleted[pos] && !s.presence[pos/s.BlockSize()].has(c) {
+			t.Fatalf("%s: mask of block %d lacks code %d of row %d", name, pos/s.BlockSize(), c, pos)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(int64(s.Len())))
+	for i := 0; i < 20; i++ {
+		cs := NewCodeSet(Byte)
+		for j := rng.Intn(4); j >= 0; j-- {
+			cs.Add(Code(rng.Intn(s.Dict().TotalCodes()) + 1))
+		}
+		var got, want []int
+		s.ScanSet(cs, func(pos int) bool {
+			got = append(got, pos)
+			return true
+		})
+		for pos := 0; pos < s.Len(); pos++ {
+			if !deleted[pos] && cs.Contains(s.Get(pos)) {
+				want = append(want, pos)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: ScanSet() visited %d rows, want %d", name, len(got), len(want))
+		}
+	}
+}
+
+func BenchmarkSketchPresenceMasks(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 100000, 1<<30))
+
+	// Uniformly spread values, among which a value is rare: in 0.1% of the
+	// rows, and within the bounds of every block.
+	values := randomInt64s(rng, 1<<20, 1<<30)
+	v, _ := dict.Value(128)
+	for i := range values {
+		if dict.Encode(values[i]) == 128 {
+			values[i]++
+		}
+		if rng.Intn(1000) == 0 {
+			values[i] = v
+		}
+	}
+	p := Eq(v)
+
+	for _, masks := range []bool{false, true} {
+		opts := []SketchOption{WithBlockSize(64)}
+		if masks {
+			opts = append(opts, WithPresenceMasks())
+		}
+		s := NewSketch(&dict, opts...)
+		s.AppendValues(values)
+
+		skipped := 0
+		f := p.candidates(&dict)
+		for i := 0; i < s.NumBlocks(); i++ {
+			skipped += int(b2u(s.rulesOut(i, f)))
+		}
+
+		b.Run(fmt.Sprintf("masks=%v/Count", masks), func(b *testing.B) {
+			b.SetBytes(int64(s.Len()))
+			for i := 0; i < b.N; i++ {
+				n, _ := s.Count(p)
+				codeSink = Code(n)
+			}
+			b.ReportMetric(float64(skipped)/float64(s.NumBlocks()), "skipped/block")
+		})
+		b.Run(fmt.Sprintf("masks=%v/Scan", masks), func(b *testing.B) {
+			b.SetBytes(int64(s.Len()))
+			for i := 0; i < b.N; i++ {
+				n := 0
+				s.Scan(p, func(int) bool {
+					n++
+					return true
+				})
+				codeSink = Code(n)
+			}
+			b.ReportMetric(float64(skipped)/float64(s.NumBlocks()), "skipped/block")
+		})
+	}
+}
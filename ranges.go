@@ -0,0 +1,111 @@
+package colsketch
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// RangeAdder is a set of row IDs that rows can be added to one at a time
+// or in runs, such as the *roaring.Bitmap of
+// github.com/RoaringBitmap/roaring, whose methods it mirrors so that such
+// bitmaps can be scanned into without this package depending on them.
+type RangeAdder interface {
+	// Add adds a row ID.
+	Add(x uint32)
+
+	// AddRange adds the row IDs in `[start, end)`.
+	AddRange(start, end uint64)
+}
+
+// ScanInto evaluates a predicate like Scan, adding the candidate rows to
+// dst as row IDs offset by offset, e.g. the ID of the first row of a row
+// group the sketch covers. Consecutive candidates are added as runs with a
+// single AddRange, single ones with Add, and blocks whose codes all are
+// candidates are added without reading their codes, as are the runs of
+// codes of sketches stored WithRunLength, so scans of clustered columns make
+// few calls. It panics if the row IDs exceed 32 bits.
+func (s *Sketch[T]) ScanInto(p Predicate[T], offset uint64, dst RangeAdder) {
+	n := s.store.len()
+	if uint64(n) > math.MaxUint32+1 || offset > math.MaxUint32+1-uint64(n) {
+		panic(fmt.Sprintf("colsketch: %d rows at offset %d exceed 32-bit row IDs", n, offset))
+	}
+
+	f := p.candidates(s.dict)
+	if f.empty() {
+		return
+	}
+
+	// The run of candidates [start, end) not yet added to dst.
+	var start, end int
+	add := func(from, to int) {
+		if from == end {
+			end = to
+			return
+		}
+		flushRun(dst, offset, start, end)
+		start, end = from, to
+	}
+
+	for i, b := range s.bounds {
+		first := i * s.blockSize
+		last := first + s.blockSize
+		if last > n {
+			last = n
+		}
+
+		all, d := f.rulesIn(b), s.blockDeletions(i)
+		switch {
+		case s.rulesOut(i, f) || d == last-first:
+			continue
+		case all && d == 0:
+			add(first, last)
+			continue
+		}
+
+		// Add runs of codes as ranges, unless rows were deleted.
+		if rs, ok := s.store.(*runStore); ok && d == 0 {
+			rs.ranges(first, last, f, add)
+			continue
+		}
+
+		for pos := first; pos < last; pos += 64 {
+			k := last - pos
+			if k > 64 {
+				k = 64
+			}
+
+			m := ^uint64(0) >> uint(64-k)
+			if !all {
+				m = f.mask(s.store, pos, k)
+			}
+			m &^= s.deletedWord(pos / 64)
+
+			// Peel the runs of set bits off the word, lowest first.
+			for base := pos; m != 0; {
+				skip := bits.TrailingZeros64(m)
+				m >>= uint(skip)
+				run := bits.TrailingZeros64(^m)
+				add(base+skip, base+skip+run)
+
+				base += skip + run
+				if run == 64 {
+					break
+				}
+				m >>= uint(run)
+			}
+		}
+	}
+	flushRun(dst, offset, start, end)
+}
+
+// flushRun adds the rows [start, end) to dst, if any.
+func flushRun(dst RangeAdder, offset uint64, start, end int) {
+	switch end - start {
+	case 0:
+	case 1:
+		dst.Add(uint32(offset + uint64(start)))
+	default:
+		dst.AddRange(offset+uint64(start), offset+uint64(end))
+	}
+}
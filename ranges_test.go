@@ -0,0 +1,151 @@
+package colsketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// bitsetAdder is a RangeAdder over a dense bitmap, which counts the calls
+// made to it.
+type bitsetAdder struct {
+	words        []uint64
+	adds, ranges int
+}
+
+func (b *bitsetAdder) set(x uint64) {
+	for int(x/64) >= len(b.words) {
+		b.words = append(b.words, 0)
+	}
+	b.words[x/64] |= 1 << (x % 64)
+}
+
+func (b *bitsetAdder) Add(x uint32) {
+	b.adds++
+	b.set(uint64(x))
+}
+
+// AddRange sets whole words at once, like roaring bitmaps add whole
+// containers.
+func (b *bitsetAdder) AddRange(start, end uint64) {
+	b.ranges++
+	b.set(end - 1)
+	for x := start; x < end; {
+		if x%64 == 0 && end-x >= 64 {
+			b.words[x/64] = ^uint64(0)
+			x += 64
+			continue
+		}
+		b.set(x)
+		x++
+	}
+}
+
+func TestSketchScanInto(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]SketchOption{
+		nil,
+		{WithBlockSize(64)},
+		{WithBitPacking(), WithBlockSize(128)},
+	} {
+		dict := NewDict(Byte, randomInt64s(rng, 5000, 10000))
+		s := NewSketch(&dict, opts...)
+
+		// Half the column is sorted, for long runs and whole blocks of
+		// candidates.
+		values := randomInt64s(rng, 10000+rng.Intn(64), 12000)
+		sort.Slice(values[:5000], func(i, j int) bool { return values[i] < values[j] })
+		s.AppendCodes(dict.EncodeAll(values, nil))
+
+		for i := 0; i < 200; i++ {
+			p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+			offset := uint64(rng.Intn(3)) * 100000
+
+			var got bitsetAdder
+			s.ScanInto(p, offset, &got)
+
+			// Compare with the bitmap, naively converted to row IDs.
+			var want bitsetAdder
+			for j, w := range s.ScanBitmap(p, nil) {
+				for k := 0; k < 64; k++ {
+					if w&(1<<k) != 0 {
+						want.set(offset + uint64(64*j+k))
+					}
+				}
+			}
+
+			for j := 0; j < len(got.words) || j < len(want.words); j++ {
+				var g, w uint64
+				if j < len(got.words) {
+					g = got.words[j]
+				}
+				if j < len(want.words) {
+					w = want.words[j]
+				}
+				if g != w {
+					t.Fatalf("%+v at offset %d: word %d = %#x, want %#x", p, offset, j, g, w)
+				}
+			}
+		}
+	}
+}
+
+func TestSketchScanIntoRuns(t *testing.T) {
+	dict := NewDict(Byte, []int{1, 2, 3})
+	s := NewSketch(&dict, WithBlockSize(64))
+	for i := 0; i < 1000; i++ {
+		s.Append(1 + i/100%3)
+	}
+
+	// Every run of candidates takes a single call, even across blocks.
+	var b bitsetAdder
+	s.ScanInto(Le(2), 0, &b)
+	if b.adds != 0 || b.ranges != 4 {
+		t.Errorf("ScanInto() made %d Add and %d AddRange calls, want 0 and 4", b.adds, b.ranges)
+	}
+
+	var lone bitsetAdder
+	s = NewSketch(&dict)
+	s.AppendCodes([]Code{2, 4, 2, 2, 4})
+	s.ScanInto(Eq(1), 0, &lone)
+	if lone.adds != 1 || lone.ranges != 1 {
+		t.Errorf("ScanInto() made %d Add and %d AddRange calls, want 1 and 1", lone.adds, lone.ranges)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("ScanInto() beyond 32-bit row IDs didn't panic")
+		}
+	}()
+	s.ScanInto(Eq(1), math.MaxUint32-3, &lone)
+}
+
+func BenchmarkSketchScanInto(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 100000, 1<<30))
+
+	// A clustered column, e.g. of timestamps.
+	values := randomInt64s(rng, 1<<20, 1<<30)
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	s := NewSketch(&dict)
+	s.AppendCodes(dict.EncodeAll(values, nil))
+	p := Between(int64(1<<28), int64(1<<29))
+
+	b.Run("runs", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var dst bitsetAdder
+			s.ScanInto(p, 0, &dst)
+		}
+	})
+	b.Run("positions", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var dst bitsetAdder
+			s.Scan(p, func(pos int) bool {
+				dst.Add(uint32(pos))
+				return true
+			})
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package colsketch
+
+import (
+	"cmp"
+	"sort"
+)
+
+// Reconstruct builds a new dictionary over sample that keeps as many of the
+// receiver's representatives as are still frequent in it, at their codes,
+// so that codes already persisted with the receiver keep their meaning and
+// fewer columns need re-encoding. A representative is kept if it occurs in
+// sample at least as often as the least frequent representative
+// NewDict(mode, sample) would pick, and at least once.
+//
+// The codes between kept representatives, and after the last one, up to as
+// many codes as that fresh dictionary has, are filled with the values of
+// sample between them: the fresh dictionary's representatives first, and
+// then the most frequent other values. A kept representative keeps its
+// exact code unless sample has too few distinct values before it to fill
+// the codes below it, in which case it moves to a lower code. Without any
+// representative to keep, Reconstruct returns the fresh dictionary.
+func (d *Dict[T]) Reconstruct(sample []T) Dict[T] {
+	fresh := NewDict(d.mode, sample)
+	if !d.mode.IsValid() {
+		return fresh
+	}
+
+	sorted := append([]T(nil), sample...)
+	sort.Slice(sorted, func(i, j int) bool { return cmp.Less(sorted[i], sorted[j]) })
+	clu := SampleClusters(sorted)
+
+	// find returns the index of the cluster of v, or len(clu) if sample
+	// doesn't hold it.
+	find := func(v T) int {
+		i := sort.Search(len(clu), func(i int) bool { return cmp.Compare(clu[i].Value, v) >= 0 })
+		if i < len(clu) && cmp.Compare(clu[i].Value, v) == 0 {
+			return i
+		}
+		return len(clu)
+	}
+	count := func(v T) int {
+		if i := find(v); i < len(clu) {
+			return clu[i].Count
+		}
+		return 0
+	}
+
+	// A representative is still frequent if it's at least as frequent as the
+	// least frequent value a fresh build deems worth an exact code.
+	threshold := len(sample)
+	isFresh := make([]bool, len(clu))
+	for _, v := range fresh.codes {
+		if i := find(v); i < len(clu) {
+			isFresh[i] = true
+		}
+		if c := count(v); c < threshold {
+			threshold = c
+		}
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	// fill appends up to n of the values of the clusters [from, to), which
+	// lie between the codes around them, preferring the fresh
+	// representatives and then the most frequent values.
+	var codes []T
+	fill := func(from, to, n int) {
+		pool := make([]int, 0, to-from)
+		for i := from; i < to; i++ {
+			pool = append(pool, i)
+		}
+		sort.SliceStable(pool, func(a, b int) bool {
+			i, j := pool[a], pool[b]
+			if isFresh[i] != isFresh[j] {
+				return isFresh[i]
+			}
+			return clu[i].Count > clu[j].Count
+		})
+		if n < len(pool) {
+			pool = pool[:n]
+		}
+		sort.Ints(pool)
+		for _, i := range pool {
+			codes = append(codes, clu[i].Value)
+		}
+	}
+
+	next, kept := 0, 0
+	for j, v := range d.codes {
+		if count(v) < threshold {
+			continue
+		}
+		i := find(v)
+		fill(next, i, j-len(codes))
+		codes = append(codes, v)
+		next = i + 1
+		kept++
+	}
+	if kept == 0 {
+		return fresh
+	}
+
+	n := len(fresh.codes)
+	if n < len(codes) {
+		n = len(codes)
+	}
+	fill(next, len(clu), n-len(codes))
+
+	return Dict[T]{mode: d.mode, codes: codes, counts: countClusters(codes, clu), sampleSize: len(sample), numClusters: len(clu)}
+}
@@ -0,0 +1,69 @@
+package colsketch
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestReconstruct(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	skewed := func(n int, shift int) []int {
+		s := make([]int, n)
+		for i := range s {
+			s[i] = int(rng.ExpFloat64()*300) + shift
+		}
+		return s
+	}
+
+	old := NewDict(Byte, skewed(50000, 0))
+
+	// The distribution drifts a little: its head is the same but some of the
+	// old representatives disappear entirely.
+	sample := skewed(50000, 0)
+	dropped := map[int]bool{}
+	for _, v := range old.codes[len(old.codes)/2:] {
+		if rng.Intn(2) == 0 {
+			dropped[v] = true
+		}
+	}
+	filtered := sample[:0]
+	for _, v := range sample {
+		if !dropped[v] {
+			filtered = append(filtered, v)
+		}
+	}
+
+	rebuilt := old.Reconstruct(filtered)
+	if err := rebuilt.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	for _, v := range rebuilt.codes {
+		if dropped[v] {
+			t.Errorf("dropped value %d kept as a representative", v)
+		}
+	}
+
+	fresh := NewDict(Byte, filtered)
+	if rebuilt.Len() != fresh.Len() {
+		t.Errorf("Len() = %d, want %d like a fresh build", rebuilt.Len(), fresh.Len())
+	}
+
+	// Reconstructing must retain more of the old representatives than a
+	// fresh build does, and at their codes.
+	rebuiltDiff, freshDiff := old.Diff(rebuilt), old.Diff(fresh)
+	if got, want := len(rebuiltDiff.Added)+len(rebuiltDiff.Removed), len(freshDiff.Added)+len(freshDiff.Removed); got >= want {
+		t.Errorf("churn of reconstructed dict = %d, not less than fresh build's %d", got, want)
+	}
+	if got, want := len(rebuiltDiff.Changed), len(freshDiff.Changed); got >= want {
+		t.Errorf("reconstructed dict changed the codes of %d representatives, not fewer than fresh build's %d", got, want)
+	}
+
+	// Reconstructing over the original sample's distribution keeps every
+	// frequent representative.
+	same := old.Reconstruct(old.codes)
+	if diff := old.Diff(same); !diff.Compatible {
+		t.Errorf("reconstructing over the representatives themselves changed the dict:\n%s", diff)
+	}
+}
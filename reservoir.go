@@ -0,0 +1,96 @@
+package colsketch
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+)
+
+// reservoirSeed seeds the sampling of WithReservoirSize, so that the same
+// sample always yields the same dictionary.
+const reservoirSeed = 1
+
+// WithReservoirSize down-samples samples of more than n values to n values
+// drawn uniformly at random with Vitter's Algorithm R before building the
+// dictionary. NewDict sorts a copy of its whole sample, which for a column of
+// a billion rows takes as much memory again and a long time, while a few
+// hundred thousand values pick representatives about as well for most
+// distributions. The reservoir should hold several times as many values as
+// the mode has exact codes, e.g. 256Ki for Word mode, or else codes split
+// columns with many distinct values less evenly. Sampling is deterministic:
+// the same sample always yields the same dictionary. For columns that don't
+// fit in memory in the first place, feed their values to a Reservoir.
+func WithReservoirSize(n int) DictOption {
+	return func(c *dictConfig) { c.reservoir, c.reservoirSize = true, n }
+}
+
+// Reservoir draws a sample of up to a fixed number of values uniformly at
+// random from a stream of values added one at a time, for building a
+// dictionary over a column that is read in pieces, without holding more
+// than the sample in memory. It samples as WithReservoirSize does, so
+// adding the values of a slice in order samples them alike:
+//
+//	r := colsketch.NewReservoir[int64](1 << 18)
+//	for rows.Next() {
+//		r.Add(rows.Value())
+//	}
+//	dict := colsketch.NewDict(colsketch.Word, r.Sample())
+//
+// A Reservoir isn't safe for concurrent use.
+type Reservoir[T cmp.Ordered] struct {
+	values []T
+	size   int
+	added  int64
+	rng    *rand.Rand
+}
+
+// NewReservoir returns an empty Reservoir of up to n values. It panics if n
+// isn't positive.
+func NewReservoir[T cmp.Ordered](n int) *Reservoir[T] {
+	if n <= 0 {
+		panic(fmt.Sprintf("colsketch: reservoir size %d isn't positive", n))
+	}
+	return newReservoir[T](n, rand.New(rand.NewSource(reservoirSeed)))
+}
+
+func newReservoir[T cmp.Ordered](n int, rng *rand.Rand) *Reservoir[T] {
+	return &Reservoir[T]{size: n, rng: rng}
+}
+
+// Add adds a value to the stream: it is kept if the reservoir isn't full,
+// and otherwise replaces a random one of the reservoir's values with
+// probability n over the number of values added so far, n being the
+// reservoir's size.
+func (r *Reservoir[T]) Add(value T) {
+	r.added++
+	if len(r.values) < r.size {
+		r.values = append(r.values, value)
+		return
+	}
+	if j := r.rng.Int63n(r.added); j < int64(r.size) {
+		r.values[j] = value
+	}
+}
+
+// Sample returns the values in the reservoir, in no particular order. The
+// slice is the reservoir's own, which later calls to Add modify.
+func (r *Reservoir[T]) Sample() []T {
+	return r.values
+}
+
+// Added returns the number of values added to the reservoir.
+func (r *Reservoir[T]) Added() int64 {
+	return r.added
+}
+
+// reservoirSample returns n values drawn uniformly at random from sample,
+// which holds more than n, in a single pass, as a Reservoir of n values
+// would.
+func reservoirSample[T cmp.Ordered](sample []T, n int, rng *rand.Rand) []T {
+	r := newReservoir[T](n, rng)
+	r.values = make([]T, 0, n)
+	for _, v := range sample {
+		r.Add(v)
+	}
+	return r.values
+}
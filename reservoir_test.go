@@ -0,0 +1,178 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestNewDictWithOptions(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sample := randomInt64s(rng, 10000, 1<<20)
+
+	if got, want := NewDictWithOptions(Byte, sample), NewDict(Byte, sample); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewDictWithOptions() without options differs from NewDict()")
+	}
+	if got, want := NewDictWithOptions(Byte, sample, WithReservoirSize(len(sample))), NewDict(Byte, sample); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewDictWithOptions() with a reservoir as large as the sample differs from NewDict()")
+	}
+
+	a := NewDictWithOptions(Word, sample, WithReservoirSize(1000))
+	b := NewDictWithOptions(Word, sample, WithReservoirSize(1000))
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("NewDictWithOptions() with a reservoir isn't deterministic")
+	}
+	n := 0
+	for _, k := range a.counts {
+		n += k
+	}
+	if n != 1000 {
+		t.Errorf("dictionary built from a reservoir of 1000 counts %d sample values", n)
+	}
+
+	for _, n := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewDictWithOptions() with a reservoir size of %d didn't panic", n)
+				}
+			}()
+			NewDictWithOptions(Byte, sample, WithReservoirSize(n))
+		}()
+	}
+}
+
+func TestReservoirSample(t *testing.T) {
+	const size, n, runs = 1000, 100, 2000
+
+	sample := make([]int, size)
+	for i := range sample {
+		sample[i] = i
+	}
+
+	// Every value is drawn with probability n/size.
+	rng := rand.New(rand.NewSource(1))
+	drawn := make([]int, size)
+	for i := 0; i < runs; i++ {
+		for _, v := range reservoirSample(sample, n, rng) {
+			drawn[v]++
+		}
+	}
+	for v, k := range drawn {
+		if want := runs * n / size; k < want*2/3 || k > want*4/3 {
+			t.Fatalf("value %d drawn %d times in %d runs, want about %d", v, k, runs, want)
+		}
+	}
+}
+
+func TestReservoir(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sample := randomInt64s(rng, 10000, 1<<20)
+
+	// Streaming a sample samples it as WithReservoirSize does.
+	r := NewReservoir[int64](1000)
+	for _, v := range sample {
+		r.Add(v)
+	}
+	if r.Added() != int64(len(sample)) || len(r.Sample()) != 1000 {
+		t.Fatalf("Reservoir of 1000 holds %d of %d values added", len(r.Sample()), r.Added())
+	}
+	if got, want := NewDict(Word, r.Sample()), NewDictWithOptions(Word, sample, WithReservoirSize(1000)); !reflect.DeepEqual(got, want) {
+		t.Errorf("dictionary over a Reservoir differs from one built WithReservoirSize()")
+	}
+
+	// A reservoir keeps every value of a stream that fits in it, in order.
+	r = NewReservoir[int64](len(sample) + 1)
+	for _, v := range sample {
+		r.Add(v)
+	}
+	if !reflect.DeepEqual(r.Sample(), sample) {
+		t.Errorf("Reservoir larger than the stream didn't keep it whole")
+	}
+
+	for _, n := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewReservoir(%d) didn't panic", n)
+				}
+			}()
+			NewReservoir[int64](n)
+		}()
+	}
+}
+
+// skewedInt64s returns n values of a mix of distributions: a heavy head of
+// exponentially distributed small values and a uniform tail.
+func skewedInt64s(rng *rand.Rand, n int) []int64 {
+	s := make([]int64, n)
+	for i := range s {
+		if rng.Intn(2) == 0 {
+			s[i] = int64(rng.ExpFloat64() * 1000)
+		} else {
+			s[i] = rng.Int63n(1 << 30)
+		}
+	}
+	return s
+}
+
+// codeCollisions returns the probability that two random values of a column
+// share a code, i.e. the expected fraction of the column an equality
+// predicate on one of its values selects as candidates, which is smaller the
+// more evenly a dictionary's codes partition the column.
+func codeCollisions(d *Dict[int64], column []int64) float64 {
+	counts := make(map[Code]int)
+	for _, v := range column {
+		counts[d.Encode(v)]++
+	}
+	var p float64
+	for _, k := range counts {
+		f := float64(k) / float64(len(column))
+		p += f * f
+	}
+	return p
+}
+
+func TestReservoirQuality(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, tc := range []struct {
+		name string
+		gen  func(n int) []int64
+	}{
+		{"uniform", func(n int) []int64 { return randomInt64s(rng, n, 100000) }},
+		{"skewed", func(n int) []int64 { return skewedInt64s(rng, n) }},
+	} {
+		sample, column := tc.gen(1<<20), tc.gen(1<<16)
+		for _, mode := range []Mode{Byte, Word} {
+			full := NewDict(mode, sample)
+			sampled := NewDictWithOptions(mode, sample, WithReservoirSize(1<<18))
+
+			f, s := codeCollisions(&full, column), codeCollisions(&sampled, column)
+			if s > 1.25*f {
+				t.Errorf("%s, mode %s: reservoir sampled dictionary has a collision rate of %.3g, full one %.3g", tc.name, mode, s, f)
+			}
+		}
+	}
+}
+
+func BenchmarkReservoirQuality(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	sample, column := skewedInt64s(rng, 1<<22), skewedInt64s(rng, 1<<16)
+
+	for _, size := range []int{0, 1 << 14, 1 << 16, 1 << 18} {
+		name, opts := "full", []DictOption(nil)
+		if size > 0 {
+			name, opts = fmt.Sprintf("reservoir=%d", size), []DictOption{WithReservoirSize(size)}
+		}
+
+		b.Run(name, func(b *testing.B) {
+			var d Dict[int64]
+			for i := 0; i < b.N; i++ {
+				d = NewDictWithOptions(Word, sample, opts...)
+			}
+			b.ReportMetric(codeCollisions(&d, column), "collisions/op")
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package colsketch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+)
+
+// WithRunLength stores codes run-length encoded, as runs of consecutive
+// rows with the same code, rather than a code per row. Columns sorted or
+// clustered by the sketched values have long runs, which take a fraction of
+// the memory of a code per row, and are scanned a run at a time: the
+// predicate is evaluated once per run, and ScanInto adds the rows of runs of
+// candidates as ranges. Columns with short runs are better stored flat,
+// since runs take 10 bytes each, and finding the run of a row, as Get does,
+// takes a binary search. See Compress to choose between them by the codes
+// of a sketch.
+//
+// Run-length encoding doesn't combine with WithBitPacking. BitsPerCode
+// reports the width of the codes of runs, 8 or 16 bits as in Byte and Word
+// mode storage, which WriteTo writes a code per row in: sketches read back
+// with ReadFrom are stored flat, and can be compressed again.
+func WithRunLength() SketchOption {
+	return func(c *sketchConfig) { c.runLength = true }
+}
+
+// Compress run-length encodes the sketch's codes, as if it had been built
+// with WithRunLength, if that takes less than half of the memory its
+// storage does, and returns whether it did. It takes a pass over the codes
+// to count their runs, and another to encode them.
+func (s *Sketch[T]) Compress() bool {
+	if _, ok := s.store.(*runStore); ok {
+		return false
+	}
+
+	n, runs := s.store.len(), 0
+	for i := 0; i < n; i++ {
+		if i == 0 || s.store.get(i) != s.store.get(i-1) {
+			runs++
+		}
+	}
+	if int64(runs)*runSize*2 >= s.store.footprint() {
+		return false
+	}
+
+	rs := newRunStore(s.dict.mode, s.store.maxCode())
+	rs.codes = make([]Code, 0, runs)
+	rs.ends = make([]int, 0, runs)
+	for i := 0; i < n; i++ {
+		rs.append(s.store.get(i))
+	}
+	s.store = rs
+	return true
+}
+
+// runSize is the number of bytes of memory a run takes.
+const runSize = int64(unsafe.Sizeof(Code(0)) + unsafe.Sizeof(0))
+
+// runStore is a codeStore holding runs of consecutive equal codes: run i
+// holds the code codes[i] for the rows `[ends[i-1], ends[i])`, with
+// ends[-1] standing for 0. Consecutive runs have different codes.
+type runStore struct {
+	codes []Code
+	ends  []int
+	max   Code
+	width int
+}
+
+// newRunStore returns an empty runStore for codes up to maxCode, of the
+// width of the mode's codes.
+func newRunStore(mode Mode, maxCode Code) *runStore {
+	return &runStore{max: maxCode, width: 8 * mode.codeWidth()}
+}
+
+// find returns the index of the run holding row i.
+func (s *runStore) find(i int) int {
+	return upperBound(s.ends, i)
+}
+
+// start returns the first row of run r.
+func (s *runStore) start(r int) int {
+	if r == 0 {
+		return 0
+	}
+	return s.ends[r-1]
+}
+
+func (s *runStore) append(c Code) {
+	if last := len(s.codes) - 1; last >= 0 && s.codes[last] == c {
+		s.ends[last]++
+		return
+	}
+	s.codes = append(s.codes, c)
+	s.ends = append(s.ends, s.len()+1)
+}
+
+func (s *runStore) appendAll(codes []Code) {
+	for _, c := range codes {
+		s.append(c)
+	}
+}
+
+// appendStore appends the runs of another runStore, offset by the rows of
+// this one, merging the first with the last run of this one if they have
+// the same code.
+func (s *runStore) appendStore(o codeStore) bool {
+	src, ok := o.(*runStore)
+	if !ok {
+		return false
+	}
+	codes, ends, n := src.codes, src.ends, s.len()
+	if len(codes) == 0 {
+		return true
+	}
+
+	if last := len(s.codes) - 1; last >= 0 && s.codes[last] == codes[0] {
+		s.ends[last] += ends[0]
+		codes = codes[1:]
+	} else {
+		s.codes = append(s.codes, codes[0])
+		s.ends = append(s.ends, n+ends[0])
+		codes = codes[1:]
+	}
+	s.codes = append(s.codes, codes...)
+	for _, end := range ends[1:] {
+		s.ends = append(s.ends, n+end)
+	}
+	return true
+}
+
+// reserve does nothing: how many runs codes will take isn't known ahead.
+func (s *runStore) reserve(n int) {}
+
+func (s *runStore) get(i int) Code {
+	if n := s.len(); i < 0 || i >= n {
+		panic(fmt.Sprintf("colsketch: index %d out of range [0:%d]", i, n))
+	}
+	return s.codes[s.find(i)]
+}
+
+// set splits the run holding row i into up to three, and merges them with
+// the runs on either side of it that have the same codes.
+func (s *runStore) set(i int, c Code) {
+	r := s.find(i)
+	if s.codes[r] == c {
+		return
+	}
+
+	// Rebuild the runs from r-1 to r+1 with row i in a run of its own.
+	first, last := r, r
+	if first > 0 {
+		first--
+	}
+	if last < len(s.codes)-1 {
+		last++
+	}
+
+	type run struct {
+		code Code
+		end  int
+	}
+	var runs [5]run
+	rebuilt := runs[:0]
+	push := func(code Code, end int) {
+		switch k := len(rebuilt) - 1; {
+		case end == s.start(first) || (k >= 0 && end == rebuilt[k].end):
+			// An empty run.
+		case k >= 0 && rebuilt[k].code == code:
+			rebuilt[k].end = end
+		default:
+			rebuilt = append(rebuilt, run{code, end})
+		}
+	}
+	for j := first; j <= last; j++ {
+		if j == r {
+			push(s.codes[r], i)
+			push(c, i+1)
+		}
+		push(s.codes[j], s.ends[j])
+	}
+
+	// Splice the rebuilt runs in place of the old ones.
+	k := len(rebuilt) - (last - first + 1)
+	if k > 0 {
+		s.codes = append(s.codes, make([]Code, k)...)
+		s.ends = append(s.ends, make([]int, k)...)
+	}
+	copy(s.codes[last+1+k:], s.codes[last+1:])
+	copy(s.ends[last+1+k:], s.ends[last+1:])
+	if k < 0 {
+		s.codes = s.codes[:len(s.codes)+k]
+		s.ends = s.ends[:len(s.ends)+k]
+	}
+	for j, run := range rebuilt {
+		s.codes[first+j], s.ends[first+j] = run.code, run.end
+	}
+}
+
+func (s *runStore) len() int {
+	if len(s.ends) == 0 {
+		return 0
+	}
+	return s.ends[len(s.ends)-1]
+}
+
+func (s *runStore) maxCode() Code    { return s.max }
+func (s *runStore) bitsPerCode() int { return s.width }
+
+func (s *runStore) footprint() int64 {
+	return int64(unsafe.Sizeof(*s)) + int64(cap(s.codes))*int64(unsafe.Sizeof(Code(0))) +
+		int64(cap(s.ends))*int64(unsafe.Sizeof(0))
+}
+
+// writePayload writes a code per row, like a codeSlice of the width.
+func (s *runStore) writePayload(w *chunkWriter) {
+	start := 0
+	for r, c := range s.codes {
+		for ; start < s.ends[r]; start++ {
+			if s.width == 8 {
+				w.buf = append(w.buf, byte(c))
+			} else {
+				w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(c))
+			}
+			w.flushIfFull()
+		}
+	}
+}
+
+// runs calls fn with the code and the rows `[from, to)` of the runs within
+// `[start, end)`, clipped to it.
+func (s *runStore) runs(start, end int, fn func(c Code, from, to int) bool) bool {
+	for r := s.find(start); r < len(s.codes) && s.start(r) < end; r++ {
+		from, to := s.start(r), s.ends[r]
+		if from < start {
+			from = start
+		}
+		if to > end {
+			to = end
+		}
+		if !fn(s.codes[r], from, to) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *runStore) countRanges(start, end int, lo, hi, dlo, dhi Code) (n, nd int) {
+	s.runs(start, end, func(c Code, from, to int) bool {
+		n += (to - from) * int(b2u(lo <= c && c <= hi))
+		nd += (to - from) * int(b2u(dlo <= c && c <= dhi))
+		return true
+	})
+	return n, nd
+}
+
+func (s *runStore) scan(start, end int, lo, hi Code, visit func(pos int) bool) bool {
+	return s.runs(start, end, func(c Code, from, to int) bool {
+		if c-lo > hi-lo {
+			return true
+		}
+		for pos := from; pos < to; pos++ {
+			if !visit(pos) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (s *runStore) rangeMask(start, n int, lo, hi Code) uint64 {
+	var m uint64
+	s.runs(start, start+n, func(c Code, from, to int) bool {
+		if c-lo <= hi-lo {
+			m |= windowMask(start, from, to)
+		}
+		return true
+	})
+	return m
+}
+
+func (s *runStore) setMask(start, n int, cs *CodeSet) uint64 {
+	var m uint64
+	s.runs(start, start+n, func(c Code, from, to int) bool {
+		if cs.Contains(c) {
+			m |= windowMask(start, from, to)
+		}
+		return true
+	})
+	return m
+}
+
+// ranges calls add with the rows `[from, to)` of each run within
+// `[start, end)` whose code passes the filter.
+func (s *runStore) ranges(start, end int, f codeFilter, add func(from, to int)) {
+	s.runs(start, end, func(c Code, from, to int) bool {
+		if f.contains(c) {
+			add(from, to)
+		}
+		return true
+	})
+}
@@ -0,0 +1,221 @@
+package colsketch
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// clusteredInt64s returns n values in runs of up to maxRun equal values
+// below max.
+func clusteredInt64s(rng *rand.Rand, n, maxRun int, max int64) []int64 {
+	values := make([]int64, 0, n)
+	for len(values) < n {
+		v, run := rng.Int63n(max), 1+rng.Intn(maxRun)
+		for i := 0; i < run && len(values) < n; i++ {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func TestSketchRunLength(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+
+		for _, blockSize := range []int{64, 256, DefaultBlockSize} {
+			values := clusteredInt64s(rng, 3*blockSize+rng.Intn(64), 100, 12000)
+			name := fmt.Sprintf("mode %s, block size %d", mode, blockSize)
+
+			flat := NewSketch(&dict, WithBlockSize(blockSize))
+			runs := NewSketch(&dict, WithBlockSize(blockSize), WithRunLength())
+			for i, v := range values {
+				// Mix single appends with bulk ones.
+				if i < 100 {
+					flat.Append(v)
+					runs.Append(v)
+				}
+			}
+			flat.AppendValues(values[100:])
+			runs.AppendValues(values[100:])
+
+			compressed := NewSketch(&dict, WithBlockSize(blockSize))
+			compressed.AppendValues(values)
+			if !compressed.Compress() {
+				t.Fatalf("%s: Compress() of clustered codes didn't compress", name)
+			}
+			if compressed.Compress() {
+				t.Fatalf("%s: Compress() of a compressed sketch compressed", name)
+			}
+			if got, want := compressed.MemoryFootprint(), flat.MemoryFootprint(); got >= want {
+				t.Errorf("%s: compressed MemoryFootprint() = %d, flat %d", name, got, want)
+			}
+
+			// Update rows, including ones at the ends of runs, to split and
+			// merge runs.
+			for i := 0; i < len(values)/10; i++ {
+				pos := rng.Intn(len(values))
+				v := values[rng.Intn(len(values))]
+				if i%2 == 0 && pos > 0 {
+					v = values[pos-1]
+				}
+				for _, s := range []*Sketch[int64]{flat, runs, compressed} {
+					s.Set(pos, v)
+				}
+			}
+
+			deleted := make([]bool, len(values))
+			for i := 0; i < len(values)/8; i++ {
+				pos := rng.Intn(len(values))
+				deleted[pos] = true
+				for _, s := range []*Sketch[int64]{flat, runs, compressed} {
+					s.Delete(pos)
+				}
+			}
+
+			for _, s := range []*Sketch[int64]{runs, compressed} {
+				testSameSketch(t, name, s, flat)
+				if s.BitsPerCode() != flat.BitsPerCode() {
+					t.Errorf("%s: BitsPerCode() = %d, want %d", name, s.BitsPerCode(), flat.BitsPerCode())
+				}
+
+				for i := 0; i < 50; i++ {
+					p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+					testDeletedScans(t, name, s, deleted, p)
+
+					var got, want rangeRecorder
+					s.ScanInto(p, 10, &got)
+					flat.ScanInto(p, 10, &want)
+					if !reflect.DeepEqual(got.rows(), want.rows()) {
+						t.Fatalf("%s, %+v: ScanInto() added %v, want %v", name, p, got.rows(), want.rows())
+					}
+				}
+
+				for i := 0; i < 5; i++ {
+					lo := rng.Intn(len(values) + 1)
+					hi := lo + rng.Intn(len(values)-lo+1)
+					testSketchSlice(t, fmt.Sprintf("%s, [%d:%d]", name, lo, hi), s.Slice(lo, hi), flat, rng)
+				}
+			}
+
+			// Runs are written a code per row, and read back flat.
+			var buf bytes.Buffer
+			if _, err := runs.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			read := NewSketch(&dict)
+			if _, err := read.ReadFrom(&buf); err != nil {
+				t.Fatalf("%s: ReadFrom() = %v", name, err)
+			}
+			testSameSketch(t, name+", read", read, flat)
+
+			// Append runs to runs, merging the runs at the seam, and flat
+			// codes to runs.
+			appended := NewSketch(&dict, WithBlockSize(blockSize), WithRunLength())
+			for _, other := range []*Sketch[int64]{runs, flat, runs} {
+				if err := appended.AppendSketch(other); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := NewSketch(&dict, WithBlockSize(blockSize))
+			for i := 0; i < 3; i++ {
+				if err := want.AppendSketch(flat); err != nil {
+					t.Fatal(err)
+				}
+			}
+			testSameSketch(t, name+", appended", appended, want)
+		}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("NewSketch() with bit packing and run-length encoding didn't panic")
+			}
+		}()
+		dict := NewDict(Byte, []int{1})
+		NewSketch(&dict, WithBitPacking(), WithRunLength())
+	}()
+
+	dict := NewDict(Byte, randomInt64s(rng, 1000, 100))
+	s := NewSketch(&dict)
+	s.AppendValues(randomInt64s(rng, 10000, 100))
+	if s.Compress() {
+		t.Errorf("Compress() of random codes compressed")
+	}
+}
+
+// rangeRecorder is a RangeAdder recording the rows added to it.
+type rangeRecorder struct {
+	ranges [][2]uint64
+}
+
+func (r *rangeRecorder) Add(x uint32) { r.AddRange(uint64(x), uint64(x)+1) }
+
+func (r *rangeRecorder) AddRange(start, end uint64) {
+	r.ranges = append(r.ranges, [2]uint64{start, end})
+}
+
+// rows returns the rows added, in order.
+func (r *rangeRecorder) rows() []uint64 {
+	var rows []uint64
+	for _, rg := range r.ranges {
+		for x := rg[0]; x < rg[1]; x++ {
+			rows = append(rows, x)
+		}
+	}
+	return rows
+}
+
+// BenchmarkSketchRunLength compares flat and run-length encoded storage of
+// sorted and clustered codes. Blocks of sorted codes mostly have headers
+// that rule them in or out, so the difference is in the blocks at the
+// boundaries of the range, while the blocks of clustered codes span many
+// values, so flat scans compare every code.
+func BenchmarkSketchRunLength(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 22
+
+	dict := NewDict(Byte, randomInt64s(rng, 100000, 1000))
+	sorted := randomInt64s(rng, n, 1000)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	for _, data := range []struct {
+		name   string
+		values []int64
+	}{
+		{"sorted", sorted},
+		{"clustered", clusteredInt64s(rng, n, 2000, 1000)},
+	} {
+		for _, storage := range []struct {
+			name string
+			opts []SketchOption
+		}{
+			{"flat", nil},
+			{"runs", []SketchOption{WithRunLength()}},
+		} {
+			s := NewSketch(&dict, storage.opts...)
+			s.AppendValues(data.values)
+			p := Between[int64](250, 500)
+
+			b.Run(fmt.Sprintf("%s/%s/Count", data.name, storage.name), func(b *testing.B) {
+				b.ReportMetric(float64(s.MemoryFootprint())/n, "bytes/row")
+				for i := 0; i < b.N; i++ {
+					sink, _ = s.Count(p)
+				}
+			})
+			b.Run(fmt.Sprintf("%s/%s/ScanInto", data.name, storage.name), func(b *testing.B) {
+				var r rangeRecorder
+				for i := 0; i < b.N; i++ {
+					r.ranges = r.ranges[:0]
+					s.ScanInto(p, 0, &r)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package colsketch
+
+import "math/bits"
+
+// Scan calls visit with the position of every row of the sketch whose code
+// may stand for a value satisfying the predicate, in increasing order, until
+// visit returns false. The predicate is compiled against the dictionary
+// once, into the range of codes that may match, and blocks whose codes all
+// fall outside of it are skipped by their headers. Predicates that compile
+// to a single code, such as Eq, visit the positions of the sketch's inverted
+// index instead, if it holds one; see BuildCodeIndex.
+//
+// Positions are candidates, not matches: a row whose code is exact, e.g.
+// under Eq on a representative, matches iff its code does, but an inexact
+// code stands for a range of values, only some of which may satisfy the
+// predicate, so its rows must be checked against the values they stand for,
+// e.g. with Predicate.Matches. Rows whose values satisfy the predicate are
+// always visited, unless they were deleted.
+func (s *Sketch[T]) Scan(p Predicate[T], visit func(pos int) bool) {
+	s.scanRows(0, s.store.len(), p, visit)
+}
+
+// ScanLimit is like Scan, but stops after visiting k rows, the first k
+// candidates by position, e.g. for LIMIT queries: like Scan, it skips the
+// blocks its headers rule out until then, and reads no codes after the k-th
+// candidate. It visits no row if k <= 0.
+func (s *Sketch[T]) ScanLimit(p Predicate[T], k int, visit func(pos int) bool) {
+	if k <= 0 {
+		return
+	}
+	s.scanRows(0, s.store.len(), p, func(pos int) bool {
+		k--
+		return visit(pos) && k > 0
+	})
+}
+
+// Any returns true iff the sketch has a candidate row of the predicate, i.e.
+// iff Scan would visit any row, e.g. for EXISTS queries. It returns as soon
+// as it finds one.
+func (s *Sketch[T]) Any(p Predicate[T]) bool {
+	found := false
+	s.scanRows(0, s.store.len(), p, func(int) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// scanRows is Scan over the rows `[from, to)`.
+func (s *Sketch[T]) scanRows(from, to int, p Predicate[T], visit func(pos int) bool) {
+	f := p.candidates(s.dict)
+	if f.empty() {
+		return
+	}
+	if positions, ok := s.indexed(f); ok {
+		for _, pos := range indexedRows(positions, from, to) {
+			if !visit(pos) {
+				return
+			}
+		}
+		return
+	}
+
+	live := func(pos int) bool {
+		return s.IsDeleted(pos) || visit(pos)
+	}
+
+	for i := from / s.blockSize; i*s.blockSize < to; i++ {
+		if s.rulesOut(i, f) || s.isDead(i) {
+			continue
+		}
+
+		start, end := s.blockRows(i, from, to)
+		if f.except {
+			// Mask out the excluded code a word of codes at a time, as
+			// ScanCertain compares them.
+			blockEnd := s.blockEnd(i)
+			for pos := start &^ 63; pos < end; pos += 64 {
+				k := blockEnd - pos
+				if k > 64 {
+					k = 64
+				}
+				m := f.mask(s.store, pos, k) &^ s.deletedWord(pos/64) & windowMask(pos, start, end)
+				for ; m != 0; m &= m - 1 {
+					if !visit(pos + bits.TrailingZeros64(m)) {
+						return
+					}
+				}
+			}
+			continue
+		}
+
+		v := visit
+		if s.blockDeletions(i) > 0 {
+			v = live
+		}
+		if !s.store.scan(start, end, f.lo, f.hi, v) {
+			return
+		}
+	}
+}
+
+// Certainty tells whether a candidate row of a scan matches its predicate.
+type Certainty uint8
+
+const (
+	// Candidate rows may match, and must be checked against the values they
+	// stand for: their codes are inexact and stand for values on either
+	// side of an operand of the predicate.
+	Candidate Certainty = iota
+
+	// Definite rows match without checking: their codes are exact, or only
+	// stand for values the predicate holds for.
+	Definite
+)
+
+// String returns the name of the certainty.
+func (c Certainty) String() string {
+	if c == Definite {
+		return "Definite"
+	}
+	return "Candidate"
+}
+
+// ScanCertain is like Scan, but also tells visit whether each row certainly
+// matches, so that only the Candidate rows need to be checked against the
+// values they stand for. Blocks whose codes all certainly match are
+// classified by their headers alone.
+func (s *Sketch[T]) ScanCertain(p Predicate[T], visit func(pos int, c Certainty) bool) {
+	s.scanCertainRows(0, s.store.len(), p, visit)
+}
+
+// scanCertainRows is ScanCertain over the rows `[from, to)`.
+func (s *Sketch[T]) scanCertainRows(from, to int, p Predicate[T], visit func(pos int, c Certainty) bool) {
+	f := p.candidates(s.dict)
+	if f.empty() {
+		return
+	}
+	df := p.definite(s.dict)
+	if positions, ok := s.indexed(f); ok {
+		c := Certainty(b2u(df.contains(f.lo)))
+		for _, pos := range indexedRows(positions, from, to) {
+			if !visit(pos, c) {
+				return
+			}
+		}
+		return
+	}
+
+	for i := from / s.blockSize; i*s.blockSize < to; i++ {
+		b := s.bounds[i]
+		if s.rulesOut(i, f) || s.isDead(i) {
+			continue
+		}
+
+		start, end := s.blockRows(i, from, to)
+		if df.rulesIn(b) {
+			for pos := start; pos < end; pos++ {
+				if !s.IsDeleted(pos) && !visit(pos, Definite) {
+					return
+				}
+			}
+			continue
+		}
+
+		// Compare whole words of codes from the word boundary at or before
+		// start, which the bit-packed storage needs, masking out the rows
+		// out of range.
+		blockEnd := s.blockEnd(i)
+		for pos := start &^ 63; pos < end; pos += 64 {
+			k := blockEnd - pos
+			if k > 64 {
+				k = 64
+			}
+
+			m := f.mask(s.store, pos, k) &^ s.deletedWord(pos/64) & windowMask(pos, start, end)
+			var definite uint64
+			if !df.empty() {
+				definite = df.mask(s.store, pos, k)
+			}
+			for ; m != 0; m &= m - 1 {
+				j := bits.TrailingZeros64(m)
+				if !visit(pos+j, Certainty(definite>>uint(j)&1)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// windowMask returns the mask of the bits of a word of the rows
+// `[pos, pos+64)` that stand for rows within `[start, end)`.
+func windowMask(pos, start, end int) uint64 {
+	m := ^uint64(0)
+	if start > pos {
+		m <<= uint(start - pos)
+	}
+	if end < pos+64 {
+		m &= ^uint64(0) >> uint(pos+64-end)
+	}
+	return m
+}
+
+// b2u converts a bool to 0 or 1 without branching.
+func b2u(b bool) uint8 {
+	var u uint8
+	if b {
+		u = 1
+	}
+	return u
+}
@@ -0,0 +1,343 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSketchScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]SketchOption{
+		nil,
+		{WithBlockSize(64)},
+		{WithBitPacking(), WithBlockSize(128)},
+	} {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+			s := NewSketch(&dict, opts...)
+
+			// Sorted runs make blocks with narrow bounds, for scans to skip.
+			values := randomInt64s(rng, 20000, 12000)
+			for i := 0; i < len(values); i += 2000 {
+				run := values[i : i+1000]
+				sort.Slice(run, func(i, j int) bool { return run[i] < run[j] })
+			}
+			for _, v := range values {
+				s.Append(v)
+			}
+
+			for i := 0; i < 200; i++ {
+				p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+				testScan(t, s, values, p)
+			}
+		}
+	}
+}
+
+func testScan(t *testing.T, s *Sketch[int64], values []int64, p Predicate[int64]) {
+	t.Helper()
+
+	var got []int
+	s.Scan(p, func(pos int) bool {
+		got = append(got, pos)
+		return true
+	})
+
+	var want []int
+	for i, v := range values {
+		iv, _ := s.Dict().Bounds(s.Get(i))
+		if mayMatch(iv, p) {
+			want = append(want, i)
+		} else if p.Matches(v) {
+			t.Fatalf("%+v: row %d with %d matches, but its code %d can't", p, i, v, s.Get(i))
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("%+v: Scan() visited %d rows, want %d", p, len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%+v: Scan() visited row %d, want %d", p, got[i], want[i])
+		}
+	}
+
+	// Eq on a representative visits exactly the rows equal to it.
+	if p.op == opEq && s.Dict().Encode(p.lo).IsExact() {
+		for _, pos := range got {
+			if values[pos] != p.lo {
+				t.Fatalf("%+v: Scan() visited row %d with %d", p, pos, values[pos])
+			}
+		}
+	}
+
+	// Scans stop as soon as visit returns false.
+	if len(want) > 1 {
+		stop := len(want) / 2
+		var n int
+		s.Scan(p, func(pos int) bool {
+			n++
+			return pos != want[stop]
+		})
+		if n != stop+1 {
+			t.Fatalf("%+v: Scan() visited %d rows after stopping at the %d-th", p, n, stop+1)
+		}
+	}
+}
+
+func TestSketchScanCertain(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]SketchOption{
+		{WithBlockSize(64)},
+		{WithBitPacking(), WithBlockSize(128)},
+	} {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+			s := NewSketch(&dict, opts...)
+
+			values := randomInt64s(rng, 10000, 12000)
+			sort.Slice(values[:5000], func(i, j int) bool { return values[i] < values[j] })
+			s.AppendCodes(dict.EncodeAll(values, nil))
+
+			for i := 0; i < 200; i++ {
+				p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+
+				var want []int
+				s.Scan(p, func(pos int) bool {
+					want = append(want, pos)
+					return true
+				})
+
+				var got []int
+				s.ScanCertain(p, func(pos int, c Certainty) bool {
+					got = append(got, pos)
+					iv, _ := dict.Bounds(s.Get(pos))
+					switch {
+					case c == Definite && !p.Matches(values[pos]):
+						t.Fatalf("%+v: row %d with %d doesn't match, but is definite", p, pos, values[pos])
+					case c == Candidate && iv.Exact:
+						t.Fatalf("%+v: row %d with exact code %d is only a candidate", p, pos, s.Get(pos))
+					case c != Definite && mustMatch(iv, p):
+						t.Fatalf("%+v: row %d with code %d is %s, want %s", p, pos, s.Get(pos), c, Definite)
+					}
+					return true
+				})
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Fatalf("%+v: ScanCertain() visited %v, want %v", p, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSketchScanNeq(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 5000, 3000))
+		for _, opts := range [][]SketchOption{nil, {WithBlockSize(64), WithBitPacking()}, {WithRunLength()}} {
+			// Clustered values, so that some blocks hold a single code.
+			values := clusteredInt64s(rng, 20000, 200, 3002)
+			s := NewSketch(&dict, opts...)
+			s.AppendValues(values)
+
+			for i := 0; i < 100; i++ {
+				v := rng.Int63n(3004) - 2
+				p := Neq(v)
+
+				// Rows are visited iff they may differ from v, and are
+				// definite only if they do.
+				visited := make([]bool, len(values))
+				definite := 0
+				s.ScanCertain(p, func(pos int, c Certainty) bool {
+					visited[pos] = true
+					if c == Definite && values[pos] == v {
+						t.Fatalf("Neq(%d): row %d with %d is definite", v, pos, values[pos])
+					}
+					definite += int(c)
+					return true
+				})
+				candidates := 0
+				for pos, ok := range visited {
+					switch {
+					case values[pos] != v && !ok:
+						t.Fatalf("Neq(%d): row %d with %d wasn't visited", v, pos, values[pos])
+					case !ok && s.Get(pos) != dict.Encode(v):
+						t.Fatalf("Neq(%d): row %d with code %d wasn't visited", v, pos, s.Get(pos))
+					case ok:
+						candidates++
+					}
+				}
+				if exact := dict.Encode(v).IsExact(); exact && candidates != definite {
+					t.Fatalf("Neq(%d): %d candidates, %d definite, want all definite", v, candidates, definite)
+				}
+				if c, d := s.Count(p); c != candidates || d != definite {
+					t.Fatalf("Neq(%d): Count() = %d, %d, want %d, %d", v, c, d, candidates, definite)
+				}
+			}
+		}
+	}
+}
+
+func TestSketchScanLimit(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]SketchOption{
+		nil,
+		{WithBlockSize(64)},
+		{WithBitPacking(), WithBlockSize(128)},
+		{WithRunLength(), WithBlockSize(64)},
+	} {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+			s := NewSketch(&dict, opts...)
+			values := randomInt64s(rng, 10000, 12000)
+			sort.Slice(values[:5000], func(i, j int) bool { return values[i] < values[j] })
+			s.AppendValues(values)
+			for i := 0; i < 500; i++ {
+				s.Delete(rng.Intn(s.Len()))
+			}
+
+			for i := 0; i < 200; i++ {
+				p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+
+				var all []int
+				s.Scan(p, func(pos int) bool {
+					all = append(all, pos)
+					return true
+				})
+				if got := s.Any(p); got != (len(all) > 0) {
+					t.Fatalf("%+v: Any() = %v, want %v", p, got, len(all) > 0)
+				}
+
+				// ScanLimit visits the first k candidates by position, rows
+				// in the middle of words of codes included.
+				for _, k := range []int{-1, 0, 1, 2, 63, 65, len(all) / 2, len(all), len(all) + 1} {
+					var got []int
+					s.ScanLimit(p, k, func(pos int) bool {
+						got = append(got, pos)
+						return true
+					})
+					want := all
+					switch {
+					case k < 0:
+						want = nil
+					case k < len(want):
+						want = want[:k]
+					}
+					if len(got) != len(want) || (len(want) > 0 && fmt.Sprint(got) != fmt.Sprint(want)) {
+						t.Fatalf("%+v: ScanLimit(%d) visited %v, want %v", p, k, got, want)
+					}
+				}
+
+				// It also stops when visit returns false.
+				if len(all) > 2 {
+					n := 0
+					s.ScanLimit(p, len(all), func(pos int) bool {
+						n++
+						return n < 2
+					})
+					if n != 2 {
+						t.Fatalf("%+v: ScanLimit() visited %d rows after visit returned false at the 2nd", p, n)
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkSketchScanLimit(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 100000, 1<<30))
+	s := NewSketch(&dict)
+	values := randomInt64s(rng, 1<<22, 1<<30)
+	s.AppendCodes(dict.EncodeAll(values, nil))
+
+	// A selective predicate, whose candidates, the rows of an inexact code,
+	// about one in 256, start among the first rows.
+	p := Eq(values[100])
+	b.Run("Scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			n := 0
+			s.Scan(p, func(int) bool {
+				n++
+				return true
+			})
+			codeSink = Code(n)
+		}
+	})
+	b.Run("ScanLimit", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			n := 0
+			s.ScanLimit(p, 10, func(int) bool {
+				n++
+				return true
+			})
+			codeSink = Code(n)
+		}
+	})
+	b.Run("Any", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			codeSink = Code(b2u(s.Any(p)))
+		}
+	})
+}
+
+func BenchmarkSketchScan(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 100000, 1<<30))
+	s := NewSketch(&dict)
+	s.AppendCodes(dict.EncodeAll(randomInt64s(rng, 1<<20, 1<<30), nil))
+
+	p := Between(int64(1<<28), int64(1<<28+1<<24))
+	b.SetBytes(int64(s.Len()))
+	for i := 0; i < b.N; i++ {
+		n := 0
+		s.Scan(p, func(int) bool {
+			n++
+			return true
+		})
+		codeSink = Code(n)
+	}
+}
+
+func BenchmarkSketchScanRange(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	values := randomInt64s(rng, 1<<20, 1<<30)
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 100000, 1<<30))
+		s := NewSketch(&dict)
+		s.AppendCodes(dict.EncodeAll(values, nil))
+
+		// Inexact operands, whose codes are candidates at the boundaries.
+		lo, hi := int64(1<<29+1), int64(1<<29+1<<26+1)
+		for _, bc := range []struct {
+			name string
+			p    Predicate[int64]
+		}{
+			{"Lt", Lt(lo)},
+			{"Le", Le(lo)},
+			{"Gt", Gt(hi)},
+			{"Ge", Ge(hi)},
+			{"Between", Between(lo, hi)},
+		} {
+			p := bc.p
+			b.Run(fmt.Sprintf("%s/%s", mode, bc.name), func(b *testing.B) {
+				b.SetBytes(int64(s.Len()))
+				for i := 0; i < b.N; i++ {
+					n := 0
+					s.Scan(p, func(int) bool {
+						n++
+						return true
+					})
+					codeSink = Code(n)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package colsketch
+
+import (
+	"cmp"
+	"encoding/binary"
+	"math/bits"
+	"unsafe"
+)
+
+// The searches below are hand-rolled rather than built on sort.Search so
+// that they make no indirect calls and so that the data-dependent update of
+// base is computed arithmetically from the comparison's outcome rather than
+// branched on: each iteration halves n regardless of that outcome, leaving
+// nothing for the branch predictor to mispredict.
+
+// lowerBound returns the position of the first element of the sorted slice s
+// that is greater than or equal to value, or len(s) if there is none.
+func lowerBound[T cmp.Ordered](s []T, value T) int {
+	if len(s) == 0 {
+		return 0
+	}
+
+	base, n := 0, len(s)
+	for n > 1 {
+		half := n >> 1
+		base += half & -int(b2u(cmp.Less(s[base+half-1], value)))
+		n -= half
+	}
+
+	if cmp.Less(s[base], value) {
+		base++
+	}
+	return base
+}
+
+// encodeSorted returns the code of value in a dictionary whose sorted
+// representatives are s. It searches for the last representative less than
+// or equal to value rather than the first one greater than or equal to it,
+// so a single final comparison tells both where value falls and whether it
+// is exactly coded, which matters when comparisons are costly, e.g. on
+// strings.
+func encodeSorted[T cmp.Ordered](s []T, value T) Code {
+	if ss, ok := any(s).([]string); ok {
+		return encodeStrings(ss, any(value).(string))
+	}
+
+	if len(s) == 0 {
+		return 1
+	}
+
+	base, n := 0, len(s)
+	for n > 1 {
+		half := n >> 1
+		base += half & -int(b2u(!cmp.Less(value, s[base+half])))
+		n -= half
+	}
+
+	return finishEncode(s[base], base, value)
+}
+
+// maxSmall is the largest number of representatives encodeSmall unrolls
+// the search of, which is as many as a Byte mode dictionary holds.
+const maxSmall = 127
+
+// encodeSmall is encodeSorted unrolled for at most maxSmall
+// representatives, as in Knuth's uniform binary search: a first comparison
+// at len(s)-k, for the largest power of two k <= len(s), leaves k
+// candidates starting at base, which halving steps of constant sizes then
+// narrow to one, in at most 7 comparisons and without a loop. Strings are
+// encoded by encodeStrings, like with encodeSorted.
+func encodeSmall[T cmp.Ordered](d *Dict[T], value T) Code {
+	s := d.codes
+	if ss, ok := any(s).([]string); ok {
+		return encodeStrings(ss, any(value).(string))
+	}
+	if len(s) == 0 || len(s) > maxSmall {
+		return encodeSorted(s, value)
+	}
+
+	k := 1 << (bits.Len(uint(len(s))) - 1)
+	base := (len(s) - k) & -int(b2u(!cmp.Less(value, s[len(s)-k])))
+	s = s[base : base+k]
+
+	i := 0
+	switch k {
+	case 64:
+		i += 32 & -int(b2u(!cmp.Less(value, s[i+32])))
+		fallthrough
+	case 32:
+		i += 16 & -int(b2u(!cmp.Less(value, s[i+16])))
+		fallthrough
+	case 16:
+		i += 8 & -int(b2u(!cmp.Less(value, s[i+8])))
+		fallthrough
+	case 8:
+		i += 4 & -int(b2u(!cmp.Less(value, s[i+4])))
+		fallthrough
+	case 4:
+		i += 2 & -int(b2u(!cmp.Less(value, s[i+2])))
+		fallthrough
+	case 2:
+		i += 1 & -int(b2u(!cmp.Less(value, s[i+1])))
+	}
+
+	return finishEncode(d.codes[base+i], base+i, value)
+}
+
+// encodeSortedUnchecked is encodeSorted without bounds checks: it reads the
+// representatives through pointer arithmetic on the start of s instead of
+// indexing it. That is safe because the search never leaves s: every
+// position it reads is less than base+n, which never exceeds len(s). Strings
+// are encoded by encodeStrings, whose skipping of common prefixes saves
+// more than bounds checks cost.
+func encodeSortedUnchecked[T cmp.Ordered](s []T, value T) Code {
+	if ss, ok := any(s).([]string); ok {
+		return encodeStrings(ss, any(value).(string))
+	}
+
+	if len(s) == 0 {
+		return 1
+	}
+
+	p, size := unsafe.Pointer(unsafe.SliceData(s)), unsafe.Sizeof(s[0])
+	base, n := 0, len(s)
+	for n > 1 {
+		half := n >> 1
+		v := *(*T)(unsafe.Add(p, uintptr(base+half)*size))
+		base += half & -int(b2u(!cmp.Less(value, v)))
+		n -= half
+	}
+
+	return finishEncode(*(*T)(unsafe.Add(p, uintptr(base)*size)), base, value)
+}
+
+// finishEncode returns the code of value given the position base at which a
+// search for the last representative less than or equal to value ended, and
+// the representative rep at that position.
+func finishEncode[T cmp.Ordered](rep T, base int, value T) Code {
+	// base only advanced past representatives less than or equal to value,
+	// so rep is the last such representative unless even the first one is
+	// greater.
+	switch c := cmp.Compare(rep, value); {
+	case c == 0:
+		return Code(2 * (base + 1))
+	case c < 0:
+		return Code(2*(base+1) + 1)
+	default:
+		return 1
+	}
+}
+
+// encodeStrings is encodeSorted for strings. Sorted strings such as URLs and
+// file paths often share long prefixes, which every comparison of a plain
+// binary search would scan again. Instead, it tracks how many leading bytes
+// value shares with the representatives bounding the search on either side:
+// every representative between them shares at least the smaller of the two,
+// so comparisons start past it and only look at the bytes that differ.
+func encodeStrings(s []string, value string) Code {
+	// s[lo] <= value < s[hi], where s[-1] and s[len(s)] stand for values
+	// below and above all others.
+	lo, hi := -1, len(s)
+	lcpLo, lcpHi := 0, 0
+
+	for hi-lo > 1 {
+		mid := int(uint(lo+hi) >> 1)
+
+		k := lcpLo
+		if lcpHi < k {
+			k = lcpHi
+		}
+
+		c, lcp := compareFrom(s[mid], value, k)
+		switch {
+		case c == 0:
+			return Code(2 * (mid + 1))
+		case c < 0:
+			lo, lcpLo = mid, lcp
+		default:
+			hi, lcpHi = mid, lcp
+		}
+	}
+
+	return Code(2*(lo+1) + 1)
+}
+
+// compareFrom compares a and b like strings.Compare, given that their first
+// k bytes are equal, and also returns the length of their common prefix. It
+// compares eight bytes at a time to keep up with the runtime's vectorized
+// string comparison.
+func compareFrom(a, b string, k int) (int, int) {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+
+	ab := unsafe.Slice(unsafe.StringData(a), len(a))
+	bb := unsafe.Slice(unsafe.StringData(b), len(b))
+
+	for ; k+8 <= n; k += 8 {
+		if x := binary.LittleEndian.Uint64(ab[k:]) ^ binary.LittleEndian.Uint64(bb[k:]); x != 0 {
+			k += bits.TrailingZeros64(x) >> 3
+			return cmp.Compare(ab[k], bb[k]), k
+		}
+	}
+
+	for ; k < n; k++ {
+		if ab[k] != bb[k] {
+			return cmp.Compare(ab[k], bb[k]), k
+		}
+	}
+
+	return cmp.Compare(len(a), len(b)), k
+}
+
+// interleaveLanes is the number of searches encodeInterleaved runs in
+// lock-step.
+const interleaveLanes = 16
+
+// encodeInterleaved is encodeSorted over each of values, storing codes in
+// dst, which must be at least as long as values. It runs interleaveLanes
+// searches in lock-step: since every search over s takes the same number of
+// steps, the probes of all lanes at one level are independent of each other
+// and their cache misses overlap instead of being paid one after another.
+// The lanes' state is a fixed-size array on the stack, so no scratch space
+// needs to be allocated or pooled.
+func encodeInterleaved[T cmp.Ordered](s []T, values []T, dst []Code) {
+	if len(s) == 0 {
+		for i := range values {
+			dst[i] = 1
+		}
+		return
+	}
+
+	i := 0
+	for ; i+interleaveLanes <= len(values); i += interleaveLanes {
+		keys := values[i : i+interleaveLanes : i+interleaveLanes]
+		out := dst[i : i+interleaveLanes : i+interleaveLanes]
+
+		var base [interleaveLanes]int
+		for n := len(s); n > 1; {
+			half := n >> 1
+			for j := range base {
+				base[j] += half & -int(b2u(!cmp.Less(keys[j], s[base[j]+half])))
+			}
+			n -= half
+		}
+
+		for j := range base {
+			out[j] = finishEncode(s[base[j]], base[j], keys[j])
+		}
+	}
+
+	for ; i < len(values); i++ {
+		dst[i] = encodeSorted(s, values[i])
+	}
+}
+
+// upperBound returns the position of the first element of the sorted slice s
+// that is greater than value, or len(s) if there is none.
+func upperBound[T cmp.Ordered](s []T, value T) int {
+	if len(s) == 0 {
+		return 0
+	}
+
+	base, n := 0, len(s)
+	for n > 1 {
+		half := n >> 1
+		base += half & -int(b2u(!cmp.Less(value, s[base+half])))
+		n -= half
+	}
+
+	if !cmp.Less(value, s[base]) {
+		base++
+	}
+	return base
+}
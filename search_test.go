@@ -0,0 +1,199 @@
+package colsketch
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// referenceEncode is the original sort.Search based implementation of
+// Encode, which the hand-rolled searches must agree with.
+func referenceEncode[T cmp.Ordered](s []T, value T) Code {
+	idx := sort.Search(len(s), func(i int) bool {
+		return cmp.Compare(s[i], value) >= 0
+	})
+
+	code := Code(2 * (idx + 1))
+	if idx >= len(s) || cmp.Compare(s[idx], value) != 0 {
+		code--
+	}
+	return code
+}
+
+func testSearch[T cmp.Ordered](t *testing.T, s []T, probes []T) {
+	t.Helper()
+	for _, v := range probes {
+		want := sort.Search(len(s), func(i int) bool { return cmp.Compare(s[i], v) >= 0 })
+		if got := lowerBound(s, v); got != want {
+			t.Fatalf("%d elements: lowerBound(%v) = %d, want %d", len(s), v, got, want)
+		}
+		if got, want := encodeSorted(s, v), referenceEncode(s, v); got != want {
+			t.Fatalf("%d elements: encodeSorted(%v) = %d, want %d", len(s), v, got, want)
+		}
+		if len(s) <= maxSmall {
+			if got, want := encodeSmall(&Dict[T]{mode: Byte, codes: s}, v), referenceEncode(s, v); got != want {
+				t.Fatalf("%d elements: encodeSmall(%v) = %d, want %d", len(s), v, got, want)
+			}
+		}
+		// Encode picks a search by the number of representatives, whatever
+		// the mode.
+		if got, want := (&Dict[T]{mode: Word, codes: s}).Encode(v), referenceEncode(s, v); got != want {
+			t.Fatalf("%d elements: Encode(%v) = %d, want %d", len(s), v, got, want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		n := rng.Intn(300)
+		if i%50 == 0 {
+			n = rng.Intn(32768)
+		}
+
+		ints := NewDict(Word, randomInt64s(rng, 2*n, int64(4*n+1)))
+		testSearch(t, ints.codes, randomInt64s(rng, 200, int64(4*n+2)))
+
+		floats := make([]float64, n)
+		for j := range floats {
+			floats[j] = rng.NormFloat64()
+		}
+		floats = append(floats, math.NaN(), math.Inf(1), math.Inf(-1))
+		fdict := NewDict(Word, floats)
+		testSearch(t, fdict.codes, append([]float64{math.NaN(), 0, math.Inf(-1), math.MaxFloat64}, floats...))
+
+		strs := NewDict(Word, randomStrings(rng, n, 2*n+1))
+		testSearch(t, strs.codes, randomStrings(rng, 200, 2*n+2))
+	}
+
+	testSearch(t, []int{}, []int{-1, 0, 1})
+
+	// Every length up to that of a full Byte mode dictionary.
+	for n := 1; n <= 127; n++ {
+		s := make([]int, n)
+		probes := []int{-1}
+		for i := range s {
+			s[i] = 2 * i
+			probes = append(probes, 2*i, 2*i+1)
+		}
+		testSearch(t, s, probes)
+	}
+}
+
+func TestEncodeStrings(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, n := range []int{1, 2, 100, 5000} {
+		urls := NewDict(Word, randomURLs(rng, n))
+		testSearch(t, urls.codes, randomURLs(rng, 500))
+		testSearch(t, urls.codes, urls.codes)
+	}
+
+	// Strings that are prefixes of each other, differ only past a multiple
+	// of eight bytes or hold bytes that sort differently when signed.
+	s := []string{"", "a", "aaaaaaa", "aaaaaaaa", "aaaaaaaab", "aaaaaaaab\xff", "aaaaaaab", "b", "\x80"}
+	testSearch(t, s, append(s, "\x00", "aa", "aaaaaaaaa", "aaaaaaaab\x00", "aaaaaaac", "\xff"))
+}
+
+func TestUpperBound(t *testing.T) {
+	s := []int{1, 3, 3, 5}
+	for v, want := range map[int]int{0: 0, 1: 1, 2: 1, 3: 3, 4: 3, 5: 4, 6: 4} {
+		if got := upperBound(s, v); got != want {
+			t.Errorf("upperBound(%d) = %d, want %d", v, got, want)
+		}
+	}
+	if got := upperBound([]int{}, 1); got != 0 {
+		t.Errorf("upperBound() on empty slice = %d", got)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 16
+
+	for _, mode := range []Mode{Byte, Word} {
+		ints := NewDict(mode, randomInt64s(rng, n, 1<<40))
+		intProbes := randomInt64s(rng, 1024, 1<<40)
+		b.Run(fmt.Sprintf("int64/%s", mode), func(b *testing.B) {
+			benchmarkEncode(b, &ints, intProbes)
+		})
+
+		floatSample := make([]float64, n)
+		for i := range floatSample {
+			floatSample[i] = rng.NormFloat64()
+		}
+		floats := NewDict(mode, floatSample)
+		floatProbes := make([]float64, 1024)
+		for i := range floatProbes {
+			floatProbes[i] = rng.NormFloat64()
+		}
+		b.Run(fmt.Sprintf("float64/%s", mode), func(b *testing.B) {
+			benchmarkEncode(b, &floats, floatProbes)
+		})
+
+		strs := NewDict(mode, randomStrings(rng, n, n))
+		strProbes := randomStrings(rng, 1024, n)
+		b.Run(fmt.Sprintf("string/%s", mode), func(b *testing.B) {
+			benchmarkEncode(b, &strs, strProbes)
+		})
+
+		urls := NewDict(mode, randomURLs(rng, n))
+		urlProbes := randomURLs(rng, 1024)
+		b.Run(fmt.Sprintf("url/%s", mode), func(b *testing.B) {
+			benchmarkEncode(b, &urls, urlProbes)
+		})
+	}
+}
+
+// BenchmarkEncodeSmall compares the unrolled search of Byte mode
+// dictionaries with the looping one of encodeSorted.
+func BenchmarkEncodeSmall(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, n := range []int64{15, 63, 127} {
+		d := NewDict(Byte, randomInt64s(rng, 1<<16, n))
+		probes := randomInt64s(rng, 1024, 2*n)
+
+		b.Run(fmt.Sprintf("n=%d/unrolled", d.Len()), func(b *testing.B) {
+			var sum Code
+			for i := 0; i < b.N; i++ {
+				sum += encodeSmall(&d, probes[i%len(probes)])
+			}
+			codeSink = sum
+		})
+		b.Run(fmt.Sprintf("n=%d/loop", d.Len()), func(b *testing.B) {
+			var sum Code
+			for i := 0; i < b.N; i++ {
+				sum += encodeSorted(d.codes, probes[i%len(probes)])
+			}
+			codeSink = sum
+		})
+	}
+}
+
+// randomURLs returns n URLs from a handful of hosts with deep, mostly shared
+// paths, so that sorted neighbours have long common prefixes.
+func randomURLs(rng *rand.Rand, n int) []string {
+	hosts := []string{"https://www.example.com", "https://static.example.com", "https://api.example.org"}
+	dirs := []string{"/assets/images/products/thumbnails", "/v2/customers/accounts/billing/invoices", "/docs/reference/latest/packages"}
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("%s%s/%04d/%06d.html", hosts[rng.Intn(len(hosts))], dirs[rng.Intn(len(dirs))], rng.Intn(100), rng.Intn(1000000))
+	}
+	return urls
+}
+
+func benchmarkEncode[T cmp.Ordered](b *testing.B, d *Dict[T], probes []T) {
+	b.ReportAllocs()
+	var sum Code
+	for i := 0; i < b.N; i++ {
+		sum += d.Encode(probes[i%len(probes)])
+	}
+	codeSink = sum
+}
+
+var codeSink Code
@@ -0,0 +1,70 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// ScanSelection evaluates a predicate like Scan over the rows
+// `[batchStart, batchStart+batchLen)` of the sketch, and returns the
+// candidates among them as a selection vector: their offsets from
+// batchStart, in increasing order. The batch is clipped to the end of the
+// sketch, so that the last batch of a loop over fixed-size batches may be
+// partial. The vector is stored in sel, which doesn't allocate if it has
+// capacity for batchLen offsets. It panics if batchStart is out of range or
+// batchLen is negative.
+//
+// It is meant to be called once per batch of a vectorized execution engine,
+// e.g. of 1024 or 2048 rows, and so does little work beyond the scan itself:
+// it compiles the predicate once per call, compares a word of codes at a
+// time, and skips the groups of 64 codes of blocks the predicate rules out.
+func (s *Sketch[T]) ScanSelection(p Predicate[T], batchStart, batchLen int, sel []uint32) []uint32 {
+	n := s.store.len()
+	if batchStart < 0 || batchStart > n || batchLen < 0 {
+		panic(fmt.Sprintf("colsketch: batch of %d rows at %d out of range [0:%d]", batchLen, batchStart, n))
+	}
+	end := batchStart + batchLen
+	if end > n || end < batchStart {
+		end = n
+	}
+
+	sel = sel[:0]
+	f := p.candidates(s.dict)
+	if f.empty() {
+		return sel
+	}
+
+	for g := batchStart &^ 63; g < end; g += 64 {
+		k := n - g
+		if k > 64 {
+			k = 64
+		}
+
+		i := g / s.blockSize
+		b := s.bounds[i]
+
+		var m uint64
+		switch {
+		case s.rulesOut(i, f) || s.isDead(i):
+			continue
+		case f.rulesIn(b):
+			m = ^uint64(0)
+		default:
+			m = f.mask(s.store, g, k)
+		}
+		m &^= s.deletedWord(g / 64)
+
+		// Drop the rows of the group outside of the batch.
+		if g < batchStart {
+			m &= ^uint64(0) << uint(batchStart-g)
+		}
+		if g+64 > end {
+			m &= ^uint64(0) >> uint(g+64-end)
+		}
+
+		for ; m != 0; m &= m - 1 {
+			sel = append(sel, uint32(g+bits.TrailingZeros64(m)-batchStart))
+		}
+	}
+	return sel
+}
@@ -0,0 +1,113 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestSketchScanSelection(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]SketchOption{
+		nil,
+		{WithBlockSize(64)},
+		{WithBitPacking(), WithBlockSize(128)},
+	} {
+		for _, mode := range []Mode{Byte, Word} {
+			dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+			s := NewSketch(&dict, opts...)
+			s.AppendCodes(dict.EncodeAll(randomInt64s(rng, 10000+rng.Intn(64), 12000), nil))
+
+			sel := make([]uint32, 0, 2048)
+			for i := 0; i < 100; i++ {
+				p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+
+				// Batches of all sizes and alignments, the last one partial.
+				first := rng.Intn(2)
+				size := []int{1, 63, 1024, 2048, 1 + rng.Intn(3000)}[rng.Intn(5)]
+
+				var want []int
+				s.Scan(p, func(pos int) bool {
+					if pos >= first {
+						want = append(want, pos)
+					}
+					return true
+				})
+
+				var got []int
+				for start := first; start < s.Len(); start += size {
+					sel = s.ScanSelection(p, start, size, sel)
+					for j, off := range sel {
+						if int(off) >= size || j > 0 && off <= sel[j-1] {
+							t.Fatalf("%+v: batch at %d of %d rows has offsets %v", p, start, size, sel)
+						}
+						got = append(got, start+int(off))
+					}
+				}
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Fatalf("%+v: ScanSelection() in batches of %d = %v, want %v", p, size, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSketchScanSelectionBounds(t *testing.T) {
+	dict := NewDict(Byte, []int{1, 2, 3})
+	s := NewSketch(&dict)
+	s.AppendCodes([]Code{2, 4, 6, 2})
+
+	if got := s.ScanSelection(Eq(1), 4, 1024, nil); len(got) != 0 {
+		t.Errorf("ScanSelection() past the last row = %v", got)
+	}
+	if got := s.ScanSelection(Eq(1), 1, 0, nil); len(got) != 0 {
+		t.Errorf("ScanSelection() of an empty batch = %v", got)
+	}
+	if got := s.ScanSelection(Eq(1), 1, 1024, nil); len(got) != 1 || got[0] != 2 {
+		t.Errorf("ScanSelection() of a partial batch = %v, want [2]", got)
+	}
+
+	for _, tc := range [][2]int{{-1, 1}, {5, 1}, {0, -1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("ScanSelection() of %d rows at %d didn't panic", tc[1], tc[0])
+				}
+			}()
+			s.ScanSelection(Eq(1), tc[0], tc[1], nil)
+		}()
+	}
+
+	sel := make([]uint32, 0, 4)
+	if n := testing.AllocsPerRun(10, func() { sel = s.ScanSelection(Le(3), 0, 4, sel) }); n != 0 {
+		t.Errorf("ScanSelection() into a vector with capacity allocated %v times", n)
+	}
+}
+
+func BenchmarkSketchScanSelection(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 100000, 1<<30))
+	s := NewSketch(&dict)
+	s.AppendCodes(dict.EncodeAll(randomInt64s(rng, 1<<20, 1<<30), nil))
+
+	for _, tc := range []struct {
+		name string
+		p    Predicate[int64]
+	}{
+		{"low", Between(int64(1<<28), int64(1<<28+1<<22))},
+		{"high", Lt(int64(1 << 29 * 3 / 2))},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			const batch = 1024
+			sel := make([]uint32, 0, batch)
+			rows := 0
+			for i := 0; i < b.N; i++ {
+				start := i * batch % s.Len()
+				sel = s.ScanSelection(tc.p, start, batch, sel)
+				rows += batch
+			}
+			b.ReportMetric(float64(rows)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
@@ -0,0 +1,354 @@
+package colsketch
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// The serialized format of a Sketch is:
+//
+//	magic     [4]byte   "CSKS"
+//	version   uint8     sketchFormatVersion
+//	mode      uint8     Byte or Word
+//	bits      uint8     bits per code: 8 or 16, or fewer if bit-packed
+//	flags     uint8     sketchFlagDeleted if tombstones follow the codes,
+//	                    sketchFlagNulls if codes may be NullCode, and
+//	                    sketchFlagChecksums if block checksums follow them
+//	rows      uint64    number of rows
+//	blockSize uint64    number of rows per block
+//	dict      uint64    Fingerprint of the sketch's dictionary
+//	reserved  [32]byte  zeros
+//	codes     []byte    the codes, padded with zeros to a multiple of 64 bytes
+//	deleted   []uint64  the tombstones, a bit per row, padded likewise, if flagged
+//	checksums []uint64  the checksum of each block, padded likewise, if flagged
+//
+// Codes are packed back to back in bits bits each, least significant bits
+// first, into little-endian 64-bit words, i.e. as bytes for 8 bits and as
+// little-endian uint16s for 16. The tombstones of deleted rows are
+// little-endian words, bit i%64 of word i/64 standing for row i. The header
+// and the padding keep the codes and what follows them at offsets that are
+// multiples of 64 bytes, so that a sketch in memory may be read in place
+// from a cache line aligned buffer; see OpenSketch. Block headers aren't
+// stored, but recomputed from the codes when reading; see WithChecksums for
+// the checksums.
+const (
+	sketchMagic         = "CSKS"
+	sketchFormatVersion = 1
+	sketchHeaderSize    = 64
+
+	sketchFlagDeleted   = 1
+	sketchFlagNulls     = 2
+	sketchFlagChecksums = 4
+)
+
+// WriteTo writes the sketch to w in its serialized format, which stores the
+// codes and the tombstones of deleted rows, but not the dictionary: that is
+// identified by its fingerprint, to be written separately, e.g. with
+// Dict.MarshalBinary. It implements io.WriterTo.
+func (s *Sketch[T]) WriteTo(w io.Writer) (int64, error) {
+	rows := s.store.len()
+	width := s.store.bitsPerCode()
+
+	cw := &chunkWriter{w: w, buf: make([]byte, 0, chunkSize)}
+	hdr := append(cw.buf, sketchMagic...)
+	hdr = append(hdr, sketchFormatVersion, byte(s.dict.mode), byte(width), 0)
+	if s.numDeleted > 0 {
+		hdr[7] |= sketchFlagDeleted
+	}
+	if s.nulls {
+		hdr[7] |= sketchFlagNulls
+	}
+	if s.checksums != nil {
+		hdr[7] |= sketchFlagChecksums
+	}
+	hdr = binary.LittleEndian.AppendUint64(hdr, uint64(rows))
+	hdr = binary.LittleEndian.AppendUint64(hdr, uint64(s.blockSize))
+	hdr = binary.LittleEndian.AppendUint64(hdr, s.dict.Fingerprint())
+	cw.buf = append(hdr, make([]byte, sketchHeaderSize-len(hdr))...)
+
+	s.store.writePayload(cw)
+	cw.pad()
+
+	if s.numDeleted > 0 {
+		for i := 0; i < (rows+63)/64; i++ {
+			cw.buf = binary.LittleEndian.AppendUint64(cw.buf, s.deletedWord(i))
+			cw.flushIfFull()
+		}
+		cw.pad()
+	}
+
+	if s.checksums != nil {
+		buf := make([]byte, 2*s.blockSize)
+		for i := range s.bounds {
+			cw.buf = binary.LittleEndian.AppendUint64(cw.buf, s.checksum(i, buf))
+			cw.flushIfFull()
+		}
+		cw.pad()
+	}
+	cw.flush()
+	return cw.n, cw.err
+}
+
+// ReadFrom replaces the contents of the sketch, i.e. its codes, block size,
+// storage and deletions, with a sketch read from r as written by WriteTo,
+// which must have been encoded with a dictionary of the same fingerprint as
+// the sketch's. The masks of a sketch built WithPresenceMasks are recomputed
+// from the codes read. The sketch keeps checksums if it was built
+// WithChecksums or the serialized sketch has them, in which case the codes
+// read are verified against them, and a *ChecksumError is returned for the
+// first block that doesn't match. The sketch is left unmodified if an error
+// is returned. It implements io.ReaderFrom.
+func (s *Sketch[T]) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	read, err := s.readFrom(cr)
+	if err != nil {
+		return cr.n, err
+	}
+	*s = *read
+	return cr.n, nil
+}
+
+func (s *Sketch[T]) readFrom(r io.Reader) (*Sketch[T], error) {
+	var buf [sketchHeaderSize]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return nil, noEOF(err)
+	}
+	hdr, err := parseSketchHeader(buf[:], s.dict)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := readN(r, hdr.codesSize())
+	if err != nil {
+		return nil, err
+	}
+	words := make([]uint64, len(payload)/8)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint64(payload[8*i:])
+	}
+	if !zeroBeyond(words, uint64(hdr.rows)*uint64(hdr.width)) {
+		return nil, errors.New("colsketch: invalid sketch code padding")
+	}
+
+	read := &Sketch[T]{
+		dict:      s.dict,
+		store:     newCodeStore(s.dict.mode, hdr.maxCode, hdr.packed),
+		blockSize: hdr.blockSize,
+		nulls:     hdr.nulls,
+	}
+	if s.presence != nil {
+		read.presence = []codeMask{}
+	}
+	if s.checksums != nil || hdr.checksums {
+		read.checksums = []uint64{}
+	}
+	read.store.reserve(hdr.rows)
+
+	var codes [1024]Code
+	mask := uint64(1)<<hdr.width - 1
+	for i := 0; i < hdr.rows; {
+		batch := codes[:0]
+		for ; i < hdr.rows && len(batch) < len(codes); i++ {
+			c := unpack(words, uint(i)*hdr.width, hdr.width, mask)
+			if (c == NullCode && !hdr.nulls) || c > hdr.maxCode {
+				return nil, fmt.Errorf("colsketch: invalid code %s at row %d of sketch", c, i)
+			}
+			batch = append(batch, c)
+		}
+		read.appendCodes(batch)
+	}
+
+	if hdr.deleted {
+		data, err := readN(r, hdr.deletedSize())
+		if err != nil {
+			return nil, err
+		}
+		deleted := make([]uint64, len(data)/8)
+		for i := range deleted {
+			deleted[i] = binary.LittleEndian.Uint64(data[8*i:])
+		}
+		if !zeroBeyond(deleted, uint64(hdr.rows)) {
+			return nil, errors.New("colsketch: tombstones of rows beyond the sketch")
+		}
+
+		for i, w := range deleted {
+			for ; w != 0; w &= w - 1 {
+				read.Delete(64*i + bits.TrailingZeros64(w))
+			}
+		}
+	}
+
+	if hdr.checksums {
+		data, err := readN(r, hdr.checksumsSize())
+		if err != nil {
+			return nil, err
+		}
+		sums := make([]uint64, len(data)/8)
+		for i := range sums {
+			sums[i] = binary.LittleEndian.Uint64(data[8*i:])
+		}
+		if !zeroBeyond(sums, 64*uint64(len(read.bounds))) {
+			return nil, errors.New("colsketch: checksums of blocks beyond the sketch")
+		}
+		if err := read.verifyAgainst(sums[:len(read.bounds)]); err != nil {
+			return nil, err
+		}
+	}
+	return read, nil
+}
+
+// sketchHeader is the validated header of a serialized sketch.
+type sketchHeader struct {
+	rows, blockSize int
+	width           uint // The number of bits per code.
+	packed          bool // Whether codes are in fewer bits than a byte or two.
+	deleted         bool // Whether tombstones follow the codes.
+	nulls           bool // Whether codes may be NullCode.
+	checksums       bool // Whether block checksums follow the tombstones.
+	maxCode         Code // The largest code of the dictionary.
+}
+
+// parseSketchHeader validates the header of a sketch serialized with a
+// dictionary of the same fingerprint as dict.
+func parseSketchHeader[T cmp.Ordered](b []byte, dict *Dict[T]) (sketchHeader, error) {
+	mode, width, flags := Mode(b[5]), uint(b[6]), b[7]
+	rows := binary.LittleEndian.Uint64(b[8:])
+	blockSize := binary.LittleEndian.Uint64(b[16:])
+	maxCode := Code(2*len(dict.codes) + 1)
+	unpacked := uint(8 * mode.codeWidth())
+
+	switch {
+	case string(b[:4]) != sketchMagic:
+		return sketchHeader{}, errors.New("colsketch: not a serialized sketch")
+	case b[4] != sketchFormatVersion:
+		return sketchHeader{}, fmt.Errorf("colsketch: unsupported sketch format version %d", b[4])
+	case mode != dict.mode:
+		return sketchHeader{}, fmt.Errorf("colsketch: %s mode sketch for a %s mode dictionary", mode, dict.mode)
+	case width != unpacked && width != newPackedStore(maxCode).bits:
+		return sketchHeader{}, fmt.Errorf("colsketch: invalid sketch code width of %d bits", width)
+	case flags&^(sketchFlagDeleted|sketchFlagNulls|sketchFlagChecksums) != 0:
+		return sketchHeader{}, fmt.Errorf("colsketch: invalid sketch flags %#x", flags)
+	case rows > math.MaxInt32*64:
+		return sketchHeader{}, fmt.Errorf("colsketch: invalid sketch length %d", rows)
+	case blockSize == 0 || blockSize%64 != 0 || blockSize > math.MaxInt32:
+		return sketchHeader{}, fmt.Errorf("colsketch: invalid sketch block size %d", blockSize)
+	case binary.LittleEndian.Uint64(b[24:]) != dict.Fingerprint():
+		return sketchHeader{}, errors.New("colsketch: sketch encoded with a different dictionary")
+	}
+	for _, c := range b[32:sketchHeaderSize] {
+		if c != 0 {
+			return sketchHeader{}, errors.New("colsketch: invalid reserved sketch header bytes")
+		}
+	}
+
+	return sketchHeader{
+		rows:      int(rows),
+		blockSize: int(blockSize),
+		width:     width,
+		packed:    width != unpacked,
+		deleted:   flags&sketchFlagDeleted != 0,
+		nulls:     flags&sketchFlagNulls != 0,
+		checksums: flags&sketchFlagChecksums != 0,
+		maxCode:   maxCode,
+	}, nil
+}
+
+// codesSize returns the padded size in bytes of the codes.
+func (h sketchHeader) codesSize() uint64 {
+	return paddedSize(uint64(h.rows) * uint64(h.width))
+}
+
+// deletedSize returns the padded size in bytes of the tombstones, if any.
+func (h sketchHeader) deletedSize() uint64 {
+	if !h.deleted {
+		return 0
+	}
+	return paddedSize(uint64(h.rows))
+}
+
+// checksumsSize returns the padded size in bytes of the block checksums,
+// if any.
+func (h sketchHeader) checksumsSize() uint64 {
+	if !h.checksums {
+		return 0
+	}
+	blocks := (uint64(h.rows) + uint64(h.blockSize) - 1) / uint64(h.blockSize)
+	return paddedSize(64 * blocks)
+}
+
+// paddedSize returns the size in bytes of n bits padded to a multiple of 64
+// bytes.
+func paddedSize(n uint64) uint64 {
+	return (n + 511) / 512 * 64
+}
+
+// zeroBeyond returns true iff the bits of words from the n-th on are zero.
+func zeroBeyond(words []uint64, n uint64) bool {
+	tail := words[n/64:]
+	if r := n % 64; r != 0 {
+		if tail[0]>>r != 0 {
+			return false
+		}
+		tail = tail[1:]
+	}
+	for _, w := range tail {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// chunkSize is the size of the chunks chunkWriter writes.
+const chunkSize = 8 << 10
+
+// chunkWriter buffers writes into chunks, keeping count of the bytes
+// written and the first error, after which it stops writing.
+type chunkWriter struct {
+	w   io.Writer
+	buf []byte
+	n   int64
+	err error
+}
+
+// flushIfFull writes the buffer if it may not have room for another word.
+func (w *chunkWriter) flushIfFull() {
+	if len(w.buf) > chunkSize-8 {
+		w.flush()
+	}
+}
+
+// pad pads what was written with zeros to a multiple of 64 bytes.
+func (w *chunkWriter) pad() {
+	for n := w.n + int64(len(w.buf)); n%64 != 0; n++ {
+		w.buf = append(w.buf, 0)
+	}
+	w.flushIfFull()
+}
+
+// flush writes the buffer.
+func (w *chunkWriter) flush() {
+	if w.err == nil && len(w.buf) > 0 {
+		var n int
+		n, w.err = w.w.Write(w.buf)
+		w.n += int64(n)
+	}
+	w.buf = w.buf[:0]
+}
+
+// countingReader counts the bytes read from a reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,178 @@
+package colsketch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func TestSketchWriteTo(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+
+		for _, opts := range [][]SketchOption{
+			nil,
+			{WithBlockSize(64)},
+			{WithBlockSize(128), WithBitPacking()},
+		} {
+			for _, n := range []int{0, 1, 63, 64, 1000} {
+				s := NewSketch(&dict, opts...)
+				name := fmt.Sprintf("mode %s, block size %d, %d bits, %d rows", mode, s.BlockSize(), s.BitsPerCode(), n)
+				s.AppendValues(randomInt64s(rng, n, 12000))
+				if n > 0 {
+					for i := 0; i < n/8+1; i++ {
+						s.Delete(rng.Intn(n))
+					}
+				}
+
+				var buf bytes.Buffer
+				written, err := s.WriteTo(&buf)
+				if err != nil || written != int64(buf.Len()) {
+					t.Fatalf("%s: WriteTo() = %d, %v with %d bytes written", name, written, err, buf.Len())
+				}
+				if buf.Len()%64 != 0 {
+					t.Errorf("%s: wrote %d bytes, not a multiple of 64", name, buf.Len())
+				}
+
+				got := NewSketch(&dict)
+				read, err := got.ReadFrom(bytes.NewReader(buf.Bytes()))
+				if err != nil || read != written {
+					t.Fatalf("%s: ReadFrom() = %d, %v, want %d, <nil>", name, read, err, written)
+				}
+				testSameSketch(t, name, got, s)
+			}
+		}
+	}
+}
+
+// testSameSketch checks that two sketches have the same rows, deletions and
+// blocks.
+func testSameSketch(t *testing.T, name string, got, want *Sketch[int64]) {
+	t.Helper()
+
+	if got.Len() != want.Len() || got.LiveLen() != want.LiveLen() || got.BlockSize() != want.BlockSize() {
+		t.Fatalf("%s: Len(), LiveLen(), BlockSize() = %d, %d, %d, want %d, %d, %d", name,
+			got.Len(), got.LiveLen(), got.BlockSize(), want.Len(), want.LiveLen(), want.BlockSize())
+	}
+	if got.BitsPerCode() != want.BitsPerCode() {
+		t.Errorf("%s: BitsPerCode() = %d, want %d", name, got.BitsPerCode(), want.BitsPerCode())
+	}
+	for i := 0; i < want.Len(); i++ {
+		if got.Get(i) != want.Get(i) || got.IsDeleted(i) != want.IsDeleted(i) {
+			t.Fatalf("%s: row %d is %d, deleted %v, want %d, deleted %v", name, i,
+				got.Get(i), got.IsDeleted(i), want.Get(i), want.IsDeleted(i))
+		}
+	}
+	for i := 0; i < want.NumBlocks(); i++ {
+		if g, w := got.Block(i), want.Block(i); g != w {
+			t.Fatalf("%s: Block(%d) = %+v, want %+v", name, i, g, w)
+		}
+	}
+}
+
+func TestSketchReadFromInvalid(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Byte, randomInt64s(rng, 1000, 100))
+	s := NewSketch(&dict, WithBlockSize(64))
+	s.AppendValues(randomInt64s(rng, 200, 120))
+	s.Delete(3)
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	other := NewDict(Byte, randomInt64s(rng, 1000, 1000))
+	for _, tc := range []struct {
+		name string
+		dict *Dict[int64]
+		edit func(b []byte)
+	}{
+		{"magic", &dict, func(b []byte) { b[0] = 'X' }},
+		{"version", &dict, func(b []byte) { b[4] = 2 }},
+		{"mode", &dict, func(b []byte) { b[5] = byte(Word) }},
+		{"code width", &dict, func(b []byte) { b[6] = 5 }},
+		{"flags", &dict, func(b []byte) { b[7] |= 8 }},
+		{"block size", &dict, func(b []byte) { binary.LittleEndian.PutUint64(b[16:], 100) }},
+		{"fingerprint", &dict, func(b []byte) { b[24]++ }},
+		{"reserved", &dict, func(b []byte) { b[63] = 1 }},
+		{"dictionary", &other, func(b []byte) {}},
+		{"code", &dict, func(b []byte) { b[sketchHeaderSize+10] = 0 }},
+		{"code padding", &dict, func(b []byte) { b[sketchHeaderSize+200] = 1 }},
+		{"tombstones", &dict, func(b []byte) { b[len(b)-1] = 0x80 }},
+	} {
+		b := append([]byte(nil), data...)
+		tc.edit(b)
+
+		got := NewSketch(tc.dict)
+		got.Append(1)
+		if _, err := got.ReadFrom(bytes.NewReader(b)); err == nil {
+			t.Errorf("%s: ReadFrom() of an invalid sketch succeeded", tc.name)
+		}
+		if got.Len() != 1 || got.Get(0) != tc.dict.Encode(1) {
+			t.Errorf("%s: failed ReadFrom() modified the sketch", tc.name)
+		}
+	}
+
+	for n := 0; n < len(data); n++ {
+		got := NewSketch(&dict)
+		if _, err := got.ReadFrom(bytes.NewReader(data[:n])); !errors.Is(err, errTruncated) {
+			t.Fatalf("ReadFrom() of %d of %d bytes = %v, want %v", n, len(data), err, errTruncated)
+		}
+	}
+}
+
+// TestSketchGolden checks that the serialized format doesn't change. Run
+// with -update to rewrite the golden file after a deliberate change.
+func TestSketchGolden(t *testing.T) {
+	values := make([]int64, 300)
+	for i := range values {
+		values[i] = int64(i * i % 97)
+	}
+	dict := NewDict(Word, values[:200])
+	s := NewSketch(&dict, WithBlockSize(128))
+	s.AppendValues(values)
+	for _, i := range []int{0, 7, 64, 129, 299} {
+		s.Delete(i)
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join("testdata", "sketch.golden")
+	if *update {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("WriteTo() wrote %d bytes differing from the %d of %s", buf.Len(), len(golden), path)
+	}
+
+	got := NewSketch(&dict)
+	if _, err := got.ReadFrom(bytes.NewReader(golden)); err != nil {
+		t.Fatal(err)
+	}
+	testSameSketch(t, "golden", got, s)
+	if !reflect.DeepEqual(got.ScanBitmap(Eq[int64](4), nil), s.ScanBitmap(Eq[int64](4), nil)) {
+		t.Errorf("golden sketch scans differently")
+	}
+}
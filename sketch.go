@@ -0,0 +1,673 @@
+package colsketch
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"unsafe"
+)
+
+// Sketch is a column sketch: a column of codes stored alongside the
+// dictionary that produced them, in a backing store as compact as the
+// dictionary's mode allows, i.e. one byte per code in Byte mode and two in
+// Word mode, or bit-packed with WithBitPacking. Codes are grouped into
+// fixed-size blocks, each summarized by a header; see Block. It is the basis
+// of scanning and serializing sketches.
+//
+// A Sketch may be read concurrently, but must not be read or mutated
+// concurrently with a mutation.
+type Sketch[T cmp.Ordered] struct {
+	dict  *Dict[T]
+	store codeStore
+
+	// The number of codes per block, and the bounds of the codes in each
+	// block, the last of which may be partially filled.
+	blockSize int
+	bounds    []blockBounds
+
+	// The tombstones of deleted rows, a bit per row, the number of deleted
+	// rows in each block, and in all; see Delete. The slices are nil until
+	// a row is deleted, and may be shorter than the sketch after rows are
+	// appended.
+	deleted      []uint64
+	blockDeleted []int
+	numDeleted   int
+
+	// Whether rows may be NULL, coded as NullCode; see WithNulls.
+	nulls bool
+
+	// The masks of the codes in each block, which is nil, rather than
+	// empty, unless the sketch was built WithPresenceMasks.
+	presence []codeMask
+
+	// The optional positions of the live rows holding each code, indexed by
+	// code. See BuildCodeIndex.
+	index [][]int
+
+	// The checksums of the blocks, which is nil, rather than empty, unless
+	// the sketch keeps them; see WithChecksums. A Sketch holds those of its
+	// blocks but the last, and a SketchView those of all of its blocks.
+	checksums []uint64
+}
+
+// A SketchOption configures a Sketch built by NewSketch.
+type SketchOption func(*sketchConfig)
+
+type sketchConfig struct {
+	bitPacked bool
+	runLength bool
+	nulls     bool
+	presence  bool
+	checksums bool
+	blockSize int
+}
+
+// WithBitPacking stores codes in as few bits as the dictionary's largest
+// code needs, rather than in a byte or two. A dictionary with 31
+// representatives, whose largest code is 63, needs 6 bits per code: 25% less
+// memory than Byte mode storage, at the cost of slower access and scans.
+func WithBitPacking() SketchOption {
+	return func(c *sketchConfig) { c.bitPacked = true }
+}
+
+// NewSketch returns an empty sketch encoding values with dict, which must
+// not be modified while the sketch is in use. It panics if given an invalid
+// option, e.g. a block size that isn't a positive multiple of 64.
+func NewSketch[T cmp.Ordered](dict *Dict[T], opts ...SketchOption) *Sketch[T] {
+	cfg := sketchConfig{blockSize: DefaultBlockSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.blockSize <= 0 || cfg.blockSize%64 != 0 {
+		panic(fmt.Sprintf("colsketch: block size %d isn't a positive multiple of 64", cfg.blockSize))
+	}
+	if cfg.bitPacked && cfg.runLength {
+		panic("colsketch: bit packing and run-length encoding don't combine")
+	}
+	if cfg.presence && dict.mode != Byte {
+		panic("colsketch: presence masks need a Byte mode dictionary")
+	}
+
+	maxCode := Code(2*len(dict.codes) + 1)
+	store := newCodeStore(dict.mode, maxCode, cfg.bitPacked)
+	if cfg.runLength {
+		store = newRunStore(dict.mode, maxCode)
+	}
+	s := &Sketch[T]{dict: dict, store: store, blockSize: cfg.blockSize, nulls: cfg.nulls}
+	if cfg.presence {
+		s.presence = []codeMask{}
+	}
+	if cfg.checksums {
+		s.checksums = []uint64{}
+	}
+	return s
+}
+
+// newCodeStore returns an empty store for the codes of a dictionary of the
+// given mode, up to maxCode.
+func newCodeStore(mode Mode, maxCode Code, bitPacked bool) codeStore {
+	switch {
+	case bitPacked:
+		return newPackedStore(maxCode)
+	case mode == Byte:
+		return new(codeSlice[uint8])
+	default:
+		return new(codeSlice[uint16])
+	}
+}
+
+// Dict returns the dictionary the sketch encodes values with.
+func (s *Sketch[T]) Dict() *Dict[T] {
+	return s.dict
+}
+
+// Append encodes a value and appends its code to the sketch.
+func (s *Sketch[T]) Append(value T) {
+	s.appendCode(s.dict.Encode(value))
+}
+
+// AppendValues encodes values and appends their codes to the sketch, like
+// a loop of Append but faster: the values are encoded in batches with
+// EncodeAll, the storage grows once for all of them, and the header of
+// each block they fill is updated once.
+func (s *Sketch[T]) AppendValues(values []T) {
+	s.store.reserve(len(values))
+
+	var buf [1024]Code
+	for len(values) > 0 {
+		batch := values
+		if len(batch) > len(buf) {
+			batch = batch[:len(buf)]
+		}
+		values = values[len(batch):]
+		s.appendCodes(s.dict.EncodeAll(batch, buf[:0]))
+	}
+}
+
+// AppendCodes appends already encoded codes to the sketch, like
+// AppendValues. It panics if a code isn't one the dictionary assigns, from
+// 1 up to 2*Len()+1, nor NullCode in a sketch built WithNulls, e.g. a Word
+// code in a Byte sketch or a code of another dictionary, in which case none
+// of the codes are appended.
+func (s *Sketch[T]) AppendCodes(codes []Code) {
+	limit := Code(2*len(s.dict.codes) + 1)
+	for _, c := range codes {
+		if (c == NullCode && !s.nulls) || c > limit {
+			panic(fmt.Sprintf("colsketch: code %s out of range for a dictionary assigning codes up to %s", c, limit))
+		}
+	}
+	s.appendCodes(codes)
+}
+
+// appendCodes appends codes known to fit the store, a block at a time, so
+// as to update the header of each block once.
+func (s *Sketch[T]) appendCodes(codes []Code) {
+	s.indexAppend(s.store.len(), codes)
+	for len(codes) > 0 {
+		n := s.store.len()
+		seg := codes
+		if k := s.blockSize - n%s.blockSize; len(seg) > k {
+			seg = seg[:k]
+		}
+		codes = codes[len(seg):]
+
+		b := blockBounds{seg[0], seg[0]}
+		for _, c := range seg[1:] {
+			b.add(c)
+		}
+		if n%s.blockSize == 0 {
+			s.bounds = append(s.bounds, b)
+		} else {
+			last := &s.bounds[len(s.bounds)-1]
+			last.add(b.min)
+			last.add(b.max)
+		}
+		if s.presence != nil {
+			m := maskOf(seg)
+			if n%s.blockSize == 0 {
+				s.presence = append(s.presence, m)
+			} else {
+				last := &s.presence[len(s.presence)-1]
+				for j := range last {
+					last[j] |= m[j]
+				}
+			}
+		}
+		s.store.appendAll(seg)
+	}
+	s.updateChecksums()
+}
+
+// appendCode appends a code to the store and accounts for it in the header
+// of the last block.
+func (s *Sketch[T]) appendCode(c Code) {
+	if s.store.len()%s.blockSize == 0 {
+		s.bounds = append(s.bounds, blockBounds{c, c})
+		s.updateChecksums()
+		if s.presence != nil {
+			s.presence = append(s.presence, codeMask{})
+		}
+	} else {
+		s.bounds[len(s.bounds)-1].add(c)
+	}
+	if s.presence != nil {
+		s.presence[len(s.presence)-1].add(c)
+	}
+	s.indexAppend(s.store.len(), []Code{c})
+	s.store.append(c)
+}
+
+// Get returns the code at position i. It panics if i is out of range.
+func (s *Sketch[T]) Get(i int) Code {
+	return s.store.get(i)
+}
+
+// Set encodes a value and overwrites the code at position i with its code,
+// e.g. to update a row in place rather than rebuild the sketch. A deleted
+// row stays deleted. It panics if i is out of range.
+//
+// The header of the block holding the row is kept exact, so that scans skip
+// as many blocks after updates as they would have had the rows been
+// appended with their new values: widening its bounds to the new code is
+// cheap, but narrowing them, when the old code was the block's smallest or
+// largest, takes rescanning the block's codes.
+func (s *Sketch[T]) Set(i int, value T) {
+	c := s.dict.Encode(value)
+	old := s.store.get(i)
+	if c == old {
+		return
+	}
+	s.store.set(i, c)
+
+	b := &s.bounds[i/s.blockSize]
+	if (old == b.min && c > old) || (old == b.max && c < old) {
+		s.rescanBlock(i / s.blockSize)
+	} else {
+		b.add(c)
+	}
+	if s.presence != nil {
+		s.presence[i/s.blockSize].add(c)
+	}
+	s.rehashBlock(i / s.blockSize)
+	if !s.IsDeleted(i) {
+		s.indexRemove(i, old)
+		s.indexInsert(i, c)
+	}
+}
+
+// rescanBlock recomputes the bounds of the i-th block from its codes, and
+// its mask, if the sketch keeps masks.
+func (s *Sketch[T]) rescanBlock(i int) {
+	start := i * s.blockSize
+	end := start + s.blockSize
+	if n := s.store.len(); end > n {
+		end = n
+	}
+
+	b := blockBounds{s.store.get(start), s.store.get(start)}
+	for j := start + 1; j < end; j++ {
+		b.add(s.store.get(j))
+	}
+	s.bounds[i] = b
+	if s.presence != nil {
+		s.presence[i] = s.blockMask(i, false)
+	}
+}
+
+// Len returns the number of codes in the sketch, including those of deleted
+// rows; see LiveLen.
+func (s *Sketch[T]) Len() int {
+	return s.store.len()
+}
+
+// CountRange returns the number of codes c of live rows in the sketch with
+// lo <= c <= hi, which, since codes preserve order, counts the rows whose
+// values may be within the range the codes stand for. It compares as many
+// codes at once as fit in a machine word, so the denser Byte mode storage
+// scans about twice as fast as Word mode storage.
+func (s *Sketch[T]) CountRange(lo, hi Code) int {
+	if lo > hi {
+		return 0
+	}
+	n, _ := s.store.countRanges(0, s.store.len(), lo, hi, 1, 0)
+	deleted, _ := s.countDeleted(0, s.store.len(), codeFilter{lo: lo, hi: hi}, emptyFilter)
+	return n - deleted
+}
+
+// BytesPerCode returns the number of bytes the sketch stores each code in:
+// 1 in Byte mode and 2 in Word mode. For bit-packed sketches it is rounded
+// up to a whole byte; see BitsPerCode.
+func (s *Sketch[T]) BytesPerCode() int {
+	return (s.store.bitsPerCode() + 7) / 8
+}
+
+// BitsPerCode returns the number of bits the sketch stores each code in.
+func (s *Sketch[T]) BitsPerCode() int {
+	return s.store.bitsPerCode()
+}
+
+// MemoryFootprint returns an estimate of the number of bytes of memory
+// retained by the sketch, excluding its dictionary, which it may share with
+// other sketches. See Dict.MemoryFootprint.
+func (s *Sketch[T]) MemoryFootprint() int64 {
+	return int64(unsafe.Sizeof(*s)) + s.store.footprint() +
+		int64(cap(s.bounds))*int64(unsafe.Sizeof(blockBounds{})) +
+		int64(cap(s.deleted))*8 + int64(cap(s.blockDeleted))*int64(unsafe.Sizeof(0)) +
+		int64(cap(s.presence))*int64(unsafe.Sizeof(codeMask{})) + s.indexFootprint() +
+		int64(cap(s.checksums))*8
+}
+
+// codeStore is the backing store of the codes of a Sketch.
+type codeStore interface {
+	// append appends a code, which is known to fit the store.
+	append(c Code)
+
+	// appendAll appends codes known to fit the store, growing it at most
+	// once.
+	appendAll(codes []Code)
+
+	// appendStore appends the codes of another store in bulk, returning
+	// false, without appending any, if it isn't of the same type and width.
+	appendStore(o codeStore) bool
+
+	// reserve grows the store, if needed, to have room for n more codes
+	// without growing again.
+	reserve(n int)
+
+	// get returns the code at position i, panicking if i is out of range.
+	get(i int) Code
+
+	// set overwrites the code at position i with a code known to fit the
+	// store, panicking if i is out of range.
+	set(i int, c Code)
+
+	// len returns the number of codes in the store.
+	len() int
+
+	// countRanges returns the numbers of codes c in `[start, end)` with
+	// lo <= c <= hi and with dlo <= c <= dhi, given that lo <= hi and that
+	// `[dlo, dhi]` is empty or is `[lo, hi]` less at most its first and
+	// last codes, as the definite codes of a predicate are.
+	countRanges(start, end int, lo, hi, dlo, dhi Code) (int, int)
+
+	// scan calls visit with the position of every code c in
+	// `[start, end)` with lo <= c <= hi, given that lo <= hi, until visit
+	// returns false, in which case it returns false too.
+	scan(start, end int, lo, hi Code, visit func(pos int) bool) bool
+
+	// rangeMask returns a word with bit j set iff lo <= c <= hi for the
+	// code c at position start+j, for j < n, given that start is a multiple
+	// of 64, that n is at most 64 and that lo <= hi.
+	rangeMask(start, n int, lo, hi Code) uint64
+
+	// setMask is like rangeMask, for the codes in cs rather than within a
+	// range.
+	setMask(start, n int, cs *CodeSet) uint64
+
+	// maxCode returns the largest code the store can hold.
+	maxCode() Code
+
+	// bitsPerCode returns the number of bits each code is stored in.
+	bitsPerCode() int
+
+	// footprint returns the number of bytes of memory the store retains.
+	footprint() int64
+
+	// writePayload writes the codes to w as the payload of the serialized
+	// format of sketches, without padding.
+	writePayload(w *chunkWriter)
+}
+
+// codeSlice is a codeStore holding each code in an element of type E, in a
+// cache line aligned backing array.
+type codeSlice[E uint8 | uint16] []E
+
+func (s *codeSlice[E]) append(c Code) {
+	if len(*s) == cap(*s) {
+		*s = growAligned(*s, 1)
+	}
+	*s = append(*s, E(c))
+}
+
+func (s *codeSlice[E]) reserve(n int) {
+	if cap(*s)-len(*s) < n {
+		*s = growAligned(*s, n)
+	}
+}
+
+func (s *codeSlice[E]) appendAll(codes []Code) {
+	s.reserve(len(codes))
+	for _, c := range codes {
+		*s = append(*s, E(c))
+	}
+}
+
+func (s *codeSlice[E]) appendStore(o codeStore) bool {
+	src, ok := o.(*codeSlice[E])
+	if !ok {
+		return false
+	}
+	codes := *src
+	s.reserve(len(codes))
+	*s = append(*s, codes...)
+	return true
+}
+
+func (s *codeSlice[E]) get(i int) Code    { return Code((*s)[i]) }
+func (s *codeSlice[E]) set(i int, c Code) { (*s)[i] = E(c) }
+func (s *codeSlice[E]) len() int          { return len(*s) }
+func (s *codeSlice[E]) maxCode() Code     { return Code(^E(0)) }
+func (s *codeSlice[E]) bitsPerCode() int  { return 8 * int(unsafe.Sizeof(E(0))) }
+
+func (s *codeSlice[E]) writePayload(w *chunkWriter) {
+	for _, c := range *s {
+		if unsafe.Sizeof(c) == 1 {
+			w.buf = append(w.buf, byte(c))
+		} else {
+			w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(c))
+		}
+		w.flushIfFull()
+	}
+}
+
+func (s *codeSlice[E]) footprint() int64 {
+	return int64(unsafe.Sizeof(*s)) + int64(cap(*s))*int64(unsafe.Sizeof(E(0)))
+}
+
+// countRanges compares codes with the range a word of them at a time, as
+// lanes of a uint64: subtracting lo from each lane maps the range onto
+// [0, hi-lo], and a lane is in range unless hi-lo minus it borrows. The
+// definite range only differs by the codes trimmed off its ends, which are
+// counted as the lanes that subtracting lo leaves at 0, or at hi-lo. Byte
+// codes are compared with a single code by countEqual instead.
+func (s *codeSlice[E]) countRanges(start, end int, lo, hi, dlo, dhi Code) (int, int) {
+	codes := (*s)[start:end]
+	maxCode := s.maxCode()
+	if lo > maxCode {
+		return 0, 0
+	}
+	if hi > maxCode {
+		hi, dhi = maxCode, maxCode
+	}
+	if lo == hi && unsafe.Sizeof(E(0)) == 1 {
+		// The definite range is within the candidate one, so it is either
+		// empty or the same single code.
+		n := countEqual(bytesOf(codes), uint8(lo))
+		if dlo > dhi {
+			return n, 0
+		}
+		return n, n
+	}
+	trimLo, trimHi := dlo > lo, dhi < hi
+
+	// Count codes one at a time up to the first word boundary, so that the
+	// rest can be loaded as aligned words.
+	i := 0
+	for i < len(codes) && uintptr(unsafe.Pointer(&codes[i]))%8 != 0 {
+		i++
+	}
+	n, trimmed := countTrimmed(codes[:i], lo, hi, trimLo, trimHi)
+
+	width := 8 * unsafe.Sizeof(E(0))
+	lanes := 64 / width
+	if words := (len(codes) - i) / int(lanes); words > 0 {
+		ones := ^uint64(0) / uint64(^E(0))
+		high := ones << (width - 1)
+		l, d := uint64(lo)*ones, uint64(hi-lo)*ones
+		tlo, thi := high*uint64(b2u(trimLo)), high*uint64(b2u(trimHi))
+
+		// Rather than popcounting every word, sum the lanes lane by lane,
+		// in batches short enough for no lane to overflow, and add up the
+		// lanes of each batch's sums by a multiplication after pairing them
+		// into lanes twice as wide.
+		pairs := ^uint64(0) / (uint64(^E(0))<<width | uint64(^E(0)))
+		wide := pairs * uint64(^E(0))
+
+		xs := unsafe.Slice((*uint64)(unsafe.Pointer(&codes[i])), words)
+		for len(xs) > 0 {
+			batch := xs
+			if len(batch) > int(^E(0)) {
+				batch = batch[:^E(0)]
+			}
+			xs = xs[len(batch):]
+
+			var out, ends uint64
+			if !trimLo && !trimHi {
+				for _, x := range batch {
+					y := laneSub(x, l, high)
+					out += (laneBorrow(d, y, laneSub(d, y, high)) & high) >> (width - 1)
+				}
+			} else {
+				for _, x := range batch {
+					y := laneSub(x, l, high)
+					diff := laneSub(d, y, high)
+					out += (laneBorrow(d, y, diff) & high) >> (width - 1)
+					ends += (laneZero(y, high)&tlo | laneZero(diff, high)&thi) >> (width - 1)
+				}
+			}
+			n += len(batch)*int(lanes) - laneSum(out, width, pairs, wide)
+			trimmed += laneSum(ends, width, pairs, wide)
+		}
+		i += words * int(lanes)
+	}
+
+	tn, tt := countTrimmed(codes[i:], lo, hi, trimLo, trimHi)
+	n, trimmed = n+tn, trimmed+tt
+
+	if dlo > dhi {
+		return n, 0
+	}
+	return n, n - trimmed
+}
+
+// countTrimmed counts the codes within `[lo, hi]`, and those of them
+// trimmed off its ends, one at a time.
+func countTrimmed[E uint8 | uint16](codes []E, lo, hi Code, trimLo, trimHi bool) (n, trimmed int) {
+	for _, c := range codes {
+		n += int(b2u(Code(c)-lo <= hi-lo))
+		trimmed += int(b2u(Code(c) == lo && trimLo || Code(c) == hi && trimHi))
+	}
+	return n, trimmed
+}
+
+// scan compares byte codes a word at a time, with scanEqual or scanRange,
+// and wider codes one at a time.
+func (s *codeSlice[E]) scan(start, end int, lo, hi Code, visit func(pos int) bool) bool {
+	if unsafe.Sizeof(E(0)) == 1 {
+		maxCode := s.maxCode()
+		if lo > maxCode {
+			return true
+		}
+		if hi > maxCode {
+			hi = maxCode
+		}
+		codes := bytesOf((*s)[start:end])
+		if lo == hi {
+			return scanEqual(codes, start, uint8(lo), visit)
+		}
+		return scanRange(codes, start, uint8(lo), uint8(hi), visit)
+	}
+
+	for i, c := range (*s)[start:end] {
+		if Code(c)-lo <= hi-lo && !visit(start+i) {
+			return false
+		}
+	}
+	return true
+}
+
+// scanRange calls visit with start plus the index of every code within
+// `[lo, hi]`, in increasing order, until visit returns false, which it
+// returns. It compares a word of codes at a time like countRanges, and
+// visits the lanes in range like scanEqual.
+func scanRange(codes []uint8, start int, lo, hi uint8, visit func(pos int) bool) bool {
+	head, words, tail := splitWords(codes)
+	for i, x := range head {
+		if x-lo <= hi-lo && !visit(start+i) {
+			return false
+		}
+	}
+
+	pos := start + len(head)
+	l, d := uint64(lo)*byteOnes, uint64(hi-lo)*byteOnes
+	for _, x := range words {
+		y := laneSub(x, l, byteHigh)
+		for m := ^laneBorrow(d, y, laneSub(d, y, byteHigh)) & byteHigh; m != 0; m &= m - 1 {
+			if !visit(pos + bits.TrailingZeros64(m)>>3) {
+				return false
+			}
+		}
+		pos += 8
+	}
+
+	for i, x := range tail {
+		if x-lo <= hi-lo && !visit(pos+i) {
+			return false
+		}
+	}
+	return true
+}
+
+// rangeMask compares a word of codes at a time, like countRanges, and
+// gathers the top bits of the lanes in range by a multiplication that moves
+// each of them to a distinct bit of the top lane. Byte codes are compared
+// with a single code by XOR, as in countEqual.
+func (s *codeSlice[E]) rangeMask(start, n int, lo, hi Code) uint64 {
+	codes := (*s)[start : start+n]
+	maxCode := s.maxCode()
+	if lo > maxCode {
+		return 0
+	}
+	if hi > maxCode {
+		hi = maxCode
+	}
+
+	var m uint64
+	if n < 64 || uintptr(unsafe.Pointer(&codes[0]))%8 != 0 {
+		for j, c := range codes {
+			m |= uint64(b2u(Code(c)-lo <= hi-lo)) << j
+		}
+		return m
+	}
+
+	width := 8 * unsafe.Sizeof(E(0))
+	lanes := 64 / width
+	ones := ^uint64(0) / uint64(^E(0))
+	high := ones << (width - 1)
+	l, d := uint64(lo)*ones, uint64(hi-lo)*ones
+
+	// The multiplier has bit 64-lanes+i-i*width set for each lane i.
+	gather := uint64(0x0102040810204080)
+	if width == 16 {
+		gather = 1<<60 | 1<<45 | 1<<30 | 1<<15
+	}
+
+	words := unsafe.Slice((*uint64)(unsafe.Pointer(&codes[0])), 64/lanes)
+	if lo == hi && width == 8 {
+		for k, x := range words {
+			in := laneZero(x^l, high)
+			m |= ((in >> 7) * gather >> 56) << (uintptr(k) * 8)
+		}
+		return m
+	}
+	for k, x := range words {
+		y := laneSub(x, l, high)
+		in := ^laneBorrow(d, y, laneSub(d, y, high)) & high
+		m |= ((in >> (width - 1)) * gather >> (64 - lanes)) << (uintptr(k) * lanes)
+	}
+	return m
+}
+
+func (s *codeSlice[E]) setMask(start, n int, cs *CodeSet) uint64 {
+	var m uint64
+	for j, c := range (*s)[start : start+n] {
+		m |= uint64(b2u(cs.Contains(Code(c)))) << j
+	}
+	return m
+}
+
+// laneSub subtracts y from x lane by lane, modulo the lane width, where high
+// has the top bit of each lane set.
+func laneSub(x, y, high uint64) uint64 {
+	return ((x | high) - (y &^ high)) ^ ((x ^ ^y) & high)
+}
+
+// laneZero returns, in the top bit of each lane, whether the lane is 0.
+func laneZero(x, high uint64) uint64 {
+	return ^((x&^high + ^high) | x) & high
+}
+
+// laneSum adds up the lanes of x, of the given width, given that pairs has
+// a 1 in the bottom lane of every pair of lanes, and wide is the mask of the
+// bottom lanes of the pairs.
+func laneSum(x uint64, width uintptr, pairs, wide uint64) int {
+	x = x&wide + x>>width&wide
+	return int(x * pairs >> (64 - 2*width))
+}
+
+// laneBorrow returns, in the top bit of each lane, whether subtracting y
+// from x borrows, given their lane by lane difference.
+func laneBorrow(x, y, diff uint64) uint64 {
+	return (^x & y) | (^(x ^ y) & diff)
+}
@@ -0,0 +1,341 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSketch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomStrings(rng, 100000, 50000))
+		s := NewSketch(&dict)
+		if s.Dict() != &dict {
+			t.Errorf("mode %s: Dict() isn't the sketch's dictionary", mode)
+		}
+
+		values := randomStrings(rng, 10000, 60000)
+		for _, v := range values[:5000] {
+			s.Append(v)
+		}
+		s.AppendCodes(dict.EncodeAll(values[5000:], nil))
+
+		if got, want := s.Len(), len(values); got != want {
+			t.Fatalf("mode %s: Len() = %d, want %d", mode, got, want)
+		}
+		for i, v := range values {
+			if got, want := s.Get(i), dict.Encode(v); got != want {
+				t.Fatalf("mode %s: Get(%d) = %d, want Encode(%q) = %d", mode, i, got, v, want)
+			}
+		}
+	}
+
+	// Codes beyond the mode are refused as a whole.
+	dict := NewDict(Byte, []int{1, 2, 3})
+	s := NewSketch(&dict)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("AppendCodes() with a Word code in a Byte sketch didn't panic")
+			}
+		}()
+		s.AppendCodes([]Code{2, 0x100})
+	}()
+	if s.Len() != 0 {
+		t.Errorf("Len() = %d after a refused AppendCodes(), want 0", s.Len())
+	}
+}
+
+func TestSketchStorage(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 20
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 100000, 1<<30))
+		s := NewSketch(&dict)
+		s.AppendCodes(dict.EncodeAll(randomInt64s(rng, n, 1<<30), nil))
+
+		width := mode.codeWidth()
+		if got := s.BytesPerCode(); got != width {
+			t.Errorf("mode %s: BytesPerCode() = %d, want %d", mode, got, width)
+		}
+
+		// Allow for the slack left by growing the backing array.
+		got, want := s.MemoryFootprint(), int64(n*width)
+		if got < want || got > want+want/4 {
+			t.Errorf("mode %s: MemoryFootprint() = %d for %d codes, want about %d", mode, got, n, want)
+		}
+	}
+}
+
+func TestCodeSliceKernels(t *testing.T) {
+	testCodeSliceKernels[uint8](t)
+	testCodeSliceKernels[uint16](t)
+}
+
+// testCodeSliceKernels compares the word at a time kernels of codeSlice with
+// a code at a time reference, over random ranges of codes of random ranges
+// of a store.
+func testCodeSliceKernels[E uint8 | uint16](t *testing.T) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+
+	var s codeSlice[E]
+	maxCode := int(s.maxCode())
+	for i := 0; i < 64*20+13; i++ {
+		// Few codes, so that ranges hold many of them, at both ends of the
+		// codes the store holds.
+		c := rng.Intn(16)
+		if rng.Intn(2) == 0 {
+			c = maxCode - c
+		}
+		s.append(Code(c))
+	}
+
+	name := fmt.Sprintf("%d bits", s.bitsPerCode())
+	for i := 0; i < 2000; i++ {
+		start := rng.Intn(s.len() + 1)
+		end := start + rng.Intn(s.len()-start+1)
+		lo := Code(rng.Intn(maxCode + 2))
+		hi := lo + Code(rng.Intn(maxCode+2-int(lo)))
+		if rng.Intn(4) == 0 {
+			hi = lo
+		}
+		dlo, dhi := lo+Code(rng.Intn(2)), hi-Code(rng.Intn(2))
+
+		var want []int
+		wantDefinite := 0
+		for pos := start; pos < end; pos++ {
+			c := s.get(pos)
+			if lo <= c && c <= hi {
+				want = append(want, pos)
+				wantDefinite += int(b2u(dlo <= c && c <= dhi))
+			}
+		}
+
+		var got []int
+		s.scan(start, end, lo, hi, func(pos int) bool {
+			got = append(got, pos)
+			return true
+		})
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s: scan(%d, %d, %d, %d) visited %v, want %v", name, start, end, lo, hi, got, want)
+		}
+
+		if n, nd := s.countRanges(start, end, lo, hi, dlo, dhi); n != len(want) || nd != wantDefinite {
+			t.Fatalf("%s: countRanges(%d, %d, %d, %d, %d, %d) = %d, %d, want %d, %d",
+				name, start, end, lo, hi, dlo, dhi, n, nd, len(want), wantDefinite)
+		}
+
+		pos := start &^ 63
+		n := s.len() - pos
+		if n > 64 {
+			n = 64
+		}
+		var wantMask uint64
+		for j := 0; j < n; j++ {
+			c := s.get(pos + j)
+			wantMask |= uint64(b2u(lo <= c && c <= hi)) << j
+		}
+		if m := s.rangeMask(pos, n, lo, hi); m != wantMask {
+			t.Fatalf("%s: rangeMask(%d, %d, %d, %d) = %#x, want %#x", name, pos, n, lo, hi, m, wantMask)
+		}
+	}
+}
+
+func TestSketchCountRange(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		maxCode := mode.MaxInexactCode()
+		for _, n := range []int{0, 1, 7, 8, 9, 1000, 4099} {
+			// A dictionary assigning every code of the mode, but 0.
+			dict := NewDict(mode, rng.Perm(mode.NumExactCodes()))
+			s := NewSketch(&dict)
+			codes := make([]Code, n)
+			for i := range codes {
+				codes[i] = 1 + Code(rng.Intn(int(maxCode)))
+			}
+			s.AppendCodes(codes)
+
+			ranges := [][2]Code{{0, maxCode}, {0, 0}, {maxCode, maxCode}, {1, maxCode - 1}, {5, 4}, {maxCode, Word.MaxInexactCode()}}
+			for i := 0; i < 20; i++ {
+				lo := Code(rng.Intn(int(maxCode) + 1))
+				ranges = append(ranges, [2]Code{lo, lo + Code(rng.Intn(int(maxCode-lo)+1))})
+			}
+
+			for _, r := range ranges {
+				want := 0
+				for _, c := range codes {
+					if r[0] <= c && c <= r[1] {
+						want++
+					}
+				}
+				if got := s.CountRange(r[0], r[1]); got != want {
+					t.Fatalf("mode %s, %d codes: CountRange(%d, %d) = %d, want %d", mode, n, r[0], r[1], got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSketchSet(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+
+		for _, opts := range [][]SketchOption{
+			{WithBlockSize(64)},
+			{WithBlockSize(128), WithBitPacking()},
+		} {
+			// Sorted values give blocks narrow bounds for overwrites to
+			// widen and narrow.
+			values := randomInt64s(rng, 1000+rng.Intn(64), 12000)
+			sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+			s := NewSketch(&dict, opts...)
+			s.AppendCodes(dict.EncodeAll(values, nil))
+
+			for round := 0; round < 20; round++ {
+				for i := 0; i < 50; i++ {
+					pos := rng.Intn(len(values))
+					switch rng.Intn(3) {
+					case 0:
+						// Rewrite a row with its neighbour, likely narrowing
+						// its block's bounds.
+						if pos > 0 {
+							values[pos] = values[pos-1]
+						}
+					default:
+						values[pos] = rng.Int63n(12002) - 1
+					}
+					s.Set(pos, values[pos])
+				}
+
+				codes := dict.EncodeAll(values, nil)
+				for i, want := range codes {
+					if got := s.Get(i); got != want {
+						t.Fatalf("mode %s: Get(%d) = %d after Set(), want %d", mode, i, got, want)
+					}
+				}
+				testBlocks(t, fmt.Sprintf("mode %s, round %d", mode, round), s, codes)
+				for i := 0; i < 20; i++ {
+					testScan(t, s, values, randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 }))
+				}
+			}
+
+			for _, pos := range []int{-1, len(values)} {
+				func() {
+					defer func() {
+						if recover() == nil {
+							t.Errorf("mode %s: Set(%d) out of range didn't panic", mode, pos)
+						}
+					}()
+					s.Set(pos, 0)
+				}()
+			}
+		}
+	}
+}
+
+func TestSketchAppendValues(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+
+		for _, opts := range [][]SketchOption{
+			nil,
+			{WithBlockSize(64)},
+			{WithBlockSize(128), WithBitPacking()},
+		} {
+			bulk, loop := NewSketch(&dict, opts...), NewSketch(&dict, opts...)
+			name := fmt.Sprintf("mode %s, block size %d, %d bits", mode, bulk.BlockSize(), bulk.BitsPerCode())
+
+			// Batches of all sizes, from empty to several blocks, start
+			// and end within blocks.
+			var values []int64
+			for i := 0; i < 20; i++ {
+				batch := randomInt64s(rng, rng.Intn(3*bulk.BlockSize()), 12000)
+				bulk.AppendValues(batch)
+				for _, v := range batch {
+					loop.Append(v)
+				}
+				values = append(values, batch...)
+			}
+
+			if bulk.Len() != loop.Len() {
+				t.Fatalf("%s: Len() = %d, want %d", name, bulk.Len(), loop.Len())
+			}
+			for i := 0; i < bulk.Len(); i++ {
+				if got, want := bulk.Get(i), loop.Get(i); got != want {
+					t.Fatalf("%s: Get(%d) = %d, want %d", name, i, got, want)
+				}
+			}
+			testBlocks(t, name, bulk, dict.EncodeAll(values, nil))
+		}
+	}
+
+	dict := NewDict(Byte, []int{1, 2, 3})
+	for _, c := range []Code{0, 8} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("AppendCodes() with code %d didn't panic", c)
+				}
+			}()
+			NewSketch(&dict).AppendCodes([]Code{2, c})
+		}()
+	}
+}
+
+// BenchmarkSketchAppendValues compares appending a column of values a
+// value at a time with appending it in bulk.
+func BenchmarkSketchAppendValues(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Word, randomInt64s(rng, 100000, 1<<30))
+	values := randomInt64s(rng, 10_000_000, 1<<30)
+
+	b.Run("Append", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s := NewSketch(&dict)
+			for _, v := range values {
+				s.Append(v)
+			}
+		}
+		b.ReportMetric(float64(b.N*len(values))/b.Elapsed().Seconds(), "values/s")
+	})
+	b.Run("AppendValues", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewSketch(&dict).AppendValues(values)
+		}
+		b.ReportMetric(float64(b.N*len(values))/b.Elapsed().Seconds(), "values/s")
+	})
+}
+
+// BenchmarkSketchCountRange shows the effect of storing Byte mode codes in a
+// byte each: twice as many are compared at once as in Word mode.
+func BenchmarkSketchCountRange(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 1 << 22
+	sample, values := randomInt64s(rng, 100000, 1<<30), randomInt64s(rng, n, 1<<30)
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, sample)
+		s := NewSketch(&dict)
+		s.AppendCodes(dict.EncodeAll(values, nil))
+		lo, hi := dict.Encode(1<<28), dict.Encode(1<<29)
+
+		b.Run(mode.String(), func(b *testing.B) {
+			b.SetBytes(int64(n * s.BytesPerCode()))
+			var count int
+			for i := 0; i < b.N; i++ {
+				count += s.CountRange(lo, hi)
+			}
+			sink = count
+		})
+	}
+}
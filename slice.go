@@ -0,0 +1,147 @@
+package colsketch
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// SketchSlice is a view of the rows `[lo, hi)` of a Sketch, e.g. a row
+// group handed to a worker, that shares its storage and its block headers.
+// Its positions are relative to the slice: row i of the slice is row lo+i of
+// the sketch, which Offset tells. It sees rows of its range updated or
+// deleted after it was sliced, but not the rows appended to the sketch
+// after it.
+//
+// Scans of a slice skip the blocks their headers rule out like scans of
+// the whole sketch do, including the blocks at the ends of the slice that
+// it only holds a part of, whose headers bound the codes of that part too.
+type SketchSlice[T cmp.Ordered] struct {
+	s      *Sketch[T]
+	lo, hi int
+}
+
+// Slice returns a view of the rows `[lo, hi)` of the sketch. It panics if
+// the range isn't within `[0, Len()]`, like slicing a Go slice.
+func (s *Sketch[T]) Slice(lo, hi int) SketchSlice[T] {
+	if n := s.store.len(); lo < 0 || hi < lo || hi > n {
+		panic(fmt.Sprintf("colsketch: slice bounds [%d:%d] out of range [0:%d]", lo, hi, n))
+	}
+	return SketchSlice[T]{s, lo, hi}
+}
+
+// Slice returns a view of the rows `[lo, hi)` of the slice, relative to it.
+// It panics if the range isn't within `[0, Len()]`.
+func (v SketchSlice[T]) Slice(lo, hi int) SketchSlice[T] {
+	if n := v.Len(); lo < 0 || hi < lo || hi > n {
+		panic(fmt.Sprintf("colsketch: slice bounds [%d:%d] out of range [0:%d]", lo, hi, n))
+	}
+	return SketchSlice[T]{v.s, v.lo + lo, v.lo + hi}
+}
+
+// Sketch returns the sketch the slice is a view of.
+func (v SketchSlice[T]) Sketch() *Sketch[T] {
+	return v.s
+}
+
+// Offset returns the position in the sketch of the first row of the slice,
+// to add to the positions of the slice to make them the sketch's.
+func (v SketchSlice[T]) Offset() int {
+	return v.lo
+}
+
+// Len returns the number of rows of the slice, including deleted ones.
+func (v SketchSlice[T]) Len() int {
+	return v.hi - v.lo
+}
+
+// LiveLen returns the number of rows of the slice that weren't deleted.
+func (v SketchSlice[T]) LiveLen() int {
+	return v.Len() - v.s.deletedIn(v.lo, v.hi)
+}
+
+// Get returns the code at position i of the slice. It panics if i is out of
+// range.
+func (v SketchSlice[T]) Get(i int) Code {
+	if i < 0 || i >= v.Len() {
+		panic(fmt.Sprintf("colsketch: index %d out of range [0:%d]", i, v.Len()))
+	}
+	return v.s.Get(v.lo + i)
+}
+
+// IsDeleted returns true iff the row at position i of the slice was
+// deleted. It returns false for positions out of range.
+func (v SketchSlice[T]) IsDeleted(i int) bool {
+	return i >= 0 && i < v.Len() && v.s.IsDeleted(v.lo+i)
+}
+
+// Scan is like Sketch.Scan over the rows of the slice, calling visit with
+// positions relative to it.
+func (v SketchSlice[T]) Scan(p Predicate[T], visit func(pos int) bool) {
+	v.s.scanRows(v.lo, v.hi, p, func(pos int) bool {
+		return visit(pos - v.lo)
+	})
+}
+
+// ScanCertain is like Sketch.ScanCertain over the rows of the slice,
+// calling visit with positions relative to it.
+func (v SketchSlice[T]) ScanCertain(p Predicate[T], visit func(pos int, c Certainty) bool) {
+	v.s.scanCertainRows(v.lo, v.hi, p, func(pos int, c Certainty) bool {
+		return visit(pos-v.lo, c)
+	})
+}
+
+// Count is like Sketch.Count over the rows of the slice.
+func (v SketchSlice[T]) Count(p Predicate[T]) (candidates, definite int) {
+	return v.s.countRows(v.lo, v.hi, p)
+}
+
+// ScanBitmap is like Sketch.ScanBitmap over the rows of the slice: bit i%64
+// of word i/64 of the bitmap stands for row i of the slice, and dst is
+// reused if it has the capacity for (Len()+63)/64 words.
+func (v SketchSlice[T]) ScanBitmap(p Predicate[T], dst []uint64) []uint64 {
+	words := (v.Len() + 63) / 64
+	if cap(dst) < words {
+		dst = make([]uint64, words)
+	}
+	dst = dst[:words]
+	for i := range dst {
+		dst[i] = 0
+	}
+
+	f := p.candidates(v.s.dict)
+	if f.empty() {
+		return dst
+	}
+
+	s := v.s
+	for i := v.lo / s.blockSize; i*s.blockSize < v.hi; i++ {
+		if s.rulesOut(i, f) || s.isDead(i) {
+			continue
+		}
+
+		// Compare whole words of codes of the sketch, like ScanCertain, and
+		// shift them into place in the words of the slice's bitmap.
+		start, end := s.blockRows(i, v.lo, v.hi)
+		blockEnd := s.blockEnd(i)
+		for pos := start &^ 63; pos < end; pos += 64 {
+			k := blockEnd - pos
+			if k > 64 {
+				k = 64
+			}
+
+			m := f.mask(s.store, pos, k) &^ s.deletedWord(pos/64) & windowMask(pos, start, end)
+			if m == 0 {
+				continue
+			}
+			if off := pos - v.lo; off < 0 {
+				dst[0] |= m >> uint(-off)
+			} else {
+				dst[off/64] |= m << uint(off%64)
+				if off%64 != 0 && off/64+1 < len(dst) {
+					dst[off/64+1] |= m >> uint(64-off%64)
+				}
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,129 @@
+package colsketch
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSketchSlice(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+
+		for _, opts := range [][]SketchOption{
+			nil,
+			{WithBlockSize(64)},
+			{WithBlockSize(128), WithBitPacking()},
+		} {
+			s := NewSketch(&dict, opts...)
+			name := fmt.Sprintf("mode %s, block size %d, %d bits", mode, s.BlockSize(), s.BitsPerCode())
+			s.AppendValues(randomInt64s(rng, 5*s.BlockSize()+rng.Intn(64), 12000))
+			for i := 0; i < s.Len()/8; i++ {
+				s.Delete(rng.Intn(s.Len()))
+			}
+
+			n, bs := s.Len(), s.BlockSize()
+			ranges := [][2]int{{0, n}, {0, 0}, {n, n}, {bs, 2 * bs}, {bs - 1, bs + 1}, {3, n - 5}}
+			for i := 0; i < 20; i++ {
+				lo := rng.Intn(n + 1)
+				ranges = append(ranges, [2]int{lo, lo + rng.Intn(n-lo+1)})
+			}
+
+			for _, r := range ranges {
+				v := s.Slice(r[0], r[1])
+				testSketchSlice(t, fmt.Sprintf("%s, [%d:%d]", name, r[0], r[1]), v, s, rng)
+
+				// Slice a slice.
+				lo := rng.Intn(v.Len() + 1)
+				vv := v.Slice(lo, lo+rng.Intn(v.Len()-lo+1))
+				if vv.Offset() != v.Offset()+lo {
+					t.Fatalf("%s: Offset() = %d, want %d", name, vv.Offset(), v.Offset()+lo)
+				}
+				testSketchSlice(t, fmt.Sprintf("%s, [%d:%d][%d:%d]", name, r[0], r[1], lo, lo+vv.Len()), vv, s, rng)
+			}
+
+			for _, r := range [][2]int{{-1, 0}, {1, 0}, {0, n + 1}} {
+				func() {
+					defer func() {
+						if recover() == nil {
+							t.Errorf("%s: Slice(%d, %d) didn't panic", name, r[0], r[1])
+						}
+					}()
+					s.Slice(r[0], r[1])
+				}()
+			}
+		}
+	}
+}
+
+// testSketchSlice checks that a slice reads and scans like the rows of the
+// sketch in its range.
+func testSketchSlice(t *testing.T, name string, v SketchSlice[int64], s *Sketch[int64], rng *rand.Rand) {
+	t.Helper()
+
+	off := v.Offset()
+	live := 0
+	for i := 0; i < v.Len(); i++ {
+		if v.Get(i) != s.Get(off+i) || v.IsDeleted(i) != s.IsDeleted(off+i) {
+			t.Fatalf("%s: row %d is %d, deleted %v, want %d, deleted %v", name, i,
+				v.Get(i), v.IsDeleted(i), s.Get(off+i), s.IsDeleted(off+i))
+		}
+		live += int(b2u(!v.IsDeleted(i)))
+	}
+	if v.LiveLen() != live {
+		t.Fatalf("%s: LiveLen() = %d, want %d", name, v.LiveLen(), live)
+	}
+	if v.IsDeleted(-1) || v.IsDeleted(v.Len()) {
+		t.Fatalf("%s: IsDeleted() out of range is true", name)
+	}
+
+	for i := 0; i < 10; i++ {
+		p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+
+		// Scan the whole sketch, keeping the rows in range.
+		var want []int
+		var wantCertain []Certainty
+		wantBitmap := make([]uint64, (v.Len()+63)/64)
+		numDefinite := 0
+		s.ScanCertain(p, func(pos int, c Certainty) bool {
+			if pos >= off && pos < off+v.Len() {
+				want = append(want, pos-off)
+				wantCertain = append(wantCertain, c)
+				wantBitmap[(pos-off)/64] |= 1 << ((pos - off) % 64)
+				numDefinite += int(c)
+			}
+			return true
+		})
+
+		var got []int
+		v.Scan(p, func(pos int) bool {
+			got = append(got, pos)
+			return true
+		})
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s, %+v: Scan() visited %v, want %v", name, p, got, want)
+		}
+
+		got = got[:0]
+		var certain []Certainty
+		v.ScanCertain(p, func(pos int, c Certainty) bool {
+			got = append(got, pos)
+			certain = append(certain, c)
+			return true
+		})
+		if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(certain, wantCertain) {
+			t.Fatalf("%s, %+v: ScanCertain() visited %v, %v, want %v, %v", name, p, got, certain, want, wantCertain)
+		}
+
+		if got := v.ScanBitmap(p, []uint64{^uint64(0)}); !reflect.DeepEqual(got, wantBitmap) {
+			t.Fatalf("%s, %+v: ScanBitmap() = %#x, want %#x", name, p, got, wantBitmap)
+		}
+
+		if c, d := v.Count(p); c != len(want) || d != numDefinite {
+			t.Fatalf("%s, %+v: Count() = %d, %d, want %d, %d", name, p, c, d, len(want), numDefinite)
+		}
+	}
+}
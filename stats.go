@@ -0,0 +1,67 @@
+package colsketch
+
+// ExactnessStats describes how many of the codes stored for a column are
+// exact, overall and per storage block.
+type ExactnessStats struct {
+	// The fraction of all codes that are exact.
+	ExactFraction float64
+
+	// The fraction of exact codes in each consecutive block of codes. The
+	// last block may be shorter than the others.
+	BlockExactFractions []float64
+
+	// The number of distinct inexact codes that occur.
+	DistinctInexact int
+
+	// The fraction of all codes that are NullCode, which are neither exact
+	// nor inexact.
+	NullFraction float64
+}
+
+// ExactStats computes exactness statistics over a column of codes, split
+// into blocks of blockLen codes. Since elision happens per storage block, a
+// single block dominated by inexact codes can defeat skipping even when the
+// column-wide fraction looks healthy; BlockExactFractions surfaces that.
+// A blockLen <= 0 treats the whole column as a single block.
+func ExactStats(codes []Code, blockLen int) ExactnessStats {
+	var stats ExactnessStats
+	if len(codes) == 0 {
+		return stats
+	}
+
+	if blockLen <= 0 {
+		blockLen = len(codes)
+	}
+
+	inexact := NewCodeSet(Word)
+
+	exact, nulls := 0, 0
+	stats.BlockExactFractions = make([]float64, 0, (len(codes)+blockLen-1)/blockLen)
+	for lo := 0; lo < len(codes); lo += blockLen {
+		hi := lo + blockLen
+		if hi > len(codes) {
+			hi = len(codes)
+		}
+
+		blockExact := 0
+		for _, c := range codes[lo:hi] {
+			switch {
+			case c == NullCode:
+				nulls++
+			case c.IsExact():
+				blockExact++
+			default:
+				inexact.Add(c)
+			}
+		}
+
+		exact += blockExact
+		stats.BlockExactFractions = append(stats.BlockExactFractions, float64(blockExact)/float64(hi-lo))
+	}
+
+	stats.ExactFraction = float64(exact) / float64(len(codes))
+	stats.DistinctInexact = inexact.Len()
+	stats.NullFraction = float64(nulls) / float64(len(codes))
+
+	return stats
+}
@@ -0,0 +1,56 @@
+package colsketch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExactStats(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		codes    []Code
+		blockLen int
+		want     ExactnessStats
+	}{
+		{
+			name: "empty",
+			want: ExactnessStats{},
+		},
+		{
+			name:     "all exact",
+			codes:    []Code{2, 4, 6, 8},
+			blockLen: 2,
+			want:     ExactnessStats{1, []float64{1, 1}, 0, 0},
+		},
+		{
+			name:     "one inexact-heavy block",
+			codes:    []Code{2, 2, 4, 4, 1, 3, 3, 5},
+			blockLen: 4,
+			want:     ExactnessStats{0.5, []float64{1, 0}, 3, 0},
+		},
+		{
+			name:     "partial last block",
+			codes:    []Code{2, 3, 4, 0xffff, 0xfffe},
+			blockLen: 2,
+			want:     ExactnessStats{0.6, []float64{0.5, 0.5, 1}, 2, 0},
+		},
+		{
+			name:     "single block",
+			codes:    []Code{1, 1, 1, 2},
+			blockLen: 0,
+			want:     ExactnessStats{0.25, []float64{0.25}, 1, 0},
+		},
+		{
+			name:     "nulls",
+			codes:    []Code{NullCode, 2, NullCode, 3},
+			blockLen: 2,
+			want:     ExactnessStats{0.25, []float64{0.5, 0}, 1, 0.5},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ExactStats(tc.codes, tc.blockLen); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ExactStats() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
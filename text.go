@@ -0,0 +1,132 @@
+package colsketch
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// MarshalText implements encoding.TextMarshaler, so that dictionaries can be
+// embedded in configuration read and written by YAML or TOML libraries, and
+// by JSON ones that don't support MarshalJSON. The text format is a line
+// "mode:Byte" or "mode:Word" followed by a line for each representative, in
+// increasing order: integers in decimal, floats in the shortest form that
+// parses back to them, and strings quoted as Go string literals, so that
+// they can hold newlines. Unlike the binary format, it doesn't hold sample
+// counts. It has a value receiver for the same reason MarshalJSON does.
+func (d Dict[T]) MarshalText() ([]byte, error) {
+	k := kindOf[T]()
+	if k == kindInvalid {
+		return nil, fmt.Errorf("colsketch: unsupported value type %T", *new(T))
+	}
+
+	b := append([]byte("mode:"), d.mode.String()...)
+	b = append(b, '\n')
+	for _, v := range d.codes {
+		b = appendValueText(b, k, v)
+		b = append(b, '\n')
+	}
+	return b, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, decoding the format
+// of MarshalText, in which the final newline is optional. It rejects
+// dictionaries that fail Validate, and only modifies the receiver if
+// decoding succeeds.
+func (d *Dict[T]) UnmarshalText(text []byte) error {
+	k := kindOf[T]()
+	if k == kindInvalid {
+		return fmt.Errorf("colsketch: unsupported value type %T", *new(T))
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(text, []byte("\n")), []byte("\n"))
+	var dec Dict[T]
+	switch string(lines[0]) {
+	case "mode:Byte":
+		dec.mode = Byte
+	case "mode:Word":
+		dec.mode = Word
+	default:
+		return fmt.Errorf("colsketch: line 1: invalid mode line %q", lines[0])
+	}
+
+	lines = lines[1:]
+	if n := dec.mode.NumExactCodes(); len(lines) > n {
+		return fmt.Errorf("colsketch: %d representatives exceed the %d exact codes of the mode", len(lines), n)
+	}
+	dec.codes = make([]T, len(lines))
+	for i, line := range lines {
+		v, err := parseValueText[T](string(line), k)
+		if err != nil {
+			return fmt.Errorf("colsketch: line %d: %w", i+2, err)
+		}
+		dec.codes[i] = v
+	}
+
+	if err := dec.Validate(); err != nil {
+		return err
+	}
+	*d = dec
+	return nil
+}
+
+// appendValueText appends the text encoding of a value of kind k.
+func appendValueText[T cmp.Ordered](b []byte, k valueKind, v T) []byte {
+	rv := reflect.ValueOf(v)
+	switch {
+	case k == kindString:
+		return strconv.AppendQuote(b, rv.String())
+	case k == kindFloat32:
+		return strconv.AppendFloat(b, rv.Float(), 'g', -1, 32)
+	case k == kindFloat64:
+		return strconv.AppendFloat(b, rv.Float(), 'g', -1, 64)
+	case rv.CanInt():
+		return strconv.AppendInt(b, rv.Int(), 10)
+	default:
+		return strconv.AppendUint(b, rv.Uint(), 10)
+	}
+}
+
+// parseValueText parses the text encoding of a value of kind k.
+func parseValueText[T cmp.Ordered](s string, k valueKind) (T, error) {
+	var v T
+	rv := reflect.ValueOf(&v).Elem()
+	bits := 8 * k.size()
+
+	switch {
+	case k == kindString:
+		u, err := strconv.Unquote(s)
+		if err != nil {
+			return v, fmt.Errorf("invalid string %s", s)
+		}
+		rv.SetString(u)
+	case k == kindFloat32 || k == kindFloat64:
+		f, err := strconv.ParseFloat(s, bits)
+		if err != nil {
+			return v, err
+		}
+		rv.SetFloat(f)
+	case rv.CanInt():
+		// int is encoded as 64 bits, but must fit the platform's.
+		if k == kindInt {
+			bits = 0
+		}
+		n, err := strconv.ParseInt(s, 10, bits)
+		if err != nil {
+			return v, err
+		}
+		rv.SetInt(n)
+	default:
+		if k == kindUint || k == kindUintptr {
+			bits = 0
+		}
+		n, err := strconv.ParseUint(s, 10, bits)
+		if err != nil {
+			return v, err
+		}
+		rv.SetUint(n)
+	}
+	return v, nil
+}
@@ -0,0 +1,106 @@
+package colsketch
+
+import (
+	"cmp"
+	"encoding"
+	"math"
+	"reflect"
+	"testing"
+)
+
+var (
+	_ encoding.TextMarshaler   = Dict[int]{}
+	_ encoding.BinaryMarshaler = Dict[int]{}
+	_ encoding.TextUnmarshaler = (*Dict[int])(nil)
+)
+
+func testMarshalTextRoundTrip[T cmp.Ordered](t *testing.T, mode Mode, sample []T) {
+	t.Helper()
+
+	dict := NewDict(mode, sample)
+	text, err := dict.MarshalText()
+	if err != nil {
+		t.Fatalf("MarshalText() = %v", err)
+	}
+
+	var got Dict[T]
+	if err := got.UnmarshalText(text); err != nil {
+		t.Fatalf("UnmarshalText(%q) = %v", text, err)
+	}
+
+	// The text format doesn't hold sample counts, nor their total, nor the
+	// number of distinct sample values.
+	want := dict
+	want.counts, want.sampleSize, want.numClusters = nil, 0, 0
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip of %q = %v, want %v", text, got, want)
+	}
+}
+
+func TestMarshalText(t *testing.T) {
+	testMarshalTextRoundTrip(t, Byte, []int{-5, 0, 7, math.MaxInt, math.MinInt})
+	testMarshalTextRoundTrip(t, Word, []int8{-128, -1, 0, 127})
+	testMarshalTextRoundTrip(t, Byte, []int16{-300, 300})
+	testMarshalTextRoundTrip(t, Byte, []int32{math.MinInt32, 1})
+	testMarshalTextRoundTrip(t, Byte, []int64{math.MinInt64, math.MaxInt64})
+	testMarshalTextRoundTrip(t, Byte, []uint{0, math.MaxUint})
+	testMarshalTextRoundTrip(t, Byte, []uint8{0, 255})
+	testMarshalTextRoundTrip(t, Byte, []uint16{0, 65535})
+	testMarshalTextRoundTrip(t, Byte, []uint32{0, math.MaxUint32})
+	testMarshalTextRoundTrip(t, Byte, []uint64{0, math.MaxUint64})
+	testMarshalTextRoundTrip(t, Byte, []uintptr{0, 42})
+	testMarshalTextRoundTrip(t, Byte, []float32{-1.5, 0.1, math.MaxFloat32, float32(math.Inf(1))})
+	testMarshalTextRoundTrip(t, Word, []float64{-1.5, 0.1, math.SmallestNonzeroFloat64, math.Inf(-1)})
+	testMarshalTextRoundTrip(t, Word, []string{"", "a", "a\nb", "\"", "\x00\xff"})
+	testMarshalTextRoundTrip[string](t, Byte, nil)
+
+	// NaN, the smallest float64, doesn't round trip through DeepEqual.
+	nan := NewDict(Byte, []float64{math.NaN(), 1})
+	text, err := nan.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Dict[float64]
+	if err := got.UnmarshalText(text); err != nil || got.Len() != 2 || !math.IsNaN(got.codes[0]) {
+		t.Errorf("UnmarshalText(%q) = %v, %v", text, err, got.codes)
+	}
+
+	dict := NewDict(Byte, []string{"x", "y\nz"})
+	text, err = dict.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "mode:Byte\n\"x\"\n\"y\\nz\"\n"; string(text) != want {
+		t.Errorf("MarshalText() = %q, want %q", text, want)
+	}
+}
+
+func TestUnmarshalTextErrors(t *testing.T) {
+	var d Dict[int8]
+	for _, text := range []string{
+		"",
+		"mode:Half\n1\n",
+		"Byte\n1\n",
+		"mode:Byte\n1\n\n",
+		"mode:Byte\n2\n1\n",
+		"mode:Byte\n1\n1\n",
+		"mode:Byte\n128\n",
+		"mode:Byte\n1.5\n",
+	} {
+		if err := d.UnmarshalText([]byte(text)); err == nil {
+			t.Errorf("UnmarshalText(%q) succeeded", text)
+		}
+	}
+	if !reflect.DeepEqual(d, Dict[int8]{}) {
+		t.Errorf("failed UnmarshalText() modified the receiver: %v", d)
+	}
+
+	if err := d.UnmarshalText([]byte("mode:Word\n-1\n3")); err != nil || d.Len() != 2 || d.mode != Word {
+		t.Errorf("UnmarshalText() without a final newline = %v, %v", err, d)
+	}
+
+	var s Dict[string]
+	if err := s.UnmarshalText([]byte("mode:Byte\nunquoted\n")); err == nil {
+		t.Errorf("UnmarshalText() of an unquoted string succeeded")
+	}
+}
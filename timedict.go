@@ -0,0 +1,92 @@
+package colsketch
+
+import (
+	"math"
+	"time"
+)
+
+// TimeDict is a dictionary over instants in time, for timestamp columns,
+// since time.Time doesn't satisfy cmp.Ordered and so can't be used with
+// Dict. Instants are ordered and assigned codes as their Unix time in
+// nanoseconds, which covers the years 1678 to 2262; instants outside of
+// that range encode as if clamped to it.
+type TimeDict struct {
+	dict Dict[int64]
+}
+
+// NewTimeDict builds a dictionary with a given Mode over a provided sample,
+// like NewDict.
+func NewTimeDict(mode Mode, sample []time.Time) TimeDict {
+	nanos := make([]int64, len(sample))
+	for i, t := range sample {
+		nanos[i] = unixNano(t)
+	}
+	return TimeDict{dict: NewDict(mode, nanos)}
+}
+
+// Encode looks up the code for an instant.
+func (d *TimeDict) Encode(t time.Time) Code {
+	return d.dict.Encode(unixNano(t))
+}
+
+// DecodeRange returns the earliest and latest instants, in UTC, that encode
+// as a code: the representative instant twice for an exact code, and the
+// instants strictly between the neighbouring representatives for an inexact
+// one, where the first and last inexact codes extend to the limits of the
+// nanosecond range. If an inexact code covers no instant, because its
+// neighbours are a nanosecond apart or it lies beyond a representative at a
+// limit of the range, the earliest instant is after the latest. It returns
+// zero times for codes the dictionary doesn't assign.
+func (d *TimeDict) DecodeRange(c Code) (time.Time, time.Time) {
+	iv, ok := d.dict.Bounds(c)
+	if !ok {
+		return time.Time{}, time.Time{}
+	}
+
+	if iv.Exact {
+		t := time.Unix(0, iv.Lo).UTC()
+		return t, t
+	}
+
+	lo, hi := int64(math.MinInt64), int64(math.MaxInt64)
+	if (iv.HasLo && iv.Lo == math.MaxInt64) || (iv.HasHi && iv.Hi == math.MinInt64) {
+		// Adjusting the bounds would overflow, and the code covers nothing.
+		return maxUnixNano.UTC(), minUnixNano.UTC()
+	}
+	if iv.HasLo {
+		lo = iv.Lo + 1
+	}
+	if iv.HasHi {
+		hi = iv.Hi - 1
+	}
+	return time.Unix(0, lo).UTC(), time.Unix(0, hi).UTC()
+}
+
+// Len returns the number of exact codes in the dictionary.
+func (d *TimeDict) Len() int {
+	return d.dict.NumExactCodes()
+}
+
+// Int64Dict returns the underlying dictionary over Unix times in
+// nanoseconds, e.g. to marshal it or to encode many instants at once.
+func (d *TimeDict) Int64Dict() *Dict[int64] {
+	return &d.dict
+}
+
+// unixNano returns t as Unix time in nanoseconds, clamped to the range of
+// int64, over which time.Time.UnixNano is undefined.
+func unixNano(t time.Time) int64 {
+	switch {
+	case t.Before(minUnixNano):
+		return math.MinInt64
+	case t.After(maxUnixNano):
+		return math.MaxInt64
+	default:
+		return t.UnixNano()
+	}
+}
+
+var (
+	minUnixNano = time.Unix(0, math.MinInt64)
+	maxUnixNano = time.Unix(0, math.MaxInt64)
+)
@@ -0,0 +1,65 @@
+package colsketch
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestTimeDict(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sample := make([]time.Time, 100000)
+	for i := range sample {
+		sample[i] = epoch.Add(time.Duration(rng.Int63n(int64(365 * 24 * time.Hour))))
+	}
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewTimeDict(mode, sample)
+		if got := dict.Len(); got != dict.Int64Dict().Len() || got > mode.NumExactCodes() {
+			t.Errorf("mode %s: Len() = %d", mode, got)
+		}
+
+		for _, ts := range append(sample[:1000], epoch, epoch.Add(-time.Hour), epoch.AddDate(2, 0, 0)) {
+			c := dict.Encode(ts)
+			lo, hi := dict.DecodeRange(c)
+			if ts.Before(lo) || ts.After(hi) {
+				t.Fatalf("mode %s: %v encoded as %d, whose range is [%v, %v]", mode, ts, c, lo, hi)
+			}
+			if c.IsExact() && !(lo.Equal(ts) && hi.Equal(ts)) {
+				t.Fatalf("mode %s: exact code %d of %v decodes to [%v, %v]", mode, c, ts, lo, hi)
+			}
+		}
+
+		// Time zones don't matter, only instants do.
+		ts := sample[0]
+		if dict.Encode(ts) != dict.Encode(ts.In(time.FixedZone("UTC+5", 5*60*60))) {
+			t.Errorf("mode %s: the same instant in another zone encodes differently", mode)
+		}
+	}
+
+	// Instants beyond the nanosecond range are clamped to it.
+	dict := NewTimeDict(Byte, []time.Time{epoch})
+	if lo, _ := dict.DecodeRange(1); !lo.Equal(minUnixNano) {
+		t.Errorf("DecodeRange(1) starts at %v, want %v", lo, minUnixNano)
+	}
+	if got := dict.Encode(time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)); got != 3 {
+		t.Errorf("Encode() of the year 3000 = %d, want 3", got)
+	}
+	if got := dict.Encode(time.Time{}); got != 1 {
+		t.Errorf("Encode() of the zero time = %d, want 1", got)
+	}
+	if lo, hi := dict.DecodeRange(4); !lo.IsZero() || !hi.IsZero() {
+		t.Errorf("DecodeRange() of an unassigned code = %v, %v, want zero times", lo, hi)
+	}
+
+	// Inexact codes beyond representatives at the limits of the range cover
+	// nothing.
+	dict = NewTimeDict(Byte, []time.Time{minUnixNano, maxUnixNano})
+	for _, c := range []Code{1, 5} {
+		if lo, hi := dict.DecodeRange(c); !lo.After(hi) {
+			t.Errorf("DecodeRange(%d) = %v, %v, want an empty range", c, lo, hi)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package colsketch
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// Validate checks the internal consistency of the dictionary: that its mode
+// is known, that it doesn't hold more representatives than the mode has exact
+// codes, that representatives are strictly increasing, that every exact
+// code round-trips through Value and Encode, and that there is a sample
+// count for every code, if any. It is meant for dictionaries
+// that didn't come out of NewDict, e.g. ones read back from storage.
+func (d *Dict[T]) Validate() error {
+	if !d.mode.IsValid() {
+		return fmt.Errorf("colsketch: invalid mode %d", d.mode)
+	}
+
+	if n, limit := len(d.codes), d.mode.NumExactCodes(); n > limit {
+		return fmt.Errorf("colsketch: %d representatives exceed the %d exact codes of the mode", n, limit)
+	}
+
+	for i := 1; i < len(d.codes); i++ {
+		if cmp.Compare(d.codes[i-1], d.codes[i]) >= 0 {
+			return fmt.Errorf("colsketch: representatives not strictly increasing at position %d", i)
+		}
+	}
+
+	for i := range d.codes {
+		c := Code(2 * (i + 1))
+		v, ok := d.Value(c)
+		if !ok || cmp.Compare(v, d.codes[i]) != 0 {
+			return fmt.Errorf("colsketch: exact code 0x%04x doesn't map back to its representative", uint16(c))
+		}
+		if got := d.Encode(v); got != c {
+			return fmt.Errorf("colsketch: representative at position %d encodes as 0x%04x, want 0x%04x", i, uint16(got), uint16(c))
+		}
+	}
+
+	if d.counts != nil && len(d.counts) != 2*len(d.codes)+1 {
+		return fmt.Errorf("colsketch: %d sample counts for %d codes", len(d.counts), 2*len(d.codes)+1)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package colsketch
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		dict  Dict[int]
+		valid bool
+	}{
+		{"zero value", Dict[int]{}, true},
+		{"built", NewDict(Byte, []int{3, 1, 2, 2}), true},
+		{"invalid mode", Dict[int]{mode: Mode(7), codes: []int{1}}, false},
+		{"unsorted", Dict[int]{mode: Byte, codes: []int{1, 3, 2}}, false},
+		{"duplicate", Dict[int]{mode: Byte, codes: []int{1, 2, 2}}, false},
+		{"too long", Dict[int]{mode: Byte, codes: make([]int, 128)}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.dict.Validate(); (err == nil) != tc.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func FuzzDict(f *testing.F) {
+	f.Add(false, []byte{})
+	f.Add(false, []byte{0, 1, 0, 1, 0, 2})
+	f.Add(true, []byte{0xff, 0xff, 0, 0, 0x80, 0x00})
+
+	big := make([]byte, 4096)
+	for i := range big {
+		big[i] = byte(i * 7)
+	}
+	f.Add(false, big)
+
+	f.Fuzz(func(t *testing.T, word bool, data []byte) {
+		mode := Byte
+		if word {
+			mode = Word
+		}
+
+		// Interpret the input as int16s so that samples are big enough to
+		// exhaust Byte mode's code space and have plenty of duplicates.
+		sample := make([]int16, len(data)/2)
+		for i := range sample {
+			sample[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+		}
+
+		dict := NewDict(mode, sample)
+		if err := dict.Validate(); err != nil {
+			t.Fatalf("Validate() = %v", err)
+		}
+
+		for i := 1; i < len(sample); i++ {
+			a, b := sample[i-1], sample[i]
+			if a > b {
+				a, b = b, a
+			}
+
+			ca, cb := dict.Encode(a), dict.Encode(b)
+			if ca > cb {
+				t.Fatalf("%d < %d but Encode(%d) = %d > Encode(%d) = %d", a, b, a, ca, b, cb)
+			}
+			if a == b && ca != cb {
+				t.Fatalf("Encode(%d) not deterministic: %d, %d", a, ca, cb)
+			}
+		}
+	})
+}
@@ -0,0 +1,329 @@
+package colsketch
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ErrReadOnly is returned by the methods of a SketchView that would modify
+// it.
+var ErrReadOnly = errors.New("colsketch: read-only sketch view")
+
+// SketchView is a read-only Sketch whose codes and tombstones are read in
+// place from its serialized format, e.g. from a memory-mapped file, rather
+// than copied into memory it owns; see OpenSketch. It has the read methods
+// of a Sketch, and the same concurrency guarantees, while its methods that
+// would modify it return ErrReadOnly.
+type SketchView[T cmp.Ordered] struct {
+	s    Sketch[T]
+	lazy *lazyChecks // The verification state, if verifying lazily.
+}
+
+// OpenSketch returns a view of the sketch serialized with WriteTo at the
+// start of data, encoded with dict, or with a dictionary of the same
+// fingerprint. Bytes of data after the sketch are ignored. The view reads
+// its codes and tombstones from data, which must not be modified while the
+// view is in use, so that opening it allocates only its block headers, and
+// takes a pass over its codes to compute and validate them.
+//
+// The serialized format places the codes and the tombstones at offsets from
+// its start that are multiples of 64 bytes, so that they are as aligned as
+// data is. Views read them as 16- and 64-bit words, and so need data to
+// start on an 8-byte boundary, like memory-mapped files, which start on a
+// page, and Go allocations of 8 or more bytes do, and a little-endian host.
+// Starting on a 64-byte boundary aligns blocks of Byte and Word mode codes
+// on cache lines, like in a Sketch.
+//
+// The block checksums of a sketch serialized WithChecksums are read in
+// place too, to be verified by Verify, or by scans WithLazyVerification.
+func OpenSketch[T cmp.Ordered](data []byte, dict *Dict[T], opts ...ViewOption) (*SketchView[T], error) {
+	var cfg viewConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if len(data) < sketchHeaderSize {
+		return nil, errTruncated
+	}
+	hdr, err := parseSketchHeader(data, dict)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)-sketchHeaderSize) < hdr.codesSize()+hdr.deletedSize()+hdr.checksumsSize() {
+		return nil, errTruncated
+	}
+	if !littleEndian {
+		return nil, errors.New("colsketch: sketch views need a little-endian host")
+	}
+	if uintptr(unsafe.Pointer(unsafe.SliceData(data)))%8 != 0 {
+		return nil, errors.New("colsketch: sketch view data isn't 8-byte aligned")
+	}
+
+	codes := data[sketchHeaderSize : sketchHeaderSize+hdr.codesSize()]
+	words := bytesToWords(codes)
+	if !zeroBeyond(words, uint64(hdr.rows)*uint64(hdr.width)) {
+		return nil, errors.New("colsketch: invalid sketch code padding")
+	}
+
+	v := &SketchView[T]{s: Sketch[T]{dict: dict, blockSize: hdr.blockSize, nulls: hdr.nulls}}
+	switch {
+	case hdr.packed:
+		v.s.store = &packedStore{
+			words: words[:(uint(hdr.rows)*hdr.width+63)/64],
+			n:     hdr.rows,
+			bits:  hdr.width,
+			mask:  1<<hdr.width - 1,
+		}
+	case dict.mode == Byte:
+		cs := codeSlice[uint8](codes[:hdr.rows:hdr.rows])
+		v.s.store = &cs
+	default:
+		cs := codeSlice[uint16](unsafe.Slice((*uint16)(unsafe.Pointer(unsafe.SliceData(codes))), hdr.rows))
+		v.s.store = &cs
+	}
+
+	v.s.bounds = make([]blockBounds, (hdr.rows+hdr.blockSize-1)/hdr.blockSize)
+	for i := range v.s.bounds {
+		v.s.rescanBlock(i)
+		if b := v.s.bounds[i]; (b.min == NullCode && !hdr.nulls) || b.max > hdr.maxCode {
+			return nil, fmt.Errorf("colsketch: invalid code in block %d of sketch", i)
+		}
+	}
+
+	if hdr.deleted {
+		off := sketchHeaderSize + hdr.codesSize()
+		deleted := bytesToWords(data[off : off+hdr.deletedSize()])
+		if !zeroBeyond(deleted, uint64(hdr.rows)) {
+			return nil, errors.New("colsketch: tombstones of rows beyond the sketch")
+		}
+
+		v.s.deleted = deleted[:(hdr.rows+63)/64]
+		v.s.blockDeleted = make([]int, len(v.s.bounds))
+		for w, x := range v.s.deleted {
+			n := bits.OnesCount64(x)
+			v.s.blockDeleted[64*w/hdr.blockSize] += n
+			v.s.numDeleted += n
+		}
+	}
+
+	if hdr.checksums {
+		off := sketchHeaderSize + hdr.codesSize() + hdr.deletedSize()
+		sums := bytesToWords(data[off : off+hdr.checksumsSize()])
+		if !zeroBeyond(sums, 64*uint64(len(v.s.bounds))) {
+			return nil, errors.New("colsketch: checksums of blocks beyond the sketch")
+		}
+		v.s.checksums = sums[:len(v.s.bounds)]
+		if cfg.lazy {
+			v.lazy = &lazyChecks{
+				done: make([]atomic.Bool, len(v.s.bounds)),
+				buf:  make([]byte, 2*v.s.blockSize),
+			}
+		}
+	}
+	return v, nil
+}
+
+// A ViewOption configures a SketchView opened by OpenSketch.
+type ViewOption func(*viewConfig)
+
+type viewConfig struct {
+	lazy bool
+}
+
+// WithLazyVerification verifies each block of a view of a sketch serialized
+// WithChecksums against its checksum the first time a scan may read its
+// codes, rather than all of them upfront with Verify, e.g. for large
+// memory-mapped files of which queries touch a few blocks. Scans verify
+// the blocks their predicate doesn't rule out before reading any codes,
+// and one that finds a corrupt block reads none, finding no rows, and
+// leaves the first mismatch found for Err to report. It has no effect on
+// views of sketches without checksums.
+func WithLazyVerification() ViewOption {
+	return func(c *viewConfig) { c.lazy = true }
+}
+
+// littleEndian is true iff the host is little-endian.
+var littleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// bytesToWords reinterprets b, which is 8-byte aligned, as 64-bit words.
+func bytesToWords(b []byte) []uint64 {
+	return unsafe.Slice((*uint64)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/8)
+}
+
+// Dict returns the dictionary the sketch was opened with.
+func (v *SketchView[T]) Dict() *Dict[T] { return v.s.Dict() }
+
+// Len returns the number of codes in the sketch; see Sketch.Len.
+func (v *SketchView[T]) Len() int { return v.s.Len() }
+
+// LiveLen returns the number of rows that weren't deleted; see
+// Sketch.LiveLen.
+func (v *SketchView[T]) LiveLen() int { return v.s.LiveLen() }
+
+// Get returns the code at position i. It panics if i is out of range.
+func (v *SketchView[T]) Get(i int) Code { return v.s.Get(i) }
+
+// IsDeleted returns true iff the row at position i was deleted; see
+// Sketch.IsDeleted.
+func (v *SketchView[T]) IsDeleted(i int) bool { return v.s.IsDeleted(i) }
+
+// BitsPerCode returns the number of bits the sketch stores each code in.
+func (v *SketchView[T]) BitsPerCode() int { return v.s.BitsPerCode() }
+
+// BlockSize returns the number of codes per block of the sketch.
+func (v *SketchView[T]) BlockSize() int { return v.s.BlockSize() }
+
+// NumBlocks returns the number of blocks in the sketch.
+func (v *SketchView[T]) NumBlocks() int { return v.s.NumBlocks() }
+
+// Block returns the header of the i-th block. It panics if i is out of
+// range.
+func (v *SketchView[T]) Block(i int) Block { return v.s.Block(i) }
+
+// HasChecksums returns true iff the sketch was serialized with block
+// checksums; see WithChecksums.
+func (v *SketchView[T]) HasChecksums() bool { return v.s.HasChecksums() }
+
+// Verify rehashes the codes of every block, and returns a *ChecksumError for
+// the first block that doesn't match its checksum, if any; see
+// Sketch.Verify.
+func (v *SketchView[T]) Verify() error { return v.s.Verify() }
+
+// Err returns a *ChecksumError for the first corrupt block found by the
+// scans of a view opened WithLazyVerification, which find no rows when
+// they would read one, if any.
+func (v *SketchView[T]) Err() error {
+	if v.lazy == nil {
+		return nil
+	}
+	v.lazy.mu.Lock()
+	defer v.lazy.mu.Unlock()
+	return v.lazy.err
+}
+
+// Scan calls visit with the position of every candidate row of the
+// predicate; see Sketch.Scan.
+func (v *SketchView[T]) Scan(p Predicate[T], visit func(pos int) bool) {
+	if v.verifyScan(p) {
+		v.s.Scan(p, visit)
+	}
+}
+
+// ScanCertain is like Scan, but classifies candidates; see
+// Sketch.ScanCertain.
+func (v *SketchView[T]) ScanCertain(p Predicate[T], visit func(pos int, c Certainty) bool) {
+	if v.verifyScan(p) {
+		v.s.ScanCertain(p, visit)
+	}
+}
+
+// ScanAll is like ScanCertain for the conjunction of predicates; see
+// Sketch.ScanAll.
+func (v *SketchView[T]) ScanAll(preds []Predicate[T], visit func(pos int, c Certainty) bool) {
+	if v.lazy != nil {
+		s := &v.s
+		fs := make([]codeFilter, len(preds))
+		for i, p := range preds {
+			fs[i] = p.candidates(s.dict)
+		}
+		f := conjoin(fs)
+		if f.empty() || !v.verifyBlocks(0, s.Len(), func(i int) bool {
+			return !f.rulesOut(s.bounds[i]) && !s.isDead(i) && !s.rulesOutAny(i, fs)
+		}) {
+			return
+		}
+	}
+	v.s.ScanAll(preds, visit)
+}
+
+// ScanBitmap sets the bits of the candidate rows of the predicate in a
+// bitmap; see Sketch.ScanBitmap.
+func (v *SketchView[T]) ScanBitmap(p Predicate[T], dst []uint64) []uint64 {
+	if !v.verifyScan(p) {
+		return v.s.resizeBitmap(dst[:0])
+	}
+	return v.s.ScanBitmap(p, dst)
+}
+
+// ScanSet calls visit with the position of every row whose code is in the
+// set; see Sketch.ScanSet.
+func (v *SketchView[T]) ScanSet(cs CodeSet, visit func(pos int) bool) {
+	if v.lazy != nil {
+		s := &v.s
+		if !v.verifyBlocks(0, s.Len(), func(i int) bool {
+			return !s.isDead(i) && !s.rulesOutSet(i, &cs)
+		}) {
+			return
+		}
+	}
+	v.s.ScanSet(cs, visit)
+}
+
+// ScanInto adds the candidate rows of the predicate to dst; see
+// Sketch.ScanInto.
+func (v *SketchView[T]) ScanInto(p Predicate[T], offset uint64, dst RangeAdder) {
+	if v.verifyScan(p) {
+		v.s.ScanInto(p, offset, dst)
+	}
+}
+
+// ScanSelection returns the candidate rows of the predicate within a batch
+// as a selection vector; see Sketch.ScanSelection.
+func (v *SketchView[T]) ScanSelection(p Predicate[T], batchStart, batchLen int, sel []uint32) []uint32 {
+	if v.lazy != nil && !v.verifyReads(p.candidates(v.s.dict), batchStart, batchStart+batchLen) {
+		return sel[:0]
+	}
+	return v.s.ScanSelection(p, batchStart, batchLen, sel)
+}
+
+// Count returns the numbers of candidate and definite rows of the
+// predicate; see Sketch.Count.
+func (v *SketchView[T]) Count(p Predicate[T]) (candidates, definite int) {
+	if !v.verifyScan(p) {
+		return 0, 0
+	}
+	return v.s.Count(p)
+}
+
+// CountRange returns the number of codes of live rows within `[lo, hi]`;
+// see Sketch.CountRange.
+func (v *SketchView[T]) CountRange(lo, hi Code) int {
+	if v.lazy != nil && lo <= hi && !v.verifyReads(codeFilter{lo: lo, hi: hi}, 0, v.s.Len()) {
+		return 0
+	}
+	return v.s.CountRange(lo, hi)
+}
+
+// WriteTo writes the sketch to w in its serialized format; see
+// Sketch.WriteTo.
+func (v *SketchView[T]) WriteTo(w io.Writer) (int64, error) { return v.s.WriteTo(w) }
+
+// Append returns ErrReadOnly.
+func (v *SketchView[T]) Append(value T) error { return ErrReadOnly }
+
+// AppendValues returns ErrReadOnly.
+func (v *SketchView[T]) AppendValues(values []T) error { return ErrReadOnly }
+
+// AppendCodes returns ErrReadOnly.
+func (v *SketchView[T]) AppendCodes(codes []Code) error { return ErrReadOnly }
+
+// Set returns ErrReadOnly.
+func (v *SketchView[T]) Set(i int, value T) error { return ErrReadOnly }
+
+// Delete returns ErrReadOnly.
+func (v *SketchView[T]) Delete(i int) error { return ErrReadOnly }
+
+// Nullable returns true iff the sketch may hold NULL rows; see
+// Sketch.Nullable.
+func (v *SketchView[T]) Nullable() bool { return v.s.Nullable() }
+
+// AppendNull returns ErrReadOnly.
+func (v *SketchView[T]) AppendNull() error { return ErrReadOnly }
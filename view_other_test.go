@@ -0,0 +1,20 @@
+//go:build !linux
+
+package colsketch
+
+import (
+	"os"
+	"testing"
+)
+
+// mapFile reads the file at path into memory: the tests only map files on
+// Linux.
+func mapFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
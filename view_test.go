@@ -0,0 +1,185 @@
+package colsketch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpenSketch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, mode := range []Mode{Byte, Word} {
+		dict := NewDict(mode, randomInt64s(rng, 5000, 10000))
+
+		for _, opts := range [][]SketchOption{
+			nil,
+			{WithBlockSize(64)},
+			{WithBlockSize(128), WithBitPacking()},
+			{WithBlockSize(64), WithChecksums()},
+		} {
+			for _, n := range []int{0, 1, 1000, 5000} {
+				s := NewSketch(&dict, opts...)
+				name := fmt.Sprintf("mode %s, block size %d, %d bits, %d rows", mode, s.BlockSize(), s.BitsPerCode(), n)
+				s.AppendValues(randomInt64s(rng, n, 12000))
+				for i := 0; i < n/8; i++ {
+					s.Delete(rng.Intn(n))
+				}
+
+				var buf bytes.Buffer
+				if _, err := s.WriteTo(&buf); err != nil {
+					t.Fatal(err)
+				}
+				path := filepath.Join(t.TempDir(), "sketch")
+				if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+
+				for _, src := range []struct {
+					name string
+					data []byte
+				}{
+					{"file", mapFile(t, path)},
+					{"slice", buf.Bytes()},
+				} {
+					v, err := OpenSketch(src.data, &dict)
+					if err != nil {
+						t.Fatalf("%s, %s: OpenSketch() = %v", name, src.name, err)
+					}
+					testSketchView(t, name+", "+src.name, v, s, rng)
+				}
+			}
+		}
+	}
+}
+
+// testSketchView checks that a view reads like the sketch it was written
+// from, without allocating.
+func testSketchView(t *testing.T, name string, v *SketchView[int64], s *Sketch[int64], rng *rand.Rand) {
+	t.Helper()
+
+	if v.Len() != s.Len() || v.LiveLen() != s.LiveLen() || v.BitsPerCode() != s.BitsPerCode() {
+		t.Fatalf("%s: Len(), LiveLen(), BitsPerCode() = %d, %d, %d, want %d, %d, %d", name,
+			v.Len(), v.LiveLen(), v.BitsPerCode(), s.Len(), s.LiveLen(), s.BitsPerCode())
+	}
+	for i := 0; i < s.Len(); i++ {
+		if v.Get(i) != s.Get(i) || v.IsDeleted(i) != s.IsDeleted(i) {
+			t.Fatalf("%s: row %d is %d, deleted %v, want %d, deleted %v", name, i,
+				v.Get(i), v.IsDeleted(i), s.Get(i), s.IsDeleted(i))
+		}
+	}
+	if v.NumBlocks() != s.NumBlocks() || v.BlockSize() != s.BlockSize() {
+		t.Fatalf("%s: %d blocks of %d, want %d of %d", name, v.NumBlocks(), v.BlockSize(), s.NumBlocks(), s.BlockSize())
+	}
+	for i := 0; i < s.NumBlocks(); i++ {
+		if got, want := v.Block(i), s.Block(i); got != want {
+			t.Fatalf("%s: Block(%d) = %+v, want %+v", name, i, got, want)
+		}
+	}
+
+	bitmap := make([]uint64, (s.Len()+63)/64)
+	for i := 0; i < 20; i++ {
+		p := randomPredicate(rng, func() int64 { return rng.Int63n(12002) - 1 })
+
+		var got, want []int
+		v.Scan(p, func(pos int) bool { got = append(got, pos); return true })
+		s.Scan(p, func(pos int) bool { want = append(want, pos); return true })
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s, %+v: Scan() visited %v, want %v", name, p, got, want)
+		}
+		if got, want := v.ScanBitmap(p, nil), s.ScanBitmap(p, nil); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s, %+v: ScanBitmap() = %#x, want %#x", name, p, got, want)
+		}
+
+		cs := s.Dict().EncodeSet([]int64{rng.Int63n(12000), rng.Int63n(12000)})
+		got, want = got[:0], want[:0]
+		v.ScanSet(cs, func(pos int) bool { got = append(got, pos); return true })
+		s.ScanSet(cs, func(pos int) bool { want = append(want, pos); return true })
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: ScanSet() visited %v, want %v", name, got, want)
+		}
+
+		c, d := v.Count(p)
+		if wc, wd := s.Count(p); c != wc || d != wd {
+			t.Fatalf("%s, %+v: Count() = %d, %d, want %d, %d", name, p, c, d, wc, wd)
+		}
+		if allocs := testing.AllocsPerRun(5, func() {
+			v.Count(p)
+			v.ScanBitmap(p, bitmap)
+		}); allocs != 0 {
+			t.Fatalf("%s, %+v: Count() and ScanBitmap() allocated %v times", name, p, allocs)
+		}
+	}
+}
+
+func TestOpenSketchInvalid(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dict := NewDict(Word, randomInt64s(rng, 1000, 1000))
+	s := NewSketch(&dict, WithBlockSize(64))
+	s.AppendValues(randomInt64s(rng, 200, 1200))
+	s.Delete(3)
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	for n := 0; n < len(data); n++ {
+		if _, err := OpenSketch(data[:n], &dict); !errors.Is(err, errTruncated) {
+			t.Fatalf("OpenSketch() of %d of %d bytes = %v, want %v", n, len(data), err, errTruncated)
+		}
+	}
+
+	// Trailing bytes are ignored, but misaligned data is rejected.
+	padded := make([]byte, len(data)+8)
+	copy(padded, data)
+	if _, err := OpenSketch(padded, &dict); err != nil {
+		t.Errorf("OpenSketch() with trailing bytes = %v", err)
+	}
+	copy(padded[1:], data)
+	if _, err := OpenSketch(padded[1:], &dict); err == nil {
+		t.Errorf("OpenSketch() of misaligned data succeeded")
+	}
+
+	for _, tc := range []struct {
+		name string
+		edit func(b []byte)
+	}{
+		{"version", func(b []byte) { b[4] = 2 }},
+		{"fingerprint", func(b []byte) { b[24]++ }},
+		{"code", func(b []byte) { b[sketchHeaderSize+20], b[sketchHeaderSize+21] = 0xff, 0xff }},
+		{"code padding", func(b []byte) { b[sketchHeaderSize+400] = 1 }},
+		{"tombstones", func(b []byte) { b[len(b)-1] = 0x80 }},
+	} {
+		b := append([]byte(nil), data...)
+		tc.edit(b)
+		if _, err := OpenSketch(b, &dict); err == nil {
+			t.Errorf("%s: OpenSketch() of an invalid sketch succeeded", tc.name)
+		}
+	}
+
+	v, err := OpenSketch(data, &dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, err := range map[string]error{
+		"Append":       v.Append(1),
+		"AppendValues": v.AppendValues([]int64{1}),
+		"AppendCodes":  v.AppendCodes([]Code{1}),
+		"Set":          v.Set(0, 1),
+		"Delete":       v.Delete(0),
+	} {
+		if err != ErrReadOnly {
+			t.Errorf("%s() = %v, want %v", name, err, ErrReadOnly)
+		}
+	}
+	if v.Len() != s.Len() || v.LiveLen() != s.LiveLen() {
+		t.Errorf("view changed by mutations")
+	}
+}
@@ -0,0 +1,19 @@
+package colsketch
+
+import "testing"
+
+func TestXXHash64(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	} {
+		if got := xxhash64([]byte(tc.in), 0); got != tc.want {
+			t.Errorf("xxhash64(%q) = %#x, want %#x", tc.in, got, tc.want)
+		}
+	}
+}